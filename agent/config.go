@@ -32,6 +32,15 @@ type Config struct {
 	InlineSkills    []string `json:"inline_skills,omitempty"`
 	InlineTraits    []string `json:"inline_traits,omitempty"`
 	InlineBehaviors []string `json:"inline_behaviors,omitempty"`
+
+	// Budget fields. Zero means no limit.
+	MaxTotalTokens   int `json:"max_total_tokens,omitempty"`   // tokens a single run may use
+	DailyTokenBudget int `json:"daily_token_budget,omitempty"` // tokens all runs may use over the last 24 hours
+
+	// OutputSchema is a JSON Schema the final answer of a run must be a JSON
+	// value of. The parsed answer is stored in the run's metadata. Nil means
+	// free text.
+	OutputSchema map[string]any `json:"output_schema,omitempty"`
 }
 
 // HasPersona returns true if this agent uses the persona system.
@@ -70,7 +70,7 @@ func (a *API) registerAgentRoutes(router forge.Router) error {
 
 	if err := g.POST("/agents/:name/run", a.runAgent,
 		forge.WithSummary("Run agent"),
-		forge.WithDescription("Executes an agent with the given input and returns the result."),
+		forge.WithDescription("Executes an agent with the given input and returns the result. With async=true the run is submitted to the background workers and 202 is returned with its ID; follow it with GET /runs/:id/wait."),
 		forge.WithOperationID("runAgent"),
 		forge.WithRequestSchema(RunAgentRequest{}),
 		forge.WithErrorResponses(),
@@ -118,29 +118,32 @@ func (a *API) createAgent(ctx forge.Context, req *CreateAgentRequest) (*agent.Co
 	}
 
 	cfg := &agent.Config{
-		Entity:          cortex.NewEntity(),
-		ID:              id.NewAgentID(),
-		Name:            req.Name,
-		Description:     req.Description,
-		AppID:           cortex.AppFromContext(ctx.Context()),
-		SystemPrompt:    req.SystemPrompt,
-		Model:           req.Model,
-		Tools:           req.Tools,
-		MaxSteps:        req.MaxSteps,
-		MaxTokens:       req.MaxTokens,
-		Temperature:     req.Temperature,
-		ReasoningLoop:   req.ReasoningLoop,
-		PersonaRef:      req.PersonaRef,
-		InlineSkills:    req.InlineSkills,
-		InlineTraits:    req.InlineTraits,
-		InlineBehaviors: req.InlineBehaviors,
-		Guardrails:      req.Guardrails,
-		Metadata:        req.Metadata,
-		Enabled:         true,
+		Entity:           cortex.NewEntity(),
+		ID:               id.NewAgentID(),
+		Name:             req.Name,
+		Description:      req.Description,
+		AppID:            cortex.AppFromContext(ctx.Context()),
+		SystemPrompt:     req.SystemPrompt,
+		Model:            req.Model,
+		Tools:            req.Tools,
+		MaxSteps:         req.MaxSteps,
+		MaxTokens:        req.MaxTokens,
+		MaxTotalTokens:   req.MaxTotalTokens,
+		DailyTokenBudget: req.DailyTokenBudget,
+		OutputSchema:     req.OutputSchema,
+		Temperature:      req.Temperature,
+		ReasoningLoop:    req.ReasoningLoop,
+		PersonaRef:       req.PersonaRef,
+		InlineSkills:     req.InlineSkills,
+		InlineTraits:     req.InlineTraits,
+		InlineBehaviors:  req.InlineBehaviors,
+		Guardrails:       req.Guardrails,
+		Metadata:         req.Metadata,
+		Enabled:          true,
 	}
 
 	if err := a.eng.CreateAgent(ctx.Context(), cfg); err != nil {
-		return nil, fmt.Errorf("create agent: %w", err)
+		return nil, mapStoreError(fmt.Errorf("create agent: %w", err))
 	}
 
 	return cfg, ctx.JSON(http.StatusCreated, cfg)
@@ -191,6 +194,12 @@ func (a *API) updateAgent(ctx forge.Context, req *UpdateAgentRequest) (*agent.Co
 	if req.MaxTokens > 0 {
 		cfg.MaxTokens = req.MaxTokens
 	}
+	if req.MaxTotalTokens > 0 {
+		cfg.MaxTotalTokens = req.MaxTotalTokens
+	}
+	if req.DailyTokenBudget > 0 {
+		cfg.DailyTokenBudget = req.DailyTokenBudget
+	}
 	if req.Temperature > 0 {
 		cfg.Temperature = req.Temperature
 	}
@@ -212,12 +221,15 @@ func (a *API) updateAgent(ctx forge.Context, req *UpdateAgentRequest) (*agent.Co
 	if req.Guardrails != nil {
 		cfg.Guardrails = req.Guardrails
 	}
+	if req.OutputSchema != nil {
+		cfg.OutputSchema = req.OutputSchema
+	}
 	if req.Metadata != nil {
 		cfg.Metadata = req.Metadata
 	}
 
 	if err := a.eng.UpdateAgent(ctx.Context(), cfg); err != nil {
-		return nil, fmt.Errorf("update agent: %w", err)
+		return nil, mapStoreError(fmt.Errorf("update agent: %w", err))
 	}
 	return cfg, ctx.JSON(http.StatusOK, cfg)
 }
@@ -238,8 +250,22 @@ func (a *API) runAgent(ctx forge.Context, req *RunAgentRequest) (*RunAgentRespon
 		return nil, forge.BadRequest("input is required")
 	}
 
+	overrides, err := runOverrides(req.Overrides, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	appID := cortex.AppFromContext(ctx.Context())
-	r, err := a.eng.RunAgent(ctx.Context(), appID, req.Name, req.Input, mapOverrides(req.Overrides))
+	if req.Async {
+		r, err := a.eng.SubmitRun(ctx.Context(), appID, req.Name, req.Input, overrides)
+		if err != nil {
+			return nil, mapStoreError(err)
+		}
+		resp := &RunAgentResponse{RunID: r.ID.String(), State: string(r.State)}
+		return resp, ctx.JSON(http.StatusAccepted, resp)
+	}
+
+	r, err := a.eng.RunAgent(ctx.Context(), appID, req.Name, req.Input, overrides)
 	if err != nil {
 		return nil, mapStoreError(err)
 	}
@@ -250,12 +276,13 @@ func (a *API) runAgent(ctx forge.Context, req *RunAgentRequest) (*RunAgentRespon
 	}
 
 	resp := &RunAgentResponse{
-		RunID:      r.ID.String(),
-		Output:     r.Output,
-		State:      string(r.State),
-		StepCount:  r.StepCount,
-		TokensUsed: r.TokensUsed,
-		DurationMs: durationMs,
+		RunID:            r.ID.String(),
+		Output:           r.Output,
+		StructuredOutput: engine.StructuredOutput(r),
+		State:            string(r.State),
+		StepCount:        r.StepCount,
+		TokensUsed:       r.TokensUsed,
+		DurationMs:       durationMs,
 	}
 	return resp, ctx.JSON(http.StatusOK, resp)
 }
@@ -264,6 +291,10 @@ func (a *API) streamAgent(ctx forge.Context, req *StreamAgentRequest) (*struct{}
 	if req.Input == "" {
 		return nil, forge.BadRequest("input is required")
 	}
+	overrides, err := runOverrides(req.Overrides, req.SessionID)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx.SetHeader("Content-Type", "text/event-stream")
 	ctx.SetHeader("Cache-Control", "no-cache")
@@ -273,7 +304,7 @@ func (a *API) streamAgent(ctx forge.Context, req *StreamAgentRequest) (*struct{}
 	appID := cortex.AppFromContext(ctx.Context())
 	events := make(chan engine.StreamEvent, 64)
 
-	if err := a.eng.StreamAgent(ctx.Context(), appID, req.Name, req.Input, mapOverrides(req.Overrides), events); err != nil {
+	if err := a.eng.StreamAgent(ctx.Context(), appID, req.Name, req.Input, overrides, events); err != nil {
 		return nil, mapStoreError(err)
 	}
 
@@ -294,10 +325,18 @@ func (a *API) previewPrompt(ctx forge.Context, _ *PreviewPromptRequest) (*Previe
 	}
 
 	// Use the engine's prompt builder for a consistent preview.
-	prompt := a.eng.BuildSystemPrompt(ctx.Context(), ag, nil)
-
+	prompt, rp := a.eng.ResolveSystemPrompt(ctx.Context(), ag, nil)
 	resp := &PreviewPromptResponse{
-		Prompt: prompt,
+		Prompt:   prompt,
+		Warnings: rp.Warnings,
+	}
+	for _, rs := range rp.Skills {
+		resp.Skills = append(resp.Skills, PreviewSkill{
+			Name:        rs.Skill.Name,
+			Proficiency: string(rs.Proficiency),
+			Source:      rs.Source,
+			RequiredBy:  rs.RequiredBy,
+		})
 	}
 	return resp, ctx.JSON(http.StatusOK, resp)
 }
@@ -311,6 +350,21 @@ func (a *API) cloneAgent(ctx forge.Context, req *CloneAgentRequest) (*agent.Conf
 	return clone, ctx.JSON(http.StatusCreated, clone)
 }
 
+// runOverrides converts the overrides and session ID of a run request to
+// engine-layer overrides.
+func runOverrides(o *AgentOverrides, sessionID string) (*engine.RunOverrides, error) {
+	overrides := mapOverrides(o)
+	sessID, err := parseSessionID(sessionID)
+	if err != nil || sessID.IsNil() {
+		return overrides, err
+	}
+	if overrides == nil {
+		overrides = &engine.RunOverrides{}
+	}
+	overrides.SessionID = sessID
+	return overrides, nil
+}
+
 // mapOverrides converts API-layer overrides to engine-layer overrides.
 func mapOverrides(o *AgentOverrides) *engine.RunOverrides {
 	if o == nil {
@@ -320,7 +374,10 @@ func mapOverrides(o *AgentOverrides) *engine.RunOverrides {
 		Model:           o.Model,
 		Temperature:     o.Temperature,
 		MaxSteps:        o.MaxSteps,
+		MaxStepsPolicy:  cortex.MaxStepsPolicy(o.MaxStepsPolicy),
+		ToolConcurrency: o.ToolConcurrency,
 		MaxTokens:       o.MaxTokens,
+		MaxTotalTokens:  o.MaxTotalTokens,
 		ReasoningLoop:   o.ReasoningLoop,
 		SystemPrompt:    o.SystemPrompt,
 		PersonaRef:      o.PersonaRef,
@@ -328,5 +385,6 @@ func mapOverrides(o *AgentOverrides) *engine.RunOverrides {
 		InlineTraits:    o.InlineTraits,
 		InlineBehaviors: o.InlineBehaviors,
 		Tools:           o.Tools,
+		OutputSchema:    o.OutputSchema,
 	}
 }
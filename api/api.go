@@ -59,11 +59,17 @@ func (a *API) RegisterRoutes(router forge.Router) error {
 	if err := a.registerMemoryRoutes(router); err != nil {
 		return err
 	}
+	if err := a.registerSessionRoutes(router); err != nil {
+		return err
+	}
 	if err := a.registerToolRoutes(router); err != nil {
 		return err
 	}
 	if err := a.registerOrchestrationRoutes(router); err != nil {
 		return err
 	}
+	if err := a.registerBudgetRoutes(router); err != nil {
+		return err
+	}
 	return a.registerConfigRoutes(router)
 }
@@ -103,7 +103,7 @@ func (a *API) createBehavior(ctx forge.Context, req *CreateBehaviorRequest) (*be
 	}
 
 	if err := a.eng.CreateBehavior(ctx.Context(), b); err != nil {
-		return nil, fmt.Errorf("create behavior: %w", err)
+		return nil, mapStoreError(fmt.Errorf("create behavior: %w", err))
 	}
 
 	return b, ctx.JSON(http.StatusCreated, b)
@@ -174,7 +174,7 @@ func (a *API) updateBehavior(ctx forge.Context, req *UpdateBehaviorRequest) (*be
 	}
 
 	if err := a.eng.UpdateBehavior(ctx.Context(), b); err != nil {
-		return nil, fmt.Errorf("update behavior: %w", err)
+		return nil, mapStoreError(fmt.Errorf("update behavior: %w", err))
 	}
 	return b, ctx.JSON(http.StatusOK, b)
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xraph/forge"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/budget"
+)
+
+func (a *API) registerBudgetRoutes(router forge.Router) error {
+	g := router.Group("/v1", forge.WithGroupTags("budgets"))
+
+	if err := g.GET("/agents/:name/budget", a.getAgentBudget,
+		forge.WithSummary("Get agent budget"),
+		forge.WithDescription("Returns the tokens the agent's runs used over the last 24 hours and what remains of its daily token budget."),
+		forge.WithOperationID("getAgentBudget"),
+		forge.WithRequestSchema(GetAgentBudgetRequest{}),
+		forge.WithResponseSchema(http.StatusOK, "Budget status", &budget.Status{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register budget routes: %w", err)
+	}
+
+	if err := g.GET("/budget", a.getTenantBudget,
+		forge.WithSummary("Get tenant budget"),
+		forge.WithDescription("Returns the tokens the request tenant's runs used this calendar month and what remains of its monthly token budget."),
+		forge.WithOperationID("getTenantBudget"),
+		forge.WithResponseSchema(http.StatusOK, "Budget status", &budget.Status{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register budget routes: %w", err)
+	}
+
+	return nil
+}
+
+func (a *API) getAgentBudget(ctx forge.Context, _ *GetAgentBudgetRequest) (*budget.Status, error) {
+	ag, err := a.eng.GetAgentByName(ctx.Context(), cortex.AppFromContext(ctx.Context()), ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	st, err := a.eng.AgentBudget(ctx.Context(), ag.ID)
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+	return st, ctx.JSON(http.StatusOK, st)
+}
+
+func (a *API) getTenantBudget(ctx forge.Context, _ *struct{}) (*budget.Status, error) {
+	tenantID := cortex.TenantFromContext(ctx.Context())
+	if tenantID == "" {
+		return nil, forge.BadRequest("tenant is required")
+	}
+
+	st, err := a.eng.TenantBudget(ctx.Context(), tenantID)
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+	return st, ctx.JSON(http.StatusOK, st)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/xraph/forge"
 
+	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/id"
 )
@@ -26,10 +27,10 @@ func (a *API) registerCheckpointRoutes(router forge.Router) error {
 
 	if err := g.POST("/checkpoints/:id/resolve", a.resolveCheckpoint,
 		forge.WithSummary("Resolve checkpoint"),
-		forge.WithDescription("Approves or rejects a pending checkpoint."),
+		forge.WithDescription("Approves or rejects a pending checkpoint and returns 202 while the paused run resumes in the background; follow it with GET /runs/:id/wait."),
 		forge.WithOperationID("resolveCheckpoint"),
 		forge.WithRequestSchema(ResolveCheckpointRequest{}),
-		forge.WithNoContentResponse(),
+		forge.WithResponseSchema(http.StatusAccepted, "Run resuming", nil),
 		forge.WithErrorResponses(),
 	); err != nil {
 		return fmt.Errorf("register checkpoint routes: %w", err)
@@ -40,8 +41,9 @@ func (a *API) registerCheckpointRoutes(router forge.Router) error {
 
 func (a *API) listCheckpoints(ctx forge.Context, req *ListCheckpointsRequest) (*ListCheckpointsResponse, error) {
 	cps, err := a.eng.ListPendingCheckpoints(ctx.Context(), &checkpoint.ListFilter{
-		Limit:  defaultLimit(req.Limit),
-		Offset: req.Offset,
+		TenantID: cortex.TenantFromContext(ctx.Context()),
+		Limit:    defaultLimit(req.Limit),
+		Offset:   req.Offset,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("list checkpoints: %w", err)
@@ -59,11 +61,12 @@ func (a *API) resolveCheckpoint(ctx forge.Context, req *ResolveCheckpointRequest
 	decision := checkpoint.Decision{
 		Approved:  req.Decision == "approved",
 		DecidedBy: req.DecidedBy,
+		Reason:    req.Reason,
 	}
 
 	if err := a.eng.ResolveCheckpoint(ctx.Context(), cpID, decision); err != nil {
 		return nil, mapStoreError(err)
 	}
 
-	return nil, ctx.NoContent(http.StatusNoContent)
+	return nil, ctx.NoContent(http.StatusAccepted)
 }
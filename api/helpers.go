@@ -19,6 +19,15 @@ func mapStoreError(err error) error {
 	if isConflict(err) {
 		return forge.NewHTTPError(409, err.Error())
 	}
+	if isInvalid(err) {
+		return forge.BadRequest(err.Error())
+	}
+	if isUnavailable(err) {
+		return forge.NewHTTPError(503, err.Error())
+	}
+	if isExhausted(err) {
+		return forge.NewHTTPError(429, err.Error())
+	}
 	return err
 }
 
@@ -31,11 +40,33 @@ func isNotFound(err error) bool {
 		errors.Is(err, cortex.ErrRunNotFound) ||
 		errors.Is(err, cortex.ErrCheckpointNotFound) ||
 		errors.Is(err, cortex.ErrOrchestrationNotFound) ||
-		errors.Is(err, cortex.ErrOrchestrationRunNotFound)
+		errors.Is(err, cortex.ErrOrchestrationRunNotFound) ||
+		errors.Is(err, cortex.ErrSessionNotFound) ||
+		errors.Is(err, cortex.ErrWorkingMemoryNotFound) ||
+		errors.Is(err, cortex.ErrFactNotFound)
 }
 
 func isConflict(err error) bool {
-	return errors.Is(err, cortex.ErrAlreadyExists)
+	return errors.Is(err, cortex.ErrAlreadyExists) ||
+		errors.Is(err, cortex.ErrInvalidState)
+}
+
+func isInvalid(err error) bool {
+	return errors.Is(err, cortex.ErrSkillDependencyNotFound) ||
+		errors.Is(err, cortex.ErrSkillDependencyCycle) ||
+		errors.Is(err, cortex.ErrSkillDependencyTooDeep) ||
+		errors.Is(err, cortex.ErrOutputSchemaInvalid) ||
+		errors.Is(err, cortex.ErrBehaviorInvalid) ||
+		errors.Is(err, cortex.ErrTenantRequired)
+}
+
+func isUnavailable(err error) bool {
+	return errors.Is(err, cortex.ErrRunQueueTimeout)
+}
+
+func isExhausted(err error) bool {
+	return errors.Is(err, cortex.ErrBudgetExhausted) ||
+		errors.Is(err, cortex.ErrMaxTokensReached)
 }
 
 // defaultLimit returns a safe default page size.
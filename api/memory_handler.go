@@ -7,7 +7,6 @@ import (
 	"github.com/xraph/forge"
 
 	"github.com/xraph/cortex"
-	"github.com/xraph/cortex/memory"
 )
 
 func (a *API) registerMemoryRoutes(router forge.Router) error {
@@ -15,10 +14,10 @@ func (a *API) registerMemoryRoutes(router forge.Router) error {
 
 	if err := g.GET("/agents/:name/memory", a.getConversation,
 		forge.WithSummary("Get conversation"),
-		forge.WithDescription("Returns conversation history for an agent."),
+		forge.WithDescription("Returns conversation history and the summaries of older messages for an agent, or for one of its sessions with session_id."),
 		forge.WithOperationID("getConversation"),
 		forge.WithRequestSchema(GetConversationRequest{}),
-		forge.WithResponseSchema(http.StatusOK, "Conversation messages", []memory.Message{}),
+		forge.WithResponseSchema(http.StatusOK, "Conversation messages and summaries", GetConversationResponse{}),
 		forge.WithErrorResponses(),
 	); err != nil {
 		return fmt.Errorf("register memory routes: %w", err)
@@ -26,8 +25,42 @@ func (a *API) registerMemoryRoutes(router forge.Router) error {
 
 	if err := g.DELETE("/agents/:name/memory", a.clearConversation,
 		forge.WithSummary("Clear conversation"),
-		forge.WithDescription("Clears conversation history for an agent."),
+		forge.WithDescription("Clears conversation history for an agent, or for one of its sessions with session_id."),
 		forge.WithOperationID("clearConversation"),
+		forge.WithRequestSchema(ClearConversationRequest{}),
+		forge.WithNoContentResponse(),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register memory routes: %w", err)
+	}
+
+	if err := g.GET("/agents/:name/facts", a.listFacts,
+		forge.WithSummary("List facts"),
+		forge.WithDescription("Lists the long-term facts extracted from the agent's conversations for the caller's tenant, most recently updated first."),
+		forge.WithOperationID("listFacts"),
+		forge.WithRequestSchema(ListFactsRequest{}),
+		forge.WithResponseSchema(http.StatusOK, "Facts", ListFactsResponse{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register memory routes: %w", err)
+	}
+
+	if err := g.DELETE("/agents/:name/facts/:key", a.deleteFact,
+		forge.WithSummary("Delete fact"),
+		forge.WithDescription("Deletes one of the agent's facts for the caller's tenant by key."),
+		forge.WithOperationID("deleteFact"),
+		forge.WithRequestSchema(DeleteFactRequest{}),
+		forge.WithNoContentResponse(),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register memory routes: %w", err)
+	}
+
+	if err := g.DELETE("/agents/:name/facts", a.clearFacts,
+		forge.WithSummary("Clear facts"),
+		forge.WithDescription("Deletes all of the agent's facts for the caller's tenant."),
+		forge.WithOperationID("clearFacts"),
+		forge.WithRequestSchema(ClearFactsRequest{}),
 		forge.WithNoContentResponse(),
 		forge.WithErrorResponses(),
 	); err != nil {
@@ -44,29 +77,86 @@ func (a *API) getConversation(ctx forge.Context, req *GetConversationRequest) (*
 		return nil, mapStoreError(err)
 	}
 
+	sessionID, err := parseSessionID(req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	tenantID := cortex.TenantFromContext(ctx.Context())
 	limit := defaultLimit(req.Limit)
 
-	messages, err := a.eng.LoadConversation(ctx.Context(), cfg.ID, tenantID, limit)
+	messages, err := a.eng.LoadConversation(ctx.Context(), cfg.ID, tenantID, sessionID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("load conversation: %w", err)
 	}
-	resp := &GetConversationResponse{Messages: messages}
+	summaries, err := a.eng.LoadSummaries(ctx.Context(), cfg.ID, tenantID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("load summaries: %w", err)
+	}
+	resp := &GetConversationResponse{Messages: messages, Summaries: summaries}
 	return resp, ctx.JSON(http.StatusOK, resp)
 }
 
-func (a *API) clearConversation(ctx forge.Context, _ *ClearConversationRequest) (*struct{}, error) {
+func (a *API) clearConversation(ctx forge.Context, req *ClearConversationRequest) (*struct{}, error) {
 	appID := cortex.AppFromContext(ctx.Context())
 	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
 	if err != nil {
 		return nil, mapStoreError(err)
 	}
 
+	sessionID, err := parseSessionID(req.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	tenantID := cortex.TenantFromContext(ctx.Context())
 
-	if err := a.eng.ClearConversation(ctx.Context(), cfg.ID, tenantID); err != nil {
+	if err := a.eng.ClearConversation(ctx.Context(), cfg.ID, tenantID, sessionID); err != nil {
 		return nil, fmt.Errorf("clear conversation: %w", err)
 	}
 
 	return nil, ctx.NoContent(http.StatusNoContent)
 }
+
+func (a *API) listFacts(ctx forge.Context, _ *ListFactsRequest) (*ListFactsResponse, error) {
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	facts, err := a.eng.ListFacts(ctx.Context(), cfg.ID, cortex.TenantFromContext(ctx.Context()))
+	if err != nil {
+		return nil, fmt.Errorf("list facts: %w", err)
+	}
+	resp := &ListFactsResponse{Items: facts}
+	return resp, ctx.JSON(http.StatusOK, resp)
+}
+
+func (a *API) deleteFact(ctx forge.Context, _ *DeleteFactRequest) (*struct{}, error) {
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	if err := a.eng.DeleteFact(ctx.Context(), cfg.ID, cortex.TenantFromContext(ctx.Context()), ctx.Param("key")); err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	return nil, ctx.NoContent(http.StatusNoContent)
+}
+
+func (a *API) clearFacts(ctx forge.Context, _ *ClearFactsRequest) (*struct{}, error) {
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	if err := a.eng.ClearFacts(ctx.Context(), cfg.ID, cortex.TenantFromContext(ctx.Context())); err != nil {
+		return nil, fmt.Errorf("clear facts: %w", err)
+	}
+
+	return nil, ctx.NoContent(http.StatusNoContent)
+}
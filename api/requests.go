@@ -8,21 +8,24 @@ import (
 
 // CreateAgentRequest is the request body for creating an agent.
 type CreateAgentRequest struct {
-	Name            string         `json:"name" description:"Unique agent name"`
-	Description     string         `json:"description,omitempty"`
-	SystemPrompt    string         `json:"system_prompt" description:"Agent system prompt"`
-	Model           string         `json:"model,omitempty" description:"LLM model (default: smart)"`
-	Tools           []string       `json:"tools,omitempty" description:"Tool names (flat mode)"`
-	MaxSteps        int            `json:"max_steps,omitempty"`
-	MaxTokens       int            `json:"max_tokens,omitempty"`
-	Temperature     float64        `json:"temperature,omitempty"`
-	ReasoningLoop   string         `json:"reasoning_loop,omitempty"`
-	PersonaRef      string         `json:"persona_ref,omitempty" description:"Persona name reference"`
-	InlineSkills    []string       `json:"inline_skills,omitempty"`
-	InlineTraits    []string       `json:"inline_traits,omitempty"`
-	InlineBehaviors []string       `json:"inline_behaviors,omitempty"`
-	Guardrails      map[string]any `json:"guardrails,omitempty"`
-	Metadata        map[string]any `json:"metadata,omitempty"`
+	Name             string         `json:"name" description:"Unique agent name"`
+	Description      string         `json:"description,omitempty"`
+	SystemPrompt     string         `json:"system_prompt" description:"Agent system prompt"`
+	Model            string         `json:"model,omitempty" description:"LLM model (default: smart)"`
+	Tools            []string       `json:"tools,omitempty" description:"Tool names (flat mode)"`
+	MaxSteps         int            `json:"max_steps,omitempty"`
+	MaxTokens        int            `json:"max_tokens,omitempty"`
+	MaxTotalTokens   int            `json:"max_total_tokens,omitempty" description:"Tokens a single run may use (0 = no limit)"`
+	DailyTokenBudget int            `json:"daily_token_budget,omitempty" description:"Tokens all runs may use over the last 24 hours (0 = no limit)"`
+	OutputSchema     map[string]any `json:"output_schema,omitempty" description:"JSON Schema the final answer must be a JSON value of (default: free text)"`
+	Temperature      float64        `json:"temperature,omitempty"`
+	ReasoningLoop    string         `json:"reasoning_loop,omitempty"`
+	PersonaRef       string         `json:"persona_ref,omitempty" description:"Persona name reference"`
+	InlineSkills     []string       `json:"inline_skills,omitempty"`
+	InlineTraits     []string       `json:"inline_traits,omitempty"`
+	InlineBehaviors  []string       `json:"inline_behaviors,omitempty"`
+	Guardrails       map[string]any `json:"guardrails,omitempty"`
+	Metadata         map[string]any `json:"metadata,omitempty"`
 }
 
 // GetAgentRequest is the request for getting an agent by name.
@@ -38,21 +41,24 @@ type ListAgentsRequest struct {
 
 // UpdateAgentRequest is the request body for updating an agent.
 type UpdateAgentRequest struct {
-	Name            string         `path:"name" description:"Agent name"`
-	Description     string         `json:"description,omitempty"`
-	SystemPrompt    string         `json:"system_prompt,omitempty"`
-	Model           string         `json:"model,omitempty"`
-	Tools           []string       `json:"tools,omitempty"`
-	MaxSteps        int            `json:"max_steps,omitempty"`
-	MaxTokens       int            `json:"max_tokens,omitempty"`
-	Temperature     float64        `json:"temperature,omitempty"`
-	ReasoningLoop   string         `json:"reasoning_loop,omitempty"`
-	PersonaRef      string         `json:"persona_ref,omitempty"`
-	InlineSkills    []string       `json:"inline_skills,omitempty"`
-	InlineTraits    []string       `json:"inline_traits,omitempty"`
-	InlineBehaviors []string       `json:"inline_behaviors,omitempty"`
-	Guardrails      map[string]any `json:"guardrails,omitempty"`
-	Metadata        map[string]any `json:"metadata,omitempty"`
+	Name             string         `path:"name" description:"Agent name"`
+	Description      string         `json:"description,omitempty"`
+	SystemPrompt     string         `json:"system_prompt,omitempty"`
+	Model            string         `json:"model,omitempty"`
+	Tools            []string       `json:"tools,omitempty"`
+	MaxSteps         int            `json:"max_steps,omitempty"`
+	MaxTokens        int            `json:"max_tokens,omitempty"`
+	MaxTotalTokens   int            `json:"max_total_tokens,omitempty" description:"Tokens a single run may use (0 = no limit)"`
+	DailyTokenBudget int            `json:"daily_token_budget,omitempty" description:"Tokens all runs may use over the last 24 hours (0 = no limit)"`
+	OutputSchema     map[string]any `json:"output_schema,omitempty" description:"JSON Schema the final answer must be a JSON value of (default: free text)"`
+	Temperature      float64        `json:"temperature,omitempty"`
+	ReasoningLoop    string         `json:"reasoning_loop,omitempty"`
+	PersonaRef       string         `json:"persona_ref,omitempty"`
+	InlineSkills     []string       `json:"inline_skills,omitempty"`
+	InlineTraits     []string       `json:"inline_traits,omitempty"`
+	InlineBehaviors  []string       `json:"inline_behaviors,omitempty"`
+	Guardrails       map[string]any `json:"guardrails,omitempty"`
+	Metadata         map[string]any `json:"metadata,omitempty"`
 }
 
 // DeleteAgentRequest is the request for deleting an agent.
@@ -60,6 +66,11 @@ type DeleteAgentRequest struct {
 	Name string `path:"name" description:"Agent name"`
 }
 
+// GetAgentBudgetRequest is the request for getting an agent's token budget.
+type GetAgentBudgetRequest struct {
+	Name string `path:"name" description:"Agent name"`
+}
+
 // ── Run requests ──────────────────────────────────────
 
 // AgentOverrides allows overriding agent configuration for a single run.
@@ -67,7 +78,10 @@ type AgentOverrides struct {
 	Model           string   `json:"model,omitempty" description:"Override LLM model"`
 	Temperature     *float64 `json:"temperature,omitempty" description:"Override temperature"`
 	MaxSteps        int      `json:"max_steps,omitempty" description:"Override max steps"`
+	MaxStepsPolicy  string   `json:"max_steps_policy,omitempty" description:"Override what happens at the step limit: fail, summarize or checkpoint"`
+	ToolConcurrency int      `json:"tool_concurrency,omitempty" description:"Override the tool calls of a step run concurrently"`
 	MaxTokens       int      `json:"max_tokens,omitempty" description:"Override max tokens"`
+	MaxTotalTokens  int      `json:"max_total_tokens,omitempty" description:"Override the tokens the run may use"`
 	ReasoningLoop   string   `json:"reasoning_loop,omitempty" description:"Override reasoning loop"`
 	SystemPrompt    string   `json:"system_prompt,omitempty" description:"Override system prompt"`
 	PersonaRef      string   `json:"persona_ref,omitempty" description:"Override persona reference"`
@@ -75,12 +89,16 @@ type AgentOverrides struct {
 	InlineTraits    []string `json:"inline_traits,omitempty" description:"Override inline traits"`
 	InlineBehaviors []string `json:"inline_behaviors,omitempty" description:"Override inline behaviors"`
 	Tools           []string `json:"tools,omitempty" description:"Override tool list"`
+	// OutputSchema replaces the agent's output schema for the run.
+	OutputSchema map[string]any `json:"output_schema,omitempty" description:"Override the JSON Schema the final answer must match"`
 }
 
 // RunAgentRequest is the request body for running an agent.
 type RunAgentRequest struct {
 	Name      string          `path:"name" description:"Agent name"`
+	Async     bool            `query:"async" description:"Submit the run and return without waiting for it"`
 	Input     string          `json:"input" description:"User input"`
+	SessionID string          `json:"session_id,omitempty" description:"Session to run in (default: the conversation outside sessions)"`
 	Overrides *AgentOverrides `json:"overrides,omitempty" description:"Configuration overrides"`
 }
 
@@ -88,6 +106,7 @@ type RunAgentRequest struct {
 type StreamAgentRequest struct {
 	Name      string          `path:"name" description:"Agent name"`
 	Input     string          `json:"input" description:"User input"`
+	SessionID string          `json:"session_id,omitempty" description:"Session to run in (default: the conversation outside sessions)"`
 	Overrides *AgentOverrides `json:"overrides,omitempty" description:"Configuration overrides"`
 }
 
@@ -107,6 +126,12 @@ type ListRunsRequest struct {
 	Offset int `query:"offset"`
 }
 
+// WaitRunRequest is the request for waiting on a run to finish.
+type WaitRunRequest struct {
+	RunID   string `path:"id" description:"Run ID"`
+	Timeout int    `query:"timeout" description:"Seconds to wait before returning the run as it is (default 30, max 120)"`
+}
+
 // CancelRunRequest is the request for cancelling a run.
 type CancelRunRequest struct {
 	RunID string `path:"id" description:"Run ID"`
@@ -350,19 +375,60 @@ type ResolveCheckpointRequest struct {
 	CheckpointID string `path:"id" description:"Checkpoint ID"`
 	Decision     string `json:"decision" description:"approved or rejected"`
 	DecidedBy    string `json:"decided_by,omitempty"`
+	Reason       string `json:"reason,omitempty" description:"Explanation fed back to the agent when rejected"`
 }
 
 // ── Memory requests ───────────────────────────────────
 
 // GetConversationRequest is the request for getting conversation history.
 type GetConversationRequest struct {
-	Name  string `path:"name" description:"Agent name"`
-	Limit int    `query:"limit"`
+	Name      string `path:"name" description:"Agent name"`
+	SessionID string `query:"session_id" description:"Session whose conversation to return (default: the conversation outside sessions)"`
+	Limit     int    `query:"limit"`
 }
 
 // ClearConversationRequest is the request for clearing conversation history.
 type ClearConversationRequest struct {
+	Name      string `path:"name" description:"Agent name"`
+	SessionID string `query:"session_id" description:"Session whose conversation to clear (default: the conversation outside sessions)"`
+}
+
+// ListFactsRequest is the request for listing an agent's facts.
+type ListFactsRequest struct {
+	Name string `path:"name" description:"Agent name"`
+}
+
+// DeleteFactRequest is the request for deleting a fact.
+type DeleteFactRequest struct {
 	Name string `path:"name" description:"Agent name"`
+	Key  string `path:"key" description:"Fact key"`
+}
+
+// ClearFactsRequest is the request for deleting all of an agent's facts.
+type ClearFactsRequest struct {
+	Name string `path:"name" description:"Agent name"`
+}
+
+// ── Session requests ──────────────────────────────────
+
+// CreateSessionRequest is the request body for creating a session.
+type CreateSessionRequest struct {
+	Name     string         `path:"name" description:"Agent name"`
+	Title    string         `json:"title,omitempty" description:"Session title"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ListSessionsRequest is the request for listing an agent's sessions.
+type ListSessionsRequest struct {
+	Name   string `path:"name" description:"Agent name"`
+	Limit  int    `query:"limit" description:"Max results (default: 50)"`
+	Offset int    `query:"offset" description:"Results to skip"`
+}
+
+// DeleteSessionRequest is the request for deleting a session.
+type DeleteSessionRequest struct {
+	Name      string `path:"name" description:"Agent name"`
+	SessionID string `path:"id" description:"Session ID"`
 }
 
 // ── Tool requests ─────────────────────────────────────
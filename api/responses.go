@@ -8,6 +8,7 @@ import (
 	"github.com/xraph/cortex/orchestration"
 	"github.com/xraph/cortex/persona"
 	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
 	"github.com/xraph/cortex/skill"
 	"github.com/xraph/cortex/trait"
 )
@@ -50,29 +51,61 @@ type ListCheckpointsResponse struct {
 	Items []*checkpoint.Checkpoint `json:"items"`
 }
 
+// ListSessionsResponse wraps a list of sessions.
+type ListSessionsResponse struct {
+	Items []*session.Session `json:"items"`
+}
+
 // ListToolsResponse wraps a list of tools.
 type ListToolsResponse struct {
 	Items []map[string]any `json:"items"`
 }
 
-// GetConversationResponse wraps conversation messages.
+// GetConversationResponse wraps conversation messages and the summaries of
+// older messages, oldest first.
 type GetConversationResponse struct {
-	Messages []memory.Message `json:"messages"`
+	Messages  []memory.Message `json:"messages"`
+	Summaries []string         `json:"summaries"`
+}
+
+// ListFactsResponse wraps a list of facts.
+type ListFactsResponse struct {
+	Items []memory.Fact `json:"items"`
+}
+
+// RunResponse is a run with the working memory its tools saved, by key, and
+// the decoded final answer of a run with an output schema.
+type RunResponse struct {
+	*run.Run
+	WorkingMemory    map[string]any `json:"working_memory,omitempty"`
+	StructuredOutput any            `json:"structured_output,omitempty"`
 }
 
 // RunAgentResponse wraps the result of a synchronous agent run.
 type RunAgentResponse struct {
-	RunID      string `json:"run_id"`
-	Output     string `json:"output"`
-	State      string `json:"state"`
-	StepCount  int    `json:"step_count"`
-	TokensUsed int    `json:"tokens_used"`
-	DurationMs int64  `json:"duration_ms"`
+	RunID            string `json:"run_id"`
+	Output           string `json:"output"`
+	StructuredOutput any    `json:"structured_output,omitempty" description:"Final answer decoded from JSON, for agents with an output schema"`
+	State            string `json:"state"`
+	StepCount        int    `json:"step_count"`
+	TokensUsed       int    `json:"tokens_used"`
+	DurationMs       int64  `json:"duration_ms"`
 }
 
 // PreviewPromptResponse wraps the computed system prompt preview.
 type PreviewPromptResponse struct {
-	Prompt string `json:"prompt"`
+	Prompt   string         `json:"prompt"`
+	Skills   []PreviewSkill `json:"skills,omitempty"`
+	Warnings []string       `json:"warnings,omitempty"`
+}
+
+// PreviewSkill describes a skill the agent holds, including skills pulled in
+// as dependencies of other skills.
+type PreviewSkill struct {
+	Name        string `json:"name"`
+	Proficiency string `json:"proficiency"`
+	Source      string `json:"source"`
+	RequiredBy  string `json:"required_by,omitempty"`
 }
 
 // StreamEvent represents a single SSE event during agent streaming.
@@ -1,15 +1,26 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/xraph/forge"
 
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/engine"
 	"github.com/xraph/cortex/id"
 	"github.com/xraph/cortex/run"
 )
 
+// Bounds of the time GET /runs/:id/wait holds a request.
+const (
+	defaultRunWait = 30 * time.Second
+	maxRunWait     = 2 * time.Minute
+)
+
 func (a *API) registerRunRoutes(router forge.Router) error {
 	g := router.Group("/v1", forge.WithGroupTags("runs"))
 
@@ -26,9 +37,20 @@ func (a *API) registerRunRoutes(router forge.Router) error {
 
 	if err := g.GET("/runs/:id", a.getRun,
 		forge.WithSummary("Get run"),
-		forge.WithDescription("Returns details of a specific run."),
+		forge.WithDescription("Returns details of a specific run, with the working memory its tools saved."),
 		forge.WithOperationID("getRun"),
-		forge.WithResponseSchema(http.StatusOK, "Run details", &run.Run{}),
+		forge.WithResponseSchema(http.StatusOK, "Run details", &RunResponse{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register run routes: %w", err)
+	}
+
+	if err := g.GET("/runs/:id/wait", a.waitRun,
+		forge.WithSummary("Wait for run"),
+		forge.WithDescription("Long-polls until the run completes, fails, is cancelled or pauses for approval, and returns it like GET /runs/:id. After the timeout the run is returned in its current state."),
+		forge.WithOperationID("waitRun"),
+		forge.WithRequestSchema(WaitRunRequest{}),
+		forge.WithResponseSchema(http.StatusOK, "Run details", &RunResponse{}),
 		forge.WithErrorResponses(),
 	); err != nil {
 		return fmt.Errorf("register run routes: %w", err)
@@ -36,7 +58,7 @@ func (a *API) registerRunRoutes(router forge.Router) error {
 
 	if err := g.POST("/runs/:id/cancel", a.cancelRun,
 		forge.WithSummary("Cancel run"),
-		forge.WithDescription("Cancels a running agent execution."),
+		forge.WithDescription("Cancels a submitted, running or paused agent execution. A run executing on this instance is stopped before the response is sent; one executing on another instance stops at its next step. Returns 409 if the run has already finished."),
 		forge.WithOperationID("cancelRun"),
 		forge.WithNoContentResponse(),
 		forge.WithErrorResponses(),
@@ -47,7 +69,7 @@ func (a *API) registerRunRoutes(router forge.Router) error {
 	return nil
 }
 
-func (a *API) getRun(ctx forge.Context, _ *GetRunRequest) (*run.Run, error) {
+func (a *API) getRun(ctx forge.Context, _ *GetRunRequest) (*RunResponse, error) {
 	runID, err := id.ParseAgentRunID(ctx.Param("id"))
 	if err != nil {
 		return nil, forge.BadRequest(fmt.Sprintf("invalid run ID: %v", err))
@@ -57,13 +79,27 @@ func (a *API) getRun(ctx forge.Context, _ *GetRunRequest) (*run.Run, error) {
 	if err != nil {
 		return nil, mapStoreError(err)
 	}
-	return r, ctx.JSON(http.StatusOK, r)
+	resp, err := a.runResponse(ctx.Context(), r)
+	if err != nil {
+		return nil, err
+	}
+	return resp, ctx.JSON(http.StatusOK, resp)
+}
+
+// runResponse returns r with its working memory and structured output.
+func (a *API) runResponse(ctx context.Context, r *run.Run) (*RunResponse, error) {
+	working, err := a.eng.ListWorking(ctx, r.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list working memory: %w", err)
+	}
+	return &RunResponse{Run: r, WorkingMemory: working, StructuredOutput: engine.StructuredOutput(r)}, nil
 }
 
 func (a *API) listRuns(ctx forge.Context, req *ListRunsRequest) (*ListRunsResponse, error) {
 	runs, err := a.eng.ListRuns(ctx.Context(), &run.ListFilter{
-		Limit:  defaultLimit(req.Limit),
-		Offset: req.Offset,
+		TenantID: cortex.TenantFromContext(ctx.Context()),
+		Limit:    defaultLimit(req.Limit),
+		Offset:   req.Offset,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("list runs: %w", err)
@@ -72,24 +108,38 @@ func (a *API) listRuns(ctx forge.Context, req *ListRunsRequest) (*ListRunsRespon
 	return resp, ctx.JSON(http.StatusOK, resp)
 }
 
-func (a *API) cancelRun(ctx forge.Context, _ *CancelRunRequest) (*struct{}, error) {
+func (a *API) waitRun(ctx forge.Context, req *WaitRunRequest) (*RunResponse, error) {
 	runID, err := id.ParseAgentRunID(ctx.Param("id"))
 	if err != nil {
 		return nil, forge.BadRequest(fmt.Sprintf("invalid run ID: %v", err))
 	}
 
-	r, err := a.eng.GetRun(ctx.Context(), runID)
-	if err != nil {
+	timeout := defaultRunWait
+	if req.Timeout > 0 {
+		timeout = min(time.Duration(req.Timeout)*time.Second, maxRunWait)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx.Context(), timeout)
+	defer cancel()
+
+	r, err := a.eng.WaitRun(waitCtx, runID)
+	if err != nil && (r == nil || !errors.Is(err, context.DeadlineExceeded)) {
 		return nil, mapStoreError(err)
 	}
+	resp, err := a.runResponse(ctx.Context(), r)
+	if err != nil {
+		return nil, err
+	}
+	return resp, ctx.JSON(http.StatusOK, resp)
+}
 
-	if r.State != run.StateRunning && r.State != run.StatePaused {
-		return nil, forge.BadRequest("run is not in a cancellable state")
+func (a *API) cancelRun(ctx forge.Context, _ *CancelRunRequest) (*struct{}, error) {
+	runID, err := id.ParseAgentRunID(ctx.Param("id"))
+	if err != nil {
+		return nil, forge.BadRequest(fmt.Sprintf("invalid run ID: %v", err))
 	}
 
-	r.State = run.StateCancelled
-	if err := a.eng.UpdateRun(ctx.Context(), r); err != nil {
-		return nil, fmt.Errorf("cancel run: %w", err)
+	if err := a.eng.CancelRun(ctx.Context(), runID); err != nil {
+		return nil, mapStoreError(err)
 	}
 
 	return nil, ctx.NoContent(http.StatusNoContent)
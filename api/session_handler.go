@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xraph/forge"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/session"
+)
+
+func (a *API) registerSessionRoutes(router forge.Router) error {
+	g := router.Group("/v1", forge.WithGroupTags("sessions"))
+
+	if err := g.POST("/agents/:name/sessions", a.createSession,
+		forge.WithSummary("Create session"),
+		forge.WithDescription("Starts a conversation session with an agent under the caller's tenant. Runs given its ID see and extend only the session's conversation."),
+		forge.WithOperationID("createSession"),
+		forge.WithRequestSchema(CreateSessionRequest{}),
+		forge.WithCreatedResponse(&session.Session{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register session routes: %w", err)
+	}
+
+	if err := g.GET("/agents/:name/sessions", a.listSessions,
+		forge.WithSummary("List sessions"),
+		forge.WithDescription("Returns the caller's sessions with an agent, most recently active first."),
+		forge.WithOperationID("listSessions"),
+		forge.WithRequestSchema(ListSessionsRequest{}),
+		forge.WithResponseSchema(http.StatusOK, "Session list", &ListSessionsResponse{}),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register session routes: %w", err)
+	}
+
+	if err := g.DELETE("/agents/:name/sessions/:id", a.deleteSession,
+		forge.WithSummary("Delete session"),
+		forge.WithDescription("Deletes a session and its conversation memory."),
+		forge.WithOperationID("deleteSession"),
+		forge.WithNoContentResponse(),
+		forge.WithErrorResponses(),
+	); err != nil {
+		return fmt.Errorf("register session routes: %w", err)
+	}
+
+	return nil
+}
+
+func (a *API) createSession(ctx forge.Context, req *CreateSessionRequest) (*session.Session, error) {
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	s := &session.Session{
+		Entity:   cortex.NewEntity(),
+		ID:       id.NewSessionID(),
+		AgentID:  cfg.ID,
+		TenantID: cortex.TenantFromContext(ctx.Context()),
+		Title:    req.Title,
+		Metadata: req.Metadata,
+	}
+	if err := a.eng.CreateSession(ctx.Context(), s); err != nil {
+		return nil, mapStoreError(err)
+	}
+	return s, ctx.JSON(http.StatusCreated, s)
+}
+
+func (a *API) listSessions(ctx forge.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	sessions, err := a.eng.ListSessions(ctx.Context(), &session.ListFilter{
+		AgentID:  cfg.ID.String(),
+		TenantID: cortex.TenantFromContext(ctx.Context()),
+		Limit:    defaultLimit(req.Limit),
+		Offset:   req.Offset,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	resp := &ListSessionsResponse{Items: sessions}
+	return resp, ctx.JSON(http.StatusOK, resp)
+}
+
+func (a *API) deleteSession(ctx forge.Context, _ *DeleteSessionRequest) (*struct{}, error) {
+	sessionID, err := id.ParseSessionID(ctx.Param("id"))
+	if err != nil {
+		return nil, forge.BadRequest(fmt.Sprintf("invalid session ID: %v", err))
+	}
+
+	appID := cortex.AppFromContext(ctx.Context())
+	cfg, err := a.eng.GetAgentByName(ctx.Context(), appID, ctx.Param("name"))
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+
+	// Sessions of other agents and tenants are reported as missing.
+	s, err := a.eng.GetSession(ctx.Context(), sessionID)
+	if err != nil {
+		return nil, mapStoreError(err)
+	}
+	if s.AgentID.String() != cfg.ID.String() || s.TenantID != cortex.TenantFromContext(ctx.Context()) {
+		return nil, mapStoreError(cortex.ErrSessionNotFound)
+	}
+
+	if err := a.eng.DeleteSession(ctx.Context(), sessionID); err != nil {
+		return nil, mapStoreError(err)
+	}
+	return nil, ctx.NoContent(http.StatusNoContent)
+}
+
+// parseSessionID parses the optional session ID of a request; the nil ID
+// when it is empty.
+func parseSessionID(raw string) (id.SessionID, error) {
+	if raw == "" {
+		return id.Nil, nil
+	}
+	sessionID, err := id.ParseSessionID(raw)
+	if err != nil {
+		return id.Nil, forge.BadRequest(fmt.Sprintf("invalid session ID: %v", err))
+	}
+	return sessionID, nil
+}
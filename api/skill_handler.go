@@ -105,7 +105,7 @@ func (a *API) createSkill(ctx forge.Context, req *CreateSkillRequest) (*skill.Sk
 	}
 
 	if err := a.eng.CreateSkill(ctx.Context(), s); err != nil {
-		return nil, fmt.Errorf("create skill: %w", err)
+		return nil, mapStoreError(fmt.Errorf("create skill: %w", err))
 	}
 
 	return s, ctx.JSON(http.StatusCreated, s)
@@ -178,7 +178,7 @@ func (a *API) updateSkill(ctx forge.Context, req *UpdateSkillRequest) (*skill.Sk
 	}
 
 	if err := a.eng.UpdateSkill(ctx.Context(), s); err != nil {
-		return nil, fmt.Errorf("update skill: %w", err)
+		return nil, mapStoreError(fmt.Errorf("update skill: %w", err))
 	}
 	return s, ctx.JSON(http.StatusOK, s)
 }
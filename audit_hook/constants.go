@@ -19,6 +19,7 @@ const (
 	ActionRunStarted             = "cortex.agent.run.started"
 	ActionRunCompleted           = "cortex.agent.run.completed"
 	ActionRunFailed              = "cortex.agent.run.failed"
+	ActionRunCancelled           = "cortex.agent.run.cancelled"
 	ActionStepStarted            = "cortex.step.started"
 	ActionStepCompleted          = "cortex.step.completed"
 	ActionToolCalled             = "cortex.tool.called"
@@ -44,6 +45,7 @@ const (
 	ResourcePersona       = "persona"
 	ResourceSkill         = "skill"
 	ResourceBehavior      = "behavior"
+	ResourceTrait         = "trait"
 	ResourceCheckpoint    = "checkpoint"
 	ResourceOrchestration = "orchestration"
 )
@@ -17,11 +17,13 @@ var (
 	_ plugin.RunStarted         = (*Extension)(nil)
 	_ plugin.RunCompleted       = (*Extension)(nil)
 	_ plugin.RunFailed          = (*Extension)(nil)
+	_ plugin.RunCancelled       = (*Extension)(nil)
 	_ plugin.ToolCalled         = (*Extension)(nil)
 	_ plugin.ToolCompleted      = (*Extension)(nil)
 	_ plugin.ToolFailed         = (*Extension)(nil)
 	_ plugin.PersonaResolved    = (*Extension)(nil)
 	_ plugin.BehaviorTriggered  = (*Extension)(nil)
+	_ plugin.TraitApplied       = (*Extension)(nil)
 	_ plugin.CheckpointCreated  = (*Extension)(nil)
 	_ plugin.CheckpointResolved = (*Extension)(nil)
 )
@@ -96,6 +98,14 @@ func (e *Extension) OnRunFailed(ctx context.Context, agentID id.AgentID, runID i
 	)
 }
 
+func (e *Extension) OnRunCancelled(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, partialOutput string) error {
+	return e.record(ctx, ActionRunCancelled, SeverityWarning, OutcomeFailure,
+		ResourceRun, runID.String(), CategoryAgent, nil,
+		"agent_id", agentID.String(),
+		"output_length", len(partialOutput),
+	)
+}
+
 func (e *Extension) OnToolCalled(ctx context.Context, runID id.AgentRunID, toolName string, _ any) error {
 	return e.record(ctx, ActionToolCalled, SeverityInfo, OutcomeSuccess,
 		ResourceTool, runID.String(), CategoryTool, nil,
@@ -132,6 +142,14 @@ func (e *Extension) OnBehaviorTriggered(ctx context.Context, runID id.AgentRunID
 	)
 }
 
+func (e *Extension) OnTraitApplied(ctx context.Context, runID id.AgentRunID, traitName, target string) error {
+	return e.record(ctx, ActionTraitApplied, SeverityInfo, OutcomeSuccess,
+		ResourceTrait, runID.String(), CategoryPersona, nil,
+		"trait_name", traitName,
+		"target", target,
+	)
+}
+
 func (e *Extension) OnCheckpointCreated(ctx context.Context, cpID id.CheckpointID, runID id.AgentRunID, reason string) error {
 	return e.record(ctx, ActionCheckpointCreated, SeverityInfo, OutcomeSuccess,
 		ResourceCheckpoint, cpID.String(), CategoryCheckpoint, nil,
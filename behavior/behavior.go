@@ -42,6 +42,12 @@ const (
 )
 
 // Action defines what happens when a behavior triggers.
+//
+// require_tool names the tool in Target; the engine asks for it in the
+// system prompt and sends final answers back to the model until the tool
+// has been called, as long as the run offers it. add_guardrail only adds
+// its rule, Value or else Target, to the system prompt: it steers the
+// model and is not enforced. Use a safety profile for rules that must be.
 type Action struct {
 	Type   ActionType `json:"type"`
 	Target string     `json:"target,omitempty"`
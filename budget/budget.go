@@ -0,0 +1,83 @@
+// Package budget defines token budgets and the persisted usage they are
+// checked against.
+package budget
+
+import (
+	"context"
+	"time"
+)
+
+// Scope identifies what a budget applies to.
+type Scope string
+
+const (
+	// ScopeAgent is a rolling 24-hour budget of an agent, keyed by agent ID.
+	ScopeAgent Scope = "agent"
+	// ScopeTenant is a calendar-month budget of a tenant, keyed by tenant ID.
+	ScopeTenant Scope = "tenant"
+)
+
+// AgentWindow is the length of the rolling window of agent budgets.
+const AgentWindow = 24 * time.Hour
+
+// Period returns the start of the period usage at t is recorded in: the
+// hour for agent budgets and the calendar month, in UTC, for tenant budgets.
+func Period(scope Scope, t time.Time) time.Time {
+	t = t.UTC()
+	if scope == ScopeTenant {
+		return monthStart(t)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// WindowStart returns the start of the first period counted against the
+// budget of scope at now: the last 24 hours for agents, the current month
+// for tenants.
+func WindowStart(scope Scope, now time.Time) time.Time {
+	if scope == ScopeTenant {
+		return Period(scope, now)
+	}
+	return Period(scope, now).Add(time.Hour - AgentWindow)
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Status reports a budget's consumption over its current window.
+type Status struct {
+	Scope Scope  `json:"scope"`
+	Key   string `json:"key"`
+	// Limit is the budget in tokens. Zero means no budget, in which case
+	// Remaining is zero as well.
+	Limit       int64     `json:"limit"`
+	Used        int64     `json:"used"`
+	Remaining   int64     `json:"remaining"`
+	WindowStart time.Time `json:"window_start"`
+}
+
+// NewStatus returns the status of a budget of limit tokens with used tokens
+// consumed.
+func NewStatus(scope Scope, key string, limit, used int64, windowStart time.Time) *Status {
+	s := &Status{Scope: scope, Key: key, Limit: limit, Used: used, WindowStart: windowStart}
+	if limit > 0 {
+		s.Remaining = max(limit-used, 0)
+	}
+	return s
+}
+
+// Exhausted reports whether the budget is set and fully used.
+func (s *Status) Exhausted() bool {
+	return s.Limit > 0 && s.Used >= s.Limit
+}
+
+// Store persists token usage per scope, key and period.
+type Store interface {
+	// AddUsage adds tokens to the usage of key in the period starting at
+	// period.
+	AddUsage(ctx context.Context, scope Scope, key string, period time.Time, tokens int64) error
+	// SumUsage returns the tokens used by key over the periods starting at
+	// or after since.
+	SumUsage(ctx context.Context, scope Scope, key string, since time.Time) (int64, error)
+}
@@ -8,6 +8,15 @@ import (
 	"github.com/xraph/cortex/id"
 )
 
+// Checkpoint states.
+const (
+	StatePending  = "pending"
+	StateResolved = "resolved"
+	// StateExpired marks a checkpoint whose run was cancelled before it
+	// was resolved.
+	StateExpired = "expired"
+)
+
 // Decision represents the resolution of a checkpoint.
 type Decision struct {
 	Approved  bool      `json:"approved"`
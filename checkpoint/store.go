@@ -10,7 +10,13 @@ import (
 type Store interface {
 	CreateCheckpoint(ctx context.Context, cp *Checkpoint) error
 	GetCheckpoint(ctx context.Context, cpID id.CheckpointID) (*Checkpoint, error)
-	Resolve(ctx context.Context, cpID id.CheckpointID, decision Decision) error
+	// Resolve atomically records decision on a pending checkpoint. It
+	// reports false when no pending checkpoint has the ID, so that only one
+	// of several concurrent callers resolves it.
+	Resolve(ctx context.Context, cpID id.CheckpointID, decision Decision) (bool, error)
+	// ExpirePending moves the pending checkpoints of a run to expired, so
+	// they can no longer be resolved.
+	ExpirePending(ctx context.Context, runID id.AgentRunID) error
 	ListPending(ctx context.Context, filter *ListFilter) ([]*Checkpoint, error)
 	CountPending(ctx context.Context, filter *ListFilter) (int64, error)
 }
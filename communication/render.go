@@ -0,0 +1,187 @@
+package communication
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Output formats recognised by Guidance and Enforce.
+const (
+	FormatMarkdown   = "markdown"
+	FormatPlain      = "plain"
+	FormatStructured = "structured"
+)
+
+// IsZero reports whether the style is unset.
+func (s Style) IsZero() bool {
+	return s == Style{}
+}
+
+// Guidance renders the style as a system prompt section. Each numeric field
+// maps to one of three buckets (below 0.35, below 0.65, and the rest); zero
+// numeric fields are treated as unset and contribute nothing. The output is
+// deterministic for a given style. It returns "" for a zero style.
+func (s Style) Guidance() string {
+	if s.IsZero() {
+		return ""
+	}
+
+	var lines []string
+	if tone := strings.TrimSpace(s.Tone); tone != "" {
+		lines = append(lines, "Keep your tone "+tone+".")
+	}
+	lines = appendBucket(lines, s.Formality,
+		"Use a casual, conversational register. Contractions and informal phrasing are fine.",
+		"Use a neutral register that is professional but approachable.",
+		"Use a formal register. Avoid slang, contractions and colloquialisms.",
+	)
+	lines = appendBucket(lines, s.Verbosity,
+		"Be concise. Answer in as few words as needed and skip preamble.",
+		"Give complete answers with brief explanations where useful.",
+		"Be thorough. Explain your reasoning and include relevant detail and examples.",
+	)
+	lines = appendBucket(lines, s.TechnicalLevel,
+		"Write for a non-technical audience. Avoid jargon, or define it when it is unavoidable.",
+		"Assume some technical familiarity. Use common technical terms but explain specialised ones.",
+		"Assume an expert audience. Use precise technical terminology without simplifying.",
+	)
+	if s.EmojiUsage {
+		lines = append(lines, "Emoji are welcome where they fit naturally.")
+	} else {
+		lines = append(lines, "Do not use emoji.")
+	}
+	switch strings.ToLower(strings.TrimSpace(s.PreferredFormat)) {
+	case "":
+	case FormatMarkdown:
+		lines = append(lines, "Format responses in Markdown, using headings, lists and code blocks where they help.")
+	case FormatPlain:
+		lines = append(lines, "Respond in plain text without Markdown or other markup.")
+	case FormatStructured:
+		lines = append(lines, "Structure responses into clearly labelled sections.")
+	default:
+		lines = append(lines, "Format responses as "+s.PreferredFormat+".")
+	}
+	if s.AdaptToUser {
+		lines = append(lines, "Mirror the user's language and adapt your style to theirs.")
+	}
+
+	return "\n## Communication style\n- " + strings.Join(lines, "\n- ")
+}
+
+// appendBucket appends the low, mid or high guidance for v. Zero is unset.
+func appendBucket(lines []string, v float64, low, mid, high string) []string {
+	switch {
+	case v <= 0:
+		return lines
+	case v < 0.35:
+		return append(lines, low)
+	case v < 0.65:
+		return append(lines, mid)
+	default:
+		return append(lines, high)
+	}
+}
+
+// Enforce post-processes a response to honour the style where that can be
+// done mechanically: emoji are stripped when EmojiUsage is false, Markdown is
+// converted to plain text for the plain format, and bullet characters are
+// normalised to Markdown list items for the markdown format. A zero style
+// returns output unchanged.
+func (s Style) Enforce(output string) string {
+	if s.IsZero() {
+		return output
+	}
+	if !s.EmojiUsage {
+		output = StripEmoji(output)
+	}
+	switch strings.ToLower(strings.TrimSpace(s.PreferredFormat)) {
+	case FormatPlain:
+		output = MarkdownToPlain(output)
+	case FormatMarkdown:
+		output = normalizeBullets(output)
+	}
+	return output
+}
+
+// StripEmoji removes emoji (including modifiers, joiners and variation
+// selectors) from text. A space left doubled or dangling by a removed emoji is
+// dropped as well.
+func StripEmoji(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	var last rune = '\n'
+	removed, dropped := false, false
+	for _, r := range text {
+		if isEmoji(r) {
+			removed, dropped = true, true
+			continue
+		}
+		if dropped && r == ' ' && (last == ' ' || last == '\n') {
+			continue
+		}
+		if dropped && r == '\n' && last == ' ' {
+			trimmed := strings.TrimRight(b.String(), " ")
+			b.Reset()
+			b.WriteString(trimmed)
+		}
+		dropped = false
+		b.WriteRune(r)
+		last = r
+	}
+	if !removed {
+		return text
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// isEmoji reports whether r is an emoji or an emoji combining character.
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // pictographs, emoticons, flags, symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // misc symbols and dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // arrows and stars (⭐, ⬆)
+		return r == 0x2B50 || r == 0x2B55 || (r >= 0x2B05 && r <= 0x2B07) || (r >= 0x2B1B && r <= 0x2B1C)
+	case r == 0x200D, r == 0xFE0F, r == 0x20E3: // joiner, variation selector, keycap
+		return true
+	}
+	return false
+}
+
+var (
+	mdFence     = regexp.MustCompile("(?m)^[ \\t]*```[^\\n]*\\n?")
+	mdHeading   = regexp.MustCompile(`(?m)^#{1,6}[ \t]+`)
+	mdQuote     = regexp.MustCompile(`(?m)^>[ \t]?`)
+	mdImage     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+	mdLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdBold      = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdItalic    = regexp.MustCompile(`(^|[^\w*])[*_]([^*_\n]+)[*_]([^\w*]|$)`)
+	mdCode      = regexp.MustCompile("`([^`]+)`")
+	mdStarList  = regexp.MustCompile(`(?m)^([ \t]*)[*+][ \t]+`)
+	mdRule      = regexp.MustCompile(`(?m)^[ \t]*([-*_])([ \t]*[-*_]){2,}[ \t]*$\n?`)
+	bulletChars = regexp.MustCompile(`(?m)^([ \t]*)[•◦▪‣●][ \t]*`)
+)
+
+// MarkdownToPlain converts Markdown to plain text: code fences, heading
+// markers, blockquote markers, horizontal rules and emphasis are removed,
+// inline code keeps its text, links become "text (url)" and "*" or "+" list
+// items become "-" items.
+func MarkdownToPlain(text string) string {
+	text = mdFence.ReplaceAllString(text, "")
+	text = mdRule.ReplaceAllString(text, "")
+	text = mdHeading.ReplaceAllString(text, "")
+	text = mdQuote.ReplaceAllString(text, "")
+	text = mdImage.ReplaceAllString(text, "$1 ($2)")
+	text = mdLink.ReplaceAllString(text, "$1 ($2)")
+	text = mdStarList.ReplaceAllString(text, "$1- ")
+	text = mdBold.ReplaceAllString(text, "$2")
+	text = mdItalic.ReplaceAllString(text, "$1$2$3")
+	text = mdCode.ReplaceAllString(text, "$1")
+	return strings.TrimRight(text, "\n")
+}
+
+// normalizeBullets rewrites bullet characters at line start as Markdown list items.
+func normalizeBullets(text string) string {
+	return bulletChars.ReplaceAllString(text, "$1- ")
+}
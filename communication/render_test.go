@@ -0,0 +1,129 @@
+package communication_test
+
+import (
+	"testing"
+
+	"github.com/xraph/cortex/communication"
+)
+
+func TestGuidance_ZeroStyle(t *testing.T) {
+	if got := (communication.Style{}).Guidance(); got != "" {
+		t.Fatalf("Guidance() = %q, want empty for zero style", got)
+	}
+}
+
+func TestGuidance_Buckets(t *testing.T) {
+	tests := []struct {
+		name  string
+		style communication.Style
+		want  string
+	}{
+		{"formality low", communication.Style{Formality: 0.2},
+			"Use a casual, conversational register. Contractions and informal phrasing are fine."},
+		{"formality mid", communication.Style{Formality: 0.5},
+			"Use a neutral register that is professional but approachable."},
+		{"formality high", communication.Style{Formality: 0.9},
+			"Use a formal register. Avoid slang, contractions and colloquialisms."},
+		{"verbosity low", communication.Style{Verbosity: 0.1},
+			"Be concise. Answer in as few words as needed and skip preamble."},
+		{"verbosity mid", communication.Style{Verbosity: 0.35},
+			"Give complete answers with brief explanations where useful."},
+		{"verbosity high", communication.Style{Verbosity: 0.65},
+			"Be thorough. Explain your reasoning and include relevant detail and examples."},
+		{"technical low", communication.Style{TechnicalLevel: 0.3},
+			"Write for a non-technical audience. Avoid jargon, or define it when it is unavoidable."},
+		{"technical mid", communication.Style{TechnicalLevel: 0.6},
+			"Assume some technical familiarity. Use common technical terms but explain specialised ones."},
+		{"technical high", communication.Style{TechnicalLevel: 1},
+			"Assume an expert audience. Use precise technical terminology without simplifying."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := "\n## Communication style\n- " + tt.want + "\n- Do not use emoji."
+			if got := tt.style.Guidance(); got != want {
+				t.Errorf("Guidance() =\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestGuidance_FullStyle(t *testing.T) {
+	s := communication.Style{
+		Tone:            "friendly",
+		Formality:       0.3,
+		Verbosity:       0.6,
+		TechnicalLevel:  0.2,
+		EmojiUsage:      true,
+		PreferredFormat: "plain",
+		AdaptToUser:     true,
+	}
+	want := "\n## Communication style\n" +
+		"- Keep your tone friendly.\n" +
+		"- Use a casual, conversational register. Contractions and informal phrasing are fine.\n" +
+		"- Give complete answers with brief explanations where useful.\n" +
+		"- Write for a non-technical audience. Avoid jargon, or define it when it is unavoidable.\n" +
+		"- Emoji are welcome where they fit naturally.\n" +
+		"- Respond in plain text without Markdown or other markup.\n" +
+		"- Mirror the user's language and adapt your style to theirs."
+	if got := s.Guidance(); got != want {
+		t.Errorf("Guidance() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGuidance_Formats(t *testing.T) {
+	tests := map[string]string{
+		"markdown":   "Format responses in Markdown, using headings, lists and code blocks where they help.",
+		"Plain":      "Respond in plain text without Markdown or other markup.",
+		"structured": "Structure responses into clearly labelled sections.",
+		"html":       "Format responses as html.",
+	}
+	for format, line := range tests {
+		s := communication.Style{PreferredFormat: format, EmojiUsage: true}
+		want := "\n## Communication style\n- Emoji are welcome where they fit naturally.\n- " + line
+		if got := s.Guidance(); got != want {
+			t.Errorf("format %q: Guidance() = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestStripEmoji(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Done! 🎉", "Done!"},
+		{"🚀 Launching now", "Launching now"},
+		{"Great 👍🏽 work", "Great work"},
+		{"Family: 👨‍👩‍👧 ok", "Family: ok"},
+		{"Sunny ☀️ day\nnext", "Sunny day\nnext"},
+		{"no emoji  here ", "no emoji  here "},
+	}
+	for _, tt := range tests {
+		if got := communication.StripEmoji(tt.in); got != tt.want {
+			t.Errorf("StripEmoji(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownToPlain(t *testing.T) {
+	in := "# Summary\n\nThis is **important** and _subtle_.\n\n* first\n+ second\n\n> quoted\n\n---\nSee [docs](https://example.com) and `run()`.\n```go\nfmt.Println(1)\n```\n"
+	want := "Summary\n\nThis is important and subtle.\n\n- first\n- second\n\nquoted\n\nSee docs (https://example.com) and run().\nfmt.Println(1)"
+	if got := communication.MarkdownToPlain(in); got != want {
+		t.Errorf("MarkdownToPlain() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEnforce(t *testing.T) {
+	plain := communication.Style{PreferredFormat: "plain"}
+	if got := plain.Enforce("**Hi** 👋"); got != "Hi" {
+		t.Errorf("plain Enforce = %q, want %q", got, "Hi")
+	}
+
+	md := communication.Style{PreferredFormat: "markdown", EmojiUsage: true}
+	if got := md.Enforce("• one\n  ◦ two 👋"); got != "- one\n  - two 👋" {
+		t.Errorf("markdown Enforce = %q", got)
+	}
+
+	if got := (communication.Style{}).Enforce("**kept** 🎉"); got != "**kept** 🎉" {
+		t.Errorf("zero style Enforce changed output: %q", got)
+	}
+}
@@ -10,6 +10,73 @@ type Config struct {
 	// DefaultMaxSteps is the maximum reasoning steps per run.
 	DefaultMaxSteps int
 
+	// MaxStepsPolicy decides what happens to a run that reaches its step
+	// limit while the model is still calling tools.
+	MaxStepsPolicy MaxStepsPolicy
+
+	// ToolConcurrency is the maximum number of tool calls from one model
+	// response a run executes concurrently. One runs them one after another;
+	// zero means no limit. Tools registered with engine.Sequential never run
+	// alongside other calls.
+	ToolConcurrency int
+
+	// ToolFailureThreshold is the number of consecutive failed calls of a
+	// tool after which a run stops offering the tool to the model. Zero never
+	// disables a tool.
+	ToolFailureThreshold int
+
+	// OutputRepairAttempts is the number of times a run with an output
+	// schema asks the model to correct a final answer that is not valid
+	// JSON matching the schema, before failing with ErrOutputInvalid.
+	OutputRepairAttempts int
+
+	// SummarizeAfterMessages is the number of stored conversation messages
+	// after which a completed run summarizes the older ones. Zero disables
+	// the message threshold.
+	SummarizeAfterMessages int
+
+	// SummarizeAfterTokens is the estimated token count of the stored
+	// conversation after which a completed run summarizes the older
+	// messages. Zero disables the token threshold.
+	SummarizeAfterTokens int
+
+	// SummaryWindow is the number of most recent conversation messages kept
+	// verbatim when the rest are summarized.
+	SummaryWindow int
+
+	// SummaryModel is the LLM model that writes conversation summaries.
+	// Empty uses the model of the agent whose conversation is summarized.
+	SummaryModel string
+
+	// KeepWorkingMemory keeps the working memory of completed runs, which
+	// the memory_set tool writes. By default it is cleared when a run
+	// completes; failed and cancelled runs always keep theirs.
+	KeepWorkingMemory bool
+
+	// FactMemory enables long-term fact memory: after each completed run
+	// the model extracts durable facts, such as user preferences, decisions
+	// and entities, into the agent's facts for the run's tenant, and the
+	// facts relevant to a run's input are added to its system prompt.
+	FactMemory bool
+
+	// FactModel is the LLM model that extracts facts. Empty uses the model
+	// of the agent whose run is processed.
+	FactModel string
+
+	// FactRecallLimit is the maximum number of facts added to a run's
+	// system prompt.
+	FactRecallLimit int
+
+	// ContextLimits sets the context window in tokens of models, on top of
+	// llm.DefaultContextLimits. A key also matches the model names it is a
+	// prefix of.
+	ContextLimits map[string]int
+
+	// DefaultContextLimit is the context window assumed for models found in
+	// neither ContextLimits nor llm.DefaultContextLimits. Zero leaves the
+	// requests of such models untrimmed.
+	DefaultContextLimit int
+
 	// DefaultMaxTokens is the maximum output tokens per LLM call.
 	DefaultMaxTokens int
 
@@ -22,19 +89,106 @@ type Config struct {
 	// ShutdownTimeout is the maximum time to wait for graceful shutdown.
 	ShutdownTimeout time.Duration
 
-	// RunConcurrency is the maximum number of agent runs processed concurrently.
+	// RunConcurrency is the maximum number of agent runs processed
+	// concurrently (default: 4). Runs over the limit are queued. Zero or
+	// less means no limit.
 	RunConcurrency int
+
+	// TenantRunConcurrency is the maximum number of concurrent runs per
+	// tenant. Zero means no per-tenant limit.
+	TenantRunConcurrency int
+
+	// RunQueueTimeout is how long a run may wait in the queue for a slot
+	// before it fails with ErrRunQueueTimeout. Zero waits until the run's
+	// context is done.
+	RunQueueTimeout time.Duration
+
+	// TenantMonthlyTokenBudget is the number of tokens the runs of each
+	// tenant may use per calendar month (UTC). Zero means no budget.
+	TenantMonthlyTokenBudget int
+
+	// TenantTokenBudgets sets the monthly token budget of individual
+	// tenants, overriding TenantMonthlyTokenBudget. A zero entry exempts the
+	// tenant from the budget.
+	TenantTokenBudgets map[string]int
+
+	// RequireTenant rejects runs started without a tenant in their context
+	// with ErrTenantRequired, and lookups and listings of runs, sessions and
+	// checkpoints too. Without it such runs execute unscoped, sharing one
+	// conversation per agent, and such reads cover every tenant.
+	RequireTenant bool
+
+	// RunWorkers is the number of background workers executing runs
+	// submitted with Engine.SubmitRun. Workers are opt-in: zero, the
+	// default, starts none, and submitted runs wait for another process
+	// sharing the store.
+	RunWorkers int
+
+	// RunPollInterval is how often idle workers check the store for
+	// submitted runs, and how often Engine.WaitRun checks on a run executing
+	// in another process.
+	RunPollInterval time.Duration
+
+	// RecoveryPolicy decides what Engine.Start does with runs left in the
+	// running state by a process that exited mid-run.
+	RecoveryPolicy RecoveryPolicy
+
+	// OrphanedRunAge is how long a running or queued run must have gone
+	// without an update before Engine.Start treats it as orphaned. Runs
+	// executing in a process are touched every quarter of it, so runs of
+	// live replicas sharing the store are left alone. Zero treats every
+	// running or queued run as orphaned, which only suits a single process.
+	OrphanedRunAge time.Duration
 }
 
+// RecoveryPolicy is the action taken on orphaned runs at engine start.
+type RecoveryPolicy string
+
+const (
+	// RecoveryFail marks orphaned runs failed with ErrRunOrphaned.
+	RecoveryFail RecoveryPolicy = "fail"
+	// RecoveryResume continues orphaned runs from their last completed step.
+	// Runs that cannot be rebuilt are marked failed.
+	RecoveryResume RecoveryPolicy = "resume"
+	// RecoveryNone leaves orphaned runs untouched.
+	RecoveryNone RecoveryPolicy = "none"
+)
+
+// MaxStepsPolicy is the action taken when a run reaches its step limit
+// without a final answer.
+type MaxStepsPolicy string
+
+const (
+	// MaxStepsFail fails the run with ErrMaxStepsReached.
+	MaxStepsFail MaxStepsPolicy = "fail"
+	// MaxStepsSummarize makes one final call without tools, asking the model
+	// to answer with what it has, and completes the run with that answer.
+	MaxStepsSummarize MaxStepsPolicy = "summarize"
+	// MaxStepsCheckpoint pauses the run at a checkpoint asking whether to
+	// grant it more steps. Approving it continues the run for another
+	// MaxSteps steps; rejecting it fails the run with ErrMaxStepsReached.
+	MaxStepsCheckpoint MaxStepsPolicy = "checkpoint"
+)
+
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
 		DefaultModel:         "smart",
 		DefaultMaxSteps:      25,
+		MaxStepsPolicy:       MaxStepsFail,
+		ToolConcurrency:      4,
+		ToolFailureThreshold: 3,
+		OutputRepairAttempts: 2,
+		SummaryWindow:        20,
+		FactRecallLimit:      10,
+		DefaultContextLimit:  128000,
 		DefaultMaxTokens:     4096,
 		DefaultTemperature:   0.7,
 		DefaultReasoningLoop: "react",
 		ShutdownTimeout:      30 * time.Second,
 		RunConcurrency:       4,
+		RunPollInterval:      time.Second,
+		RecoveryPolicy:       RecoveryFail,
+		OrphanedRunAge:       time.Minute,
 	}
 }
@@ -649,13 +649,17 @@ func (c *Contributor) renderMemory(ctx context.Context, s store.Store, params co
 	agents, _ := fetchAgents(ctx, s, "") //nolint:errcheck // best-effort UI data
 	agentIDStr := params.QueryParams["agent"]
 	var messages []memory.Message
+	var summaries []string
+	var facts []memory.Fact
 	if agentIDStr != "" {
 		agID, parseErr := id.ParseAgentID(agentIDStr)
 		if parseErr == nil {
-			messages, _ = s.LoadConversation(ctx, agID, "", 100) //nolint:errcheck // best-effort UI data
+			messages, _ = s.LoadConversation(ctx, agID, "", id.Nil, 100) //nolint:errcheck // best-effort UI data
+			summaries, _ = s.LoadSummaries(ctx, agID, "", id.Nil)        //nolint:errcheck // best-effort UI data
+			facts, _ = s.ListFacts(ctx, agID, "")                        //nolint:errcheck // best-effort UI data
 		}
 	}
-	return pages.MemoryPage(agents, agentIDStr, messages), nil
+	return pages.MemoryPage(agents, agentIDStr, messages, summaries, facts), nil
 }
 
 // --- Widget Renderers ---
@@ -772,7 +776,7 @@ func (c *Contributor) renderChat(ctx context.Context, s store.Store, params cont
 	if selectedAgent != "" {
 		ag, err := s.GetByName(ctx, "", selectedAgent)
 		if err == nil {
-			messages, _ = s.LoadConversation(ctx, ag.ID, "", 100) //nolint:errcheck // best-effort UI data
+			messages, _ = s.LoadConversation(ctx, ag.ID, "", id.Nil, 100) //nolint:errcheck // best-effort UI data
 		}
 	}
 
@@ -800,9 +804,9 @@ func (c *Contributor) renderPlayground(ctx context.Context, s store.Store, param
 	}), nil
 }
 
-func (c *Contributor) renderPlaygroundPromptPreview(ctx context.Context, s store.Store, params contributor.Params) (templ.Component, error) {
+func (c *Contributor) renderPlaygroundPromptPreview(ctx context.Context, _ store.Store, params contributor.Params) (templ.Component, error) {
 	agentName := params.QueryParams["agent"]
-	prompt := computeSystemPrompt(ctx, s, agentName, params.QueryParams["persona"], params.QueryParams["skills"], params.QueryParams["traits"])
+	prompt := computeSystemPrompt(ctx, c.engine, agentName, params.QueryParams["persona"], params.QueryParams["skills"], params.QueryParams["traits"])
 	return pages.PlaygroundPromptPreview(prompt), nil
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/xraph/cortex/behavior"
 	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/dashboard/shared"
+	"github.com/xraph/cortex/engine"
 	"github.com/xraph/cortex/persona"
 	"github.com/xraph/cortex/run"
 	"github.com/xraph/cortex/skill"
@@ -466,66 +467,36 @@ func bytesContains(s, sub []byte) bool {
 
 // --- System Prompt Computation ---
 
-// computeSystemPrompt assembles the full system prompt from agent, persona, skills, and traits.
-func computeSystemPrompt(ctx context.Context, s store.Store, agentName, personaRef, skillsCSV, traitsCSV string) string {
-	var parts []string
-
-	// Agent base prompt.
+// computeSystemPrompt assembles the full system prompt for the playground
+// preview. It resolves the persona through the engine so the preview matches
+// what a run with the same persona, skills and traits would send.
+func computeSystemPrompt(ctx context.Context, eng *engine.Engine, agentName, personaRef, skillsCSV, traitsCSV string) string {
+	ag := &agent.Config{}
 	if agentName != "" {
-		ag, err := s.GetByName(ctx, "", agentName)
-		if err == nil && ag.SystemPrompt != "" {
-			parts = append(parts, ag.SystemPrompt)
-		}
-		// Use agent's persona ref if none explicitly provided.
-		if personaRef == "" && ag != nil {
-			personaRef = ag.PersonaRef
+		if found, err := eng.Store().GetByName(ctx, "", agentName); err == nil {
+			ag = found
 		}
 	}
 
-	// Persona identity.
-	if personaRef != "" {
-		p, err := s.GetPersonaByName(ctx, "", personaRef)
-		if err == nil && p.Identity != "" {
-			parts = append(parts, "\n## Identity\n"+p.Identity)
-		}
+	overrides := &engine.RunOverrides{
+		PersonaRef:   personaRef,
+		InlineSkills: splitCSV(skillsCSV),
+		InlineTraits: splitCSV(traitsCSV),
 	}
-
-	// Skill prompt fragments.
-	if skillsCSV != "" {
-		for _, sName := range strings.Split(skillsCSV, ",") {
-			sName = strings.TrimSpace(sName)
-			if sName == "" {
-				continue
-			}
-			sk, err := s.GetSkillByName(ctx, "", sName)
-			if err == nil && sk.SystemPromptFragment != "" {
-				parts = append(parts, "\n## Skill: "+sk.Name+"\n"+sk.SystemPromptFragment)
-			}
-		}
+	prompt := eng.BuildSystemPrompt(ctx, ag, overrides)
+	if prompt == "" {
+		return "(No system prompt configured)"
 	}
+	return prompt
+}
 
-	// Trait prompt injections.
-	if traitsCSV != "" {
-		for _, tName := range strings.Split(traitsCSV, ",") {
-			tName = strings.TrimSpace(tName)
-			if tName == "" {
-				continue
-			}
-			t, err := s.GetTraitByName(ctx, "", tName)
-			if err == nil {
-				for _, inf := range t.Influences {
-					if inf.Target == "prompt_injection" {
-						if v, ok := inf.Value.(string); ok && v != "" {
-							parts = append(parts, "\n## Trait: "+t.Name+"\n"+v)
-						}
-					}
-				}
-			}
+// splitCSV splits a comma-separated query value into trimmed, non-empty names.
+func splitCSV(csv string) []string {
+	var out []string
+	for _, v := range strings.Split(csv, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
 		}
 	}
-
-	if len(parts) == 0 {
-		return "(No system prompt configured)"
-	}
-	return strings.Join(parts, "\n")
+	return out
 }
@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/run"
+)
+
+// admission limits the number of runs executing at once, globally and per
+// tenant. Runs over the limits wait in a FIFO queue; a queued run is skipped
+// over only while its own tenant is at its limit.
+type admission struct {
+	limit       int
+	tenantLimit int
+
+	mu      sync.Mutex
+	running int
+	tenants map[string]int
+	queue   []*admissionTicket
+}
+
+// admissionTicket is a queued run waiting for a slot.
+type admissionTicket struct {
+	tenant   string
+	ready    chan struct{}
+	admitted bool
+}
+
+// newAdmission returns an admission controller. A limit of zero or less
+// means no limit.
+func newAdmission(limit, tenantLimit int) *admission {
+	return &admission{limit: limit, tenantLimit: tenantLimit, tenants: make(map[string]int)}
+}
+
+// fits reports whether a run of tenant can start now. a.mu must be held.
+func (a *admission) fits(tenant string) bool {
+	if a.limit > 0 && a.running >= a.limit {
+		return false
+	}
+	if a.tenantLimit > 0 && tenant != "" && a.tenants[tenant] >= a.tenantLimit {
+		return false
+	}
+	return true
+}
+
+// take occupies a slot for tenant. a.mu must be held.
+func (a *admission) take(tenant string) {
+	a.running++
+	if tenant != "" {
+		a.tenants[tenant]++
+	}
+}
+
+// releaser returns the func that frees the slot taken for tenant. Calling it
+// more than once has no further effect.
+func (a *admission) releaser(tenant string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.running--
+			if tenant != "" {
+				if a.tenants[tenant]--; a.tenants[tenant] <= 0 {
+					delete(a.tenants, tenant)
+				}
+			}
+			a.dispatch()
+		})
+	}
+}
+
+// dispatch admits queued runs in order while they fit. a.mu must be held.
+func (a *admission) dispatch() {
+	for i := 0; i < len(a.queue); {
+		t := a.queue[i]
+		if !a.fits(t.tenant) {
+			i++
+			continue
+		}
+		a.take(t.tenant)
+		t.admitted = true
+		close(t.ready)
+		a.queue = slices.Delete(a.queue, i, i+1)
+	}
+}
+
+// tryAcquire takes a slot for tenant if one is free.
+func (a *admission) tryAcquire(tenant string) (release func(), ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.fits(tenant) {
+		return nil, false
+	}
+	a.take(tenant)
+	return a.releaser(tenant), true
+}
+
+// enqueue adds a run of tenant to the queue and returns its ticket and the
+// queue depth.
+func (a *admission) enqueue(tenant string) (*admissionTicket, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t := &admissionTicket{tenant: tenant, ready: make(chan struct{})}
+	a.queue = append(a.queue, t)
+	a.dispatch()
+	return t, len(a.queue)
+}
+
+// wait blocks until t is admitted or ctx is done. A run that gives up is
+// removed from the queue.
+func (a *admission) wait(ctx context.Context, t *admissionTicket) (release func(), err error) {
+	select {
+	case <-t.ready:
+		return a.releaser(t.tenant), nil
+	case <-ctx.Done():
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t.admitted {
+		return a.releaser(t.tenant), nil
+	}
+	a.queue = slices.DeleteFunc(a.queue, func(q *admissionTicket) bool { return q == t })
+	return nil, ctx.Err()
+}
+
+// depth returns the number of queued runs.
+func (a *admission) depth() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.queue)
+}
+
+// admitRun obtains an execution slot for r, queueing it when the engine is
+// at its concurrency limits. On failure the run has been recorded as failed
+// or cancelled.
+func (e *Engine) admitRun(ctx context.Context, r *run.Run) (release func(), err error) {
+	if release, ok := e.admission.tryAcquire(r.TenantID); ok {
+		if r.State == run.StateQueued {
+			e.markAdmitted(ctx, r)
+		}
+		return release, nil
+	}
+	return e.awaitAdmission(ctx, r)
+}
+
+// awaitAdmission moves r to the queued state and waits for a slot, up to
+// Config.RunQueueTimeout. Once admitted the run is moved back to running. A
+// run whose context is done while queued is recorded as cancelled and
+// cortex.ErrRunCancelled is returned; one that times out is failed with
+// cortex.ErrRunQueueTimeout.
+func (e *Engine) awaitAdmission(ctx context.Context, r *run.Run) (func(), error) {
+	if r.State != run.StateQueued {
+		r.State = run.StateQueued
+		if err := e.store.UpdateRun(ctx, r); err != nil {
+			e.logger.Error("update run on queue", log.String("error", err.Error()))
+		}
+	}
+	t, depth := e.admission.enqueue(r.TenantID)
+	e.extensions.EmitRunQueued(ctx, r.AgentID, r.ID, depth)
+
+	waitCtx := ctx
+	if timeout := e.config.RunQueueTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	queuedAt := time.Now()
+	release, err := e.admission.wait(waitCtx, t)
+	switch {
+	case err == nil && e.cancelledInStore(ctx, r):
+		release()
+		e.recordCancelled(ctx, r, "")
+		return nil, cortex.ErrRunCancelled
+	case err == nil:
+	case ctx.Err() != nil:
+		e.recordCancelled(ctx, r, "")
+		return nil, cortex.ErrRunCancelled
+	default:
+		err = fmt.Errorf("%w after %s", cortex.ErrRunQueueTimeout, e.config.RunQueueTimeout)
+		e.failRun(ctx, r, r.AgentID, err)
+		return nil, err
+	}
+
+	e.markAdmitted(ctx, r)
+	e.extensions.EmitRunAdmitted(ctx, r.AgentID, r.ID, time.Since(queuedAt), e.admission.depth())
+	return release, nil
+}
+
+// markAdmitted moves a queued run to running.
+func (e *Engine) markAdmitted(ctx context.Context, r *run.Run) {
+	r.State = run.StateRunning
+	if r.StartedAt == nil {
+		now := time.Now().UTC()
+		r.StartedAt = &now
+	}
+	if err := e.store.UpdateRun(ctx, r); err != nil {
+		e.logger.Error("update run on admission", log.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+func TestAdmission_LimitsGloballyAndPerTenant(t *testing.T) {
+	a := newAdmission(3, 1)
+
+	releaseA, ok := a.tryAcquire("a")
+	if !ok {
+		t.Fatal("first run of tenant a not admitted")
+	}
+	if _, ok := a.tryAcquire("a"); ok {
+		t.Fatal("second run of tenant a admitted over the tenant limit")
+	}
+	if _, ok := a.tryAcquire("b"); !ok {
+		t.Fatal("run of tenant b blocked by tenant a")
+	}
+
+	// Tenant a's queued run must not hold up tenant c.
+	queuedA, depth := a.enqueue("a")
+	if depth != 1 {
+		t.Fatalf("depth = %d, want 1", depth)
+	}
+	if _, ok := a.tryAcquire("c"); !ok {
+		t.Fatal("run of tenant c blocked by tenant a's queued run")
+	}
+	if _, ok := a.tryAcquire("d"); ok {
+		t.Fatal("run admitted over the global limit")
+	}
+
+	releaseA()
+	release, err := a.wait(context.Background(), queuedA)
+	if err != nil {
+		t.Fatalf("queued run not admitted after release: %v", err)
+	}
+	release()
+	release() // idempotent
+	if a.running != 2 || a.depth() != 0 {
+		t.Errorf("running = %d, depth = %d; want 2 and 0", a.running, a.depth())
+	}
+
+	if _, ok := a.tryAcquire("e"); !ok {
+		t.Fatal("run not admitted under the global limit")
+	}
+	queuedD, _ := a.enqueue("d")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.wait(ctx, queuedD); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait err = %v, want DeadlineExceeded", err)
+	}
+	if a.depth() != 0 {
+		t.Errorf("timed out run left in the queue")
+	}
+}
+
+// queueRecorder is an extension recording RunQueued and RunAdmitted hooks.
+type queueRecorder struct {
+	mu     sync.Mutex
+	depths []int
+	waits  []time.Duration
+}
+
+func (q *queueRecorder) Name() string { return "queue-recorder" }
+
+func (q *queueRecorder) OnRunQueued(_ context.Context, _ id.AgentID, _ id.AgentRunID, queueDepth int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.depths = append(q.depths, queueDepth)
+	return nil
+}
+
+func (q *queueRecorder) OnRunAdmitted(_ context.Context, _ id.AgentID, _ id.AgentRunID, waited time.Duration, _ int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.waits = append(q.waits, waited)
+	return nil
+}
+
+func TestRunAgent_QueuesRunsOverConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	tool := newBlockingTool()
+	client := &scriptedLLM{responses: []*llm.Response{waitStep()}}
+	rec := &queueRecorder{}
+	cfg := cortex.DefaultConfig()
+	cfg.RunConcurrency = 1
+	cfg.RunQueueTimeout = 0
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg), WithTool(llm.Tool{Name: "wait"}, tool.handle), WithExtension(rec))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	first, _ := startBlockedRun(t, e, s, tool)
+
+	second := make(chan *run.Run, 1)
+	go func() {
+		r, err := e.RunAgent(ctx, "app1", "worker", "more work", nil)
+		if err != nil {
+			t.Errorf("RunAgent: %v", err)
+		}
+		second <- r
+	}()
+	var queued []*run.Run
+	for len(queued) == 0 {
+		time.Sleep(time.Millisecond)
+		if queued, err = s.ListRuns(ctx, &run.ListFilter{State: run.StateQueued}); err != nil {
+			t.Fatalf("ListRuns: %v", err)
+		}
+	}
+	if queued[0].Input != "more work" || queued[0].StartedAt != nil {
+		t.Errorf("queued run = %+v", queued[0])
+	}
+
+	close(tool.release)
+	if r := <-first; r.State != run.StateCompleted {
+		t.Fatalf("first run = %s, want completed", r.State)
+	}
+	r := <-second
+	if r.State != run.StateCompleted || r.StartedAt == nil {
+		t.Fatalf("second run = %+v, want completed after admission", r)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.depths) != 1 || rec.depths[0] != 1 || len(rec.waits) != 1 || rec.waits[0] <= 0 {
+		t.Errorf("queue hooks: depths = %v, waits = %v", rec.depths, rec.waits)
+	}
+}
+
+func TestRunAgent_FailsRunAfterQueueTimeout(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	cfg := cortex.DefaultConfig()
+	cfg.RunConcurrency = 1
+	cfg.RunQueueTimeout = 20 * time.Millisecond
+	e, err := New(WithStore(s), WithLLM(&scriptedLLM{}), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	release, ok := e.admission.tryAcquire("")
+	if !ok {
+		t.Fatal("could not occupy the only slot")
+	}
+	defer release()
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "work", nil); !errors.Is(err, cortex.ErrRunQueueTimeout) {
+		t.Fatalf("RunAgent err = %v, want ErrRunQueueTimeout", err)
+	}
+	runs, err := s.ListRuns(ctx, &run.ListFilter{State: run.StateFailed})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("failed runs = %d, %v; want 1", len(runs), err)
+	}
+	if e.admission.depth() != 0 {
+		t.Errorf("timed out run left in the queue")
+	}
+}
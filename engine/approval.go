@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/checkpoint"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// approvalGuardrail is the agent Guardrails key listing tools whose calls
+// need human approval. The value is a tool name or a list of names; "*"
+// matches every tool.
+const approvalGuardrail = "require_approval"
+
+// requiresApproval reports whether a call to the named tool must be approved
+// before it runs: the tool was registered with RequireApproval, or the
+// agent's Guardrails list it under "require_approval".
+func (e *Engine) requiresApproval(ag *agent.Config, name string) bool {
+	for _, rt := range e.tools {
+		if rt.def.Name == name {
+			if rt.requireApproval {
+				return true
+			}
+			break
+		}
+	}
+	for _, n := range toStrings(ag.Guardrails[approvalGuardrail]) {
+		if n == name || n == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// pauseForApproval creates a checkpoint for the first of calls and moves the
+// run to paused, persisting the loop state so ResolveCheckpoint can resume it.
+func (e *Engine) pauseForApproval(ctx context.Context, rr *reactRun, stepID id.StepID, stepIndex int, calls []llm.ToolCall) error {
+	r, tc := rr.r, calls[0]
+	cp := &checkpoint.Checkpoint{
+		Entity:    cortex.NewEntity(),
+		ID:        id.NewCheckpointID(),
+		RunID:     r.ID,
+		AgentID:   rr.ag.ID,
+		TenantID:  r.TenantID,
+		Reason:    fmt.Sprintf("tool %q requires approval", tc.Name),
+		StepIndex: stepIndex,
+		State:     checkpoint.StatePending,
+		Metadata: map[string]any{
+			"tool_name":    tc.Name,
+			"arguments":    tc.Arguments,
+			"tool_call_id": tc.ID,
+		},
+	}
+	if err := e.store.CreateCheckpoint(ctx, cp); err != nil {
+		err = fmt.Errorf("create checkpoint: %w", err)
+		e.failRun(ctx, r, rr.ag.ID, err)
+		return err
+	}
+
+	rr.st.Pending = calls
+	rr.st.PendingStep = stepID
+	if err := rr.saveState(); err != nil {
+		e.failRun(ctx, r, rr.ag.ID, err)
+		return err
+	}
+	r.State = run.StatePaused
+	r.StepCount = rr.st.Step
+	r.TokensUsed = rr.st.TotalTokens
+	if err := e.store.UpdateRun(ctx, r); err != nil {
+		e.logger.Error("update run on pause", log.String("error", err.Error()))
+	}
+
+	e.extensions.EmitCheckpointCreated(ctx, cp.ID, r.ID, cp.Reason)
+	if rr.events != nil {
+		rr.events <- StreamEvent{Type: EventCheckpoint, Data: map[string]any{
+			"checkpoint_id": cp.ID.String(),
+			"run_id":        r.ID.String(),
+			"tool_name":     tc.Name,
+			"arguments":     tc.Arguments,
+			"reason":        cp.Reason,
+		}}
+	}
+	return nil
+}
+
+// ResolveCheckpoint records a decision on a pending checkpoint and resumes
+// the run it paused from its persisted messages. When approved, the tool call
+// runs; when rejected, the rejection and its reason are fed back to the model
+// as the tool result. A checkpoint created because the run reached its step
+// limit grants the run more steps when approved and fails it when rejected.
+//
+// ResolveCheckpoint returns once the decision is recorded. The run continues
+// in the background, detached from ctx, until it completes or pauses again;
+// WaitRun follows it, CancelRun stops it and Stop waits for it. Resolving a
+// checkpoint that is no longer pending returns cortex.ErrInvalidState; one
+// of another tenant than that of ctx, cortex.ErrCheckpointNotFound.
+func (e *Engine) ResolveCheckpoint(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) error {
+	if e.store == nil {
+		return cortex.ErrNoStore
+	}
+	cp, err := e.store.GetCheckpoint(ctx, cpID)
+	if err != nil {
+		return err
+	}
+	if err := e.checkRecordTenant(ctx, cp.TenantID, cortex.ErrCheckpointNotFound); err != nil {
+		return err
+	}
+	if cp.State != checkpoint.StatePending {
+		return fmt.Errorf("%w: checkpoint %s is %s", cortex.ErrInvalidState, cpID, cp.State)
+	}
+	if decision.DecidedAt.IsZero() {
+		decision.DecidedAt = time.Now().UTC()
+	}
+	// Resolve only claims a checkpoint that is still pending, so of several
+	// concurrent callers exactly one goes on to resume the run.
+	claimed, err := e.store.Resolve(ctx, cpID, decision)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("%w: checkpoint %s is no longer pending", cortex.ErrInvalidState, cpID)
+	}
+	e.extensions.EmitCheckpointResolved(ctx, cpID, decisionLabel(decision))
+
+	// The run is tracked before returning, so that a WaitRun or CancelRun
+	// following the decision finds it executing.
+	ctx, untrack := e.trackRun(context.WithoutCancel(ctx), cp.RunID)
+	e.background.Go(func() {
+		defer untrack()
+		if _, err := e.resumeRun(ctx, cp, decision); err != nil {
+			e.logger.Warn("resumed run failed", log.String("run_id", cp.RunID.String()), log.String("error", err.Error()))
+		}
+	})
+	return nil
+}
+
+// resumeRun continues the run paused at cp under ctx, which the caller has
+// registered with trackRun. Runs that are not paused or carry no persisted
+// state are left untouched; one that cannot be resumed is failed, since its
+// checkpoint is already resolved.
+func (e *Engine) resumeRun(ctx context.Context, cp *checkpoint.Checkpoint, decision checkpoint.Decision) (*run.Run, error) {
+	r, err := e.store.GetRun(ctx, cp.RunID)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := loadRunState(r)
+	maxSteps := isMaxStepsCheckpoint(cp)
+	if r.State != run.StatePaused || !ok || (len(st.Pending) == 0 && !maxSteps) {
+		return r, nil
+	}
+	if ctx.Err() != nil {
+		_, err := e.cancelPausedRun(ctx, r)
+		return r, err
+	}
+	if e.llm == nil {
+		err := fmt.Errorf("cortex: no LLM client configured")
+		e.failRun(ctx, r, r.AgentID, err)
+		return r, err
+	}
+	ctx = withRunTenant(ctx, r)
+	ag, err := e.store.Get(ctx, r.AgentID)
+	if err != nil {
+		err = fmt.Errorf("resolve agent: %w", err)
+		e.failRun(ctx, r, r.AgentID, err)
+		return r, err
+	}
+
+	// The run is only resumed while it is still paused: one cancelled
+	// meanwhile stays cancelled. The stored state keeps the pending calls
+	// until they have run, so a crash in between is not mistaken for a
+	// resumable step boundary.
+	resumed, err := e.store.TransitionRun(ctx, r.ID, run.StatePaused, run.StateRunning)
+	if err != nil {
+		return r, fmt.Errorf("resume run: %w", err)
+	}
+	if !resumed {
+		return r, nil
+	}
+	r.State = run.StateRunning
+
+	release, err := e.admitRun(ctx, r)
+	if errors.Is(err, cortex.ErrRunCancelled) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	defer release()
+
+	rr := e.newReactRun(ctx, ag, st.Overrides)
+	rr.r = r
+	rr.restoreState(st)
+	if maxSteps {
+		return e.resumeSteps(ctx, rr, decision)
+	}
+	pending, stepID := st.Pending, st.PendingStep
+	rr.st.Pending, rr.st.PendingStep = nil, id.StepID{}
+
+	if decision.Approved {
+		e.runToolCall(ctx, rr, stepID, pending[0])
+	} else {
+		e.rejectToolCall(ctx, rr, stepID, pending[0], decision)
+	}
+	if paused, err := e.runToolCalls(ctx, rr, stepID, cp.StepIndex, pending[1:]); paused || err != nil {
+		return r, err
+	}
+	e.emitPhaseChange(ctx, r.ID, rr.cog.afterStep(pending, len(rr.toolErrors) > 0))
+	e.persistRunState(ctx, rr)
+	return e.reactLoop(ctx, rr)
+}
+
+// rejectToolCall records a rejected tool call and feeds the rejection back
+// to the model as its result.
+func (e *Engine) rejectToolCall(ctx context.Context, rr *reactRun, stepID id.StepID, tc llm.ToolCall, decision checkpoint.Decision) {
+	msg := fmt.Sprintf("the call to tool %q was rejected by a human reviewer", tc.Name)
+	if decision.DecidedBy != "" {
+		msg = fmt.Sprintf("the call to tool %q was rejected by %s", tc.Name, decision.DecidedBy)
+	}
+	if decision.Reason != "" {
+		msg += ": " + decision.Reason
+	}
+	e.recordToolCall(ctx, rr, stepID, tc, toolResult{
+		result:  jsonResult("error", msg),
+		err:     fmt.Errorf("rejected: %s", msg),
+		started: time.Now().UTC(),
+	})
+}
+
+// decisionLabel returns "approved" or "rejected" for a decision.
+func decisionLabel(d checkpoint.Decision) string {
+	if d.Approved {
+		return "approved"
+	}
+	return "rejected"
+}
@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/checkpoint"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/store/sqlite"
+)
+
+// scriptedLLM is an llm.Client that replays a fixed list of responses and
+// records the requests it receives. Once the script is exhausted it answers
+// with "done".
+type scriptedLLM struct {
+	mu        sync.Mutex
+	responses []*llm.Response
+	requests  []*llm.Request
+}
+
+func (s *scriptedLLM) Complete(_ context.Context, req *llm.Request) (*llm.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	if len(s.responses) == 0 {
+		return &llm.Response{Content: "done", Usage: llm.Usage{TotalTokens: 1}}, nil
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func (s *scriptedLLM) CompleteStream(context.Context, *llm.Request) (llm.Stream, error) {
+	return nil, errors.New("scriptedLLM: streaming not supported")
+}
+
+// lastRequest returns the most recent request.
+func (s *scriptedLLM) lastRequest() *llm.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+// toolCallResponse returns a response requesting a single tool call.
+func toolCallResponse(callID, name, args string) *llm.Response {
+	return &llm.Response{
+		ToolCalls: []llm.ToolCall{{ID: callID, Name: name, Arguments: args}},
+		Usage:     llm.Usage{TotalTokens: 1},
+	}
+}
+
+// newApprovalEngine returns an engine with a "deploy" tool that counts its
+// calls, and an agent allowed to use it.
+func newApprovalEngine(t *testing.T, client llm.Client, guardrails map[string]any, opts ...ToolOption) (*Engine, *sqlite.Store, *int) {
+	t.Helper()
+	s := newTestStore(t)
+	calls := new(int)
+	deploy := func(context.Context, string) (string, error) {
+		*calls++
+		return "deployed", nil
+	}
+	e, err := New(WithStore(s), WithLLM(client), WithTool(llm.Tool{Name: "deploy"}, deploy, opts...))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(context.Background(), &agent.Config{
+		ID: id.NewAgentID(), Name: "ops", AppID: "app1", Tools: []string{"deploy"}, Guardrails: guardrails,
+	}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	return e, s, calls
+}
+
+// pendingCheckpoint returns the single pending checkpoint of the run.
+func pendingCheckpoint(t *testing.T, s *sqlite.Store, runID id.AgentRunID) *checkpoint.Checkpoint {
+	t.Helper()
+	cps, err := s.ListPending(context.Background(), &checkpoint.ListFilter{RunID: runID.String()})
+	if err != nil || len(cps) != 1 {
+		t.Fatalf("ListPending = %d checkpoints, %v; want 1", len(cps), err)
+	}
+	return cps[0]
+}
+
+// resolveAndWait resolves a checkpoint and waits for the run it resumes to
+// settle.
+func resolveAndWait(t *testing.T, e *Engine, cp *checkpoint.Checkpoint, decision checkpoint.Decision) *run.Run {
+	t.Helper()
+	ctx := context.Background()
+	if err := e.ResolveCheckpoint(ctx, cp.ID, decision); err != nil {
+		t.Fatalf("ResolveCheckpoint: %v", err)
+	}
+	r, err := e.WaitRun(ctx, cp.RunID)
+	if err != nil {
+		t.Fatalf("WaitRun: %v", err)
+	}
+	return r
+}
+
+func TestApproval_PausesAndResumesOnApproval(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{"env":"prod"}`)}}
+	e, s, calls := newApprovalEngine(t, client, map[string]any{"require_approval": []any{"deploy"}})
+
+	r, err := e.RunAgent(ctx, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.State != run.StatePaused || *calls != 0 {
+		t.Fatalf("state = %s, tool calls = %d; want paused before the tool runs", r.State, *calls)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+	if cp.Metadata["tool_name"] != "deploy" || cp.Metadata["arguments"] != `{"env":"prod"}` {
+		t.Errorf("checkpoint metadata = %v", cp.Metadata)
+	}
+	// The loop state is persisted with the run but never serialized.
+	paused, err := e.GetRun(ctx, r.ID)
+	if err != nil || len(paused.LoopState) == 0 {
+		t.Fatalf("paused run = %+v, %v; want its loop state stored", paused, err)
+	}
+	if data, err := json.Marshal(paused); err != nil || strings.Contains(string(data), `"messages"`) {
+		t.Errorf("paused run JSON exposes its loop state: %s", data)
+	}
+
+	got := resolveAndWait(t, e, cp, checkpoint.Decision{Approved: true, DecidedBy: "alice"})
+	if *calls != 1 {
+		t.Fatalf("tool calls after approval = %d, want 1", *calls)
+	}
+	if got.State != run.StateCompleted || got.Output != "done" || got.StepCount != 2 {
+		t.Errorf("run = %s %q after %d steps, want completed \"done\" after 2", got.State, got.Output, got.StepCount)
+	}
+	if len(got.LoopState) != 0 {
+		t.Errorf("loop state left in the run after completion")
+	}
+	msgs := client.lastRequest().Messages
+	if last := msgs[len(msgs)-1]; last.Role != "tool" || last.Content != "deployed" || last.ToolCallID != "call-1" {
+		t.Errorf("last message = %+v, want the deploy result", last)
+	}
+
+	err = e.ResolveCheckpoint(ctx, cp.ID, checkpoint.Decision{Approved: true})
+	if !errors.Is(err, cortex.ErrInvalidState) {
+		t.Errorf("second ResolveCheckpoint err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestApproval_RejectionIsFedBackToModel(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, calls := newApprovalEngine(t, client, nil, RequireApproval())
+
+	r, err := e.RunAgent(ctx, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+
+	got := resolveAndWait(t, e, cp, checkpoint.Decision{Approved: false, DecidedBy: "bob", Reason: "change freeze"})
+	if *calls != 0 {
+		t.Fatalf("rejected tool ran %d times", *calls)
+	}
+	msgs := client.lastRequest().Messages
+	last := msgs[len(msgs)-1]
+	if last.Role != "tool" || !strings.Contains(last.Content, "rejected by bob: change freeze") {
+		t.Errorf("last message = %+v, want the rejection reason", last)
+	}
+
+	if got.State != run.StateCompleted {
+		t.Fatalf("run = %+v, want completed", got)
+	}
+	resolved, err := s.GetCheckpoint(ctx, cp.ID)
+	if err != nil || resolved.State != checkpoint.StateResolved || resolved.Decision.Approved {
+		t.Errorf("checkpoint = %+v, %v; want resolved and rejected", resolved, err)
+	}
+}
+
+func TestApproval_FailsRunThatCannotResume(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, _ := newApprovalEngine(t, client, nil, RequireApproval())
+
+	r, err := e.RunAgent(ctx, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if err := s.Delete(ctx, r.AgentID); err != nil {
+		t.Fatalf("delete agent: %v", err)
+	}
+
+	got := resolveAndWait(t, e, pendingCheckpoint(t, s, r.ID), checkpoint.Decision{Approved: true})
+	if got.State != run.StateFailed || !strings.Contains(got.Error, "resolve agent") {
+		t.Errorf("run = %s %q, want failed instead of left paused", got.State, got.Error)
+	}
+}
+
+func TestApproval_CancelExpiresCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, calls := newApprovalEngine(t, client, nil, RequireApproval())
+
+	r, err := e.RunAgent(ctx, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+	if err := e.CancelRun(ctx, r.ID); err != nil {
+		t.Fatalf("CancelRun: %v", err)
+	}
+
+	if got, err := s.GetCheckpoint(ctx, cp.ID); err != nil || got.State != checkpoint.StateExpired {
+		t.Fatalf("checkpoint = %+v, %v; want expired", got, err)
+	}
+	err = e.ResolveCheckpoint(ctx, cp.ID, checkpoint.Decision{Approved: true})
+	if !errors.Is(err, cortex.ErrInvalidState) {
+		t.Errorf("ResolveCheckpoint err = %v, want ErrInvalidState", err)
+	}
+	if got, err := e.GetRun(ctx, r.ID); err != nil || got.State != run.StateCancelled || *calls != 0 {
+		t.Errorf("run = %+v, %v after %d tool calls; want cancelled without calls", got, err, *calls)
+	}
+}
+
+// cancelOnReadStore cancels a paused run in the store right after it has
+// been read, as a concurrent CancelRun would.
+type cancelOnReadStore struct {
+	*sqlite.Store
+}
+
+func (s cancelOnReadStore) GetRun(ctx context.Context, runID id.AgentRunID) (*run.Run, error) {
+	r, err := s.Store.GetRun(ctx, runID)
+	if err == nil {
+		_, err = s.TransitionRun(ctx, runID, run.StatePaused, run.StateCancelled)
+	}
+	return r, err
+}
+
+func TestApproval_ResumeLeavesCancelledRun(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, calls := newApprovalEngine(t, client, nil, RequireApproval())
+
+	r, err := e.RunAgent(ctx, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+	e.store = cancelOnReadStore{s}
+
+	if _, err := e.resumeRun(ctx, cp, checkpoint.Decision{Approved: true}); err != nil {
+		t.Fatalf("resumeRun: %v", err)
+	}
+	if got, err := s.GetRun(ctx, r.ID); err != nil || got.State != run.StateCancelled || *calls != 0 {
+		t.Errorf("run = %+v, %v after %d tool calls; want left cancelled", got, err, *calls)
+	}
+}
@@ -0,0 +1,366 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+)
+
+// behaviorSignals are the per-step observations behavior triggers match against.
+type behaviorSignals struct {
+	// Step is the zero-based index of the step about to run.
+	Step int
+	// Input is the user input of the run.
+	Input string
+	// ToolResults holds the results of the tool calls made in the previous step.
+	ToolResults []string
+	// Errors holds tool errors raised in the previous step.
+	Errors []string
+	// Context is the concatenated conversation sent to the model.
+	Context string
+}
+
+// behaviorEffects is the merged outcome of all behaviors that fired for a step.
+type behaviorEffects struct {
+	// Triggered lists the names of the behaviors that fired, in priority order.
+	Triggered []string
+	// Prompt holds system prompt sections contributed by the fired behaviors.
+	Prompt []string
+	// Params holds request parameter overrides from modify_param actions.
+	Params map[string]any
+	// Cognitive is the strategy requested by a switch_cognitive action.
+	Cognitive string
+	// RequiredTools lists the tools require_tool actions require to be
+	// called before the final answer.
+	RequiredTools []string
+}
+
+// behaviorEvaluator evaluates an agent's behaviors at each step of a run.
+type behaviorEvaluator struct {
+	behaviors []*behavior.Behavior
+	patterns  map[string]*regexp.Regexp // compiled trigger patterns, by pattern
+	fired     map[string]bool           // on_step_count behaviors that already fired
+}
+
+// newBehaviorEvaluator returns an evaluator for the resolved persona's
+// behaviors, which are already filtered by their requirements and ordered by
+// Priority. Trigger patterns are compiled once here: a behavior with a
+// pattern that does not compile is left out and reported in the returned
+// error.
+func newBehaviorEvaluator(rp *ResolvedPersona) (*behaviorEvaluator, error) {
+	ev := &behaviorEvaluator{
+		patterns: make(map[string]*regexp.Regexp),
+		fired:    make(map[string]bool),
+	}
+	if rp == nil {
+		return ev, nil
+	}
+	var errs []error
+	for _, b := range rp.Behaviors {
+		patterns, err := compileTriggers(b)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		maps.Copy(ev.patterns, patterns)
+		ev.behaviors = append(ev.behaviors, b)
+	}
+	return ev, errors.Join(errs...)
+}
+
+// compileTriggers returns the compiled patterns of b's on_input,
+// on_tool_result, on_error and on_context triggers, keyed by pattern. A
+// pattern that does not compile returns cortex.ErrBehaviorInvalid.
+func compileTriggers(b *behavior.Behavior) (map[string]*regexp.Regexp, error) {
+	patterns := make(map[string]*regexp.Regexp)
+	for _, t := range b.Triggers {
+		switch t.Type {
+		case behavior.TriggerOnInput, behavior.TriggerOnToolResult, behavior.TriggerOnError, behavior.TriggerOnContext:
+		default:
+			continue
+		}
+		if t.Pattern == "" || patterns[t.Pattern] != nil {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + t.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: behavior %q: invalid %s pattern %q: %v", cortex.ErrBehaviorInvalid, b.Name, t.Type, t.Pattern, err)
+		}
+		patterns[t.Pattern] = re
+	}
+	return patterns, nil
+}
+
+// firedNames returns the on_step_count behaviors that already fired, to
+// persist with the run.
+func (ev *behaviorEvaluator) firedNames() []string {
+	if ev == nil || len(ev.fired) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(ev.fired))
+	for name := range ev.fired {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// restore marks persisted on_step_count behaviors as fired, so a resumed
+// run does not fire them again.
+func (ev *behaviorEvaluator) restore(names []string) {
+	if ev == nil {
+		return
+	}
+	for _, name := range names {
+		ev.fired[name] = true
+	}
+}
+
+// evaluate checks every behavior's triggers against sig and merges the actions
+// of those that fire. Behaviors are visited in priority order, so for
+// conflicting parameter or cognitive changes the highest priority wins.
+func (ev *behaviorEvaluator) evaluate(sig behaviorSignals) behaviorEffects {
+	var fx behaviorEffects
+	if ev == nil {
+		return fx
+	}
+	for _, b := range ev.behaviors {
+		if !ev.triggered(b, sig) {
+			continue
+		}
+		fx.Triggered = append(fx.Triggered, b.Name)
+		for _, a := range b.Actions {
+			fx.apply(b.Name, a)
+		}
+	}
+	return fx
+}
+
+// triggered reports whether any of b's triggers match sig.
+func (ev *behaviorEvaluator) triggered(b *behavior.Behavior, sig behaviorSignals) bool {
+	for _, t := range b.Triggers {
+		switch t.Type {
+		case behavior.TriggerAlways:
+			return true
+		case behavior.TriggerOnInput:
+			if sig.Step == 0 && ev.match(t.Pattern, sig.Input) {
+				return true
+			}
+		case behavior.TriggerOnToolResult:
+			for _, res := range sig.ToolResults {
+				if ev.match(t.Pattern, res) {
+					return true
+				}
+			}
+		case behavior.TriggerOnError:
+			for _, msg := range sig.Errors {
+				if ev.match(t.Pattern, msg) {
+					return true
+				}
+			}
+		case behavior.TriggerOnStepCount:
+			n, err := strconv.Atoi(strings.TrimSpace(t.Pattern))
+			if err != nil || ev.fired[b.Name] {
+				continue
+			}
+			if sig.Step >= n {
+				ev.fired[b.Name] = true
+				return true
+			}
+		case behavior.TriggerOnContext:
+			if t.Pattern != "" && ev.match(t.Pattern, sig.Context) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apply merges a single action into the effects.
+func (fx *behaviorEffects) apply(name string, a behavior.Action) {
+	switch a.Type {
+	case behavior.ActionInjectPrompt:
+		if v, ok := a.Value.(string); ok && v != "" {
+			fx.Prompt = append(fx.Prompt, "\n## Behavior: "+name+"\n"+v)
+		}
+	case behavior.ActionPreferSkill:
+		if a.Target != "" {
+			fx.Prompt = append(fx.Prompt, "\n## Behavior: "+name+"\nPrefer applying your "+a.Target+" skill for this step.")
+		}
+	case behavior.ActionRequireTool:
+		if a.Target != "" {
+			fx.Prompt = append(fx.Prompt, "\n## Behavior: "+name+"\nYou must call the "+a.Target+" tool before giving a final answer.")
+			fx.RequiredTools = append(fx.RequiredTools, a.Target)
+		}
+	case behavior.ActionModifyParam:
+		if a.Target == "" {
+			return
+		}
+		if fx.Params == nil {
+			fx.Params = make(map[string]any)
+		}
+		if _, set := fx.Params[a.Target]; !set {
+			fx.Params[a.Target] = a.Value
+		}
+	case behavior.ActionSwitchCognitive:
+		strategy := a.Target
+		if v, ok := a.Value.(string); ok && strategy == "" {
+			strategy = v
+		}
+		if fx.Cognitive == "" {
+			fx.Cognitive = strategy
+		}
+	case behavior.ActionAddGuardrail:
+		rule := a.Target
+		if v, ok := a.Value.(string); ok && v != "" {
+			rule = v
+		}
+		if rule != "" {
+			fx.Prompt = append(fx.Prompt, "\n## Guardrail ("+name+")\n"+rule)
+		}
+	}
+}
+
+// applyTo applies the effects to an outgoing request: prompt sections are
+// appended to the system prompt and modify_param values override temperature,
+// max_tokens and model. Cognitive is applied by the cognitive engine.
+func (fx behaviorEffects) applyTo(req *llm.Request) {
+	if len(fx.Prompt) > 0 {
+		req.System = strings.TrimLeft(req.System+"\n"+strings.Join(fx.Prompt, "\n"), "\n")
+	}
+	for param, v := range fx.Params {
+		switch param {
+		case "temperature":
+			if f, ok := toFloat(v); ok {
+				req.Temperature = &f
+			}
+		case "max_tokens":
+			if f, ok := toFloat(v); ok && f > 0 {
+				req.MaxTokens = int(f)
+			}
+		case "model":
+			if s, ok := v.(string); ok && s != "" {
+				req.Model = s
+			}
+		}
+	}
+}
+
+// metadata returns the step metadata entry describing the fired behaviors.
+func (fx behaviorEffects) metadata() map[string]any {
+	if len(fx.Triggered) == 0 {
+		return nil
+	}
+	return map[string]any{"behaviors": fx.Triggered}
+}
+
+// checkRequiredTools sends a final answer back to the model while a tool
+// required by a require_tool action has not been called during the run,
+// and reports whether it did. Tools the run does not offer, because they
+// are out of scope or disabled, cannot be called and are not waited for.
+// The answer and the reminder are kept out of conversation memory; the
+// step limit bounds the reminders.
+func (e *Engine) checkRequiredTools(ctx context.Context, rr *reactRun, answer string) bool {
+	var missing []string
+	for _, t := range e.offeredTools(rr) {
+		if slices.Contains(rr.st.RequiredTools, t.Name) && !rr.st.called(t.Name) {
+			missing = append(missing, t.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return false
+	}
+	n := len(rr.st.Messages)
+	rr.st.Unsaved = append(rr.st.Unsaved, n, n+1)
+	rr.st.Messages = append(rr.st.Messages,
+		llm.Message{Role: "assistant", Content: answer},
+		llm.Message{Role: "user", Content: requiredToolsPrompt(missing)},
+	)
+	e.persistRunState(ctx, rr)
+	return true
+}
+
+// requiredToolsPrompt asks the model to call the required tools it skipped
+// before answering.
+func requiredToolsPrompt(tools []string) string {
+	return "Before giving your final answer you must call these tools: " + strings.Join(tools, ", ") +
+		". Call them now, then answer again."
+}
+
+// emitBehaviors fires the BehaviorTriggered hook for each fired behavior.
+func (e *Engine) emitBehaviors(ctx context.Context, runID id.AgentRunID, fx behaviorEffects) {
+	for _, name := range fx.Triggered {
+		e.extensions.EmitBehaviorTriggered(ctx, runID, name)
+	}
+}
+
+// behaviorSignalsFor assembles trigger signals from the current messages.
+// Tool results and errors are taken from the tool messages that follow the
+// most recent assistant turn.
+func behaviorSignalsFor(step int, input string, msgs []llm.Message, toolErrors []string) behaviorSignals {
+	sig := behaviorSignals{Step: step, Input: input, Errors: toolErrors}
+	var ctxBuf strings.Builder
+	for _, m := range msgs {
+		ctxBuf.WriteString(m.Content)
+		ctxBuf.WriteString("\n")
+	}
+	sig.Context = ctxBuf.String()
+	for i := len(msgs) - 1; i >= 0 && msgs[i].Role == "tool"; i-- {
+		sig.ToolResults = append(sig.ToolResults, msgs[i].Content)
+	}
+	return sig
+}
+
+// match reports whether text matches pattern, a case-insensitive regular
+// expression compiled by newBehaviorEvaluator. An empty pattern matches any
+// non-empty text.
+func (ev *behaviorEvaluator) match(pattern, text string) bool {
+	if pattern == "" {
+		return text != ""
+	}
+	re := ev.patterns[pattern]
+	return re != nil && re.MatchString(text)
+}
+
+// toFloat converts a JSON-decoded numeric value to float64.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// mergeMetadata copies src entries into dst, allocating dst when needed.
+func mergeMetadata(dst, src map[string]any) map[string]any {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]any, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+)
+
+func TestBehaviorEvaluator_PriorityOrderWinsConflicts(t *testing.T) {
+	ev := &behaviorEvaluator{
+		fired: make(map[string]bool),
+		behaviors: []*behavior.Behavior{
+			{
+				Name:     "careful",
+				Priority: 10,
+				Triggers: []behavior.Trigger{{Type: behavior.TriggerAlways}},
+				Actions:  []behavior.Action{{Type: behavior.ActionModifyParam, Target: "temperature", Value: 0.1}},
+			},
+			{
+				Name:     "creative",
+				Priority: 20,
+				Triggers: []behavior.Trigger{{Type: behavior.TriggerAlways}},
+				Actions:  []behavior.Action{{Type: behavior.ActionModifyParam, Target: "temperature", Value: 0.9}},
+			},
+		},
+	}
+
+	fx := ev.evaluate(behaviorSignals{})
+	if len(fx.Triggered) != 2 || fx.Triggered[0] != "careful" {
+		t.Fatalf("Triggered = %v, want [careful creative]", fx.Triggered)
+	}
+
+	req := &llm.Request{}
+	fx.applyTo(req)
+	if req.Temperature == nil || *req.Temperature != 0.1 {
+		t.Fatalf("Temperature = %v, want 0.1 from the higher-priority behavior", req.Temperature)
+	}
+}
+
+func TestBehaviorEvaluator_Triggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger behavior.Trigger
+		sig     behaviorSignals
+		want    bool
+	}{
+		{"on_input matches", behavior.Trigger{Type: behavior.TriggerOnInput, Pattern: "refund"}, behaviorSignals{Input: "I want a REFUND"}, true},
+		{"on_input only on first step", behavior.Trigger{Type: behavior.TriggerOnInput, Pattern: "refund"}, behaviorSignals{Step: 1, Input: "refund"}, false},
+		{"on_tool_result regex", behavior.Trigger{Type: behavior.TriggerOnToolResult, Pattern: `status":\s*"error`}, behaviorSignals{ToolResults: []string{`{"status": "error"}`}}, true},
+		{"on_error any", behavior.Trigger{Type: behavior.TriggerOnError}, behaviorSignals{Errors: []string{"boom"}}, true},
+		{"on_error none", behavior.Trigger{Type: behavior.TriggerOnError}, behaviorSignals{}, false},
+		{"on_context", behavior.Trigger{Type: behavior.TriggerOnContext, Pattern: "invoice"}, behaviorSignals{Context: "see invoice 42"}, true},
+		{"on_step_count below", behavior.Trigger{Type: behavior.TriggerOnStepCount, Pattern: "3"}, behaviorSignals{Step: 2}, false},
+		{"on_step_count reached", behavior.Trigger{Type: behavior.TriggerOnStepCount, Pattern: "3"}, behaviorSignals{Step: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &behavior.Behavior{Name: "b", Triggers: []behavior.Trigger{tt.trigger}}
+			ev, err := newBehaviorEvaluator(&ResolvedPersona{Behaviors: []*behavior.Behavior{b}})
+			if err != nil {
+				t.Fatalf("newBehaviorEvaluator: %v", err)
+			}
+			if got := ev.triggered(b, tt.sig); got != tt.want {
+				t.Errorf("triggered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBehaviorEvaluator_LeavesOutInvalidPatterns(t *testing.T) {
+	valid := &behavior.Behavior{Name: "refunds", Triggers: []behavior.Trigger{{Type: behavior.TriggerOnInput, Pattern: "refund"}}}
+	invalid := &behavior.Behavior{Name: "broken", Triggers: []behavior.Trigger{{Type: behavior.TriggerOnInput, Pattern: "refund("}}}
+
+	ev, err := newBehaviorEvaluator(&ResolvedPersona{Behaviors: []*behavior.Behavior{valid, invalid}})
+	if !errors.Is(err, cortex.ErrBehaviorInvalid) || !strings.Contains(err.Error(), `"broken"`) {
+		t.Fatalf("newBehaviorEvaluator err = %v, want ErrBehaviorInvalid naming the behavior", err)
+	}
+	if fx := ev.evaluate(behaviorSignals{Input: "refund("}); !slices.Equal(fx.Triggered, []string{"refunds"}) {
+		t.Errorf("Triggered = %v, want only the valid behavior", fx.Triggered)
+	}
+}
+
+func TestCreateBehavior_RejectsInvalidPattern(t *testing.T) {
+	e, err := New(WithStore(newTestStore(t)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	b := &behavior.Behavior{
+		ID: id.NewBehaviorID(), Name: "broken", AppID: "app1",
+		Triggers: []behavior.Trigger{{Type: behavior.TriggerOnError, Pattern: "[timeout"}},
+	}
+	if err := e.CreateBehavior(context.Background(), b); !errors.Is(err, cortex.ErrBehaviorInvalid) {
+		t.Fatalf("CreateBehavior err = %v, want ErrBehaviorInvalid", err)
+	}
+}
+
+func TestBehaviorEvaluator_StepCountFiresOnce(t *testing.T) {
+	ev := &behaviorEvaluator{
+		fired: make(map[string]bool),
+		behaviors: []*behavior.Behavior{{
+			Name:     "wrap-up",
+			Triggers: []behavior.Trigger{{Type: behavior.TriggerOnStepCount, Pattern: "2"}},
+			Actions:  []behavior.Action{{Type: behavior.ActionInjectPrompt, Value: "Start wrapping up."}},
+		}},
+	}
+	var fires int
+	for step := 0; step < 5; step++ {
+		fires += len(ev.evaluate(behaviorSignals{Step: step}).Triggered)
+	}
+	if fires != 1 {
+		t.Fatalf("on_step_count behavior fired %d times, want 1", fires)
+	}
+}
+
+func TestBehaviorEffects_ApplyToPrompt(t *testing.T) {
+	var fx behaviorEffects
+	fx.apply("verify", behavior.Action{Type: behavior.ActionInjectPrompt, Value: "Verify before answering."})
+	fx.apply("verify", behavior.Action{Type: behavior.ActionRequireTool, Target: "search"})
+	fx.apply("safe", behavior.Action{Type: behavior.ActionAddGuardrail, Value: "Never share credentials."})
+
+	req := &llm.Request{System: "You are helpful."}
+	fx.applyTo(req)
+	for _, want := range []string{
+		"You are helpful.",
+		"## Behavior: verify\nVerify before answering.",
+		"You must call the search tool",
+		"## Guardrail (safe)\nNever share credentials.",
+	} {
+		if !strings.Contains(req.System, want) {
+			t.Errorf("System prompt missing %q:\n%s", want, req.System)
+		}
+	}
+}
+
+func TestRunAgent_RequireToolSendsAnswerBack(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{
+		{Content: "a guess", Usage: llm.Usage{TotalTokens: 1}},
+		toolCallResponse("call-1", "note", `{}`),
+	}}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{InlineBehaviors: []string{"verify"}})
+	if err := e.CreateBehavior(ctx, &behavior.Behavior{
+		ID: id.NewBehaviorID(), Name: "verify", AppID: "app1",
+		Triggers: []behavior.Trigger{{Type: behavior.TriggerAlways}},
+		Actions: []behavior.Action{
+			{Type: behavior.ActionRequireTool, Target: "note"},
+			{Type: behavior.ActionRequireTool, Target: "missing"},
+		},
+	}); err != nil {
+		t.Fatalf("CreateBehavior: %v", err)
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.Output != "done" || len(client.requests) != 3 {
+		t.Fatalf("output = %q after %d model calls, want \"done\" after 3", r.Output, len(client.requests))
+	}
+	// The tool the run does not offer is not waited for.
+	msgs := client.requests[1].Messages
+	if last := msgs[len(msgs)-1]; last.Role != "user" || !strings.Contains(last.Content, "note") || strings.Contains(last.Content, "missing") {
+		t.Errorf("message after the early answer = %+v, want a reminder to call note", last)
+	}
+
+	history, err := e.LoadConversation(ctx, r.AgentID, "", id.Nil, 0)
+	if err != nil {
+		t.Fatalf("LoadConversation: %v", err)
+	}
+	for _, m := range history {
+		if m.Content == "a guess" || strings.Contains(m.Content, "must call") {
+			t.Errorf("conversation keeps %+v, want the early answer and reminder left out", m)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/budget"
+	"github.com/xraph/cortex/id"
+)
+
+// checkBudgets returns the error a run fails with before its next model
+// call: cortex.ErrMaxTokensReached once the run has used its own token cap,
+// or cortex.ErrBudgetExhausted once its agent's daily or its tenant's
+// monthly budget is used up. A budget whose usage cannot be read is logged
+// and not enforced.
+func (e *Engine) checkBudgets(ctx context.Context, rr *reactRun) error {
+	if limit := rr.cfg.MaxTotalTokens; limit > 0 && rr.st.TotalTokens >= limit {
+		return fmt.Errorf("%w: run used %d of %d tokens", cortex.ErrMaxTokensReached, rr.st.TotalTokens, limit)
+	}
+
+	for _, b := range []struct {
+		scope budget.Scope
+		key   string
+		limit int
+	}{
+		{budget.ScopeAgent, rr.ag.ID.String(), rr.ag.DailyTokenBudget},
+		{budget.ScopeTenant, rr.r.TenantID, e.tenantBudget(rr.r.TenantID)},
+	} {
+		if b.limit <= 0 || b.key == "" {
+			continue
+		}
+		st, err := e.budgetStatus(ctx, b.scope, b.key, b.limit)
+		if err != nil {
+			e.logger.Error("read budget usage",
+				log.String("scope", string(b.scope)),
+				log.String("key", b.key),
+				log.String("error", err.Error()),
+			)
+			continue
+		}
+		if st.Exhausted() {
+			return fmt.Errorf("%w: %s %s used %d of %d tokens since %s",
+				cortex.ErrBudgetExhausted, b.scope, b.key, st.Used, st.Limit, st.WindowStart.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// recordUsage adds the tokens of a model call to the usage of the run's
+// agent and tenant.
+func (e *Engine) recordUsage(ctx context.Context, rr *reactRun, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	usage := map[budget.Scope]string{budget.ScopeAgent: rr.ag.ID.String()}
+	if rr.r.TenantID != "" {
+		usage[budget.ScopeTenant] = rr.r.TenantID
+	}
+	for scope, key := range usage {
+		if err := e.store.AddUsage(ctx, scope, key, budget.Period(scope, now), int64(tokens)); err != nil {
+			e.logger.Error("record budget usage",
+				log.String("scope", string(scope)),
+				log.String("key", key),
+				log.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+// budgetStatus reads the usage of key in scope over the current window.
+func (e *Engine) budgetStatus(ctx context.Context, scope budget.Scope, key string, limit int) (*budget.Status, error) {
+	start := budget.WindowStart(scope, time.Now())
+	used, err := e.store.SumUsage(ctx, scope, key, start)
+	if err != nil {
+		return nil, err
+	}
+	return budget.NewStatus(scope, key, int64(limit), used, start), nil
+}
+
+// tenantBudget returns the monthly token budget of tenant.
+func (e *Engine) tenantBudget(tenant string) int {
+	if limit, ok := e.config.TenantTokenBudgets[tenant]; ok {
+		return limit
+	}
+	return e.config.TenantMonthlyTokenBudget
+}
+
+// AgentBudget returns the tokens the agent's runs used over the last 24
+// hours against its DailyTokenBudget.
+func (e *Engine) AgentBudget(ctx context.Context, agentID id.AgentID) (*budget.Status, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	ag, err := e.store.Get(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return e.budgetStatus(ctx, budget.ScopeAgent, ag.ID.String(), ag.DailyTokenBudget)
+}
+
+// TenantBudget returns the tokens the tenant's runs used this month against
+// its monthly budget.
+func (e *Engine) TenantBudget(ctx context.Context, tenantID string) (*budget.Status, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	return e.budgetStatus(ctx, budget.ScopeTenant, tenantID, e.tenantBudget(tenantID))
+}
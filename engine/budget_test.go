@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/budget"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// budgetStep returns a tool call step using tokens tokens.
+func budgetStep(tokens int) *llm.Response {
+	resp := toolCallResponse("call-1", "note", `{}`)
+	resp.Usage.TotalTokens = tokens
+	return resp
+}
+
+func newBudgetEngine(t *testing.T, client llm.Client, cfg cortex.Config, ag *agent.Config) *Engine {
+	t.Helper()
+	s := newTestStore(t)
+	note := func(context.Context, string) (string, error) { return "noted", nil }
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg), WithTool(llm.Tool{Name: "note"}, note))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag.ID, ag.Name, ag.AppID, ag.Tools = id.NewAgentID(), "worker", "app1", []string{"note"}
+	if err := s.Create(context.Background(), ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	return e
+}
+
+func TestRunAgent_StopsAtMaxTotalTokens(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{budgetStep(60), budgetStep(60), budgetStep(60)}}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{MaxTotalTokens: 1000})
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "work", &RunOverrides{MaxTotalTokens: 100}); !errors.Is(err, cortex.ErrMaxTokensReached) {
+		t.Fatalf("RunAgent err = %v, want ErrMaxTokensReached", err)
+	}
+	runs, err := e.store.ListRuns(ctx, &run.ListFilter{State: run.StateFailed})
+	if err != nil || len(runs) != 1 || runs[0].TokensUsed != 120 {
+		t.Fatalf("failed runs = %+v, %v; want one after 120 tokens", runs, err)
+	}
+	if len(client.requests) != 2 {
+		t.Errorf("model called %d times, want 2", len(client.requests))
+	}
+}
+
+func TestRunAgent_EnforcesAgentAndTenantBudgets(t *testing.T) {
+	ctx := cortex.WithTenant(context.Background(), "acme")
+	cfg := cortex.DefaultConfig()
+	cfg.TenantMonthlyTokenBudget = 1000
+	cfg.TenantTokenBudgets = map[string]int{"free": 0}
+	client := &scriptedLLM{responses: []*llm.Response{budgetStep(30), {Content: "done", Usage: llm.Usage{TotalTokens: 20}}}}
+	e := newBudgetEngine(t, client, cfg, &agent.Config{DailyTokenBudget: 50})
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "work", nil); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	ag, err := e.store.GetByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	st, err := e.AgentBudget(ctx, ag.ID)
+	if err != nil || st.Used != 50 || st.Remaining != 0 || !st.Exhausted() {
+		t.Fatalf("AgentBudget = %+v, %v; want 50 used and exhausted", st, err)
+	}
+	if st, err := e.TenantBudget(ctx, "acme"); err != nil || st.Used != 50 || st.Remaining != 950 {
+		t.Fatalf("TenantBudget = %+v, %v; want 50 of 1000 used", st, err)
+	}
+	if st, err := e.TenantBudget(ctx, "free"); err != nil || st.Limit != 0 || st.Scope != budget.ScopeTenant {
+		t.Fatalf("TenantBudget(free) = %+v, %v; want no budget", st, err)
+	}
+
+	calls := len(client.requests)
+	if _, err := e.RunAgent(ctx, "app1", "worker", "more work", nil); !errors.Is(err, cortex.ErrBudgetExhausted) {
+		t.Fatalf("second run err = %v, want ErrBudgetExhausted", err)
+	}
+	if len(client.requests) != calls {
+		t.Errorf("model called over an exhausted budget")
+	}
+}
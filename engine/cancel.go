@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// activeRun is a run executing in this process.
+type activeRun struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// trackRun registers a run as executing in this process and returns the
+// context it must execute under, which CancelRun cancels. The returned func
+// unregisters the run and must be called once execution stops.
+func (e *Engine) trackRun(ctx context.Context, runID id.AgentRunID) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	ar := &activeRun{cancel: cancel, done: make(chan struct{})}
+
+	e.activeMu.Lock()
+	e.active[runID] = ar
+	e.activeMu.Unlock()
+	e.heartbeat(ctx, runID)
+
+	return ctx, func() {
+		e.activeMu.Lock()
+		delete(e.active, runID)
+		e.activeMu.Unlock()
+		cancel(nil)
+		close(ar.done)
+	}
+}
+
+// CancelRun stops a run.
+//
+// A run executing in this process has its context cancelled: the model call
+// or tool in flight is interrupted, no further tools or steps run, and the
+// run is recorded as cancelled with the output produced so far. CancelRun
+// waits for that to happen, or for ctx to be done.
+//
+// A running or queued run owned by another process is marked cancelled in
+// the store; that process stops it at its next step boundary or when it
+// leaves the queue. A submitted run no worker has claimed yet is cancelled
+// directly, and so is a paused run, whose pending checkpoint expires.
+// Cancelling a run that has finished returns cortex.ErrInvalidState; one of
+// another tenant than that of ctx, cortex.ErrRunNotFound.
+func (e *Engine) CancelRun(ctx context.Context, runID id.AgentRunID) error {
+	r, err := e.GetRun(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	e.activeMu.Lock()
+	ar, ok := e.active[runID]
+	e.activeMu.Unlock()
+	if ok {
+		ar.cancel(cortex.ErrRunCancelled)
+		select {
+		case <-ar.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		switch r.State {
+		case run.StateCreated, run.StateRunning, run.StateQueued:
+			// Only the state is written: the process owning a running or
+			// queued run keeps writing its progress.
+			cancelled, err := e.store.TransitionRun(ctx, runID, r.State, run.StateCancelled)
+			if err != nil {
+				return err
+			}
+			if cancelled {
+				if r.State == run.StateCreated {
+					e.recordCancelled(ctx, r, "")
+				}
+				return nil
+			}
+		case run.StatePaused:
+			cancelled, err := e.cancelPausedRun(ctx, r)
+			if err != nil {
+				return err
+			}
+			if cancelled {
+				return nil
+			}
+		default:
+			return fmt.Errorf("%w: run %s is %s", cortex.ErrInvalidState, runID, r.State)
+		}
+		// The run moved on in the meantime, e.g. a worker claimed it.
+		if r, err = e.store.GetRun(ctx, runID); err != nil {
+			return err
+		}
+	}
+}
+
+// cancelPausedRun moves a paused run to cancelled, expires its pending
+// checkpoint and records the cancellation. It reports false when the run
+// left the paused state meanwhile, e.g. because its checkpoint was resolved.
+func (e *Engine) cancelPausedRun(ctx context.Context, r *run.Run) (bool, error) {
+	cancelled, err := e.store.TransitionRun(ctx, r.ID, run.StatePaused, run.StateCancelled)
+	if err != nil || !cancelled {
+		return false, err
+	}
+	if err := e.store.ExpirePending(ctx, r.ID); err != nil {
+		e.logger.Error("expire checkpoints on cancel", log.String("error", err.Error()))
+	}
+	e.recordCancelled(ctx, r, r.Output)
+	return true, nil
+}
+
+// stopRequested reports whether the run must stop: its context was cancelled
+// or another process cancelled it through the store.
+func (rr *reactRun) stopRequested(ctx context.Context) bool {
+	return ctx.Err() != nil || rr.cancelled
+}
+
+// cancelledInStore reports whether the run was marked cancelled in the store
+// by another process.
+func (e *Engine) cancelledInStore(ctx context.Context, r *run.Run) bool {
+	stored, err := e.store.GetRun(ctx, r.ID)
+	return err == nil && stored.State == run.StateCancelled
+}
+
+// cancelReactRun records rr as cancelled. partial is the output produced
+// before the run stopped; when empty, the last assistant message is used.
+func (e *Engine) cancelReactRun(ctx context.Context, rr *reactRun, partial string) {
+	if partial == "" {
+		partial = lastAssistantContent(rr.st.Messages)
+	}
+	rr.r.StepCount = rr.st.Step
+	rr.r.TokensUsed = rr.st.TotalTokens
+	e.recordCancelled(ctx, rr.r, partial)
+}
+
+// recordCancelled marks the run cancelled with its partial output and emits
+// the RunCancelled hook. ctx is usually what stopped the run, so its
+// cancellation is ignored.
+func (e *Engine) recordCancelled(ctx context.Context, r *run.Run, partial string) {
+	ctx = context.WithoutCancel(ctx)
+	completedAt := time.Now().UTC()
+	r.State = run.StateCancelled
+	r.Output = partial
+	r.CompletedAt = &completedAt
+	clearRunState(r)
+	if err := e.store.UpdateRun(ctx, r); err != nil {
+		e.logger.Error("update run on cancel", log.String("error", err.Error()))
+	}
+	e.extensions.EmitRunCancelled(ctx, r.AgentID, r.ID, partial)
+}
+
+// lastAssistantContent returns the content of the last non-empty assistant
+// message.
+func lastAssistantContent(msgs []llm.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "assistant" && msgs[i].Content != "" {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/store/sqlite"
+)
+
+// cancelRecorder is an extension recording RunCancelled hooks.
+type cancelRecorder struct {
+	mu      sync.Mutex
+	outputs map[id.AgentRunID]string
+}
+
+func (c *cancelRecorder) Name() string { return "cancel-recorder" }
+
+func (c *cancelRecorder) OnRunCancelled(_ context.Context, _ id.AgentID, runID id.AgentRunID, partialOutput string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.outputs == nil {
+		c.outputs = make(map[id.AgentRunID]string)
+	}
+	c.outputs[runID] = partialOutput
+	return nil
+}
+
+func (c *cancelRecorder) output(runID id.AgentRunID) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.outputs[runID]
+	return out, ok
+}
+
+// blockingTool is a tool handler that signals when it starts and then blocks
+// until its context is done or it is released.
+type blockingTool struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingTool() *blockingTool {
+	return &blockingTool{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (b *blockingTool) handle(ctx context.Context, _ string) (string, error) {
+	close(b.started)
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-b.release:
+		return "finished", nil
+	}
+}
+
+// newCancelEngine returns an engine on s with the "wait" tool and a
+// recorder for RunCancelled hooks.
+func newCancelEngine(t *testing.T, s *sqlite.Store, client llm.Client, wait ToolHandler) (*Engine, *cancelRecorder) {
+	t.Helper()
+	rec := &cancelRecorder{}
+	e, err := New(WithStore(s), WithLLM(client), WithTool(llm.Tool{Name: "wait"}, wait), WithExtension(rec))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e, rec
+}
+
+// startBlockedRun starts a run of the "worker" agent whose first step calls
+// the "wait" tool, and returns once the tool is executing.
+func startBlockedRun(t *testing.T, e *Engine, s *sqlite.Store, tool *blockingTool) (<-chan *run.Run, id.AgentRunID) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1", Tools: []string{"wait"}}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	done := make(chan *run.Run, 1)
+	go func() {
+		r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+		if err != nil {
+			t.Errorf("RunAgent: %v", err)
+		}
+		done <- r
+	}()
+	<-tool.started
+
+	runs, err := s.ListRuns(ctx, &run.ListFilter{State: run.StateRunning})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("running runs = %d, %v; want 1", len(runs), err)
+	}
+	return done, runs[0].ID
+}
+
+// waitStep is a step that reports progress and calls the "wait" tool.
+func waitStep() *llm.Response {
+	resp := toolCallResponse("call-1", "wait", `{}`)
+	resp.Content = "checking the logs"
+	return resp
+}
+
+func TestCancelRun_StopsRunInThisProcess(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	tool := newBlockingTool()
+	client := &scriptedLLM{responses: []*llm.Response{waitStep()}}
+	e, rec := newCancelEngine(t, s, client, tool.handle)
+	done, runID := startBlockedRun(t, e, s, tool)
+
+	if err := e.CancelRun(ctx, runID); err != nil {
+		t.Fatalf("CancelRun: %v", err)
+	}
+	r := <-done
+	if r == nil || r.State != run.StateCancelled || r.Output != "checking the logs" {
+		t.Fatalf("run = %+v, want cancelled with the partial output", r)
+	}
+	if len(client.requests) != 1 {
+		t.Errorf("model called %d times, want 1", len(client.requests))
+	}
+	if out, ok := rec.output(runID); !ok || out != "checking the logs" {
+		t.Errorf("RunCancelled hook = %q, %v", out, ok)
+	}
+
+	stored, err := s.GetRun(ctx, runID)
+	if err != nil || stored.State != run.StateCancelled || stored.CompletedAt == nil {
+		t.Errorf("stored run = %+v, %v; want cancelled", stored, err)
+	}
+	if err := e.CancelRun(ctx, runID); !errors.Is(err, cortex.ErrInvalidState) {
+		t.Errorf("second CancelRun err = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestCancelRun_SignalsOtherProcessThroughStore(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	tool := newBlockingTool()
+	client := &scriptedLLM{responses: []*llm.Response{waitStep()}}
+	owner, rec := newCancelEngine(t, s, client, tool.handle)
+	done, runID := startBlockedRun(t, owner, s, tool)
+
+	// A second engine on the same store stands in for another replica.
+	other, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := other.CancelRun(ctx, runID); err != nil {
+		t.Fatalf("CancelRun: %v", err)
+	}
+	close(tool.release)
+
+	r := <-done
+	if r == nil || r.State != run.StateCancelled || r.Output != "checking the logs" {
+		t.Fatalf("run = %+v, want cancelled with the partial output", r)
+	}
+	if len(client.requests) != 1 {
+		t.Errorf("model called %d times after cancellation, want 1", len(client.requests))
+	}
+	if _, ok := rec.output(runID); !ok {
+		t.Errorf("RunCancelled hook not emitted by the owning engine")
+	}
+	stored, err := s.GetRun(ctx, runID)
+	if err != nil || stored.State != run.StateCancelled || stored.StepCount != 1 || stored.CompletedAt == nil {
+		t.Errorf("stored run = %+v, %v; want cancelled after its step", stored, err)
+	}
+}
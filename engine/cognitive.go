@@ -0,0 +1,313 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/xraph/cortex/cognitive"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+)
+
+// strategyProfile is the prompting and sampling profile of a cognitive strategy.
+type strategyProfile struct {
+	// Prompt is the guidance injected into the system prompt while the strategy is active.
+	Prompt string
+	// Temperature is the sampling temperature used while the strategy is active.
+	Temperature float64
+}
+
+// strategyProfiles maps each cognitive strategy to its profile.
+var strategyProfiles = map[cognitive.Strategy]strategyProfile{
+	cognitive.StrategyAnalytical: {
+		Prompt:      "Break the problem into parts and reason through each one step by step. Ground every conclusion in evidence from the conversation or tool results.",
+		Temperature: 0.2,
+	},
+	cognitive.StrategyCreative: {
+		Prompt:      "Explore several different approaches before settling on one. Favor novel ideas and lateral connections over the obvious answer.",
+		Temperature: 0.9,
+	},
+	cognitive.StrategyMethodical: {
+		Prompt:      "Work through the task systematically, one item at a time. Keep track of what is done and what remains, and do not skip steps.",
+		Temperature: 0.1,
+	},
+	cognitive.StrategyReactive: {
+		Prompt:      "Respond quickly and directly. Take the most obvious next action with minimal deliberation.",
+		Temperature: 0.5,
+	},
+	cognitive.StrategyReflective: {
+		Prompt:      "Review your previous reasoning and results critically. Identify mistakes or gaps and refine your approach before continuing.",
+		Temperature: 0.3,
+	},
+	cognitive.StrategyCollaborative: {
+		Prompt:      "Consider multiple perspectives, including the user's. Ask clarifying questions when requirements are ambiguous.",
+		Temperature: 0.6,
+	},
+}
+
+// phaseChange describes a transition between cognitive phases.
+type phaseChange struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// cognitiveEngine drives a persona's cognitive phase chain during a run.
+type cognitiveEngine struct {
+	style        cognitive.Style
+	current      int
+	steps        int          // steps taken in the run
+	stepsInPhase int          // steps taken in the current phase
+	lastCalls    string       // signature of the previous step's tool calls
+	keepTemp     bool         // temperature fixed by the agent, run or a trait
+	active       string       // strategy applied to the current step
+	pending      *phaseChange // transition not yet recorded on a step
+}
+
+// cognitiveState is the persisted progress of a cognitive engine.
+type cognitiveState struct {
+	Phase        int    `json:"phase"`
+	Strategy     string `json:"strategy,omitempty"`
+	Steps        int    `json:"steps,omitempty"`
+	StepsInPhase int    `json:"steps_in_phase,omitempty"`
+	LastCalls    string `json:"last_calls,omitempty"`
+}
+
+// newCognitiveEngine returns the cognitive engine for a run, driven by the
+// resolved persona's CognitiveStyle. Without phases the engine is inert unless
+// a behavior switches strategy.
+func newCognitiveEngine(cfg resolvedConfig, rp *ResolvedPersona) *cognitiveEngine {
+	c := &cognitiveEngine{keepTemp: cfg.FixedTemperature}
+	if rp != nil {
+		c.style = rp.CognitiveStyle
+	}
+	return c
+}
+
+// state returns the progress of the engine to persist with the run.
+func (c *cognitiveEngine) state() *cognitiveState {
+	if c == nil || (c.steps == 0 && c.current == 0) {
+		return nil
+	}
+	return &cognitiveState{
+		Phase:        c.current,
+		Strategy:     string(c.phase()),
+		Steps:        c.steps,
+		StepsInPhase: c.stepsInPhase,
+		LastCalls:    c.lastCalls,
+	}
+}
+
+// restore continues from persisted progress. The phase is looked up by its
+// strategy when the persona's phases changed since it was saved.
+func (c *cognitiveEngine) restore(s *cognitiveState) {
+	if c == nil || s == nil {
+		return
+	}
+	c.steps = s.Steps
+	phase := s.Phase
+	if phase >= len(c.style.Phases) || string(c.style.Phases[phase].Strategy) != s.Strategy {
+		phase = slices.IndexFunc(c.style.Phases, func(p cognitive.Phase) bool { return string(p.Strategy) == s.Strategy })
+		if phase < 0 {
+			return
+		}
+	}
+	c.current = phase
+	c.stepsInPhase = s.StepsInPhase
+	c.lastCalls = s.LastCalls
+}
+
+// phase returns the strategy of the current phase, or "" when there are no phases.
+func (c *cognitiveEngine) phase() cognitive.Strategy {
+	if c == nil || len(c.style.Phases) == 0 {
+		return ""
+	}
+	return c.style.Phases[c.current].Strategy
+}
+
+// applyTo injects the active strategy's guidance into req and applies its
+// temperature unless the agent, run or a trait fixed it. A non-empty
+// override (from a switch_cognitive behavior) replaces the phase strategy
+// for this step only.
+func (c *cognitiveEngine) applyTo(req *llm.Request, override string) {
+	if c == nil {
+		return
+	}
+	strategy := c.phase()
+	if override != "" {
+		strategy = cognitive.Strategy(override)
+	}
+	c.active = string(strategy)
+	if strategy == "" {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("\n## Cognitive phase: " + string(strategy) + "\n")
+	profile, ok := strategyProfiles[strategy]
+	if ok {
+		b.WriteString(profile.Prompt)
+	} else {
+		b.WriteString("Take a " + string(strategy) + " approach to this step.")
+	}
+	switch {
+	case c.style.DepthPreference >= 0.7:
+		b.WriteString(" Go deep: examine details and edge cases thoroughly.")
+	case c.style.DepthPreference > 0 && c.style.DepthPreference <= 0.3:
+		b.WriteString(" Stay at a high level and avoid unnecessary detail.")
+	}
+	switch {
+	case c.style.FocusPreference >= 0.7:
+		b.WriteString(" Stay narrowly focused on the task at hand.")
+	case c.style.FocusPreference > 0 && c.style.FocusPreference <= 0.3:
+		b.WriteString(" Consider the broader context around the task.")
+	}
+	if c.reflectNow() {
+		b.WriteString(" Before continuing, briefly reflect on whether your approach so far is working.")
+	}
+	req.System = strings.TrimLeft(req.System+"\n"+b.String(), "\n")
+
+	if ok && !c.keepTemp {
+		t := profile.Temperature
+		req.Temperature = &t
+	}
+}
+
+// reflectNow reports whether ReflectionFrequency calls for a reflection
+// prompt on the current step. A frequency of 0.25 reflects every fourth step.
+func (c *cognitiveEngine) reflectNow() bool {
+	f := c.style.ReflectionFrequency
+	if f <= 0 || c.steps == 0 {
+		return false
+	}
+	every := int(math.Ceil(1 / math.Min(f, 1)))
+	return c.steps%every == 0
+}
+
+// afterStep records a completed step that made tool calls and advances to the
+// next phase when the current phase's transition condition is met. A phase's
+// MaxSteps also caps phases with a non-step transition.
+func (c *cognitiveEngine) afterStep(calls []llm.ToolCall, failed bool) *phaseChange {
+	if c == nil {
+		return nil
+	}
+	c.steps++
+	if len(c.style.Phases) == 0 {
+		return nil
+	}
+	c.stepsInPhase++
+	sig := toolCallSignature(calls)
+	stuck := sig != "" && sig == c.lastCalls
+	c.lastCalls = sig
+
+	p := c.style.Phases[c.current]
+	var reason string
+	switch p.Transition {
+	case cognitive.TransitionAfterSteps, "":
+		if p.MaxSteps > 0 && c.stepsInPhase >= p.MaxSteps {
+			reason = string(cognitive.TransitionAfterSteps)
+		}
+	case cognitive.TransitionOnStuck:
+		if stuck {
+			reason = string(cognitive.TransitionOnStuck)
+		}
+	case cognitive.TransitionOnError:
+		if failed {
+			reason = string(cognitive.TransitionOnError)
+		}
+	}
+	if reason == "" && p.MaxSteps > 0 && c.stepsInPhase >= p.MaxSteps {
+		reason = "max_steps"
+	}
+	if reason == "" {
+		return nil
+	}
+	return c.advance(c.current+1, reason)
+}
+
+// planComplete is called when the model answers without tool calls. When the
+// current phase transitions on_plan_complete and a later phase exists, the
+// engine advances and the run continues instead of finishing.
+func (c *cognitiveEngine) planComplete() *phaseChange {
+	if c == nil || len(c.style.Phases) == 0 {
+		return nil
+	}
+	if c.style.Phases[c.current].Transition != cognitive.TransitionOnPlanComplete {
+		return nil
+	}
+	c.steps++
+	return c.advance(c.current+1, string(cognitive.TransitionOnPlanComplete))
+}
+
+// switchTo moves to the first phase using strategy, as requested by a
+// switch_cognitive behavior. It returns nil when no such phase exists or it
+// is already current; the strategy is then applied for the step only.
+func (c *cognitiveEngine) switchTo(strategy string) *phaseChange {
+	if c == nil || strategy == "" || string(c.phase()) == strategy {
+		return nil
+	}
+	for i, p := range c.style.Phases {
+		if string(p.Strategy) == strategy {
+			return c.advance(i, "behavior")
+		}
+	}
+	return nil
+}
+
+// advance moves to phase next and records the transition.
+func (c *cognitiveEngine) advance(next int, reason string) *phaseChange {
+	if next >= len(c.style.Phases) || next == c.current {
+		return nil
+	}
+	ch := &phaseChange{
+		From:   string(c.style.Phases[c.current].Strategy),
+		To:     string(c.style.Phases[next].Strategy),
+		Reason: reason,
+	}
+	c.current = next
+	c.stepsInPhase = 0
+	c.lastCalls = ""
+	c.pending = ch
+	return ch
+}
+
+// metadata returns the step metadata for the current step: the active
+// strategy and any transition that led into it.
+func (c *cognitiveEngine) metadata() map[string]any {
+	if c == nil || c.active == "" {
+		return nil
+	}
+	md := map[string]any{"cognitive_phase": c.active}
+	if c.pending != nil {
+		md["cognitive_transition"] = *c.pending
+		c.pending = nil
+	}
+	return md
+}
+
+// emitPhaseChange fires the CognitivePhaseChanged hook for ch, if any.
+func (e *Engine) emitPhaseChange(ctx context.Context, runID id.AgentRunID, ch *phaseChange) {
+	if ch != nil {
+		e.extensions.EmitCognitivePhaseChanged(ctx, runID, ch.From, ch.To)
+	}
+}
+
+// phaseContinuePrompt is the user message that moves the run into the next
+// phase after the model completed its plan.
+func phaseContinuePrompt(ch *phaseChange) string {
+	return "Continue with the next phase of the task using a " + ch.To + " approach."
+}
+
+// toolCallSignature returns a comparable signature of a step's tool calls.
+func toolCallSignature(calls []llm.ToolCall) string {
+	var b strings.Builder
+	for _, tc := range calls {
+		b.WriteString(tc.Name)
+		b.WriteString("(")
+		b.WriteString(tc.Arguments)
+		b.WriteString(");")
+	}
+	return b.String()
+}
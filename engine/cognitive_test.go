@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/cognitive"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/persona"
+)
+
+func TestCognitiveEngine_AfterStepsTransition(t *testing.T) {
+	c := &cognitiveEngine{style: cognitive.Style{Phases: []cognitive.Phase{
+		{Strategy: cognitive.StrategyAnalytical, MaxSteps: 2, Transition: cognitive.TransitionAfterSteps},
+		{Strategy: cognitive.StrategyMethodical},
+	}}}
+	call := []llm.ToolCall{{Name: "search", Arguments: `{"q":"a"}`}}
+
+	if ch := c.afterStep(call, false); ch != nil {
+		t.Fatalf("transitioned after 1 step: %+v", ch)
+	}
+	ch := c.afterStep(call, false)
+	if ch == nil || ch.From != "analytical" || ch.To != "methodical" || ch.Reason != "after_steps" {
+		t.Fatalf("afterStep = %+v, want analytical -> methodical (after_steps)", ch)
+	}
+	if c.phase() != cognitive.StrategyMethodical {
+		t.Fatalf("phase = %q, want methodical", c.phase())
+	}
+	// The last phase never transitions.
+	for range 5 {
+		if ch := c.afterStep(call, false); ch != nil {
+			t.Fatalf("transitioned out of last phase: %+v", ch)
+		}
+	}
+}
+
+func TestCognitiveEngine_OnStuckAndOnError(t *testing.T) {
+	c := &cognitiveEngine{style: cognitive.Style{Phases: []cognitive.Phase{
+		{Strategy: cognitive.StrategyReactive, Transition: cognitive.TransitionOnStuck},
+		{Strategy: cognitive.StrategyCreative, Transition: cognitive.TransitionOnError},
+		{Strategy: cognitive.StrategyReflective},
+	}}}
+
+	c.afterStep([]llm.ToolCall{{Name: "search", Arguments: "a"}}, false)
+	if ch := c.afterStep([]llm.ToolCall{{Name: "search", Arguments: "b"}}, false); ch != nil {
+		t.Fatalf("different calls reported stuck: %+v", ch)
+	}
+	ch := c.afterStep([]llm.ToolCall{{Name: "search", Arguments: "b"}}, false)
+	if ch == nil || ch.Reason != "on_stuck" {
+		t.Fatalf("repeated call: afterStep = %+v, want on_stuck", ch)
+	}
+
+	if ch := c.afterStep(nil, false); ch != nil {
+		t.Fatalf("on_error fired without error: %+v", ch)
+	}
+	ch = c.afterStep(nil, true)
+	if ch == nil || ch.To != "reflective" || ch.Reason != "on_error" {
+		t.Fatalf("afterStep = %+v, want creative -> reflective (on_error)", ch)
+	}
+}
+
+func TestCognitiveEngine_PlanComplete(t *testing.T) {
+	c := &cognitiveEngine{style: cognitive.Style{Phases: []cognitive.Phase{
+		{Strategy: cognitive.StrategyAnalytical, Transition: cognitive.TransitionAfterSteps, MaxSteps: 3},
+		{Strategy: cognitive.StrategyCreative, Transition: cognitive.TransitionOnPlanComplete},
+		{Strategy: cognitive.StrategyReflective, Transition: cognitive.TransitionOnPlanComplete},
+	}}}
+	if ch := c.planComplete(); ch != nil {
+		t.Fatalf("after_steps phase advanced on plan complete: %+v", ch)
+	}
+	c.current = 1
+	ch := c.planComplete()
+	if ch == nil || ch.To != "reflective" {
+		t.Fatalf("planComplete = %+v, want creative -> reflective", ch)
+	}
+	if ch := c.planComplete(); ch != nil {
+		t.Fatalf("last phase advanced: %+v", ch)
+	}
+}
+
+func TestRunAgent_KeepsPhasePromptsOutOfConversation(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	if err := s.CreatePersona(ctx, &persona.Persona{
+		ID: id.NewPersonaID(), Name: "planner", AppID: "app1",
+		CognitiveStyle: cognitive.Style{Phases: []cognitive.Phase{
+			{Strategy: cognitive.StrategyAnalytical, Transition: cognitive.TransitionOnPlanComplete},
+			{Strategy: cognitive.StrategyReflective, Transition: cognitive.TransitionOnPlanComplete},
+		}},
+	}); err != nil {
+		t.Fatalf("create persona: %v", err)
+	}
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "helper", AppID: "app1", PersonaRef: "planner"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	client := &scriptedLLM{responses: []*llm.Response{{Content: "draft"}, {Content: "final"}}}
+	e, err := New(WithStore(s), WithLLM(client))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := e.RunAgent(ctx, "app1", "helper", "plan the trip", nil); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	history, err := e.LoadConversation(ctx, ag.ID, "", id.Nil, 0)
+	if err != nil || len(history) != 2 || history[0].Content != "plan the trip" || history[1].Content != "final" {
+		t.Errorf("conversation = %+v, %v; want the input and the final answer only", history, err)
+	}
+}
+
+func TestCognitiveEngine_ApplyToAndMetadata(t *testing.T) {
+	c := &cognitiveEngine{style: cognitive.Style{Phases: []cognitive.Phase{
+		{Strategy: cognitive.StrategyAnalytical},
+		{Strategy: cognitive.StrategyCreative},
+	}}}
+	if ch := c.switchTo("creative"); ch == nil || ch.Reason != "behavior" {
+		t.Fatalf("switchTo = %+v, want transition with reason behavior", ch)
+	}
+
+	req := &llm.Request{System: "base"}
+	c.applyTo(req, "")
+	if !strings.Contains(req.System, "## Cognitive phase: creative") {
+		t.Errorf("System missing creative phase section:\n%s", req.System)
+	}
+	if req.Temperature == nil || *req.Temperature != strategyProfiles[cognitive.StrategyCreative].Temperature {
+		t.Errorf("Temperature = %v, want creative profile temperature", req.Temperature)
+	}
+
+	md := c.metadata()
+	if md["cognitive_phase"] != "creative" {
+		t.Errorf("cognitive_phase = %v, want creative", md["cognitive_phase"])
+	}
+	if _, ok := md["cognitive_transition"]; !ok {
+		t.Errorf("transition not recorded on step metadata: %v", md)
+	}
+	if _, ok := c.metadata()["cognitive_transition"]; ok {
+		t.Errorf("transition recorded twice")
+	}
+}
+
+func TestCognitiveEngine_KeepsExplicitTemperature(t *testing.T) {
+	c := &cognitiveEngine{keepTemp: true}
+	temp := 0.7
+	req := &llm.Request{Temperature: &temp}
+	c.applyTo(req, string(cognitive.StrategyMethodical))
+	if *req.Temperature != 0.7 {
+		t.Fatalf("Temperature = %v, want explicit 0.7 kept", *req.Temperature)
+	}
+	if !strings.Contains(req.System, "## Cognitive phase: methodical") {
+		t.Fatalf("override strategy not applied:\n%s", req.System)
+	}
+}
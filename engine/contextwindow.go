@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/xraph/cortex/llm"
+)
+
+// minToolResultTokens is the size below which tool results are never
+// truncated to fit a request into the context window.
+const minToolResultTokens = 256
+
+// contextLimit returns the context window of model in tokens: its entry in
+// Config.ContextLimits, else in llm.DefaultContextLimits, else
+// Config.DefaultContextLimit.
+func (e *Engine) contextLimit(model string) int {
+	if n, ok := llm.ContextLimit(e.config.ContextLimits, model); ok {
+		return n
+	}
+	if n, ok := llm.ContextLimit(llm.DefaultContextLimits, model); ok {
+		return n
+	}
+	return e.config.DefaultContextLimit
+}
+
+// contextTrim records how a request was trimmed to fit its model's context
+// window.
+type contextTrim struct {
+	limit, before, after int
+	history              int
+	toolResults          int
+	knowledgeChunks      int
+}
+
+// metadata returns the trim as step metadata.
+func (t contextTrim) metadata() map[string]any {
+	return map[string]any{"context_trim": map[string]any{
+		"limit":                    t.limit,
+		"tokens_before":            t.before,
+		"tokens_after":             t.after,
+		"dropped_history":          t.history,
+		"truncated_tool_results":   t.toolResults,
+		"dropped_knowledge_chunks": t.knowledgeChunks,
+	}}
+}
+
+// fitContext trims req so its prompt and MaxTokens of output fit the context
+// window of its model. It drops, in order and only as far as needed: the
+// oldest of the run's first history messages, the tail of the longest tool
+// results, marking each truncation, and the lowest scoring knowledge chunks
+// of prompt. req.System must be prompt rendered and followed by extra. It
+// returns the trim as step metadata, or nil when req already fits. A request
+// that still does not fit is sent as trimmed.
+func (e *Engine) fitContext(req *llm.Request, history int, prompt *systemPrompt, extra string) map[string]any {
+	limit := e.contextLimit(req.Model)
+	if limit <= 0 {
+		return nil
+	}
+	budget := limit - req.MaxTokens
+	total := llm.CountRequest(e.tokenizer, req)
+	if total <= budget {
+		return nil
+	}
+	trim := contextTrim{limit: limit, before: total}
+
+	// Oldest history first. Tool results left at the front lose their tool
+	// call, so they go with it.
+	msgs := req.Messages
+	history = min(history, len(msgs))
+	drop := 0
+	for drop < history && total > budget {
+		total -= llm.CountMessage(e.tokenizer, req.Model, msgs[drop])
+		drop++
+		for drop < history && msgs[drop].Role == "tool" {
+			total -= llm.CountMessage(e.tokenizer, req.Model, msgs[drop])
+			drop++
+		}
+	}
+	msgs = msgs[drop:]
+	trim.history = drop
+
+	// Then the longest tool results, each truncated once.
+	if total > budget {
+		msgs = slices.Clone(msgs)
+		truncated := make(map[int]bool)
+		for total > budget {
+			i, n := -1, minToolResultTokens
+			for j, m := range msgs {
+				if c := e.tokenizer.Count(req.Model, m.Content); m.Role == "tool" && !truncated[j] && c > n {
+					i, n = j, c
+				}
+			}
+			if i < 0 {
+				break
+			}
+			marker := e.tokenizer.Count(req.Model, truncationMarker(len(msgs[i].Content), len(msgs[i].Content)))
+			keep := max(minToolResultTokens, n-(total-budget)-marker)
+			before := llm.CountMessage(e.tokenizer, req.Model, msgs[i])
+			msgs[i].Content = truncateContent(msgs[i].Content, n, keep)
+			total += llm.CountMessage(e.tokenizer, req.Model, msgs[i]) - before
+			truncated[i] = true
+		}
+		trim.toolResults = len(truncated)
+	}
+	req.Messages = msgs
+
+	// Then the lowest scoring knowledge chunks.
+	if total > budget && prompt != nil {
+		p, dropped := e.dropKnowledge(req, prompt, extra, total-budget)
+		total += e.tokenizer.Count(req.Model, p) - e.tokenizer.Count(req.Model, req.System)
+		req.System = p
+		trim.knowledgeChunks = dropped
+	}
+
+	trim.after = total
+	return trim.metadata()
+}
+
+// dropKnowledge removes knowledge chunks from prompt, lowest score first and
+// later chunks first among equal scores, until the rendered prompt followed
+// by extra is excess tokens shorter than req.System or no chunks are left.
+// It returns the new system prompt and the number of chunks dropped.
+func (e *Engine) dropKnowledge(req *llm.Request, prompt *systemPrompt, extra string, excess int) (string, int) {
+	type ref struct {
+		section, chunk int
+		score          float64
+	}
+	var refs []ref
+	for s, ks := range prompt.knowledge {
+		for c, chunk := range ks.chunks {
+			refs = append(refs, ref{s, c, chunk.Score})
+		}
+	}
+	slices.SortStableFunc(refs, func(a, b ref) int {
+		if c := cmp.Compare(a.score, b.score); c != 0 {
+			return c
+		}
+		return cmp.Or(cmp.Compare(b.section, a.section), cmp.Compare(b.chunk, a.chunk))
+	})
+
+	target := e.tokenizer.Count(req.Model, req.System) - excess
+	removed := make(map[[2]int]bool)
+	p := *prompt
+	system := req.System
+	for _, r := range refs {
+		if e.tokenizer.Count(req.Model, system) <= target {
+			break
+		}
+		removed[[2]int{r.section, r.chunk}] = true
+		p.knowledge = make([]knowledgeSection, len(prompt.knowledge))
+		for s, ks := range prompt.knowledge {
+			p.knowledge[s] = knowledgeSection{source: ks.source}
+			for c, chunk := range ks.chunks {
+				if !removed[[2]int{s, c}] {
+					p.knowledge[s].chunks = append(p.knowledge[s].chunks, chunk)
+				}
+			}
+		}
+		system = p.String() + extra
+	}
+	return system, len(removed)
+}
+
+// truncateContent cuts content of n tokens down to about keep tokens,
+// keeping its start and marking the cut.
+func truncateContent(content string, n, keep int) string {
+	cut := len(content) * keep / n
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + truncationMarker(len(content)-cut, len(content))
+}
+
+// truncationMarker marks a tool result of total characters cut by omitted.
+func truncationMarker(omitted, total int) string {
+	return fmt.Sprintf("\n[truncated: %d of %d characters omitted to fit the context window]", omitted, total)
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/knowledge"
+	"github.com/xraph/cortex/llm"
+)
+
+// newContextEngine returns an engine whose model "tiny" has a context window
+// of limit tokens.
+func newContextEngine(t *testing.T, limit int) *Engine {
+	t.Helper()
+	cfg := cortex.DefaultConfig()
+	cfg.ContextLimits = map[string]int{"tiny": limit}
+	e, err := New(WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+// trimOf returns the context trim recorded in step metadata.
+func trimOf(t *testing.T, metadata map[string]any) map[string]any {
+	t.Helper()
+	trim, ok := metadata["context_trim"].(map[string]any)
+	if !ok {
+		t.Fatalf("metadata %v has no context trim", metadata)
+	}
+	return trim
+}
+
+func TestFitContext_LeavesFittingRequestAlone(t *testing.T) {
+	e := newContextEngine(t, 1000)
+	req := &llm.Request{Model: "tiny", Messages: []llm.Message{{Role: "user", Content: "hello"}}}
+	if md := e.fitContext(req, 0, nil, ""); md != nil {
+		t.Errorf("fitContext = %v, want no trim", md)
+	}
+}
+
+func TestFitContext_DropsOldestHistoryFirst(t *testing.T) {
+	e := newContextEngine(t, 100)
+	long := strings.Repeat("h", 100) // 25 tokens, 29 with framing
+	req := &llm.Request{Model: "tiny", Messages: []llm.Message{
+		{Role: "user", Content: "old " + long},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: long},
+		{Role: "user", Content: strings.Repeat("i", 40)},
+	}}
+
+	trim := trimOf(t, e.fitContext(req, 3, nil, ""))
+	if trim["dropped_history"] != 1 || trim["truncated_tool_results"] != 0 {
+		t.Errorf("trim = %v, want one history message dropped", trim)
+	}
+	if len(req.Messages) != 3 || strings.HasPrefix(req.Messages[0].Content, "old") {
+		t.Errorf("messages = %+v, want the oldest dropped", req.Messages)
+	}
+}
+
+func TestFitContext_TruncatesLongToolResults(t *testing.T) {
+	e := newContextEngine(t, 600)
+	result := strings.Repeat("r", 4000)
+	msgs := []llm.Message{
+		{Role: "user", Content: "look it up"},
+		{Role: "assistant", ToolCalls: []llm.ToolCall{{ID: "c1", Name: "search", Arguments: "{}"}}},
+		{Role: "tool", Content: result, ToolCallID: "c1"},
+	}
+	req := &llm.Request{Model: "tiny", Messages: msgs, MaxTokens: 100}
+
+	trim := trimOf(t, e.fitContext(req, 0, nil, ""))
+	if trim["truncated_tool_results"] != 1 || trim["tokens_after"].(int) > 500 {
+		t.Errorf("trim = %v, want one tool result truncated into the budget", trim)
+	}
+	if got := req.Messages[2].Content; len(got) >= len(result) || !strings.Contains(got, "[truncated:") {
+		t.Errorf("tool result = %q, want it cut with a marker", got)
+	}
+	if msgs[2].Content != result {
+		t.Error("fitContext changed the run's own messages")
+	}
+}
+
+func TestFitContext_DropsLowestScoringKnowledgeLast(t *testing.T) {
+	e := newContextEngine(t, 150)
+	prompt := &systemPrompt{
+		head: []string{"You are helpful."},
+		knowledge: []knowledgeSection{{source: "docs", chunks: []knowledge.ScoredChunk{
+			{Content: "relevant " + strings.Repeat("a", 400), Score: 0.9},
+			{Content: "marginal " + strings.Repeat("b", 400), Score: 0.1},
+		}}},
+	}
+	req := &llm.Request{
+		Model:    "tiny",
+		System:   prompt.String() + "\n## Focus",
+		Messages: []llm.Message{{Role: "user", Content: "question"}},
+	}
+
+	trim := trimOf(t, e.fitContext(req, 0, prompt, "\n## Focus"))
+	if trim["dropped_knowledge_chunks"] != 1 {
+		t.Errorf("trim = %v, want one knowledge chunk dropped", trim)
+	}
+	if !strings.Contains(req.System, "relevant") || strings.Contains(req.System, "marginal") || !strings.HasSuffix(req.System, "## Focus") {
+		t.Errorf("system prompt = %q, want the low scoring chunk dropped and the rest kept", req.System)
+	}
+}
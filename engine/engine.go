@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	log "github.com/xraph/go-utils/log"
@@ -26,8 +27,13 @@ import (
 
 // registeredTool pairs an externally-registered tool definition with its handler.
 type registeredTool struct {
-	def     llm.Tool
-	handler ToolHandler
+	def             llm.Tool
+	handler         ToolHandler
+	requireApproval bool
+	sequential      bool
+	timeout         time.Duration
+	attempts        int
+	backoff         time.Duration
 }
 
 // Engine is the central coordinator for the Cortex agent system.
@@ -38,9 +44,29 @@ type Engine struct {
 	llm         llm.Client
 	safety      safety.Scanner
 	knowledge   knowledge.Provider
+	tokenizer   llm.Tokenizer
 	extensions  *plugin.Registry
 	pendingExts []plugin.Extension
 	tools       []registeredTool
+
+	// background tracks goroutines executing runs outside a caller's
+	// request: orphaned runs resumed by Start and the run workers.
+	background sync.WaitGroup
+
+	// submitted wakes an idle worker when a run is submitted.
+	submitted chan struct{}
+	// stopWorkers stops the workers from claiming further runs.
+	stopWorkers context.CancelFunc
+
+	// admission limits the number of concurrent runs.
+	admission *admission
+
+	// active holds the runs executing in this process, for CancelRun.
+	activeMu sync.Mutex
+	active   map[id.AgentRunID]*activeRun
+
+	// conversations serializes the summarization of each conversation.
+	conversations conversationLocks
 }
 
 // LLM returns the configured LLM client, or nil if none is set.
@@ -57,7 +83,10 @@ type RunOverrides struct {
 	Model           string
 	Temperature     *float64
 	MaxSteps        int
+	MaxStepsPolicy  cortex.MaxStepsPolicy
+	ToolConcurrency int
 	MaxTokens       int
+	MaxTotalTokens  int
 	ReasoningLoop   string
 	SystemPrompt    string
 	PersonaRef      string
@@ -65,13 +94,23 @@ type RunOverrides struct {
 	InlineTraits    []string
 	InlineBehaviors []string
 	Tools           []string
+	// SessionID runs the agent in a conversation session of the agent and
+	// the caller's tenant. The run sees and extends only the session's
+	// conversation memory; the nil ID uses the conversation outside
+	// sessions.
+	SessionID id.SessionID
+	// OutputSchema replaces the agent's output schema for the run.
+	OutputSchema map[string]any
 }
 
 // New creates a new Engine with the given options.
 func New(opts ...Option) (*Engine, error) {
 	e := &Engine{
-		config: cortex.DefaultConfig(),
-		logger: log.NewNoopLogger(),
+		config:    cortex.DefaultConfig(),
+		logger:    log.NewNoopLogger(),
+		tokenizer: llm.HeuristicTokenizer{},
+		active:    make(map[id.AgentRunID]*activeRun),
+		submitted: make(chan struct{}, 1),
 	}
 
 	for _, opt := range opts {
@@ -80,6 +119,8 @@ func New(opts ...Option) (*Engine, error) {
 		}
 	}
 
+	e.admission = newAdmission(e.config.RunConcurrency, e.config.TenantRunConcurrency)
+
 	e.extensions = plugin.NewRegistry(e.logger)
 	for _, ext := range e.pendingExts {
 		e.extensions.Register(ext)
@@ -97,14 +138,37 @@ func (e *Engine) Health(ctx context.Context) error {
 	return nil
 }
 
-// Start initializes the engine for operation.
-func (e *Engine) Start(_ context.Context) error {
+// Start initializes the engine for operation. Runs left running by a
+// previous process are recovered according to Config.RecoveryPolicy, and
+// Config.RunWorkers workers start executing submitted runs.
+func (e *Engine) Start(ctx context.Context) error {
+	if e.store != nil {
+		if err := e.recoverRuns(ctx); err != nil {
+			return fmt.Errorf("cortex: recover runs: %w", err)
+		}
+		e.startWorkers()
+	}
 	e.logger.Info("cortex engine started")
 	return nil
 }
 
-// Stop gracefully shuts down the engine.
+// Stop gracefully shuts down the engine. Workers stop claiming submitted
+// runs; Stop waits for the runs they and Start are executing to finish, or
+// for ctx to be done.
 func (e *Engine) Stop(ctx context.Context) error {
+	if e.stopWorkers != nil {
+		e.stopWorkers()
+	}
+	done := make(chan struct{})
+	go func() {
+		e.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		e.logger.Warn("stopped before background runs finished")
+	}
 	e.extensions.EmitShutdown(ctx)
 	e.logger.Info("cortex engine stopped")
 	return nil
@@ -148,6 +212,9 @@ func (e *Engine) CreateAgent(ctx context.Context, config *agent.Config) error {
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if err := checkSchema(config.OutputSchema); err != nil {
+		return err
+	}
 	return e.store.Create(ctx, config)
 }
 
@@ -169,6 +236,9 @@ func (e *Engine) UpdateAgent(ctx context.Context, config *agent.Config) error {
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if err := checkSchema(config.OutputSchema); err != nil {
+		return err
+	}
 	return e.store.Update(ctx, config)
 }
 
@@ -197,10 +267,15 @@ func (e *Engine) CountAgents(ctx context.Context, filter *agent.ListFilter) (int
 // Skill CRUD passthrough
 // ──────────────────────────────────────────────────
 
+// CreateSkill stores a new skill. Its dependencies must exist in the same
+// app and must not form a cycle.
 func (e *Engine) CreateSkill(ctx context.Context, s *skill.Skill) error {
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if err := e.validateSkillDependencies(ctx, s); err != nil {
+		return err
+	}
 	return e.store.CreateSkill(ctx, s)
 }
 
@@ -218,10 +293,14 @@ func (e *Engine) GetSkillByName(ctx context.Context, appID, name string) (*skill
 	return e.store.GetSkillByName(ctx, appID, name)
 }
 
+// UpdateSkill updates a skill. Its dependencies are validated as in CreateSkill.
 func (e *Engine) UpdateSkill(ctx context.Context, s *skill.Skill) error {
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if err := e.validateSkillDependencies(ctx, s); err != nil {
+		return err
+	}
 	return e.store.UpdateSkill(ctx, s)
 }
 
@@ -307,6 +386,9 @@ func (e *Engine) CreateBehavior(ctx context.Context, b *behavior.Behavior) error
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if _, err := compileTriggers(b); err != nil {
+		return err
+	}
 	return e.store.CreateBehavior(ctx, b)
 }
 
@@ -328,6 +410,9 @@ func (e *Engine) UpdateBehavior(ctx context.Context, b *behavior.Behavior) error
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
+	if _, err := compileTriggers(b); err != nil {
+		return err
+	}
 	return e.store.UpdateBehavior(ctx, b)
 }
 
@@ -409,25 +494,60 @@ func (e *Engine) CountPersonas(ctx context.Context, filter *persona.ListFilter)
 // Run CRUD passthrough
 // ──────────────────────────────────────────────────
 
+// GetRun returns a run readable under ctx; runs of other tenants are
+// reported as cortex.ErrRunNotFound.
 func (e *Engine) GetRun(ctx context.Context, runID id.AgentRunID) (*run.Run, error) {
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
-	return e.store.GetRun(ctx, runID)
+	r, err := e.store.GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkRecordTenant(ctx, r.TenantID, cortex.ErrRunNotFound); err != nil {
+		return nil, err
+	}
+	return r, nil
 }
 
+// ListRuns lists runs matching filter. A ctx carrying a tenant only lists
+// runs of that tenant.
 func (e *Engine) ListRuns(ctx context.Context, filter *run.ListFilter) ([]*run.Run, error) {
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
-	return e.store.ListRuns(ctx, filter)
+	f, err := e.tenantRunFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return e.store.ListRuns(ctx, f)
 }
 
+// CountRuns counts runs matching filter. A ctx carrying a tenant only
+// counts runs of that tenant.
 func (e *Engine) CountRuns(ctx context.Context, filter *run.ListFilter) (int64, error) {
 	if e.store == nil {
 		return 0, cortex.ErrNoStore
 	}
-	return e.store.CountRuns(ctx, filter)
+	f, err := e.tenantRunFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return e.store.CountRuns(ctx, f)
+}
+
+// tenantRunFilter returns a copy of filter restricted to the tenant of ctx.
+func (e *Engine) tenantRunFilter(ctx context.Context, filter *run.ListFilter) (*run.ListFilter, error) {
+	var f run.ListFilter
+	if filter != nil {
+		f = *filter
+	}
+	tenant, err := e.listTenant(ctx, f.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	f.TenantID = tenant
+	return &f, nil
 }
 
 func (e *Engine) ListSteps(ctx context.Context, runID id.AgentRunID) ([]*run.Step, error) {
@@ -448,50 +568,112 @@ func (e *Engine) ListToolCalls(ctx context.Context, stepID id.StepID) ([]*run.To
 // Memory passthrough
 // ──────────────────────────────────────────────────
 
-func (e *Engine) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, limit int) ([]memory.Message, error) {
+func (e *Engine) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, limit int) ([]memory.Message, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	return e.store.LoadConversation(ctx, agentID, tenantID, sessionID, limit)
+}
+
+// LoadSummaries returns the summaries of a conversation, oldest first.
+func (e *Engine) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) ([]string, error) {
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
-	return e.store.LoadConversation(ctx, agentID, tenantID, limit)
+	return e.store.LoadSummaries(ctx, agentID, tenantID, sessionID)
 }
 
-func (e *Engine) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string) error {
+func (e *Engine) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) error {
 	if e.store == nil {
 		return cortex.ErrNoStore
 	}
-	return e.store.ClearConversation(ctx, agentID, tenantID)
+	return e.store.ClearConversation(ctx, agentID, tenantID, sessionID)
+}
+
+// ListFacts returns the facts of an agent for a tenant, most recently
+// updated first.
+func (e *Engine) ListFacts(ctx context.Context, agentID id.AgentID, tenantID string) ([]memory.Fact, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	return e.store.ListFacts(ctx, agentID, tenantID)
+}
+
+// DeleteFact removes a fact of an agent for a tenant by key.
+func (e *Engine) DeleteFact(ctx context.Context, agentID id.AgentID, tenantID, key string) error {
+	if e.store == nil {
+		return cortex.ErrNoStore
+	}
+	return e.store.DeleteFact(ctx, agentID, tenantID, key)
+}
+
+// ClearFacts removes all facts of an agent for a tenant.
+func (e *Engine) ClearFacts(ctx context.Context, agentID id.AgentID, tenantID string) error {
+	if e.store == nil {
+		return cortex.ErrNoStore
+	}
+	return e.store.ClearFacts(ctx, agentID, tenantID)
 }
 
 // ──────────────────────────────────────────────────
 // Checkpoint passthrough
 // ──────────────────────────────────────────────────
 
+// GetCheckpoint returns a checkpoint readable under ctx; checkpoints of
+// other tenants are reported as cortex.ErrCheckpointNotFound.
 func (e *Engine) GetCheckpoint(ctx context.Context, cpID id.CheckpointID) (*checkpoint.Checkpoint, error) {
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
-	return e.store.GetCheckpoint(ctx, cpID)
+	cp, err := e.store.GetCheckpoint(ctx, cpID)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkRecordTenant(ctx, cp.TenantID, cortex.ErrCheckpointNotFound); err != nil {
+		return nil, err
+	}
+	return cp, nil
 }
 
+// ListPendingCheckpoints lists pending checkpoints matching filter. A ctx
+// carrying a tenant only lists checkpoints of that tenant.
 func (e *Engine) ListPendingCheckpoints(ctx context.Context, filter *checkpoint.ListFilter) ([]*checkpoint.Checkpoint, error) {
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
-	return e.store.ListPending(ctx, filter)
+	f, err := e.tenantCheckpointFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return e.store.ListPending(ctx, f)
 }
 
+// CountPendingCheckpoints counts pending checkpoints matching filter. A ctx
+// carrying a tenant only counts checkpoints of that tenant.
 func (e *Engine) CountPendingCheckpoints(ctx context.Context, filter *checkpoint.ListFilter) (int64, error) {
 	if e.store == nil {
 		return 0, cortex.ErrNoStore
 	}
-	return e.store.CountPending(ctx, filter)
+	f, err := e.tenantCheckpointFilter(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return e.store.CountPending(ctx, f)
 }
 
-func (e *Engine) ResolveCheckpoint(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) error {
-	if e.store == nil {
-		return cortex.ErrNoStore
+// tenantCheckpointFilter returns a copy of filter restricted to the tenant
+// of ctx.
+func (e *Engine) tenantCheckpointFilter(ctx context.Context, filter *checkpoint.ListFilter) (*checkpoint.ListFilter, error) {
+	var f checkpoint.ListFilter
+	if filter != nil {
+		f = *filter
 	}
-	return e.store.Resolve(ctx, cpID, decision)
+	tenant, err := e.listTenant(ctx, f.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	f.TenantID = tenant
+	return &f, nil
 }
 
 // ──────────────────────────────────────────────────
@@ -525,11 +707,20 @@ func (e *Engine) RunAgent(ctx context.Context, appID, agentName, input string, o
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
+	if err := e.checkTenant(ctx); err != nil {
+		return nil, err
+	}
 
 	ag, err := e.store.GetByName(ctx, appID, agentName)
 	if err != nil {
 		return nil, fmt.Errorf("resolve agent: %w", err)
 	}
+	if err := e.checkSession(ctx, ag, overrides); err != nil {
+		return nil, err
+	}
+	if err := checkOutputSchema(ag, overrides); err != nil {
+		return nil, err
+	}
 
 	// Use real execution if LLM client is available.
 	if e.llm != nil {
@@ -537,7 +728,7 @@ func (e *Engine) RunAgent(ctx context.Context, appID, agentName, input string, o
 	}
 
 	// Fallback: mock/echo execution.
-	return e.runMock(ctx, ag, input)
+	return e.runMock(ctx, ag, input, sessionOf(overrides))
 }
 
 // StreamAgent executes an agent and sends streaming events to the channel.
@@ -549,12 +740,24 @@ func (e *Engine) StreamAgent(ctx context.Context, appID, agentName, input string
 		close(events)
 		return cortex.ErrNoStore
 	}
+	if err := e.checkTenant(ctx); err != nil {
+		close(events)
+		return err
+	}
 
 	ag, err := e.store.GetByName(ctx, appID, agentName)
 	if err != nil {
 		close(events)
 		return fmt.Errorf("resolve agent: %w", err)
 	}
+	if err := e.checkSession(ctx, ag, overrides); err != nil {
+		close(events)
+		return err
+	}
+	if err := checkOutputSchema(ag, overrides); err != nil {
+		close(events)
+		return err
+	}
 
 	// Use real execution if LLM client is available.
 	if e.llm != nil {
@@ -562,16 +765,18 @@ func (e *Engine) StreamAgent(ctx context.Context, appID, agentName, input string
 	}
 
 	// Fallback: mock/echo execution.
-	return e.streamMock(ctx, ag, input, events)
+	return e.streamMock(ctx, ag, input, sessionOf(overrides), events)
 }
 
 // runMock is the mock/echo fallback for RunAgent.
-func (e *Engine) runMock(ctx context.Context, ag *agent.Config, input string) (*run.Run, error) {
+func (e *Engine) runMock(ctx context.Context, ag *agent.Config, input string, sessionID id.SessionID) (*run.Run, error) {
 	now := time.Now().UTC()
 	r := &run.Run{
 		Entity:     cortex.NewEntity(),
 		ID:         id.NewAgentRunID(),
 		AgentID:    ag.ID,
+		TenantID:   cortex.TenantFromContext(ctx),
+		SessionID:  sessionID,
 		State:      run.StateRunning,
 		Input:      input,
 		StartedAt:  &now,
@@ -580,7 +785,12 @@ func (e *Engine) runMock(ctx context.Context, ag *agent.Config, input string) (*
 	if err := e.store.CreateRun(ctx, r); err != nil {
 		return nil, fmt.Errorf("create run: %w", err)
 	}
+	return e.completeMock(ctx, ag, r), nil
+}
 
+// completeMock echoes the input of a started run as its output.
+func (e *Engine) completeMock(ctx context.Context, ag *agent.Config, r *run.Run) *run.Run {
+	input := r.Input
 	e.extensions.EmitRunStarted(ctx, ag.ID, r.ID, input)
 
 	stepStart := time.Now().UTC()
@@ -607,22 +817,26 @@ func (e *Engine) runMock(ctx context.Context, ag *agent.Config, input string) (*
 	r.StepCount = 1
 	r.TokensUsed = step.TokensUsed
 	r.CompletedAt = &completedAt
+	clearRunState(r)
 	if err := e.store.UpdateRun(ctx, r); err != nil {
 		e.logger.Error("update run", log.String("error", err.Error()))
 	}
+	e.touchSession(ctx, r)
 
-	e.extensions.EmitRunCompleted(ctx, ag.ID, r.ID, r.Output, completedAt.Sub(now))
+	e.extensions.EmitRunCompleted(ctx, ag.ID, r.ID, r.Output, runDuration(r, completedAt))
 
-	return r, nil
+	return r
 }
 
 // streamMock is the mock/echo fallback for StreamAgent.
-func (e *Engine) streamMock(ctx context.Context, ag *agent.Config, input string, events chan<- StreamEvent) error {
+func (e *Engine) streamMock(ctx context.Context, ag *agent.Config, input string, sessionID id.SessionID, events chan<- StreamEvent) error {
 	now := time.Now().UTC()
 	r := &run.Run{
 		Entity:     cortex.NewEntity(),
 		ID:         id.NewAgentRunID(),
 		AgentID:    ag.ID,
+		TenantID:   cortex.TenantFromContext(ctx),
+		SessionID:  sessionID,
 		State:      run.StateRunning,
 		Input:      input,
 		StartedAt:  &now,
@@ -700,6 +914,7 @@ func (e *Engine) streamMock(ctx context.Context, ag *agent.Config, input string,
 		if err := e.store.UpdateRun(ctx, r); err != nil {
 			e.logger.Error("update run", log.String("error", err.Error()))
 		}
+		e.touchSession(ctx, r)
 
 		e.extensions.EmitRunCompleted(ctx, ag.ID, r.ID, r.Output, completedAt.Sub(now))
 
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/memory"
+)
+
+// factExtractionPrompt is the system prompt of the call that extracts facts
+// from a completed run.
+const factExtractionPrompt = "You maintain the long-term memory of an assistant. " +
+	"Extract from the conversation below the durable facts worth knowing in later conversations: " +
+	"user preferences, decisions, and entities such as people, organizations, projects and products. " +
+	"Leave out small talk, one-off requests and anything only relevant to this conversation. " +
+	"Give each fact a short snake_case key naming what it is about, a category (preference, decision, entity or other) and a one-sentence content. " +
+	"Known facts are listed with their keys. To update a known fact that changed or is contradicted, reuse its key; " +
+	"to drop one that no longer holds, list its key under forget. Do not repeat known facts that did not change. " +
+	`Answer with JSON only: {"facts": [{"key": "...", "category": "...", "content": "..."}], "forget": ["..."]}`
+
+// factExtraction is the answer of a fact extraction call.
+type factExtraction struct {
+	Facts  []memory.Fact `json:"facts"`
+	Forget []string      `json:"forget"`
+}
+
+// recallFacts returns the contents of the agent's facts for the run's
+// tenant that are most relevant to input, at most FactRecallLimit. Facts
+// sharing more words with input come first; ties and facts sharing none
+// follow in order of recency. Failures are logged and recall nothing.
+func (e *Engine) recallFacts(ctx context.Context, rr *reactRun, input string) []string {
+	if !e.config.FactMemory || e.config.FactRecallLimit <= 0 {
+		return nil
+	}
+	facts, err := e.store.ListFacts(ctx, rr.ag.ID, rr.r.TenantID)
+	if err != nil {
+		e.logger.Warn("list facts",
+			log.String("agent_id", rr.ag.ID.String()),
+			log.String("error", err.Error()),
+		)
+		return nil
+	}
+
+	terms := factTerms(input)
+	scores := make(map[string]int, len(facts))
+	for _, f := range facts {
+		for t := range factTerms(strings.ReplaceAll(f.Key, "_", " ") + " " + f.Content) {
+			if terms[t] {
+				scores[f.Key]++
+			}
+		}
+	}
+	slices.SortStableFunc(facts, func(a, b memory.Fact) int {
+		return cmp.Compare(scores[b.Key], scores[a.Key])
+	})
+
+	recalled := make([]string, 0, min(len(facts), e.config.FactRecallLimit))
+	for _, f := range facts[:min(len(facts), e.config.FactRecallLimit)] {
+		recalled = append(recalled, f.Content)
+	}
+	return recalled
+}
+
+// factTerms returns the lowercased words of text of three or more letters
+// or digits.
+func factTerms(text string) map[string]bool {
+	terms := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 3 {
+			terms[w] = true
+		}
+	}
+	return terms
+}
+
+// extractFacts asks the fact model for the durable facts in the run's new
+// messages and merges them into the agent's facts for the run's tenant: new
+// keys are added, known keys are replaced and forgotten keys are deleted.
+// Facts whose content is already known are skipped. Failures are logged and
+// leave the facts as they are. It runs after the run completed, so its
+// tokens count against budgets but not the run's TokensUsed.
+func (e *Engine) extractFacts(ctx context.Context, rr *reactRun) {
+	if !e.config.FactMemory {
+		return
+	}
+	transcript := factTranscript(rr.st.newMessages())
+	if transcript == "" {
+		return
+	}
+	known, err := e.store.ListFacts(ctx, rr.ag.ID, rr.r.TenantID)
+	if err != nil {
+		e.logger.Warn("list facts for extraction", log.String("error", err.Error()))
+		return
+	}
+
+	resp, err := e.llm.Complete(ctx, &llm.Request{
+		Model:     coalesceStr(e.config.FactModel, rr.cfg.Model),
+		System:    factExtractionPrompt,
+		Messages:  []llm.Message{{Role: "user", Content: factInput(known, transcript)}},
+		MaxTokens: rr.cfg.MaxTokens,
+	})
+	if err != nil {
+		e.logger.Warn("extract facts", log.String("error", err.Error()))
+		return
+	}
+	e.recordUsage(ctx, rr, resp.Usage.TotalTokens)
+
+	ext, ok := parseFactExtraction(resp.Content)
+	if !ok {
+		e.logger.Warn("extract facts: unparsable answer", log.String("run_id", rr.r.ID.String()))
+		return
+	}
+
+	for _, key := range ext.Forget {
+		err := e.store.DeleteFact(ctx, rr.ag.ID, rr.r.TenantID, normalizeFactKey(key))
+		if err != nil && !errors.Is(err, cortex.ErrFactNotFound) {
+			e.logger.Error("delete fact", log.String("error", err.Error()))
+		}
+	}
+	for _, f := range ext.Facts {
+		f.Key = normalizeFactKey(f.Key)
+		f.Content = strings.TrimSpace(f.Content)
+		if f.Key == "" || f.Content == "" || repeatsKnownFact(known, f) {
+			continue
+		}
+		f.Category = normalizeFactCategory(f.Category)
+		f.RunID = rr.r.ID
+		if err := e.store.SaveFact(ctx, rr.ag.ID, rr.r.TenantID, &f); err != nil {
+			e.logger.Error("save fact", log.String("error", err.Error()))
+		}
+	}
+}
+
+// factTranscript renders the user and assistant messages of msgs as the
+// conversation of a fact extraction call.
+func factTranscript(msgs []llm.Message) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		if (m.Role != "user" && m.Role != "assistant") || m.Content == "" {
+			continue
+		}
+		b.WriteString(m.Role + ": " + m.Content + "\n")
+	}
+	return b.String()
+}
+
+// factInput renders the known facts and the transcript as the input of a
+// fact extraction call.
+func factInput(known []memory.Fact, transcript string) string {
+	var b strings.Builder
+	b.WriteString("Known facts:\n")
+	if len(known) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, f := range known {
+		b.WriteString("- " + f.Key + " (" + string(f.Category) + "): " + f.Content + "\n")
+	}
+	b.WriteString("\nConversation:\n" + transcript)
+	return b.String()
+}
+
+// parseFactExtraction decodes the JSON object in a fact extraction answer,
+// ignoring any text or code fence around it.
+func parseFactExtraction(content string) (factExtraction, bool) {
+	var ext factExtraction
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return ext, false
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &ext); err != nil {
+		return ext, false
+	}
+	return ext, true
+}
+
+// repeatsKnownFact reports whether a known fact, under any key, has the
+// content of f.
+func repeatsKnownFact(known []memory.Fact, f memory.Fact) bool {
+	for _, k := range known {
+		if strings.EqualFold(k.Content, f.Content) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFactKey lowercases key and joins its words with underscores.
+func normalizeFactKey(key string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	}), "_")
+}
+
+// normalizeFactCategory returns c if it is a known category, else
+// memory.FactOther.
+func normalizeFactCategory(c memory.FactCategory) memory.FactCategory {
+	c = memory.FactCategory(strings.ToLower(strings.TrimSpace(string(c))))
+	switch c {
+	case memory.FactPreference, memory.FactDecision, memory.FactEntity:
+		return c
+	default:
+		return memory.FactOther
+	}
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/budget"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/memory"
+	"github.com/xraph/cortex/run"
+)
+
+// factConfig returns the default config with fact memory enabled.
+func factConfig() cortex.Config {
+	cfg := cortex.DefaultConfig()
+	cfg.FactMemory = true
+	return cfg
+}
+
+func TestExtractFacts_MergesIntoTenantFacts(t *testing.T) {
+	ctx := cortex.WithTenant(context.Background(), "acme")
+	client := &scriptedLLM{responses: []*llm.Response{
+		{Content: "Noted, I will answer in French from now on.", Usage: llm.Usage{TotalTokens: 5}},
+		{Content: "```json\n" + `{"facts": [
+			{"key": "Reply Language", "category": "preference", "content": "Wants replies in French"},
+			{"key": "home_city", "category": "Preference", "content": "lives in berlin"},
+			{"key": "project", "category": "milestone", "content": "Works on the Apollo launch"}
+		], "forget": ["old_plan"]}` + "\n```", Usage: llm.Usage{TotalTokens: 7}},
+	}}
+	e := newBudgetEngine(t, client, factConfig(), &agent.Config{})
+	ag, err := e.GetAgentByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+	for _, f := range []memory.Fact{
+		{Key: "reply_language", Category: memory.FactPreference, Content: "Wants replies in English"},
+		{Key: "city", Category: memory.FactEntity, Content: "Lives in Berlin"},
+		{Key: "old_plan", Category: memory.FactDecision, Content: "Migrate in March"},
+	} {
+		if err := e.store.SaveFact(ctx, ag.ID, "acme", &f); err != nil {
+			t.Fatalf("SaveFact: %v", err)
+		}
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "Please always answer in French.", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	// The run is complete before the facts are extracted in the background.
+	if r.State != run.StateCompleted || r.TokensUsed != 5 {
+		t.Errorf("run = %s using %d tokens, want completed using 5", r.State, r.TokensUsed)
+	}
+	e.background.Wait()
+	used, err := e.store.SumUsage(ctx, budget.ScopeTenant, "acme", time.Time{})
+	if err != nil || used != 12 {
+		t.Errorf("tenant usage = %d, %v; want the extraction call counted", used, err)
+	}
+
+	extraction := client.lastRequest()
+	if extraction.System != factExtractionPrompt ||
+		!strings.Contains(extraction.Messages[0].Content, "- reply_language (preference): Wants replies in English") ||
+		!strings.Contains(extraction.Messages[0].Content, "user: Please always answer in French.") {
+		t.Errorf("extraction input = %q", extraction.Messages[0].Content)
+	}
+
+	facts, err := e.ListFacts(ctx, ag.ID, "acme")
+	if err != nil {
+		t.Fatalf("ListFacts: %v", err)
+	}
+	got := make(map[string]memory.Fact)
+	for _, f := range facts {
+		got[f.Key] = f
+	}
+	if len(got) != 3 {
+		t.Errorf("facts = %+v; want reply_language, city and project", facts)
+	}
+	if f := got["reply_language"]; f.Content != "Wants replies in French" || f.RunID != r.ID {
+		t.Errorf("reply_language = %+v; want updated by the run", f)
+	}
+	if _, ok := got["home_city"]; ok {
+		t.Error("fact repeating a known fact under another key was saved")
+	}
+	if _, ok := got["old_plan"]; ok {
+		t.Error("forgotten fact was kept")
+	}
+	if f := got["project"]; f.Category != memory.FactOther {
+		t.Errorf("project category = %q, want other for an unknown category", f.Category)
+	}
+
+	if other, err := e.ListFacts(ctx, ag.ID, "globex"); err != nil || len(other) != 0 {
+		t.Errorf("other tenant's facts = %+v, %v; want none", other, err)
+	}
+}
+
+func TestRecallFacts_AddsRelevantFactsToPrompt(t *testing.T) {
+	ctx := cortex.WithTenant(context.Background(), "acme")
+	cfg := factConfig()
+	cfg.FactRecallLimit = 2
+	client := &scriptedLLM{responses: []*llm.Response{{Content: "done", Usage: llm.Usage{TotalTokens: 1}}, {Content: "{}"}}}
+	e := newBudgetEngine(t, client, cfg, &agent.Config{})
+	ag, err := e.GetAgentByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+	save := func(tenantID, key, content string) {
+		t.Helper()
+		if err := e.store.SaveFact(ctx, ag.ID, tenantID, &memory.Fact{Key: key, Content: content}); err != nil {
+			t.Fatalf("SaveFact: %v", err)
+		}
+	}
+	save("acme", "invoice_email", "Invoices go to billing@acme.test")
+	save("acme", "reply_language", "Wants replies in French")
+	save("acme", "deploy_window", "Deploys only on Tuesdays")
+	save("globex", "secret", "Globex invoices are confidential")
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "Where should the invoices be sent?", nil); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	e.background.Wait()
+
+	system := client.requests[0].System
+	want := "## Known facts\n- Invoices go to billing@acme.test\n- Deploys only on Tuesdays\n"
+	if !strings.Contains(system, want) {
+		t.Errorf("system prompt = %q; want the matching fact, then the most recent", system)
+	}
+	if strings.Contains(system, "French") || strings.Contains(system, "Globex") {
+		t.Errorf("system prompt = %q; want at most 2 facts of the run's tenant", system)
+	}
+}
+
+func TestFactMemoryDisabled_NoExtractionOrRecall(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{})
+	ag, err := e.GetAgentByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+	if err := e.store.SaveFact(ctx, ag.ID, "", &memory.Fact{Key: "reply_language", Content: "Wants replies in French"}); err != nil {
+		t.Fatalf("SaveFact: %v", err)
+	}
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "Hello in French", nil); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if len(client.requests) != 1 {
+		t.Errorf("model called %d times, want no extraction call", len(client.requests))
+	}
+	if strings.Contains(client.requests[0].System, "Known facts") {
+		t.Error("facts recalled with fact memory disabled")
+	}
+}
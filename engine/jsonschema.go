@@ -0,0 +1,345 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xraph/cortex"
+)
+
+// schemaKeywords are the JSON Schema keywords validateSchema supports: the
+// keywords used to describe structured output, and annotations with no
+// effect on validation.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "format": true, "deprecated": true,
+	"readOnly": true, "writeOnly": true,
+}
+
+// checkSchema returns cortex.ErrOutputSchemaInvalid describing the problems
+// of a schema validateSchema cannot enforce: keywords it does not support,
+// such as $ref, and patterns that are not valid regular expressions. A nil
+// schema is valid.
+func checkSchema(schema map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	normalized, err := normalizeSchema(schema)
+	if err != nil {
+		return fmt.Errorf("%w: %s", cortex.ErrOutputSchemaInvalid, err.Error())
+	}
+	var v schemaValidator
+	v.check("$", normalized)
+	if len(v.problems) > 0 {
+		return fmt.Errorf("%w: %s", cortex.ErrOutputSchemaInvalid, strings.Join(v.problems, "; "))
+	}
+	return nil
+}
+
+// validateSchema checks value, a decoded JSON value, against a JSON Schema
+// and returns the problems found, each prefixed with the path of the
+// offending value. It supports the keywords in schemaKeywords; schemas are
+// checked with checkSchema when they are set, so others do not reach it.
+func validateSchema(schema map[string]any, value any) []string {
+	normalized, err := normalizeSchema(schema)
+	if err != nil {
+		return []string{"invalid output schema: " + err.Error()}
+	}
+
+	var v schemaValidator
+	v.validate("$", normalized, value)
+	return v.problems
+}
+
+// normalizeSchema round-trips schema through JSON so schemas written as Go
+// literals hold the same types as decoded ones.
+func normalizeSchema(schema map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// schemaValidator collects the problems found while validating a value.
+type schemaValidator struct {
+	problems []string
+}
+
+func (v *schemaValidator) addf(path, format string, args ...any) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// matches reports whether value is valid against schema, without recording
+// problems.
+func matches(schema, value any) bool {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return true
+	}
+	var sub schemaValidator
+	sub.validate("$", s, value)
+	return len(sub.problems) == 0
+}
+
+// check records the unsupported keywords and invalid patterns of schema and
+// of the schemas nested in it.
+func (v *schemaValidator) check(path string, schema map[string]any) {
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if !schemaKeywords[k] {
+			v.addf(path, "keyword %q is not supported", k)
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			v.addf(path, "invalid pattern %q: %v", pattern, err)
+		}
+	}
+
+	if props, ok := schema["properties"].(map[string]any); ok {
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if sub, ok := props[name].(map[string]any); ok {
+				v.check(path+".properties."+name, sub)
+			}
+		}
+	}
+	if sub, ok := schema["additionalProperties"].(map[string]any); ok {
+		v.check(path+".additionalProperties", sub)
+	}
+	switch items := schema["items"].(type) {
+	case map[string]any:
+		v.check(path+".items", items)
+	case []any:
+		v.addf(path, "keyword \"items\" must be a schema, not a list")
+	}
+	for _, k := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := schema[k].([]any)
+		for i, s := range list {
+			if sub, ok := s.(map[string]any); ok {
+				v.check(fmt.Sprintf("%s.%s[%d]", path, k, i), sub)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validate(path string, schema map[string]any, value any) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 &&
+		!slices.ContainsFunc(types, func(t string) bool { return hasJSONType(value, t) }) {
+		v.addf(path, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok &&
+		!slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+		v.addf(path, "must be one of %s", compactJSON(enum))
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		v.addf(path, "must be %s", compactJSON(c))
+	}
+
+	switch x := value.(type) {
+	case map[string]any:
+		v.validateObject(path, schema, x)
+	case []any:
+		v.validateArray(path, schema, x)
+	case string:
+		v.validateString(path, schema, x)
+	case float64:
+		v.validateNumber(path, schema, x)
+	}
+
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, s := range all {
+			if sub, ok := s.(map[string]any); ok {
+				v.validate(path, sub, value)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok &&
+		!slices.ContainsFunc(anyOf, func(s any) bool { return matches(s, value) }) {
+		v.addf(path, "does not match any of the anyOf schemas")
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		n := 0
+		for _, s := range oneOf {
+			if matches(s, value) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.addf(path, "matches %d of the oneOf schemas, want exactly one", n)
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(path string, schema, obj map[string]any) {
+	props, _ := schema["properties"].(map[string]any)
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					v.addf(path, "missing required property %q", name)
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if sub, ok := props[k].(map[string]any); ok {
+			v.validate(path+"."+k, sub, obj[k])
+			continue
+		}
+		if _, declared := props[k]; declared {
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.addf(path, "property %q is not allowed", k)
+			}
+		case map[string]any:
+			v.validate(path+"."+k, extra, obj[k])
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(path string, schema map[string]any, arr []any) {
+	if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.addf(path, "must have at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.addf(path, "must have at most %v items, got %d", n, len(arr))
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			v.validate(fmt.Sprintf("%s[%d]", path, i), items, item)
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(path string, schema map[string]any, s string) {
+	n := float64(utf8.RuneCountInString(s))
+	if limit, ok := schemaNumber(schema["minLength"]); ok && n < limit {
+		v.addf(path, "must be at least %v characters long", limit)
+	}
+	if limit, ok := schemaNumber(schema["maxLength"]); ok && n > limit {
+		v.addf(path, "must be at most %v characters long", limit)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(s) {
+			v.addf(path, "must match the pattern %q", pattern)
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(path string, schema map[string]any, x float64) {
+	if n, ok := schemaNumber(schema["minimum"]); ok && x < n {
+		v.addf(path, "must be at least %v", n)
+	}
+	if n, ok := schemaNumber(schema["maximum"]); ok && x > n {
+		v.addf(path, "must be at most %v", n)
+	}
+	if n, ok := schemaNumber(schema["exclusiveMinimum"]); ok && x <= n {
+		v.addf(path, "must be greater than %v", n)
+	}
+	if n, ok := schemaNumber(schema["exclusiveMaximum"]); ok && x >= n {
+		v.addf(path, "must be less than %v", n)
+	}
+}
+
+// schemaTypes returns the types named by a type keyword, given as a string
+// or a list of strings.
+func schemaTypes(t any) []string {
+	switch x := t.(type) {
+	case string:
+		return []string{x}
+	case []any:
+		types := make([]string, 0, len(x))
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// schemaNumber returns the value of a numeric keyword.
+func schemaNumber(n any) (float64, bool) {
+	f, ok := n.(float64)
+	return f, ok
+}
+
+// hasJSONType reports whether value is of the JSON Schema type t.
+func hasJSONType(value any, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+// jsonType returns the JSON type of a decoded JSON value.
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// compactJSON renders v as JSON for a problem description.
+func compactJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
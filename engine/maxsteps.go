@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/checkpoint"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// maxStepsKey is the run metadata key recording the MaxStepsPolicy applied
+// when the run reached its step limit.
+const maxStepsKey = "max_steps_reached"
+
+// checkpointKindMaxSteps marks, under the checkpoint metadata key "kind", a
+// checkpoint asking whether to grant a run more steps.
+const checkpointKindMaxSteps = "max_steps"
+
+// summarizePrompt asks the model for a final answer once the steps run out.
+const summarizePrompt = "You have reached the maximum number of steps and cannot call any more tools. " +
+	"Answer now with what you have so far, and say what remains unresolved."
+
+// stepLimit returns the number of steps the run may take: MaxSteps plus the
+// steps granted at max-steps checkpoints.
+func (rr *reactRun) stepLimit() int {
+	return rr.cfg.MaxSteps + rr.st.ExtraSteps
+}
+
+// stepsExhausted applies the run's MaxStepsPolicy once it has taken all its
+// steps without a final answer, and records the policy on the run.
+func (e *Engine) stepsExhausted(ctx context.Context, rr *reactRun) (*run.Run, error) {
+	r := rr.r
+	policy := rr.cfg.MaxStepsPolicy
+	if r.Metadata == nil {
+		r.Metadata = make(map[string]any)
+	}
+
+	switch policy {
+	case cortex.MaxStepsSummarize:
+		r.Metadata[maxStepsKey] = string(policy)
+		return e.summarizeRun(ctx, rr)
+	case cortex.MaxStepsCheckpoint:
+		r.Metadata[maxStepsKey] = string(policy)
+		if err := e.pauseForSteps(ctx, rr); err != nil {
+			return nil, err
+		}
+		return r, nil
+	default:
+		r.Metadata[maxStepsKey] = string(cortex.MaxStepsFail)
+		err := fmt.Errorf("%w: %d steps", cortex.ErrMaxStepsReached, rr.stepLimit())
+		e.failRun(ctx, r, rr.ag.ID, err)
+		return nil, err
+	}
+}
+
+// summarizeRun makes a final model call without tools asking for an answer
+// from what the run has gathered, and completes the run with it. An answer
+// not matching the run's output schema is repaired with further calls.
+func (e *Engine) summarizeRun(ctx context.Context, rr *reactRun) (*run.Run, error) {
+	r := rr.r
+	// The summary prompt is kept out of conversation memory.
+	rr.st.Unsaved = append(rr.st.Unsaved, len(rr.st.Messages))
+	rr.st.Messages = append(rr.st.Messages, llm.Message{Role: "user", Content: summarizePrompt})
+
+	for {
+		if err := e.checkBudgets(ctx, rr); err != nil {
+			e.failRun(ctx, r, rr.ag.ID, err)
+			return nil, err
+		}
+
+		stepStart := time.Now().UTC()
+		stepIndex := rr.st.Step
+		e.extensions.EmitStepStarted(ctx, r.ID, stepIndex)
+
+		prompt := rr.prompt.withFacts(rr.st.Facts).withSummary(rr.st.Summary)
+		req := &llm.Request{
+			Model:        rr.cfg.Model,
+			System:       prompt.String(),
+			Messages:     rr.st.Messages,
+			MaxTokens:    rr.cfg.MaxTokens,
+			Temperature:  rr.cfg.Temperature,
+			OutputSchema: rr.cfg.OutputSchema,
+		}
+		trimmed := e.fitContext(req, rr.st.History, prompt, "")
+		resp, err := e.llm.Complete(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			e.cancelReactRun(ctx, rr, "")
+			return r, nil
+		}
+		if err != nil {
+			e.failRun(ctx, r, rr.ag.ID, err)
+			return nil, fmt.Errorf("llm complete: %w", err)
+		}
+		rr.st.TotalTokens += resp.Usage.TotalTokens
+		e.recordUsage(ctx, rr, resp.Usage.TotalTokens)
+
+		stepEnd := time.Now().UTC()
+		step := &run.Step{
+			Entity:      cortex.NewEntity(),
+			ID:          id.NewStepID(),
+			RunID:       r.ID,
+			Index:       stepIndex,
+			Type:        "summary",
+			Input:       lastContent(rr.st.Messages),
+			Output:      resp.Content,
+			TokensUsed:  resp.Usage.TotalTokens,
+			Metadata:    trimmed,
+			StartedAt:   &stepStart,
+			CompletedAt: &stepEnd,
+		}
+		if err := e.store.CreateStep(ctx, step); err != nil {
+			e.logger.Error("create step", log.String("error", err.Error()))
+		}
+		e.extensions.EmitStepCompleted(ctx, r.ID, stepIndex, stepEnd.Sub(stepStart))
+		rr.st.Step++
+
+		finalOutput, blocked := e.scanOutput(ctx, rr, rr.styleOutput(resp.Content))
+		if blocked != nil {
+			e.failRun(ctx, r, rr.ag.ID, fmt.Errorf("safety: output blocked — %s", blocked.Decision))
+			return nil, fmt.Errorf("safety: output blocked by %s profile", blocked.ProfileUsed)
+		}
+		if repair, err := e.checkOutput(ctx, rr, finalOutput); err != nil {
+			e.failRun(ctx, r, rr.ag.ID, err)
+			return nil, err
+		} else if repair {
+			continue
+		}
+
+		rr.st.Messages = append(rr.st.Messages, llm.Message{Role: "assistant", Content: finalOutput})
+		e.completeRun(ctx, rr, finalOutput)
+		return r, nil
+	}
+}
+
+// pauseForSteps creates a checkpoint asking whether to grant the run more
+// steps and moves the run to paused, persisting the loop state so
+// ResolveCheckpoint can resume it.
+func (e *Engine) pauseForSteps(ctx context.Context, rr *reactRun) error {
+	r := rr.r
+	cp := &checkpoint.Checkpoint{
+		Entity:    cortex.NewEntity(),
+		ID:        id.NewCheckpointID(),
+		RunID:     r.ID,
+		AgentID:   rr.ag.ID,
+		TenantID:  r.TenantID,
+		Reason:    fmt.Sprintf("run reached its limit of %d steps; approve to grant %d more", rr.stepLimit(), rr.cfg.MaxSteps),
+		StepIndex: rr.st.Step,
+		State:     checkpoint.StatePending,
+		Metadata: map[string]any{
+			"kind":      checkpointKindMaxSteps,
+			"max_steps": rr.stepLimit(),
+		},
+	}
+	if err := e.store.CreateCheckpoint(ctx, cp); err != nil {
+		err = fmt.Errorf("create checkpoint: %w", err)
+		e.failRun(ctx, r, rr.ag.ID, err)
+		return err
+	}
+
+	if err := rr.saveState(); err != nil {
+		e.failRun(ctx, r, rr.ag.ID, err)
+		return err
+	}
+	r.State = run.StatePaused
+	r.StepCount = rr.st.Step
+	r.TokensUsed = rr.st.TotalTokens
+	if err := e.store.UpdateRun(ctx, r); err != nil {
+		e.logger.Error("update run on pause", log.String("error", err.Error()))
+	}
+
+	e.extensions.EmitCheckpointCreated(ctx, cp.ID, r.ID, cp.Reason)
+	if rr.events != nil {
+		rr.events <- StreamEvent{Type: EventCheckpoint, Data: map[string]any{
+			"checkpoint_id": cp.ID.String(),
+			"run_id":        r.ID.String(),
+			"reason":        cp.Reason,
+		}}
+	}
+	return nil
+}
+
+// isMaxStepsCheckpoint reports whether cp was created by pauseForSteps.
+func isMaxStepsCheckpoint(cp *checkpoint.Checkpoint) bool {
+	return cp.Metadata["kind"] == checkpointKindMaxSteps
+}
+
+// resumeSteps continues a run paused at a max-steps checkpoint. Approval
+// grants it another MaxSteps steps; rejection fails it with
+// cortex.ErrMaxStepsReached.
+func (e *Engine) resumeSteps(ctx context.Context, rr *reactRun, decision checkpoint.Decision) (*run.Run, error) {
+	if !decision.Approved {
+		err := fmt.Errorf("%w: %d steps; more steps rejected", cortex.ErrMaxStepsReached, rr.stepLimit())
+		if decision.Reason != "" {
+			err = fmt.Errorf("%w: %s", err, decision.Reason)
+		}
+		e.failRun(ctx, rr.r, rr.ag.ID, err)
+		return rr.r, nil
+	}
+	rr.st.ExtraSteps += rr.cfg.MaxSteps
+	return e.reactLoop(ctx, rr)
+}
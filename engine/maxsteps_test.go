@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/checkpoint"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// toolSteps returns n tool call steps.
+func toolSteps(n int) []*llm.Response {
+	steps := make([]*llm.Response, n)
+	for i := range steps {
+		steps[i] = budgetStep(1)
+	}
+	return steps
+}
+
+func TestRunAgent_FailsAtMaxStepsByDefault(t *testing.T) {
+	ctx := context.Background()
+	e := newBudgetEngine(t, &scriptedLLM{responses: toolSteps(3)}, cortex.DefaultConfig(), &agent.Config{MaxSteps: 2})
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "work", nil); !errors.Is(err, cortex.ErrMaxStepsReached) {
+		t.Fatalf("RunAgent err = %v, want ErrMaxStepsReached", err)
+	}
+	runs, err := e.store.ListRuns(ctx, &run.ListFilter{State: run.StateFailed})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("failed runs = %d, %v; want 1", len(runs), err)
+	}
+	if runs[0].StepCount != 2 || runs[0].Metadata[maxStepsKey] != "fail" {
+		t.Errorf("run = %+v, want 2 steps and the fail policy recorded", runs[0])
+	}
+}
+
+func TestRunAgent_SummarizesAtMaxSteps(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: append(toolSteps(2), &llm.Response{Content: "partial answer"})}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{MaxSteps: 2})
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", &RunOverrides{MaxStepsPolicy: cortex.MaxStepsSummarize})
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.State != run.StateCompleted || r.Output != "partial answer" || r.Metadata[maxStepsKey] != "summarize" {
+		t.Fatalf("run = %+v, want completed with the summary", r)
+	}
+	req := client.lastRequest()
+	if len(req.Tools) != 0 || req.Messages[len(req.Messages)-1].Content != summarizePrompt {
+		t.Errorf("summary request = %d tools, last message %q", len(req.Tools), req.Messages[len(req.Messages)-1].Content)
+	}
+	history, err := e.LoadConversation(ctx, r.AgentID, "", id.Nil, 0)
+	if err != nil || len(history) == 0 {
+		t.Fatalf("conversation = %+v, %v", history, err)
+	}
+	for _, m := range history {
+		if m.Content == summarizePrompt {
+			t.Errorf("conversation = %+v, want the summary prompt left out", history)
+		}
+	}
+}
+
+func TestRunAgent_CheckpointGrantsMoreSteps(t *testing.T) {
+	ctx := context.Background()
+	cfg := cortex.DefaultConfig()
+	cfg.MaxStepsPolicy = cortex.MaxStepsCheckpoint
+	client := &scriptedLLM{responses: toolSteps(4)}
+	e := newBudgetEngine(t, client, cfg, &agent.Config{MaxSteps: 2})
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.State != run.StatePaused {
+		t.Fatalf("run state = %s, want paused", r.State)
+	}
+	cps, err := e.store.ListPending(ctx, &checkpoint.ListFilter{RunID: r.ID.String()})
+	if err != nil || len(cps) != 1 {
+		t.Fatalf("ListPending = %d, %v; want 1", len(cps), err)
+	}
+
+	// Two more tool steps use the granted steps; the run pauses again.
+	if r = resolveAndWait(t, e, cps[0], checkpoint.Decision{Approved: true}); r.State != run.StatePaused || r.StepCount != 4 {
+		t.Fatalf("run = %s after %d steps, want paused after 4", r.State, r.StepCount)
+	}
+	if cps, err = e.store.ListPending(ctx, &checkpoint.ListFilter{RunID: r.ID.String()}); err != nil || len(cps) != 1 {
+		t.Fatalf("ListPending = %d, %v; want 1", len(cps), err)
+	}
+
+	if r = resolveAndWait(t, e, cps[0], checkpoint.Decision{Reason: "enough"}); r.State != run.StateFailed || r.Metadata[maxStepsKey] != "checkpoint" {
+		t.Fatalf("run = %+v, want failed with the checkpoint policy recorded", r)
+	}
+}
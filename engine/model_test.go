@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+)
+
+// overloadedLLM fails every call to the model "primary" as overloaded and
+// answers calls to any other model.
+type overloadedLLM struct{}
+
+func (overloadedLLM) Complete(_ context.Context, req *llm.Request) (*llm.Response, error) {
+	if req.Model == "primary" {
+		return nil, &llm.StatusError{StatusCode: 529, Err: errors.New("overloaded")}
+	}
+	return &llm.Response{Content: "done", Usage: llm.Usage{TotalTokens: 1}}, nil
+}
+
+func (overloadedLLM) CompleteStream(context.Context, *llm.Request) (llm.Stream, error) {
+	return nil, errors.New("overloadedLLM: streaming not supported")
+}
+
+func TestRunAgent_RecordsAnsweringModel(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	e, err := New(WithStore(s), WithLLM(llm.NewFallbackClient(overloadedLLM{}, "backup")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1", Model: "primary"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if got, err := s.GetRun(ctx, r.ID); err != nil || got.Metadata[modelKey] != "backup" {
+		t.Fatalf("run metadata = %v, %v; want model backup", got.Metadata, err)
+	}
+	steps, err := s.ListSteps(ctx, r.ID)
+	if err != nil || len(steps) != 1 || steps[0].Metadata[modelKey] != "backup" {
+		t.Fatalf("steps = %+v, %v; want one answered by backup", steps, err)
+	}
+}
@@ -78,18 +78,55 @@ func WithKnowledge(provider knowledge.Provider) Option {
 	}
 }
 
+// WithTokenizer sets the tokenizer the engine counts prompt tokens with when
+// fitting requests into a model's context window. The default estimates four
+// bytes per token.
+func WithTokenizer(t llm.Tokenizer) Option {
+	return func(e *Engine) error {
+		e.tokenizer = t
+		return nil
+	}
+}
+
 // ToolHandler executes a registered tool. arguments is the raw JSON argument
 // string from the LLM tool call; the return string is the tool result fed back
-// to the model.
+// to the model. Handlers must return promptly once ctx is done: a handler
+// that outlives its ToolTimeout is abandoned, not stopped.
 type ToolHandler func(ctx context.Context, arguments string) (string, error)
 
 // WithTool registers an externally-provided executable tool. The def is
 // advertised to the LLM (resolveTools); the handler runs when the model calls
 // it (executeTool). Registering tools with the same name appends both; the
-// first match wins at dispatch.
-func WithTool(def llm.Tool, h ToolHandler) Option {
+// first match wins at dispatch. An agent only sees the tool when it is named
+// in its Tools list or bound by one of its skills.
+func WithTool(def llm.Tool, h ToolHandler, opts ...ToolOption) Option {
 	return func(e *Engine) error {
-		e.tools = append(e.tools, registeredTool{def: def, handler: h})
+		rt := registeredTool{def: def, handler: h}
+		for _, opt := range opts {
+			opt(&rt)
+		}
+		e.tools = append(e.tools, rt)
 		return nil
 	}
 }
+
+// ToolOption configures a tool registered with WithTool.
+type ToolOption func(*registeredTool)
+
+// RequireApproval makes every call to the tool pause the run at a checkpoint
+// until a human approves or rejects it through ResolveCheckpoint.
+func RequireApproval() ToolOption {
+	return func(rt *registeredTool) {
+		rt.requireApproval = true
+	}
+}
+
+// Sequential marks the tool as unsafe to run concurrently with other tool
+// calls. When the model requests it together with other tools in one
+// response, it runs alone, after the calls before it and before the calls
+// after it.
+func Sequential() ToolOption {
+	return func(rt *registeredTool) {
+		rt.sequential = true
+	}
+}
@@ -63,6 +63,9 @@ func (e *Engine) RunOrchestration(ctx context.Context, appID, name, input string
 	if e.store == nil {
 		return nil, cortex.ErrNoStore
 	}
+	if err := e.checkTenant(ctx); err != nil {
+		return nil, err
+	}
 	svc := orchestration.NewService(
 		agentRunnerAdapter{eng: e},
 		e.store,
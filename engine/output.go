@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// structuredOutputKey is the run metadata key holding the final answer of a
+// run with an output schema, decoded from JSON.
+const structuredOutputKey = "structured_output"
+
+// StructuredOutput returns the final answer of a run completed with an
+// output schema, decoded from JSON; nil for other runs.
+func StructuredOutput(r *run.Run) any {
+	return r.Metadata[structuredOutputKey]
+}
+
+// withOutputSchema returns a copy of p ending with a section asking for
+// answers matching schema; p itself when schema is nil. Providers without
+// structured output only learn the schema from it.
+func (p *systemPrompt) withOutputSchema(schema map[string]any) *systemPrompt {
+	if schema == nil {
+		return p
+	}
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return p
+	}
+	cp := *p
+	cp.tail = append(append([]string(nil), p.tail...),
+		"\n## Output format\nWhen you give your final answer, answer with a single JSON value matching this JSON Schema and nothing else:\n"+string(b))
+	return &cp
+}
+
+// styleOutput applies the communication style to a final answer. Answers
+// to an output schema are data and are left as they are.
+func (rr *reactRun) styleOutput(content string) string {
+	if rr.cfg.OutputSchema != nil {
+		return content
+	}
+	return rr.rp.CommunicationStyle.Enforce(content)
+}
+
+// checkOutput validates the final answer of a run with an output schema and
+// stores the decoded answer in the run's metadata. An invalid answer is
+// sent back to the model with the problems found while repair attempts
+// remain, and repair is reported; after that it fails with
+// cortex.ErrOutputInvalid. The invalid answer and the repair prompt are kept
+// out of conversation memory.
+func (e *Engine) checkOutput(ctx context.Context, rr *reactRun, output string) (repair bool, err error) {
+	if rr.cfg.OutputSchema == nil {
+		return false, nil
+	}
+	value, problems := parseOutput(output, rr.cfg.OutputSchema)
+	if len(problems) == 0 {
+		if rr.r.Metadata == nil {
+			rr.r.Metadata = make(map[string]any)
+		}
+		rr.r.Metadata[structuredOutputKey] = value
+		return false, nil
+	}
+	if rr.st.OutputRepairs >= e.config.OutputRepairAttempts {
+		return false, fmt.Errorf("%w: %s", cortex.ErrOutputInvalid, strings.Join(problems, "; "))
+	}
+
+	rr.st.OutputRepairs++
+	n := len(rr.st.Messages)
+	rr.st.Unsaved = append(rr.st.Unsaved, n, n+1)
+	rr.st.Messages = append(rr.st.Messages,
+		llm.Message{Role: "assistant", Content: output},
+		llm.Message{Role: "user", Content: outputRepairPrompt(problems)},
+	)
+	e.persistRunState(ctx, rr)
+	return true, nil
+}
+
+// checkOutputSchema returns cortex.ErrOutputSchemaInvalid when the output
+// schema a run of ag would use, from overrides or else the agent, has
+// keywords the validator does not support.
+func checkOutputSchema(ag *agent.Config, overrides *RunOverrides) error {
+	if overrides != nil && overrides.OutputSchema != nil {
+		return checkSchema(overrides.OutputSchema)
+	}
+	return checkSchema(ag.OutputSchema)
+}
+
+// outputRepairPrompt asks the model to correct a final answer that does not
+// match the output schema.
+func outputRepairPrompt(problems []string) string {
+	return "Your answer does not match the required JSON Schema:\n- " + strings.Join(problems, "\n- ") +
+		"\nAnswer again with only the corrected JSON value."
+}
+
+// parseOutput decodes the JSON value of a final answer, ignoring a code
+// fence around it, and validates it against schema. It returns the value
+// and the problems found.
+func parseOutput(content string, schema map[string]any) (any, []string) {
+	text := strings.TrimSpace(content)
+	if body, ok := strings.CutPrefix(text, "```"); ok {
+		// Drop the fence and the language tag following it.
+		_, body, _ = strings.Cut(body, "\n")
+		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "```"))
+	}
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, []string{"the answer is not valid JSON: " + err.Error()}
+	}
+	return value, validateSchema(schema, value)
+}
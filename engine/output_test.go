@@ -0,0 +1,217 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// invoiceSchema describes the structured answer of the output tests.
+func invoiceSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"vendor": map[string]any{"type": "string", "minLength": 1},
+			"total":  map[string]any{"type": "number", "minimum": 0},
+			"status": map[string]any{"enum": []string{"paid", "due"}},
+		},
+		"required":             []string{"vendor", "total"},
+		"additionalProperties": false,
+	}
+}
+
+func TestOutputSchema_RepairsInvalidAnswer(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{
+		{Content: `{"vendor": "Acme", "total": "12.50"}`, Usage: llm.Usage{TotalTokens: 1}},
+		{Content: "```json\n{\"vendor\": \"Acme\", \"total\": 12.5, \"status\": \"due\"}\n```", Usage: llm.Usage{TotalTokens: 1}},
+	}}
+	ag := &agent.Config{OutputSchema: invoiceSchema()}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), ag)
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "Extract the invoice", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+
+	first := client.requests[0]
+	if first.OutputSchema["type"] != "object" || !strings.Contains(first.System, "## Output format") {
+		t.Errorf("request schema = %v, system = %q; want the output schema passed on", first.OutputSchema, first.System)
+	}
+	repair := client.lastRequest().Messages
+	if msg := repair[len(repair)-1]; msg.Role != "user" || !strings.Contains(msg.Content, "$.total: expected number, got string") {
+		t.Errorf("repair message = %+v, want the validation problem", msg)
+	}
+
+	got, err := e.GetRun(ctx, r.ID)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	out, ok := StructuredOutput(got).(map[string]any)
+	if !ok || out["vendor"] != "Acme" || out["total"] != 12.5 || out["status"] != "due" {
+		t.Errorf("structured output = %#v, want the repaired answer", StructuredOutput(got))
+	}
+	if got.State != run.StateCompleted || got.StepCount != 2 {
+		t.Errorf("run = %s after %d steps, want completed after 2", got.State, got.StepCount)
+	}
+
+	history, err := e.LoadConversation(ctx, ag.ID, "", id.Nil, 0)
+	if err != nil || len(history) != 2 || history[0].Content != "Extract the invoice" || !strings.Contains(history[1].Content, `"status": "due"`) {
+		t.Errorf("conversation = %+v, %v; want the input and the repaired answer only", history, err)
+	}
+}
+
+func TestOutputSchema_FailsWhenRepairsRunOut(t *testing.T) {
+	ctx := context.Background()
+	cfg := cortex.DefaultConfig()
+	cfg.OutputRepairAttempts = 1
+	client := &scriptedLLM{responses: []*llm.Response{
+		{Content: "The vendor is Acme.", Usage: llm.Usage{TotalTokens: 1}},
+		{Content: `{"vendor": "Acme", "total": 3, "currency": "EUR"}`, Usage: llm.Usage{TotalTokens: 1}},
+	}}
+	e := newBudgetEngine(t, client, cfg, &agent.Config{OutputSchema: invoiceSchema()})
+
+	_, err := e.RunAgent(ctx, "app1", "worker", "Extract the invoice", nil)
+	if !errors.Is(err, cortex.ErrOutputInvalid) || !strings.Contains(err.Error(), `property "currency" is not allowed`) {
+		t.Fatalf("RunAgent err = %v, want ErrOutputInvalid", err)
+	}
+	runs, err := e.store.ListRuns(ctx, &run.ListFilter{State: run.StateFailed})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("failed runs = %+v, %v; want one", runs, err)
+	}
+	if len(client.requests) != 2 {
+		t.Errorf("model called %d times, want one repair", len(client.requests))
+	}
+}
+
+func TestOutputSchema_OverrideReplacesAgentSchema(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{{Content: `["a", "b"]`, Usage: llm.Usage{TotalTokens: 1}}}}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{OutputSchema: invoiceSchema()})
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "List the tags", &RunOverrides{
+		OutputSchema: map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	})
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if tags, ok := StructuredOutput(r).([]any); !ok || !slices.Equal(tags, []any{"a", "b"}) {
+		t.Errorf("structured output = %#v, want the tags", StructuredOutput(r))
+	}
+}
+
+func TestOutputSchema_RejectsUnsupportedKeywords(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{})
+
+	refSchema := map[string]any{"$ref": "#/$defs/invoice", "$defs": map[string]any{"invoice": invoiceSchema()}}
+	if _, err := e.RunAgent(ctx, "app1", "worker", "Extract the invoice", &RunOverrides{OutputSchema: refSchema}); !errors.Is(err, cortex.ErrOutputSchemaInvalid) {
+		t.Fatalf("RunAgent err = %v, want ErrOutputSchemaInvalid", err)
+	}
+	if len(client.requests) != 0 {
+		t.Errorf("model called %d times, want none", len(client.requests))
+	}
+
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "refs", AppID: "app1", OutputSchema: refSchema}
+	if err := e.CreateAgent(ctx, ag); !errors.Is(err, cortex.ErrOutputSchemaInvalid) {
+		t.Errorf("CreateAgent err = %v, want ErrOutputSchemaInvalid", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema map[string]any
+		want   string
+	}{
+		{"nil", nil, ""},
+		{"supported", invoiceSchema(), ""},
+		{"annotations", map[string]any{"title": "Tag", "description": "A tag", "type": "string", "format": "slug"}, ""},
+		{"ref", map[string]any{"$ref": "#/$defs/x"}, `$: keyword "$ref" is not supported`},
+		{"nested", map[string]any{"type": "object", "properties": map[string]any{
+			"owner": map[string]any{"not": map[string]any{"type": "null"}},
+		}}, `$.properties.owner: keyword "not" is not supported`},
+		{"tuple items", map[string]any{"type": "array", "items": []any{map[string]any{"type": "string"}}}, `$: keyword "items" must be a schema, not a list`},
+		{"invalid pattern", map[string]any{"anyOf": []any{map[string]any{"pattern": "("}}}, `$.anyOf[0]: invalid pattern "("`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSchema(tt.schema)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkSchema = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, cortex.ErrOutputSchemaInvalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkSchema = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputSchema_FreeTextWithoutSchema(t *testing.T) {
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{})
+
+	r, err := e.RunAgent(context.Background(), "app1", "worker", "Hello", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if r.Output != "done" || StructuredOutput(r) != nil || client.requests[0].OutputSchema != nil {
+		t.Errorf("output = %q, structured = %v; want free text", r.Output, StructuredOutput(r))
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":    map[string]any{"type": "integer", "exclusiveMinimum": 0},
+			"email": map[string]any{"type": "string", "pattern": "^[^@]+@[^@]+$"},
+			"tags": map[string]any{
+				"type": "array", "maxItems": 2,
+				"items": map[string]any{"type": "string", "maxLength": 5},
+			},
+			"owner": map[string]any{"anyOf": []any{
+				map[string]any{"type": "null"},
+				map[string]any{"type": "object", "required": []string{"name"}},
+			}},
+		},
+		"required": []string{"id"},
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  []string
+	}{
+		{"valid", map[string]any{"id": 7.0, "email": "a@b.test", "tags": []any{"x"}, "owner": nil}, nil},
+		{"wrong type", "x", []string{"$: expected object, got string"}},
+		{"missing required", map[string]any{}, []string{`$: missing required property "id"`}},
+		{"not an integer", map[string]any{"id": 1.5}, []string{"$.id: expected integer, got number"}},
+		{"below minimum", map[string]any{"id": 0.0}, []string{"$.id: must be greater than 0"}},
+		{"pattern", map[string]any{"id": 1.0, "email": "nope"}, []string{`$.email: must match the pattern "^[^@]+@[^@]+$"`}},
+		{"array limits", map[string]any{"id": 1.0, "tags": []any{"ok", "too long", "c"}}, []string{
+			"$.tags: must have at most 2 items, got 3",
+			"$.tags[1]: must be at most 5 characters long",
+		}},
+		{"anyOf", map[string]any{"id": 1.0, "owner": map[string]any{}}, []string{"$.owner: does not match any of the anyOf schemas"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateSchema(schema, tt.value); !slices.Equal(got, tt.want) {
+				t.Errorf("validateSchema = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
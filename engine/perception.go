@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/xraph/cortex/llm"
+)
+
+// maxHistoryMessages is the number of most recent conversation messages sent
+// with a run. A persona's ContextWindow selects a fraction of it.
+const maxHistoryMessages = 100
+
+// attentionFilter is a perception.AttentionFilter with its patterns compiled.
+type attentionFilter struct {
+	name     string
+	keywords []string
+	patterns []*regexp.Regexp
+	prompt   string
+}
+
+// perceiver is the perception stage of a run. It matches the persona's
+// attention filters against what the agent observes before each LLM call.
+type perceiver struct {
+	filters []attentionFilter
+	window  float64
+	detail  float64
+}
+
+// perceptionEffects is the outcome of the perception stage for one step.
+type perceptionEffects struct {
+	// Matched lists the names of the attention filters that matched, in
+	// definition order.
+	Matched []string
+	// Prompt holds the focus hints of the matched filters.
+	Prompt []string
+}
+
+// newPerceiver returns the perception stage for the resolved persona.
+// Keywords match case-insensitively as substrings. Patterns are
+// case-insensitive regular expressions; a pattern that does not compile is
+// matched as a plain substring.
+func newPerceiver(rp *ResolvedPersona) *perceiver {
+	p := &perceiver{}
+	if rp == nil {
+		return p
+	}
+	m := rp.Perception
+	p.window = m.ContextWindow
+	p.detail = m.DetailOrientation
+	for _, f := range m.AttentionFilters {
+		af := attentionFilter{name: f.Name, prompt: strings.TrimSpace(f.Prompt)}
+		for _, kw := range f.Keywords {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				af.keywords = append(af.keywords, strings.ToLower(kw))
+			}
+		}
+		for _, pat := range f.Patterns {
+			if pat == "" {
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + pat)
+			if err != nil {
+				re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(pat))
+			}
+			af.patterns = append(af.patterns, re)
+		}
+		p.filters = append(p.filters, af)
+	}
+	return p
+}
+
+// historyLimit returns how many of the most recent conversation messages to
+// include. A ContextWindow of 0 is unset and keeps the full history; any
+// other value keeps that fraction of maxHistoryMessages, at least one.
+func (p *perceiver) historyLimit() int {
+	if p == nil || p.window <= 0 || p.window >= 1 {
+		return maxHistoryMessages
+	}
+	return max(1, int(math.Ceil(p.window*maxHistoryMessages)))
+}
+
+// observe matches the attention filters against the run input and the
+// results of the previous step's tool calls.
+func (p *perceiver) observe(input string, toolResults []string) perceptionEffects {
+	var fx perceptionEffects
+	if p == nil {
+		return fx
+	}
+	texts := append([]string{input}, toolResults...)
+	for _, f := range p.filters {
+		if !f.matches(texts) {
+			continue
+		}
+		fx.Matched = append(fx.Matched, f.name)
+		if f.prompt != "" {
+			fx.Prompt = append(fx.Prompt, f.prompt)
+		}
+	}
+	return fx
+}
+
+// matches reports whether any keyword or pattern of f occurs in texts.
+func (f attentionFilter) matches(texts []string) bool {
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		lower := strings.ToLower(text)
+		for _, kw := range f.keywords {
+			if strings.Contains(lower, kw) {
+				return true
+			}
+		}
+		for _, re := range f.patterns {
+			if re.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyTo appends a focus section to the request's system prompt with the
+// hints of the matched filters and the persona's detail orientation.
+func (p *perceiver) applyTo(req *llm.Request, fx perceptionEffects) {
+	if p == nil {
+		return
+	}
+	hints := fx.Prompt
+	switch {
+	case p.detail >= 0.7:
+		hints = append(hints, "Attend to fine-grained details in the input and tool results.")
+	case p.detail > 0 && p.detail <= 0.3:
+		hints = append(hints, "Focus on the high-level picture rather than fine-grained details.")
+	}
+	if len(hints) == 0 {
+		return
+	}
+	req.System = strings.TrimLeft(req.System+"\n\n## Focus\n- "+strings.Join(hints, "\n- "), "\n")
+}
+
+// metadata returns the step metadata entry recording what the agent noticed.
+func (fx perceptionEffects) metadata() map[string]any {
+	if len(fx.Matched) == 0 {
+		return nil
+	}
+	return map[string]any{"attention_filters": fx.Matched}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/memory"
+	"github.com/xraph/cortex/perception"
+	"github.com/xraph/cortex/persona"
+)
+
+func securityPerception() perception.Model {
+	return perception.Model{
+		AttentionFilters: []perception.AttentionFilter{
+			{Name: "security", Keywords: []string{"Password", "token"}, Prompt: "Watch for security implications."},
+			{Name: "errors", Patterns: []string{`error code \d+`}, Prompt: "Explain error codes."},
+			{Name: "silent", Keywords: []string{"latency"}},
+		},
+	}
+}
+
+func TestPerceiver_Observe(t *testing.T) {
+	p := newPerceiver(&ResolvedPersona{Perception: securityPerception()})
+
+	tests := []struct {
+		name        string
+		input       string
+		toolResults []string
+		want        []string
+	}{
+		{"keyword is case-insensitive", "I forgot my PASSWORD", nil, []string{"security"}},
+		{"pattern", "got Error Code 42", nil, []string{"errors"}},
+		{"tool result", "check it", []string{`{"latency_ms": 300}`}, []string{"silent"}},
+		{"several", "token rejected with error code 7", nil, []string{"security", "errors"}},
+		{"none", "hello", []string{"world"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fx := p.observe(tt.input, tt.toolResults)
+			if !reflect.DeepEqual(fx.Matched, tt.want) {
+				t.Errorf("Matched = %v, want %v", fx.Matched, tt.want)
+			}
+		})
+	}
+}
+
+func TestPerceiver_ApplyToAndMetadata(t *testing.T) {
+	m := securityPerception()
+	m.DetailOrientation = 0.9
+	p := newPerceiver(&ResolvedPersona{Perception: m})
+
+	fx := p.observe("reset my token, latency is high", nil)
+	req := &llm.Request{System: "Base."}
+	p.applyTo(req, fx)
+
+	want := "Base.\n\n## Focus\n- Watch for security implications.\n- Attend to fine-grained details in the input and tool results."
+	if req.System != want {
+		t.Errorf("System =\n%q\nwant\n%q", req.System, want)
+	}
+	if got := fx.metadata()["attention_filters"]; !reflect.DeepEqual(got, []string{"security", "silent"}) {
+		t.Errorf("metadata = %v", got)
+	}
+	if md := p.observe("hello", nil).metadata(); md != nil {
+		t.Errorf("metadata without matches = %v, want nil", md)
+	}
+}
+
+func TestPerceiver_HistoryLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	agentID := id.NewAgentID()
+	history := make([]memory.Message, 150)
+	for i := range history {
+		history[i].Content = fmt.Sprint(i)
+	}
+	if err := s.SaveConversation(ctx, agentID, "", id.Nil, history); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	tests := []struct {
+		window    float64
+		wantLen   int
+		wantFirst string
+	}{
+		{0, maxHistoryMessages, "50"},
+		{1, maxHistoryMessages, "50"},
+		{0.2, 20, "130"},
+		{0.001, 1, "149"},
+	}
+	for _, tt := range tests {
+		p := newPerceiver(&ResolvedPersona{Perception: perception.Model{ContextWindow: tt.window}})
+		got, err := s.LoadConversation(ctx, agentID, "", id.Nil, p.historyLimit())
+		if err != nil || len(got) != tt.wantLen || got[0].Content != tt.wantFirst || got[len(got)-1].Content != "149" {
+			t.Errorf("window %v: loaded %d messages from %s, %v; want %d from %s to 149",
+				tt.window, len(got), got[0].Content, err, tt.wantLen, tt.wantFirst)
+		}
+	}
+}
+
+func TestRunReAct_RecordsAttentionFilters(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	if err := s.CreatePersona(ctx, &persona.Persona{
+		ID: id.NewPersonaID(), Name: "guard", AppID: "app1", Perception: securityPerception(),
+	}); err != nil {
+		t.Fatalf("create persona: %v", err)
+	}
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "helper", AppID: "app1", PersonaRef: "guard"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	e, err := New(WithStore(s), WithLLM(llm.NewMockClient()))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "helper", "my token expired", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	steps, err := s.ListSteps(ctx, r.ID)
+	if err != nil || len(steps) == 0 {
+		t.Fatalf("ListSteps = %d steps, %v", len(steps), err)
+	}
+	got := fmt.Sprint(steps[0].Metadata["attention_filters"])
+	if !strings.Contains(got, "security") {
+		t.Errorf("step metadata attention_filters = %s, want security", got)
+	}
+}
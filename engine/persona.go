@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/cognitive"
+	"github.com/xraph/cortex/communication"
+	"github.com/xraph/cortex/perception"
+	"github.com/xraph/cortex/skill"
+	"github.com/xraph/cortex/trait"
+)
+
+// Sources of a resolved skill or trait.
+const (
+	SourceInline     = "inline"
+	SourcePersona    = "persona"
+	SourceDependency = "dependency"
+)
+
+// ResolvedSkill is a skill held by an agent with its effective proficiency.
+type ResolvedSkill struct {
+	Skill       *skill.Skill      `json:"skill"`
+	Proficiency skill.Proficiency `json:"proficiency"`
+	Source      string            `json:"source"`
+	// RequiredBy names the skill that pulled this one in when Source is
+	// SourceDependency.
+	RequiredBy string `json:"required_by,omitempty"`
+}
+
+// ResolvedTrait is a trait held by an agent with its effective dimension values.
+type ResolvedTrait struct {
+	Trait           *trait.Trait       `json:"trait"`
+	DimensionValues map[string]float64 `json:"dimension_values,omitempty"`
+	Source          string             `json:"source"`
+}
+
+// ResolvedPersona is an agent's effective identity: its persona's
+// assignments merged with the agent's inline skills, traits and behaviors.
+// It is shared by the run loop, BuildSystemPrompt, ResolveSystemPrompt and
+// the dashboard preview.
+type ResolvedPersona struct {
+	// Name is the persona name, or "" when the agent has no persona or it
+	// could not be loaded.
+	Name               string               `json:"name,omitempty"`
+	Identity           string               `json:"identity,omitempty"`
+	Skills             []ResolvedSkill      `json:"skills,omitempty"`
+	Traits             []ResolvedTrait      `json:"traits,omitempty"`
+	Behaviors          []*behavior.Behavior `json:"behaviors,omitempty"`
+	CognitiveStyle     cognitive.Style      `json:"cognitive_style,omitempty"`
+	CommunicationStyle communication.Style  `json:"communication_style,omitempty"`
+	Perception         perception.Model     `json:"perception,omitempty"`
+	// Warnings describes problems found while resolving, such as a skill
+	// dependency cycle, that left the persona incomplete.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// HasSkill reports whether the resolved persona holds the named skill.
+func (rp *ResolvedPersona) HasSkill(name string) bool {
+	for _, s := range rp.Skills {
+		if s.Skill.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTrait reports whether the resolved persona holds the named trait.
+func (rp *ResolvedPersona) HasTrait(name string) bool {
+	for _, t := range rp.Traits {
+		if t.Trait.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolvePersona merges the agent's persona with its inline assignments.
+//
+// Precedence rules:
+//   - RunOverrides lists (InlineSkills, InlineTraits, InlineBehaviors,
+//     PersonaRef) replace the agent's.
+//   - Inline entries come first, followed by persona entries not already
+//     listed inline.
+//   - A skill's proficiency is the persona's SkillAssignment.Proficiency when
+//     set, otherwise the skill's DefaultProficiency, otherwise competent.
+//   - Skill dependencies are expanded transitively after the listed skills,
+//     skipping skills already held. A dependency cycle, a chain that is too
+//     deep or a missing dependency is logged, added to Warnings and
+//     expansion stops there.
+//   - A trait's dimension values are the persona's
+//     TraitAssignment.DimensionValues layered over the trait's own.
+//   - Behaviors whose RequiresSkill or RequiresTrait is not held are dropped;
+//     the rest are ordered by Priority, lowest number first.
+//   - Identity, CognitiveStyle, CommunicationStyle and Perception come from
+//     the persona.
+//
+// Entities that cannot be loaded are skipped.
+func (e *Engine) ResolvePersona(ctx context.Context, ag *agent.Config, overrides *RunOverrides) *ResolvedPersona {
+	rp := &ResolvedPersona{}
+	if e.store == nil {
+		return rp
+	}
+
+	skillNames := ag.InlineSkills
+	traitNames := ag.InlineTraits
+	behaviorNames := ag.InlineBehaviors
+	personaRef := ag.PersonaRef
+	if overrides != nil {
+		if len(overrides.InlineSkills) > 0 {
+			skillNames = overrides.InlineSkills
+		}
+		if len(overrides.InlineTraits) > 0 {
+			traitNames = overrides.InlineTraits
+		}
+		if len(overrides.InlineBehaviors) > 0 {
+			behaviorNames = overrides.InlineBehaviors
+		}
+		if overrides.PersonaRef != "" {
+			personaRef = overrides.PersonaRef
+		}
+	}
+
+	type assignment struct {
+		name   string
+		source string
+	}
+	var skills, traits, behaviors []assignment
+	for _, n := range skillNames {
+		skills = append(skills, assignment{n, SourceInline})
+	}
+	for _, n := range traitNames {
+		traits = append(traits, assignment{n, SourceInline})
+	}
+	for _, n := range behaviorNames {
+		behaviors = append(behaviors, assignment{n, SourceInline})
+	}
+
+	proficiency := make(map[string]skill.Proficiency)
+	dimValues := make(map[string]map[string]float64)
+	if personaRef != "" {
+		if p, err := e.store.GetPersonaByName(ctx, ag.AppID, personaRef); err == nil {
+			rp.Name = p.Name
+			rp.Identity = p.Identity
+			rp.CognitiveStyle = p.CognitiveStyle
+			rp.CommunicationStyle = p.CommunicationStyle
+			rp.Perception = p.Perception
+			for _, sa := range p.Skills {
+				skills = append(skills, assignment{sa.SkillName, SourcePersona})
+				if sa.Proficiency != "" {
+					proficiency[sa.SkillName] = sa.Proficiency
+				}
+			}
+			for _, ta := range p.Traits {
+				traits = append(traits, assignment{ta.TraitName, SourcePersona})
+				if len(ta.DimensionValues) > 0 {
+					dimValues[ta.TraitName] = ta.DimensionValues
+				}
+			}
+			for _, n := range p.Behaviors {
+				behaviors = append(behaviors, assignment{n, SourcePersona})
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range skills {
+		name := strings.TrimSpace(a.name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		sk, err := e.store.GetSkillByName(ctx, ag.AppID, name)
+		if err != nil {
+			continue
+		}
+		prof := coalesceStr(string(proficiency[name]), string(sk.DefaultProficiency), string(skill.ProficiencyCompetent))
+		rp.Skills = append(rp.Skills, ResolvedSkill{Skill: sk, Proficiency: skill.Proficiency(prof), Source: a.source})
+	}
+
+	held := make([]*skill.Skill, len(rp.Skills))
+	for i, rs := range rp.Skills {
+		held[i] = rs.Skill
+	}
+	deps, err := e.expandSkillDependencies(ctx, ag.AppID, held)
+	if err != nil {
+		e.logger.Warn("resolve skill dependencies",
+			log.String("agent", ag.Name),
+			log.String("error", err.Error()),
+		)
+		rp.Warnings = append(rp.Warnings, err.Error())
+	}
+	for _, d := range deps {
+		prof := coalesceStr(string(d.skill.DefaultProficiency), string(skill.ProficiencyCompetent))
+		rp.Skills = append(rp.Skills, ResolvedSkill{
+			Skill:       d.skill,
+			Proficiency: skill.Proficiency(prof),
+			Source:      SourceDependency,
+			RequiredBy:  d.requiredBy,
+		})
+	}
+
+	seen = make(map[string]bool)
+	for _, a := range traits {
+		name := strings.TrimSpace(a.name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		t, err := e.store.GetTraitByName(ctx, ag.AppID, name)
+		if err != nil {
+			continue
+		}
+		rp.Traits = append(rp.Traits, ResolvedTrait{
+			Trait:           t,
+			DimensionValues: traitDimensions(t, dimValues[name]),
+			Source:          a.source,
+		})
+	}
+
+	seen = make(map[string]bool)
+	for _, a := range behaviors {
+		name := strings.TrimSpace(a.name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		b, err := e.store.GetBehaviorByName(ctx, ag.AppID, name)
+		if err != nil {
+			continue
+		}
+		if b.RequiresSkill != "" && !rp.HasSkill(b.RequiresSkill) {
+			continue
+		}
+		if b.RequiresTrait != "" && !rp.HasTrait(b.RequiresTrait) {
+			continue
+		}
+		rp.Behaviors = append(rp.Behaviors, b)
+	}
+	sort.SliceStable(rp.Behaviors, func(i, j int) bool {
+		return rp.Behaviors[i].Priority < rp.Behaviors[j].Priority
+	})
+
+	return rp
+}
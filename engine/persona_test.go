@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xraph/grove"
+	"github.com/xraph/grove/drivers/sqlitedriver"
+	_ "github.com/xraph/grove/drivers/sqlitedriver/sqlitemigrate"
+
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/cognitive"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/persona"
+	"github.com/xraph/cortex/skill"
+	"github.com/xraph/cortex/store/sqlite"
+	"github.com/xraph/cortex/trait"
+)
+
+// newTestStore opens a migrated SQLite store backed by a temporary file.
+func newTestStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+	return openTestStore(t, filepath.Join(t.TempDir(), "cortex_test.db"))
+}
+
+// openTestStore opens and migrates the SQLite database at path.
+func openTestStore(t *testing.T, path string) *sqlite.Store {
+	t.Helper()
+	ctx := context.Background()
+	drv := sqlitedriver.New()
+	if err := drv.Open(ctx, path); err != nil {
+		t.Fatalf("open sqlite driver: %v", err)
+	}
+	db, err := grove.Open(drv)
+	if err != nil {
+		t.Fatalf("grove open: %v", err)
+	}
+	s := sqlite.New(db)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// seedPersona stores a persona with two skills, a trait and a behavior, plus
+// an inline skill and a behavior whose required skill is missing.
+func seedPersona(t *testing.T, s *sqlite.Store) {
+	t.Helper()
+	ctx := context.Background()
+	for _, sk := range []*skill.Skill{
+		{ID: id.NewSkillID(), Name: "triage", AppID: "app1", SystemPromptFragment: "Triage tickets.", DefaultProficiency: skill.ProficiencyApprentice},
+		{ID: id.NewSkillID(), Name: "billing", AppID: "app1", SystemPromptFragment: "Handle invoices.",
+			Tools: []skill.ToolBinding{{ToolName: "lookup_invoice"}}},
+		{ID: id.NewSkillID(), Name: "writing", AppID: "app1", SystemPromptFragment: "Write clearly."},
+	} {
+		if err := s.CreateSkill(ctx, sk); err != nil {
+			t.Fatalf("create skill: %v", err)
+		}
+	}
+	if err := s.CreateTrait(ctx, &trait.Trait{
+		ID: id.NewTraitID(), Name: "cautious", AppID: "app1",
+		Dimensions: []trait.Dimension{{Name: "caution", Value: 0.2}},
+		Influences: []trait.Influence{{Target: trait.TargetPromptInjection, Value: "Double-check."}},
+	}); err != nil {
+		t.Fatalf("create trait: %v", err)
+	}
+	for _, b := range []*behavior.Behavior{
+		{ID: id.NewBehaviorID(), Name: "escalate", AppID: "app1", Priority: 20, RequiresSkill: "triage"},
+		{ID: id.NewBehaviorID(), Name: "greet", AppID: "app1", Priority: 10},
+		{ID: id.NewBehaviorID(), Name: "refund", AppID: "app1", RequiresSkill: "refunds"},
+	} {
+		if err := s.CreateBehavior(ctx, b); err != nil {
+			t.Fatalf("create behavior: %v", err)
+		}
+	}
+	if err := s.CreatePersona(ctx, &persona.Persona{
+		ID: id.NewPersonaID(), Name: "support", AppID: "app1", Identity: "A support agent.",
+		Skills: []persona.SkillAssignment{
+			{SkillName: "triage", Proficiency: skill.ProficiencyExpert},
+			{SkillName: "billing"},
+		},
+		Traits:         []persona.TraitAssignment{{TraitName: "cautious", DimensionValues: map[string]float64{"caution": 0.9}}},
+		Behaviors:      []string{"escalate", "refund"},
+		CognitiveStyle: cognitive.Style{Phases: []cognitive.Phase{{Strategy: cognitive.StrategyAnalytical}}},
+	}); err != nil {
+		t.Fatalf("create persona: %v", err)
+	}
+}
+
+func TestResolvePersona_MergesPersonaAndInline(t *testing.T) {
+	s := newTestStore(t)
+	seedPersona(t, s)
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ag := &agent.Config{
+		Name: "helpdesk", AppID: "app1", PersonaRef: "support",
+		InlineSkills:    []string{"writing", "triage"},
+		InlineBehaviors: []string{"greet"},
+	}
+	rp := e.ResolvePersona(context.Background(), ag, nil)
+
+	if rp.Name != "support" || rp.Identity != "A support agent." {
+		t.Fatalf("persona = (%q, %q), want support identity", rp.Name, rp.Identity)
+	}
+	var skills []string
+	for _, rs := range rp.Skills {
+		skills = append(skills, rs.Skill.Name+":"+string(rs.Proficiency)+":"+rs.Source)
+	}
+	want := "writing:competent:inline,triage:expert:inline,billing:competent:persona"
+	if got := strings.Join(skills, ","); got != want {
+		t.Errorf("skills = %s, want %s", got, want)
+	}
+	if len(rp.Traits) != 1 || rp.Traits[0].DimensionValues["caution"] != 0.9 {
+		t.Errorf("traits = %+v, want cautious with persona dimension value 0.9", rp.Traits)
+	}
+	var behaviors []string
+	for _, b := range rp.Behaviors {
+		behaviors = append(behaviors, b.Name)
+	}
+	if got := strings.Join(behaviors, ","); got != "greet,escalate" {
+		t.Errorf("behaviors = %s, want greet,escalate (refund requires a missing skill)", got)
+	}
+	if len(rp.CognitiveStyle.Phases) != 1 {
+		t.Errorf("cognitive style not carried over: %+v", rp.CognitiveStyle)
+	}
+	if !e.resolveToolScope(e.effectiveConfig(ag, nil), rp).allows("lookup_invoice") {
+		t.Errorf("persona skill tool binding missing from tool scope")
+	}
+}
+
+func TestBuildSystemPrompt_IncludesPersonaSkillsAndTraits(t *testing.T) {
+	s := newTestStore(t)
+	seedPersona(t, s)
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ag := &agent.Config{Name: "helpdesk", AppID: "app1", SystemPrompt: "Be helpful.", PersonaRef: "support"}
+	got := e.BuildSystemPrompt(context.Background(), ag, nil)
+	for _, want := range []string{
+		"Be helpful.",
+		"## Identity\nA support agent.",
+		"## Skill: triage (expert)\nTriage tickets.",
+		"## Skill: billing (competent)\nHandle invoices.",
+		"## Trait: cautious\nDouble-check.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestResolveSystemPrompt_ReturnsPersonaOfPrompt(t *testing.T) {
+	s := newTestStore(t)
+	seedPersona(t, s)
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ag := &agent.Config{Name: "helpdesk", AppID: "app1", PersonaRef: "support"}
+	prompt, rp := e.ResolveSystemPrompt(context.Background(), ag, nil)
+	if rp.Name != "support" || len(rp.Skills) != 2 {
+		t.Fatalf("persona = %q with %d skills, want support with 2", rp.Name, len(rp.Skills))
+	}
+	if want := e.BuildSystemPrompt(context.Background(), ag, nil); prompt != want {
+		t.Errorf("prompt = %q, want %q", prompt, want)
+	}
+}
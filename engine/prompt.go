@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/agent"
 	"github.com/xraph/cortex/knowledge"
 )
@@ -11,31 +12,45 @@ import (
 // resolvedConfig holds the effective configuration after merging
 // agent config, engine defaults, and per-run overrides.
 type resolvedConfig struct {
-	Model         string
-	Temperature   *float64
-	MaxSteps      int
-	MaxTokens     int
-	ReasoningLoop string
-	Tools         []string
-	PersonaRef    string
+	Model           string
+	Temperature     *float64
+	MaxSteps        int
+	MaxStepsPolicy  cortex.MaxStepsPolicy
+	ToolConcurrency int // tool calls of a step run concurrently; 0 means no cap
+	MaxTokens       int
+	MaxTotalTokens  int // tokens the whole run may use; 0 means no cap
+	ReasoningLoop   string
+	Tools           []string
+	PersonaRef      string
+	OutputSchema    map[string]any // JSON Schema of the final answer; nil means free text
+
+	// FixedTemperature is set when Temperature comes from the agent, the run
+	// overrides or a trait rather than the engine default. Cognitive strategy
+	// profiles only adjust temperature when it is not fixed.
+	FixedTemperature bool
 }
 
 // effectiveConfig merges agent config + engine defaults + overrides.
 // Priority: overrides > agent > engine defaults.
 func (e *Engine) effectiveConfig(ag *agent.Config, overrides *RunOverrides) resolvedConfig {
 	cfg := resolvedConfig{
-		Model:         coalesceStr(ag.Model, e.config.DefaultModel),
-		MaxSteps:      coalesceInt(ag.MaxSteps, e.config.DefaultMaxSteps),
-		MaxTokens:     coalesceInt(ag.MaxTokens, e.config.DefaultMaxTokens),
-		ReasoningLoop: coalesceStr(ag.ReasoningLoop, e.config.DefaultReasoningLoop),
-		Tools:         ag.Tools,
-		PersonaRef:    ag.PersonaRef,
+		Model:           coalesceStr(ag.Model, e.config.DefaultModel),
+		MaxSteps:        coalesceInt(ag.MaxSteps, e.config.DefaultMaxSteps),
+		MaxStepsPolicy:  e.config.MaxStepsPolicy,
+		ToolConcurrency: e.config.ToolConcurrency,
+		MaxTokens:       coalesceInt(ag.MaxTokens, e.config.DefaultMaxTokens),
+		MaxTotalTokens:  ag.MaxTotalTokens,
+		ReasoningLoop:   coalesceStr(ag.ReasoningLoop, e.config.DefaultReasoningLoop),
+		Tools:           ag.Tools,
+		PersonaRef:      ag.PersonaRef,
+		OutputSchema:    ag.OutputSchema,
 	}
 
 	// Agent temperature: use agent value if non-zero, otherwise engine default.
 	if ag.Temperature != 0 {
 		t := ag.Temperature
 		cfg.Temperature = &t
+		cfg.FixedTemperature = true
 	} else {
 		t := e.config.DefaultTemperature
 		cfg.Temperature = &t
@@ -49,14 +64,24 @@ func (e *Engine) effectiveConfig(ag *agent.Config, overrides *RunOverrides) reso
 		if overrides.MaxSteps > 0 {
 			cfg.MaxSteps = overrides.MaxSteps
 		}
+		if overrides.MaxStepsPolicy != "" {
+			cfg.MaxStepsPolicy = overrides.MaxStepsPolicy
+		}
+		if overrides.ToolConcurrency > 0 {
+			cfg.ToolConcurrency = overrides.ToolConcurrency
+		}
 		if overrides.MaxTokens > 0 {
 			cfg.MaxTokens = overrides.MaxTokens
 		}
+		if overrides.MaxTotalTokens > 0 {
+			cfg.MaxTotalTokens = overrides.MaxTotalTokens
+		}
 		if overrides.ReasoningLoop != "" {
 			cfg.ReasoningLoop = overrides.ReasoningLoop
 		}
 		if overrides.Temperature != nil {
 			cfg.Temperature = overrides.Temperature
+			cfg.FixedTemperature = true
 		}
 		if len(overrides.Tools) > 0 {
 			cfg.Tools = overrides.Tools
@@ -64,72 +89,136 @@ func (e *Engine) effectiveConfig(ag *agent.Config, overrides *RunOverrides) reso
 		if overrides.PersonaRef != "" {
 			cfg.PersonaRef = overrides.PersonaRef
 		}
+		if overrides.OutputSchema != nil {
+			cfg.OutputSchema = overrides.OutputSchema
+		}
 	}
 
 	return cfg
 }
 
-// BuildSystemPrompt assembles the full system prompt from agent config,
-// persona identity, skill fragments, and trait injections.
-// This is the engine-level equivalent of dashboard/data.go:computeSystemPrompt.
+// BuildSystemPrompt assembles the full system prompt from agent config and
+// the agent's resolved persona: identity, skill fragments, skill knowledge,
+// trait prompt influences and communication style.
 func (e *Engine) BuildSystemPrompt(ctx context.Context, ag *agent.Config, overrides *RunOverrides) string {
-	var parts []string
+	prompt, _ := e.ResolveSystemPrompt(ctx, ag, overrides)
+	return prompt
+}
 
-	// Determine effective system prompt.
-	systemPrompt := ag.SystemPrompt
-	if overrides != nil && overrides.SystemPrompt != "" {
-		systemPrompt = overrides.SystemPrompt
+// ResolveSystemPrompt is BuildSystemPrompt that also returns the persona
+// the prompt was built from, for callers reporting both without resolving
+// the persona twice.
+func (e *Engine) ResolveSystemPrompt(ctx context.Context, ag *agent.Config, overrides *RunOverrides) (string, *ResolvedPersona) {
+	rp := e.ResolvePersona(ctx, ag, overrides)
+	return e.buildSystemPrompt(ctx, ag, overrides, rp), rp
+}
+
+// buildSystemPrompt assembles the system prompt for an already resolved persona.
+func (e *Engine) buildSystemPrompt(ctx context.Context, ag *agent.Config, overrides *RunOverrides, rp *ResolvedPersona) string {
+	return e.assembleSystemPrompt(ctx, ag, overrides, rp).String()
+}
+
+// systemPrompt is an assembled system prompt. Knowledge retrieved for skills
+// is kept apart from the sections around it so the context assembler can
+// drop chunks that do not fit the model's context window.
+type systemPrompt struct {
+	head      []string
+	knowledge []knowledgeSection
+	tail      []string
+}
+
+// knowledgeSection holds the chunks retrieved from one knowledge source.
+type knowledgeSection struct {
+	source string
+	chunks []knowledge.ScoredChunk
+}
+
+// String renders the prompt. Knowledge sections left without chunks are
+// omitted.
+func (p *systemPrompt) String() string {
+	parts := append([]string(nil), p.head...)
+	for _, ks := range p.knowledge {
+		if len(ks.chunks) == 0 {
+			continue
+		}
+		var kb strings.Builder
+		kb.WriteString("\n## Knowledge: " + ks.source + "\n")
+		for _, c := range ks.chunks {
+			kb.WriteString("- " + c.Content + "\n")
+		}
+		parts = append(parts, kb.String())
 	}
-	if systemPrompt != "" {
-		parts = append(parts, systemPrompt)
+	parts = append(parts, p.tail...)
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "\n")
+}
+
+// withSummary returns a copy of p ending with a conversation summary
+// section; p itself when summary is empty.
+func (p *systemPrompt) withSummary(summary string) *systemPrompt {
+	if summary == "" {
+		return p
 	}
+	cp := *p
+	cp.tail = append(append([]string(nil), p.tail...), "\n## Conversation summary\n"+summary)
+	return &cp
+}
 
-	// Determine effective persona ref.
-	personaRef := ag.PersonaRef
-	if overrides != nil && overrides.PersonaRef != "" {
-		personaRef = overrides.PersonaRef
+// withFacts returns a copy of p ending with a section of recalled facts;
+// p itself when there are none.
+func (p *systemPrompt) withFacts(facts []string) *systemPrompt {
+	if len(facts) == 0 {
+		return p
+	}
+	var b strings.Builder
+	b.WriteString("\n## Known facts\n")
+	for _, f := range facts {
+		b.WriteString("- " + f + "\n")
 	}
+	cp := *p
+	cp.tail = append(append([]string(nil), p.tail...), b.String())
+	return &cp
+}
 
-	// Resolve persona identity.
-	if personaRef != "" && e.store != nil {
-		p, err := e.store.GetPersonaByName(ctx, ag.AppID, personaRef)
-		if err == nil && p.Identity != "" {
-			parts = append(parts, "\n## Identity\n"+p.Identity)
-		}
+// assembleSystemPrompt assembles the system prompt sections for an already
+// resolved persona.
+func (e *Engine) assembleSystemPrompt(ctx context.Context, ag *agent.Config, overrides *RunOverrides, rp *ResolvedPersona) *systemPrompt {
+	p := &systemPrompt{}
+
+	// Determine effective system prompt.
+	base := ag.SystemPrompt
+	if overrides != nil && overrides.SystemPrompt != "" {
+		base = overrides.SystemPrompt
+	}
+	if base != "" {
+		p.head = append(p.head, base)
 	}
 
-	// Determine effective skills.
-	skillNames := ag.InlineSkills
-	if overrides != nil && len(overrides.InlineSkills) > 0 {
-		skillNames = overrides.InlineSkills
+	// Persona identity.
+	if rp.Identity != "" {
+		p.head = append(p.head, "\n## Identity\n"+rp.Identity)
 	}
 
-	// Inject skill prompt fragments.
-	if e.store != nil {
-		for _, sName := range skillNames {
-			sName = strings.TrimSpace(sName)
-			if sName == "" {
-				continue
-			}
-			sk, err := e.store.GetSkillByName(ctx, ag.AppID, sName)
-			if err == nil && sk.SystemPromptFragment != "" {
-				parts = append(parts, "\n## Skill: "+sk.Name+"\n"+sk.SystemPromptFragment)
-			}
+	// Inject skill prompt fragments. Dependencies name the skill that
+	// requires them.
+	for _, rs := range rp.Skills {
+		if rs.Skill.SystemPromptFragment == "" {
+			continue
+		}
+		label := string(rs.Proficiency)
+		if rs.RequiredBy != "" {
+			label += ", required by " + rs.RequiredBy
 		}
+		p.head = append(p.head, "\n## Skill: "+rs.Skill.Name+" ("+label+")\n"+rs.Skill.SystemPromptFragment)
 	}
 
 	// Inject knowledge from skill KnowledgeRef entries.
-	if e.knowledge != nil && e.store != nil {
-		for _, sName := range skillNames {
-			sName = strings.TrimSpace(sName)
-			if sName == "" {
-				continue
-			}
-			sk, err := e.store.GetSkillByName(ctx, ag.AppID, sName)
-			if err != nil || len(sk.Knowledge) == 0 {
-				continue
-			}
-			for _, kref := range sk.Knowledge {
+	if e.knowledge != nil {
+		for _, rs := range rp.Skills {
+			for _, kref := range rs.Skill.Knowledge {
 				if kref.Source == "" {
 					continue
 				}
@@ -139,46 +228,21 @@ func (e *Engine) BuildSystemPrompt(ctx context.Context, ag *agent.Config, overri
 				if kErr != nil || len(chunks) == 0 {
 					continue
 				}
-				var kb strings.Builder
-				kb.WriteString("\n## Knowledge: " + kref.Source + "\n")
-				for _, c := range chunks {
-					kb.WriteString("- " + c.Content + "\n")
-				}
-				parts = append(parts, kb.String())
+				p.knowledge = append(p.knowledge, knowledgeSection{source: kref.Source, chunks: chunks})
 			}
 		}
 	}
 
-	// Determine effective traits.
-	traitNames := ag.InlineTraits
-	if overrides != nil && len(overrides.InlineTraits) > 0 {
-		traitNames = overrides.InlineTraits
-	}
+	// Inject trait prompt sections: prompt injections, response styles and
+	// tool preferences.
+	p.tail = append(p.tail, resolveTraits(rp).prompt()...)
 
-	// Inject trait prompt injections.
-	if e.store != nil {
-		for _, tName := range traitNames {
-			tName = strings.TrimSpace(tName)
-			if tName == "" {
-				continue
-			}
-			t, err := e.store.GetTraitByName(ctx, ag.AppID, tName)
-			if err == nil {
-				for _, inf := range t.Influences {
-					if inf.Target == "prompt_injection" {
-						if v, ok := inf.Value.(string); ok && v != "" {
-							parts = append(parts, "\n## Trait: "+t.Name+"\n"+v)
-						}
-					}
-				}
-			}
-		}
+	// Communication style guidance.
+	if guidance := rp.CommunicationStyle.Guidance(); guidance != "" {
+		p.tail = append(p.tail, guidance)
 	}
 
-	if len(parts) == 0 {
-		return ""
-	}
-	return strings.Join(parts, "\n")
+	return p
 }
 
 // coalesceStr returns the first non-empty string.
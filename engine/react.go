@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/xraph/go-utils/log"
@@ -18,75 +20,330 @@ import (
 	"github.com/xraph/cortex/safety"
 )
 
-// runReAct executes an agent using the ReAct reasoning loop synchronously.
-func (e *Engine) runReAct(ctx context.Context, ag *agent.Config, input string, overrides *RunOverrides) (*run.Run, error) {
-	cfg := e.effectiveConfig(ag, overrides)
-	systemPrompt := e.BuildSystemPrompt(ctx, ag, overrides)
+// reactRun holds a ReAct run in progress: its resolved configuration, the
+// persona-driven stages evaluated at each step and the loop state.
+type reactRun struct {
+	ag        *agent.Config
+	r         *run.Run
+	cfg       resolvedConfig
+	rp        *ResolvedPersona
+	traits    *traitEffects
+	prompt    *systemPrompt
+	scope     toolScope
+	tools     []llm.Tool
+	behaviors *behaviorEvaluator
+	cog       *cognitiveEngine
+	pv        *perceiver
+
+	// st is the loop state, persisted after every completed step.
+	st runState
+	// cancelled is set when another process cancelled the run through the
+	// store.
+	cancelled bool
+	// toolErrors holds tool errors raised in the previous step.
+	toolErrors []string
+	// events receives stream events; nil for synchronous runs.
+	events chan<- StreamEvent
+	// finish frees the run's execution slot and unregisters it from the
+	// active runs. It is set by startRun.
+	finish func()
+}
 
+// newReactRun resolves the agent's configuration and persona for a run.
+func (e *Engine) newReactRun(ctx context.Context, ag *agent.Config, overrides *RunOverrides) *reactRun {
+	rr := &reactRun{ag: ag, cfg: e.effectiveConfig(ag, overrides)}
+	rr.rp = e.ResolvePersona(ctx, ag, overrides)
+	rr.traits = resolveTraits(rr.rp)
+	rr.traits.applyToConfig(&rr.cfg, overrides)
+	rr.prompt = e.assembleSystemPrompt(ctx, ag, overrides, rr.rp).withOutputSchema(rr.cfg.OutputSchema)
+	rr.scope = e.resolveToolScope(rr.cfg, rr.rp)
+	rr.traits.restrictTools(rr.scope)
+	rr.tools = e.resolveTools(rr.scope)
+	behaviors, err := newBehaviorEvaluator(rr.rp)
+	if err != nil {
+		e.logger.Warn("behaviors left out",
+			log.String("agent_id", ag.ID.String()),
+			log.String("error", err.Error()),
+		)
+	}
+	rr.behaviors = behaviors
+	rr.cog = newCognitiveEngine(rr.cfg, rr.rp)
+	rr.pv = newPerceiver(rr.rp)
+	rr.st.Overrides = overrides
+	return rr
+}
+
+// startRun creates the run record under the tenant of ctx, seeds the
+// messages with the conversation history of the tenant and session and the
+// input, stores the record with that initial state, waits for an execution
+// slot and emits the start hooks. It returns the context the run executes
+// under; rr.finish must be called once execution stops. A run cancelled
+// while queued returns cortex.ErrRunCancelled.
+func (e *Engine) startRun(ctx context.Context, rr *reactRun, input string) (context.Context, error) {
 	now := time.Now().UTC()
-	r := &run.Run{
+	rr.r = &run.Run{
 		Entity:     cortex.NewEntity(),
 		ID:         id.NewAgentRunID(),
-		AgentID:    ag.ID,
+		AgentID:    rr.ag.ID,
+		TenantID:   cortex.TenantFromContext(ctx),
+		SessionID:  sessionOf(rr.st.Overrides),
 		State:      run.StateRunning,
 		Input:      input,
 		StartedAt:  &now,
-		PersonaRef: cfg.PersonaRef,
+		PersonaRef: rr.cfg.PersonaRef,
+	}
+	e.seedMessages(ctx, rr, input)
+	release, admitted := e.admission.tryAcquire(rr.r.TenantID)
+	if !admitted {
+		rr.r.State = run.StateQueued
+		rr.r.StartedAt = nil
+	}
+	if err := rr.saveState(); err != nil {
+		return ctx, err
+	}
+	if err := e.store.CreateRun(ctx, rr.r); err != nil {
+		if admitted {
+			release()
+		}
+		return ctx, fmt.Errorf("create run: %w", err)
+	}
+
+	ctx, untrack := e.trackRun(ctx, rr.r.ID)
+	if !admitted {
+		var err error
+		if release, err = e.awaitAdmission(ctx, rr.r); err != nil {
+			untrack()
+			return ctx, err
+		}
+	}
+	rr.finish = func() {
+		untrack()
+		release()
+	}
+
+	e.emitRunStarted(ctx, rr)
+	return ctx, nil
+}
+
+// seedMessages sets the run's initial messages, conversation summary and
+// facts: the latest summary and the recent conversation history of the
+// run's agent, tenant and session, followed by the input, and the facts
+// recalled for the input.
+func (e *Engine) seedMessages(ctx context.Context, rr *reactRun, input string) {
+	// Load conversation history, never halfway through a summary replacing
+	// older messages.
+	unlock := e.conversations.rlock(conversationOf(rr))
+	history, _ := e.store.LoadConversation(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, rr.pv.historyLimit()) //nolint:errcheck // best-effort history load
+	rr.st.Summary = e.latestSummary(ctx, rr)
+	unlock()
+	rr.st.Messages = memoryToLLM(history)
+	rr.st.History = len(rr.st.Messages)
+	rr.st.Messages = append(rr.st.Messages, llm.Message{Role: "user", Content: input})
+	rr.st.Facts = e.recallFacts(ctx, rr, input)
+}
+
+// emitRunStarted emits the hooks marking the start of a run.
+func (e *Engine) emitRunStarted(ctx context.Context, rr *reactRun) {
+	e.extensions.EmitRunStarted(ctx, rr.ag.ID, rr.r.ID, rr.r.Input)
+	if rr.rp.Name != "" {
+		e.extensions.EmitPersonaResolved(ctx, rr.ag.ID, rr.rp.Name)
+	}
+	e.emitTraits(ctx, rr.r.ID, rr.traits)
+}
+
+// prepareStep builds the LLM request for the next step and applies the
+// perception, behavior and cognitive stages to it. It returns the request
+// and the step metadata.
+func (e *Engine) prepareStep(ctx context.Context, rr *reactRun) (*llm.Request, map[string]any) {
+	prompt := rr.prompt.withFacts(rr.st.Facts).withSummary(rr.st.Summary)
+	req := &llm.Request{
+		Model:        rr.cfg.Model,
+		System:       prompt.String(),
+		Messages:     rr.st.Messages,
+		MaxTokens:    rr.cfg.MaxTokens,
+		Temperature:  rr.cfg.Temperature,
+		Tools:        e.offeredTools(rr),
+		OutputSchema: rr.cfg.OutputSchema,
+	}
+	base := req.System
+
+	// Perception: match attention filters against the input and the
+	// latest tool results, and add their focus hints.
+	sig := behaviorSignalsFor(rr.st.Step, rr.r.Input, rr.st.Messages, rr.toolErrors)
+	seen := rr.pv.observe(rr.r.Input, sig.ToolResults)
+	rr.pv.applyTo(req, seen)
+
+	// Behaviors: evaluate triggers and apply actions for this step.
+	fx := rr.behaviors.evaluate(sig)
+	e.emitBehaviors(ctx, rr.r.ID, fx)
+	rr.st.require(fx.RequiredTools)
+	rr.toolErrors = nil
+
+	// Cognitive phase: apply the active strategy's profile. Behavior
+	// parameter overrides are applied last and take precedence.
+	e.emitPhaseChange(ctx, rr.r.ID, rr.cog.switchTo(fx.Cognitive))
+	rr.cog.applyTo(req, fx.Cognitive)
+	fx.applyTo(req)
+
+	// Context window: trim the request to fit the model. The stages above
+	// only append to the system prompt.
+	extra, ok := strings.CutPrefix(req.System, base)
+	if !ok {
+		prompt = nil
+	}
+	trimmed := e.fitContext(req, rr.st.History, prompt, extra)
+
+	return req, mergeMetadata(mergeMetadata(mergeMetadata(fx.metadata(), rr.cog.metadata()), seen.metadata()), trimmed)
+}
+
+// scanInput runs the safety scanner over the run input. It returns the scan
+// result when the input is blocked.
+func (e *Engine) scanInput(ctx context.Context, rr *reactRun) *safety.ScanResult {
+	if e.safety == nil {
+		return nil
+	}
+	scanReq := &safety.ScanRequest{
+		Content:     rr.r.Input,
+		Direction:   safety.DirectionInput,
+		AgentID:     rr.ag.ID.String(),
+		RunID:       rr.r.ID.String(),
+		ProfileName: extractSafetyProfile(rr.ag),
+		AppID:       rr.ag.AppID,
+		TenantID:    rr.r.TenantID,
+	}
+	scanResult, scanErr := e.safety.ScanInput(ctx, scanReq)
+	if scanErr != nil {
+		e.logger.Warn("safety scan input error", log.String("error", scanErr.Error()))
+		return nil
 	}
-	if err := e.store.CreateRun(ctx, r); err != nil {
-		return nil, fmt.Errorf("create run: %w", err)
+	if scanResult != nil && scanResult.Blocked {
+		return scanResult
+	}
+	return nil
+}
+
+// scanOutput runs the safety scanner over the final output. It returns the
+// possibly redacted output, and the scan result when the output is blocked.
+func (e *Engine) scanOutput(ctx context.Context, rr *reactRun, output string) (string, *safety.ScanResult) {
+	if e.safety == nil {
+		return output, nil
+	}
+	scanReq := &safety.ScanRequest{
+		Content:     output,
+		Direction:   safety.DirectionOutput,
+		AgentID:     rr.ag.ID.String(),
+		RunID:       rr.r.ID.String(),
+		ProfileName: extractSafetyProfile(rr.ag),
+		AppID:       rr.ag.AppID,
+		TenantID:    rr.r.TenantID,
+	}
+	scanResult, scanErr := e.safety.ScanOutput(ctx, scanReq)
+	switch {
+	case scanErr != nil:
+		e.logger.Warn("safety scan output error", log.String("error", scanErr.Error()))
+	case scanResult != nil && scanResult.Blocked:
+		return output, scanResult
+	case scanResult != nil && scanResult.Redacted != "":
+		return scanResult.Redacted, nil
+	}
+	return output, nil
+}
+
+// completeRun saves the run's new messages to the conversation, summarizes
+// the conversation when it has grown past the summary thresholds, extracts
+// facts from the new messages and marks the run completed.
+func (e *Engine) completeRun(ctx context.Context, rr *reactRun, finalOutput string) {
+	r := rr.r
+
+	// Save updated conversation.
+	convMsgs := llmToMemory(rr.st.newMessages())
+	saved := true
+	if err := e.store.SaveConversation(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, convMsgs); err != nil {
+		e.logger.Error("save conversation", log.String("error", err.Error()))
+		saved = false
 	}
 
-	e.extensions.EmitRunStarted(ctx, ag.ID, r.ID, input)
+	// Complete the run.
+	completedAt := time.Now().UTC()
+	r.State = run.StateCompleted
+	r.Output = finalOutput
+	r.StepCount = rr.st.Step
+	r.TokensUsed = rr.st.TotalTokens
+	r.CompletedAt = &completedAt
+	clearRunState(r)
+	if err := e.store.UpdateRun(ctx, r); err != nil {
+		e.logger.Error("update run", log.String("error", err.Error()))
+	}
+	e.touchSession(ctx, r)
+	e.clearWorkingMemory(ctx, r)
 
-	// Load conversation history.
-	history, _ := e.store.LoadConversation(ctx, ag.ID, "", 100) //nolint:errcheck // best-effort history load
-	messages := memoryToLLM(history)
-	messages = append(messages, llm.Message{Role: "user", Content: input})
+	e.extensions.EmitRunCompleted(ctx, rr.ag.ID, r.ID, r.Output, runDuration(r, completedAt))
 
-	var totalTokens int
-	var stepIndex int
-	var finalOutput string
+	// The conversation is summarized and facts are extracted once the run
+	// is reported complete, so the extra model calls do not delay it; Stop
+	// waits for them.
+	bgCtx := context.WithoutCancel(ctx)
+	if saved {
+		e.background.Go(func() { e.summarizeConversation(bgCtx, rr) })
+	}
+	e.background.Go(func() { e.extractFacts(bgCtx, rr) })
+}
+
+// runReAct executes an agent using the ReAct reasoning loop synchronously.
+func (e *Engine) runReAct(ctx context.Context, ag *agent.Config, input string, overrides *RunOverrides) (*run.Run, error) {
+	rr := e.newReactRun(ctx, ag, overrides)
+	ctx, err := e.startRun(ctx, rr, input)
+	if errors.Is(err, cortex.ErrRunCancelled) {
+		return rr.r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rr.finish()
+	return e.reactLoop(ctx, rr)
+}
+
+// reactLoop runs ReAct steps until the model produces a final answer, the
+// step limit is reached, a tool call pauses the run for approval or the run
+// is cancelled.
+func (e *Engine) reactLoop(ctx context.Context, rr *reactRun) (*run.Run, error) {
+	r := rr.r
 
 	// ReAct loop.
-	for stepIndex < cfg.MaxSteps {
+	for rr.st.Step < rr.stepLimit() {
+		if rr.stopRequested(ctx) {
+			e.cancelReactRun(ctx, rr, "")
+			return r, nil
+		}
+		if err := e.checkBudgets(ctx, rr); err != nil {
+			e.failRun(ctx, r, rr.ag.ID, err)
+			return nil, err
+		}
 		stepStart := time.Now().UTC()
+		stepIndex := rr.st.Step
 		e.extensions.EmitStepStarted(ctx, r.ID, stepIndex)
 
-		req := &llm.Request{
-			Model:       cfg.Model,
-			System:      systemPrompt,
-			Messages:    messages,
-			MaxTokens:   cfg.MaxTokens,
-			Temperature: cfg.Temperature,
-			Tools:       e.resolveTools(cfg.Tools),
-		}
+		req, metadata := e.prepareStep(ctx, rr)
 
 		// Safety: scan input before LLM call.
-		if e.safety != nil {
-			scanReq := &safety.ScanRequest{
-				Content:     input,
-				Direction:   safety.DirectionInput,
-				AgentID:     ag.ID.String(),
-				RunID:       r.ID.String(),
-				ProfileName: extractSafetyProfile(ag),
-				AppID:       ag.AppID,
-			}
-			if scanResult, scanErr := e.safety.ScanInput(ctx, scanReq); scanErr != nil {
-				e.logger.Warn("safety scan input error", log.String("error", scanErr.Error()))
-			} else if scanResult != nil && scanResult.Blocked {
-				e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: input blocked — %s", scanResult.Decision), now)
-				return nil, fmt.Errorf("safety: input blocked by %s profile", scanResult.ProfileUsed)
-			}
+		if blocked := e.scanInput(ctx, rr); blocked != nil {
+			e.failRun(ctx, r, rr.ag.ID, fmt.Errorf("safety: input blocked — %s", blocked.Decision))
+			return nil, fmt.Errorf("safety: input blocked by %s profile", blocked.ProfileUsed)
 		}
 
 		resp, err := e.llm.Complete(ctx, req)
+		if err != nil && ctx.Err() != nil {
+			e.cancelReactRun(ctx, rr, "")
+			return r, nil
+		}
 		if err != nil {
-			e.failRun(ctx, r, ag.ID, err, now)
+			e.failRun(ctx, r, rr.ag.ID, err)
 			return nil, fmt.Errorf("llm complete: %w", err)
 		}
 
-		totalTokens += resp.Usage.TotalTokens
+		rr.st.TotalTokens += resp.Usage.TotalTokens
+		e.recordUsage(ctx, rr, resp.Usage.TotalTokens)
+		metadata = recordModel(rr, metadata, resp.Model)
 
 		// Record the step.
 		stepEnd := time.Now().UTC()
@@ -96,154 +353,114 @@ func (e *Engine) runReAct(ctx context.Context, ag *agent.Config, input string, o
 			RunID:       r.ID,
 			Index:       stepIndex,
 			Type:        "generation",
-			Input:       lastContent(messages),
+			Input:       lastContent(rr.st.Messages),
 			Output:      resp.Content,
 			TokensUsed:  resp.Usage.TotalTokens,
 			StartedAt:   &stepStart,
 			CompletedAt: &stepEnd,
+			Metadata:    metadata,
 		}
 		if err := e.store.CreateStep(ctx, step); err != nil {
 			e.logger.Error("create step", log.String("error", err.Error()))
 		}
 
 		e.extensions.EmitStepCompleted(ctx, r.ID, stepIndex, stepEnd.Sub(stepStart))
-		stepIndex++
+		rr.st.Step++
 
 		// Check for tool calls.
 		if len(resp.ToolCalls) > 0 {
 			// Append assistant message with tool calls.
-			messages = append(messages, llm.Message{
+			rr.st.Messages = append(rr.st.Messages, llm.Message{
 				Role:      "assistant",
 				Content:   resp.Content,
 				ToolCalls: resp.ToolCalls,
 			})
 
-			// Execute each tool call.
-			for _, tc := range resp.ToolCalls {
-				tcStart := time.Now().UTC()
-				e.extensions.EmitToolCalled(ctx, r.ID, tc.Name, tc.Arguments)
-
-				// Execute tool (stub: returns acknowledgement).
-				result := e.executeTool(ctx, tc)
-
-				tcEnd := time.Now().UTC()
-				toolCall := &run.ToolCall{
-					Entity:      cortex.NewEntity(),
-					ID:          id.NewToolCallID(),
-					StepID:      step.ID,
-					RunID:       r.ID,
-					ToolName:    tc.Name,
-					Arguments:   tc.Arguments,
-					Result:      result,
-					StartedAt:   &tcStart,
-					CompletedAt: &tcEnd,
-				}
-				if err := e.store.CreateToolCall(ctx, toolCall); err != nil {
-					e.logger.Error("create tool call", log.String("error", err.Error()))
-				}
-
-				e.extensions.EmitToolCompleted(ctx, r.ID, tc.Name, result, tcEnd.Sub(tcStart))
-
-				// Append tool result message.
-				messages = append(messages, llm.Message{
-					Role:       "tool",
-					Content:    result,
-					ToolCallID: tc.ID,
-				})
+			// Execute each tool call, pausing at the first that needs approval.
+			if paused, err := e.runToolCalls(ctx, rr, step.ID, stepIndex, resp.ToolCalls); paused || err != nil {
+				return r, err
 			}
+			e.emitPhaseChange(ctx, r.ID, rr.cog.afterStep(resp.ToolCalls, len(rr.toolErrors) > 0))
+			e.persistRunState(ctx, rr)
 			continue // Continue the ReAct loop.
 		}
 
-		// No tool calls — this is the final response.
-		finalOutput = resp.Content
-
-		// Safety: scan output before returning.
-		if e.safety != nil {
-			scanReq := &safety.ScanRequest{
-				Content:     finalOutput,
-				Direction:   safety.DirectionOutput,
-				AgentID:     ag.ID.String(),
-				RunID:       r.ID.String(),
-				ProfileName: extractSafetyProfile(ag),
-				AppID:       ag.AppID,
-			}
-			if scanResult, scanErr := e.safety.ScanOutput(ctx, scanReq); scanErr != nil {
-				e.logger.Warn("safety scan output error", log.String("error", scanErr.Error()))
-			} else if scanResult != nil && scanResult.Blocked {
-				e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: output blocked — %s", scanResult.Decision), now)
-				return nil, fmt.Errorf("safety: output blocked by %s profile", scanResult.ProfileUsed)
-			} else if scanResult != nil && scanResult.Redacted != "" {
-				finalOutput = scanResult.Redacted
-			}
+		// No tool calls — the plan of the current phase is complete. Move on
+		// to the next phase if there is one; otherwise this is the final response.
+		if ch := rr.cog.planComplete(); ch != nil {
+			e.emitPhaseChange(ctx, r.ID, ch)
+			// The intermediate answer and the phase prompt are kept out of
+			// conversation memory.
+			n := len(rr.st.Messages)
+			rr.st.Unsaved = append(rr.st.Unsaved, n, n+1)
+			rr.st.Messages = append(rr.st.Messages,
+				llm.Message{Role: "assistant", Content: resp.Content},
+				llm.Message{Role: "user", Content: phaseContinuePrompt(ch)},
+			)
+			e.persistRunState(ctx, rr)
+			continue
+		}
+		if e.checkRequiredTools(ctx, rr, resp.Content) {
+			continue
+		}
+		finalOutput, blocked := e.scanOutput(ctx, rr, rr.styleOutput(resp.Content))
+		if blocked != nil {
+			e.failRun(ctx, r, rr.ag.ID, fmt.Errorf("safety: output blocked — %s", blocked.Decision))
+			return nil, fmt.Errorf("safety: output blocked by %s profile", blocked.ProfileUsed)
+		}
+		if repair, err := e.checkOutput(ctx, rr, finalOutput); err != nil {
+			e.failRun(ctx, r, rr.ag.ID, err)
+			return nil, err
+		} else if repair {
+			continue
 		}
 
-		messages = append(messages, llm.Message{Role: "assistant", Content: finalOutput})
-		break
-	}
-
-	// Save updated conversation.
-	convMsgs := llmToMemory(messages)
-	if err := e.store.SaveConversation(ctx, ag.ID, "", convMsgs); err != nil {
-		e.logger.Error("save conversation", log.String("error", err.Error()))
-	}
-
-	// Complete the run.
-	completedAt := time.Now().UTC()
-	r.State = run.StateCompleted
-	r.Output = finalOutput
-	r.StepCount = stepIndex
-	r.TokensUsed = totalTokens
-	r.CompletedAt = &completedAt
-	if err := e.store.UpdateRun(ctx, r); err != nil {
-		e.logger.Error("update run", log.String("error", err.Error()))
+		rr.st.Messages = append(rr.st.Messages, llm.Message{Role: "assistant", Content: finalOutput})
+		e.completeRun(ctx, rr, finalOutput)
+		return r, nil
 	}
 
-	e.extensions.EmitRunCompleted(ctx, ag.ID, r.ID, r.Output, completedAt.Sub(now))
-	return r, nil
+	return e.stepsExhausted(ctx, rr)
 }
 
 // streamReAct executes an agent using the ReAct reasoning loop with streaming.
 func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string, overrides *RunOverrides, events chan<- StreamEvent) error {
-	cfg := e.effectiveConfig(ag, overrides)
-	systemPrompt := e.BuildSystemPrompt(ctx, ag, overrides)
-
-	now := time.Now().UTC()
-	r := &run.Run{
-		Entity:     cortex.NewEntity(),
-		ID:         id.NewAgentRunID(),
-		AgentID:    ag.ID,
-		State:      run.StateRunning,
-		Input:      input,
-		StartedAt:  &now,
-		PersonaRef: cfg.PersonaRef,
+	rr := e.newReactRun(ctx, ag, overrides)
+	rr.events = events
+	ctx, err := e.startRun(ctx, rr, input)
+	if errors.Is(err, cortex.ErrRunCancelled) {
+		close(events)
+		return nil
 	}
-	if err := e.store.CreateRun(ctx, r); err != nil {
+	if err != nil {
 		close(events)
-		return fmt.Errorf("create run: %w", err)
+		return err
 	}
-
-	e.extensions.EmitRunStarted(ctx, ag.ID, r.ID, input)
+	r := rr.r
 
 	go func() {
 		defer close(events)
+		defer rr.finish()
 
 		events <- StreamEvent{Type: EventRunStarted, Data: map[string]any{
 			"run_id":   r.ID.String(),
 			"agent_id": ag.ID.String(),
 		}}
 
-		// Load conversation history.
-		history, _ := e.store.LoadConversation(ctx, ag.ID, "", 100) //nolint:errcheck // best-effort history load
-		messages := memoryToLLM(history)
-		messages = append(messages, llm.Message{Role: "user", Content: input})
-
-		var totalTokens int
-		var stepIndex int
-		var finalOutput string
-
 		// ReAct loop.
-		for stepIndex < cfg.MaxSteps {
+		for rr.st.Step < rr.stepLimit() {
+			if rr.stopRequested(ctx) {
+				e.cancelReactRun(ctx, rr, "")
+				events <- StreamEvent{Type: EventError, Data: map[string]any{"message": "cancelled"}}
+				return
+			}
+			if err := e.checkBudgets(ctx, rr); err != nil {
+				e.failRun(ctx, r, ag.ID, err)
+				events <- StreamEvent{Type: EventError, Data: map[string]any{"message": err.Error()}}
+				return
+			}
 			stepStart := time.Now().UTC()
+			stepIndex := rr.st.Step
 			e.extensions.EmitStepStarted(ctx, r.ID, stepIndex)
 
 			stepID := id.NewStepID()
@@ -253,41 +470,27 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 				"type":    "generation",
 			}}
 
-			req := &llm.Request{
-				Model:       cfg.Model,
-				System:      systemPrompt,
-				Messages:    messages,
-				MaxTokens:   cfg.MaxTokens,
-				Temperature: cfg.Temperature,
-				Tools:       e.resolveTools(cfg.Tools),
-			}
+			req, metadata := e.prepareStep(ctx, rr)
 
 			// Safety: scan input before LLM call.
-			if e.safety != nil {
-				scanReq := &safety.ScanRequest{
-					Content:     input,
-					Direction:   safety.DirectionInput,
-					AgentID:     ag.ID.String(),
-					RunID:       r.ID.String(),
-					ProfileName: extractSafetyProfile(ag),
-					AppID:       ag.AppID,
-				}
-				if scanResult, scanErr := e.safety.ScanInput(ctx, scanReq); scanErr != nil {
-					e.logger.Warn("safety scan input error", log.String("error", scanErr.Error()))
-				} else if scanResult != nil && scanResult.Blocked {
-					e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: input blocked — %s", scanResult.Decision), now)
-					events <- StreamEvent{Type: EventSafetyBlock, Data: map[string]any{
-						"direction": "input",
-						"decision":  string(scanResult.Decision),
-						"profile":   scanResult.ProfileUsed,
-					}}
-					return
-				}
+			if blocked := e.scanInput(ctx, rr); blocked != nil {
+				e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: input blocked — %s", blocked.Decision))
+				events <- StreamEvent{Type: EventSafetyBlock, Data: map[string]any{
+					"direction": "input",
+					"decision":  string(blocked.Decision),
+					"profile":   blocked.ProfileUsed,
+				}}
+				return
 			}
 
 			stream, err := e.llm.CompleteStream(ctx, req)
+			if err != nil && ctx.Err() != nil {
+				e.cancelReactRun(ctx, rr, "")
+				events <- StreamEvent{Type: EventError, Data: map[string]any{"message": "cancelled"}}
+				return
+			}
 			if err != nil {
-				e.failRun(ctx, r, ag.ID, err, now)
+				e.failRun(ctx, r, ag.ID, err)
 				events <- StreamEvent{Type: EventError, Data: map[string]any{
 					"message": err.Error(),
 				}}
@@ -300,27 +503,19 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 			tokenIndex := 0
 
 			for {
-				select {
-				case <-ctx.Done():
-					stream.Close()
-					r.State = run.StateCancelled
-					completedAt := time.Now().UTC()
-					r.CompletedAt = &completedAt
-					if err := e.store.UpdateRun(ctx, r); err != nil {
-						e.logger.Error("update run on cancel", log.String("error", err.Error()))
-					}
-					events <- StreamEvent{Type: EventError, Data: map[string]any{"message": "cancelled"}}
-					return
-				default:
-				}
-
 				chunk, err := stream.Next(ctx)
 				if errors.Is(err, io.EOF) {
 					break
 				}
+				if ctx.Err() != nil {
+					stream.Close()
+					e.cancelReactRun(ctx, rr, contentBuf)
+					events <- StreamEvent{Type: EventError, Data: map[string]any{"message": "cancelled"}}
+					return
+				}
 				if err != nil {
 					stream.Close()
-					e.failRun(ctx, r, ag.ID, err, now)
+					e.failRun(ctx, r, ag.ID, err)
 					events <- StreamEvent{Type: EventError, Data: map[string]any{
 						"message": err.Error(),
 					}}
@@ -343,8 +538,10 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 
 			// Collect usage from stream.
 			if u := stream.Usage(); u != nil {
-				totalTokens += u.TotalTokens
+				rr.st.TotalTokens += u.TotalTokens
+				e.recordUsage(ctx, rr, u.TotalTokens)
 			}
+			metadata = recordModel(rr, metadata, llm.StreamModel(stream))
 			stream.Close()
 
 			// Record the step.
@@ -355,10 +552,11 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 				RunID:       r.ID,
 				Index:       stepIndex,
 				Type:        "generation",
-				Input:       lastContent(messages),
+				Input:       lastContent(rr.st.Messages),
 				Output:      contentBuf,
 				StartedAt:   &stepStart,
 				CompletedAt: &stepEnd,
+				Metadata:    metadata,
 			}
 			if u := stream.Usage(); u != nil {
 				step.TokensUsed = u.TotalTokens
@@ -368,115 +566,270 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 			}
 
 			e.extensions.EmitStepCompleted(ctx, r.ID, stepIndex, stepEnd.Sub(stepStart))
-			stepIndex++
+			rr.st.Step++
 
 			// Check for tool calls.
 			if len(toolCalls) > 0 {
-				messages = append(messages, llm.Message{
+				rr.st.Messages = append(rr.st.Messages, llm.Message{
 					Role:      "assistant",
 					Content:   contentBuf,
 					ToolCalls: toolCalls,
 				})
 
-				for _, tc := range toolCalls {
-					tcStart := time.Now().UTC()
-					e.extensions.EmitToolCalled(ctx, r.ID, tc.Name, tc.Arguments)
-
-					events <- StreamEvent{Type: EventToolCall, Data: map[string]any{
-						"tool_name": tc.Name,
-						"arguments": tc.Arguments,
-						"tool_id":   tc.ID,
-					}}
-
-					result := e.executeTool(ctx, tc)
-
-					tcEnd := time.Now().UTC()
-					toolCall := &run.ToolCall{
-						Entity:      cortex.NewEntity(),
-						ID:          id.NewToolCallID(),
-						StepID:      step.ID,
-						RunID:       r.ID,
-						ToolName:    tc.Name,
-						Arguments:   tc.Arguments,
-						Result:      result,
-						StartedAt:   &tcStart,
-						CompletedAt: &tcEnd,
-					}
-					if err := e.store.CreateToolCall(ctx, toolCall); err != nil {
-						e.logger.Error("create tool call", log.String("error", err.Error()))
-					}
-
-					e.extensions.EmitToolCompleted(ctx, r.ID, tc.Name, result, tcEnd.Sub(tcStart))
-
-					messages = append(messages, llm.Message{
-						Role:       "tool",
-						Content:    result,
-						ToolCallID: tc.ID,
-					})
+				// Execute each tool call, pausing at the first that needs
+				// approval. The run is resumed through ResolveCheckpoint.
+				if paused, err := e.runToolCalls(ctx, rr, step.ID, stepIndex, toolCalls); err != nil {
+					events <- StreamEvent{Type: EventError, Data: map[string]any{"message": err.Error()}}
+					return
+				} else if paused {
+					return
 				}
+				e.emitPhaseChange(ctx, r.ID, rr.cog.afterStep(toolCalls, len(rr.toolErrors) > 0))
+				e.persistRunState(ctx, rr)
 				continue // Continue the ReAct loop.
 			}
 
-			// No tool calls — this is the final response.
-			finalOutput = contentBuf
-
-			// Safety: scan output before returning.
-			if e.safety != nil {
-				scanReq := &safety.ScanRequest{
-					Content:     finalOutput,
-					Direction:   safety.DirectionOutput,
-					AgentID:     ag.ID.String(),
-					RunID:       r.ID.String(),
-					ProfileName: extractSafetyProfile(ag),
-					AppID:       ag.AppID,
-				}
-				if scanResult, scanErr := e.safety.ScanOutput(ctx, scanReq); scanErr != nil {
-					e.logger.Warn("safety scan output error", log.String("error", scanErr.Error()))
-				} else if scanResult != nil && scanResult.Blocked {
-					e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: output blocked — %s", scanResult.Decision), now)
-					events <- StreamEvent{Type: EventSafetyBlock, Data: map[string]any{
-						"direction": "output",
-						"decision":  string(scanResult.Decision),
-						"profile":   scanResult.ProfileUsed,
-					}}
-					return
-				} else if scanResult != nil && scanResult.Redacted != "" {
-					finalOutput = scanResult.Redacted
-				}
+			// No tool calls — the plan of the current phase is complete. Move on
+			// to the next phase if there is one; otherwise this is the final response.
+			if ch := rr.cog.planComplete(); ch != nil {
+				e.emitPhaseChange(ctx, r.ID, ch)
+				// The intermediate answer and the phase prompt are kept out of
+				// conversation memory.
+				n := len(rr.st.Messages)
+				rr.st.Unsaved = append(rr.st.Unsaved, n, n+1)
+				rr.st.Messages = append(rr.st.Messages,
+					llm.Message{Role: "assistant", Content: contentBuf},
+					llm.Message{Role: "user", Content: phaseContinuePrompt(ch)},
+				)
+				e.persistRunState(ctx, rr)
+				continue
 			}
+			if e.checkRequiredTools(ctx, rr, contentBuf) {
+				continue
+			}
+			finalOutput, blocked := e.scanOutput(ctx, rr, rr.styleOutput(contentBuf))
+			if blocked != nil {
+				e.failRun(ctx, r, ag.ID, fmt.Errorf("safety: output blocked — %s", blocked.Decision))
+				events <- StreamEvent{Type: EventSafetyBlock, Data: map[string]any{
+					"direction": "output",
+					"decision":  string(blocked.Decision),
+					"profile":   blocked.ProfileUsed,
+				}}
+				return
+			}
+			if repair, err := e.checkOutput(ctx, rr, finalOutput); err != nil {
+				e.failRun(ctx, r, ag.ID, err)
+				events <- StreamEvent{Type: EventError, Data: map[string]any{"message": err.Error()}}
+				return
+			} else if repair {
+				continue
+			}
+
+			rr.st.Messages = append(rr.st.Messages, llm.Message{Role: "assistant", Content: finalOutput})
+			e.completeRun(ctx, rr, finalOutput)
+			events <- doneEvent(rr)
+			return
+		}
+
+		// The steps ran out. A summarized run is done; a paused one has
+		// already sent its checkpoint event.
+		if _, err := e.stepsExhausted(ctx, rr); err != nil {
+			events <- StreamEvent{Type: EventError, Data: map[string]any{"message": err.Error()}}
+		} else if r.State == run.StateCompleted {
+			events <- doneEvent(rr)
+		}
+	}()
+
+	return nil
+}
+
+// doneEvent returns the event reporting the completion of the run, with the
+// decoded answer of a run with an output schema.
+func doneEvent(rr *reactRun) StreamEvent {
+	data := map[string]any{
+		"run_id":      rr.r.ID.String(),
+		"output":      rr.r.Output,
+		"tokens_used": rr.st.TotalTokens,
+		"duration_ms": runDuration(rr.r, *rr.r.CompletedAt).Milliseconds(),
+	}
+	if v, ok := rr.r.Metadata[structuredOutputKey]; ok {
+		data["structured_output"] = v
+	}
+	return StreamEvent{Type: EventDone, Data: data}
+}
+
+// runToolCalls executes a step's tool calls and appends their results to
+// the messages in the order the model requested them. Consecutive calls run
+// concurrently, up to the run's ToolConcurrency; a Sequential tool runs on
+// its own. When a call requires approval it creates a checkpoint, pauses the
+// run and reports paused; the remaining calls run once the checkpoint is
+// resolved. Once the run is cancelled no further calls are made.
+func (e *Engine) runToolCalls(ctx context.Context, rr *reactRun, stepID id.StepID, stepIndex int, calls []llm.ToolCall) (paused bool, err error) {
+	for len(calls) > 0 {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		if rr.scope.allows(calls[0].Name) && e.requiresApproval(rr.ag, calls[0].Name) {
+			return true, e.pauseForApproval(ctx, rr, stepID, stepIndex, calls)
+		}
+		n := e.parallelCalls(rr, calls)
+		e.runToolBatch(ctx, rr, stepID, calls[:n])
+		calls = calls[n:]
+	}
+	return false, nil
+}
 
-			messages = append(messages, llm.Message{Role: "assistant", Content: finalOutput})
+// parallelCalls returns how many of the leading calls may run together: the
+// first call, and the calls after it up to the first that is Sequential or
+// requires approval. A Sequential first call runs alone.
+func (e *Engine) parallelCalls(rr *reactRun, calls []llm.ToolCall) int {
+	if e.isSequential(calls[0].Name) {
+		return 1
+	}
+	n := 1
+	for _, tc := range calls[1:] {
+		if e.isSequential(tc.Name) || (rr.scope.allows(tc.Name) && e.requiresApproval(rr.ag, tc.Name)) {
 			break
 		}
+		n++
+	}
+	return n
+}
 
-		// Save updated conversation.
-		convMsgs := llmToMemory(messages)
-		if err := e.store.SaveConversation(ctx, ag.ID, "", convMsgs); err != nil {
-			e.logger.Error("save conversation", log.String("error", err.Error()))
+// isSequential reports whether the named tool was registered with
+// Sequential.
+func (e *Engine) isSequential(name string) bool {
+	for _, rt := range e.tools {
+		if rt.def.Name == name {
+			return rt.sequential
 		}
+	}
+	return false
+}
+
+// runToolBatch executes calls concurrently, at most ToolConcurrency at a
+// time, and records them in order. Calls not started when the run is
+// cancelled are skipped.
+func (e *Engine) runToolBatch(ctx context.Context, rr *reactRun, stepID id.StepID, calls []llm.ToolCall) {
+	if len(calls) == 1 {
+		e.runToolCall(ctx, rr, stepID, calls[0])
+		return
+	}
 
-		// Complete the run.
-		completedAt := time.Now().UTC()
-		r.State = run.StateCompleted
-		r.Output = finalOutput
-		r.StepCount = stepIndex
-		r.TokensUsed = totalTokens
-		r.CompletedAt = &completedAt
-		if err := e.store.UpdateRun(ctx, r); err != nil {
-			e.logger.Error("update run", log.String("error", err.Error()))
+	limit := rr.cfg.ToolConcurrency
+	if limit <= 0 || limit > len(calls) {
+		limit = len(calls)
+	}
+	slots := make(chan struct{}, limit)
+	results := make([]toolResult, len(calls))
+	var wg sync.WaitGroup
+	started := 0
+	for i, tc := range calls {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
 		}
+		started++
+		wg.Go(func() {
+			defer func() { <-slots }()
+			results[i] = e.execToolCall(ctx, rr, tc)
+		})
+	}
+	wg.Wait()
 
-		e.extensions.EmitRunCompleted(ctx, ag.ID, r.ID, r.Output, completedAt.Sub(now))
+	for i, res := range results[:started] {
+		e.finishToolCall(ctx, rr, stepID, calls[i], res)
+	}
+}
 
-		events <- StreamEvent{Type: EventDone, Data: map[string]any{
-			"run_id":      r.ID.String(),
-			"output":      finalOutput,
-			"tokens_used": totalTokens,
-			"duration_ms": completedAt.Sub(now).Milliseconds(),
+// toolResult is the outcome of a tool call.
+type toolResult struct {
+	result   string
+	err      error
+	started  time.Time
+	attempts int // times the handler ran
+}
+
+// runToolCall executes a single tool call, records it and appends its
+// result to the messages.
+func (e *Engine) runToolCall(ctx context.Context, rr *reactRun, stepID id.StepID, tc llm.ToolCall) {
+	e.finishToolCall(ctx, rr, stepID, tc, e.execToolCall(ctx, rr, tc))
+}
+
+// execToolCall executes a tool call and emits its hooks. It does not touch
+// the loop state, so calls of one step can run concurrently.
+func (e *Engine) execToolCall(ctx context.Context, rr *reactRun, tc llm.ToolCall) toolResult {
+	tcStart := time.Now().UTC()
+	e.extensions.EmitToolCalled(ctx, rr.r.ID, tc.Name, tc.Arguments)
+	if rr.events != nil {
+		rr.events <- StreamEvent{Type: EventToolCall, Data: map[string]any{
+			"tool_name": tc.Name,
+			"arguments": tc.Arguments,
+			"tool_id":   tc.ID,
 		}}
-	}()
+	}
 
-	return nil
+	res := toolResult{started: tcStart}
+	if e.toolDisabled(rr, tc.Name) {
+		res.err = fmt.Errorf("%w: %q", cortex.ErrToolDisabled, tc.Name)
+		res.result = jsonResult("error", fmt.Sprintf("tool %q is disabled after repeated failures", tc.Name))
+	} else {
+		res.result, res.attempts, res.err = e.callTool(withRunID(ctx, rr.r.ID), tc, rr.scope)
+	}
+	if res.err != nil {
+		e.extensions.EmitToolFailed(ctx, rr.r.ID, tc.Name, res.err)
+	} else {
+		e.extensions.EmitToolCompleted(ctx, rr.r.ID, tc.Name, res.result, time.Since(tcStart))
+	}
+	return res
+}
+
+// finishToolCall records an executed tool call, appends its result to the
+// messages and counts the outcome towards the tool's circuit breaker.
+func (e *Engine) finishToolCall(ctx context.Context, rr *reactRun, stepID id.StepID, tc llm.ToolCall, res toolResult) {
+	if res.err != nil {
+		rr.toolErrors = append(rr.toolErrors, res.err.Error())
+	}
+	if res.attempts > 0 {
+		e.countToolOutcome(rr, tc.Name, res.err != nil)
+	}
+	e.recordToolCall(ctx, rr, stepID, tc, res)
+}
+
+// recordToolCall stores the tool call and appends its result message.
+func (e *Engine) recordToolCall(ctx context.Context, rr *reactRun, stepID id.StepID, tc llm.ToolCall, res toolResult) {
+	result, toolErr, started := res.result, res.err, res.started
+	tcEnd := time.Now().UTC()
+	toolCall := &run.ToolCall{
+		Entity:      cortex.NewEntity(),
+		ID:          id.NewToolCallID(),
+		StepID:      stepID,
+		RunID:       rr.r.ID,
+		ToolName:    tc.Name,
+		Arguments:   tc.Arguments,
+		Result:      result,
+		StartedAt:   &started,
+		CompletedAt: &tcEnd,
+	}
+	if toolErr != nil {
+		toolCall.Error = toolErr.Error()
+	}
+	if res.attempts > 0 {
+		toolCall.Metadata = map[string]any{"attempts": res.attempts}
+	}
+	if err := e.store.CreateToolCall(ctx, toolCall); err != nil {
+		e.logger.Error("create tool call", log.String("error", err.Error()))
+	}
+
+	// Append tool result message.
+	rr.st.Messages = append(rr.st.Messages, llm.Message{
+		Role:       "tool",
+		Content:    result,
+		ToolCallID: tc.ID,
+	})
 }
 
 // ──────────────────────────────────────────────────
@@ -484,41 +837,37 @@ func (e *Engine) streamReAct(ctx context.Context, ag *agent.Config, input string
 // ──────────────────────────────────────────────────
 
 // failRun marks a run as failed and emits the RunFailed hook.
-func (e *Engine) failRun(ctx context.Context, r *run.Run, agentID id.AgentID, runErr error, _ time.Time) {
+func (e *Engine) failRun(ctx context.Context, r *run.Run, agentID id.AgentID, runErr error) {
 	completedAt := time.Now().UTC()
 	r.State = run.StateFailed
 	r.Error = runErr.Error()
 	r.CompletedAt = &completedAt
+	clearRunState(r)
 	if err := e.store.UpdateRun(ctx, r); err != nil {
 		e.logger.Error("update run on failure", log.String("error", err.Error()))
 	}
 	e.extensions.EmitRunFailed(ctx, agentID, r.ID, runErr)
 }
 
-// resolveTools converts tool name references to llm.Tool definitions.
-func (e *Engine) resolveTools(_ []string) []llm.Tool {
-	tools := e.builtinTools()
-	for _, rt := range e.tools {
-		tools = append(tools, rt.def)
-	}
-	return tools
-}
-
-// executeTool executes a tool call and returns the result.
-func (e *Engine) executeTool(ctx context.Context, tc llm.ToolCall) string {
-	if result, handled := e.executeBuiltinTool(ctx, tc.Name, tc.Arguments); handled {
-		return result
+// resolveTools returns the llm.Tool definitions of builtin and registered
+// tools that fall within scope. When several registrations share a name only
+// the first is advertised, matching dispatch order in executeTool.
+func (e *Engine) resolveTools(scope toolScope) []llm.Tool {
+	var tools []llm.Tool
+	seen := make(map[string]bool)
+	for _, t := range e.builtinTools() {
+		if scope.allows(t.Name) && !seen[t.Name] {
+			seen[t.Name] = true
+			tools = append(tools, t)
+		}
 	}
 	for _, rt := range e.tools {
-		if rt.def.Name == tc.Name {
-			out, err := rt.handler(ctx, tc.Arguments)
-			if err != nil {
-				return jsonResult("error", err.Error())
-			}
-			return out
+		if scope.allows(rt.def.Name) && !seen[rt.def.Name] {
+			seen[rt.def.Name] = true
+			tools = append(tools, rt.def)
 		}
 	}
-	return jsonResult("error", fmt.Sprintf("unknown tool %q", tc.Name))
+	return tools
 }
 
 // memoryToLLM converts memory messages to llm messages.
@@ -562,6 +911,24 @@ func llmToMemory(msgs []llm.Message) []memory.Message {
 	return out
 }
 
+// modelKey is the run and step metadata key recording the model that
+// answered.
+const modelKey = "model"
+
+// recordModel records the model that answered a model call on the run and
+// in the step metadata it returns. The model differs from the requested one
+// when an llm.FallbackClient fell back.
+func recordModel(rr *reactRun, metadata map[string]any, model string) map[string]any {
+	if model == "" {
+		return metadata
+	}
+	if rr.r.Metadata == nil {
+		rr.r.Metadata = make(map[string]any)
+	}
+	rr.r.Metadata[modelKey] = model
+	return mergeMetadata(metadata, map[string]any{modelKey: model})
+}
+
 // lastContent returns the content of the last message.
 func lastContent(msgs []llm.Message) string {
 	if len(msgs) == 0 {
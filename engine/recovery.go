@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/run"
+)
+
+// persistRunState stores the loop state and progress of a running run so it
+// can be rebuilt from its last completed step. Failures are logged; the run
+// carries on with the previous state persisted. The run is only written
+// while it is still in its state in the store: a run cancelled through the
+// store meanwhile is flagged instead, so the cancellation is not
+// overwritten.
+func (e *Engine) persistRunState(ctx context.Context, rr *reactRun) {
+	r := rr.r
+	if ctx.Err() != nil {
+		return
+	}
+	if err := rr.saveState(); err != nil {
+		e.logger.Error("save run state", log.String("error", err.Error()))
+		return
+	}
+	r.StepCount = rr.st.Step
+	r.TokensUsed = rr.st.TotalTokens
+	updated, err := e.store.UpdateRunInState(ctx, r, r.State)
+	if err != nil {
+		e.logger.Error("update run state", log.String("error", err.Error()))
+		return
+	}
+	if !updated && e.cancelledInStore(ctx, r) {
+		rr.cancelled = true
+	}
+}
+
+// heartbeat touches the run in the store every quarter of
+// Config.OrphanedRunAge until ctx is done, so that a run busy with a long
+// step is not taken for an orphan by another process starting up.
+func (e *Engine) heartbeat(ctx context.Context, runID id.AgentRunID) {
+	interval := e.config.OrphanedRunAge / 4
+	if e.store == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			// Moving the run to the state it is in only refreshes its
+			// update time, and leaves runs cancelled meanwhile alone.
+			for _, state := range []run.State{run.StateRunning, run.StateQueued} {
+				if touched, err := e.store.TransitionRun(ctx, runID, state, state); err != nil || touched {
+					break
+				}
+			}
+		}
+	}()
+}
+
+// recoverRuns applies the configured recovery policy to runs left running or
+// queued by a process that exited mid-run: runs not updated within
+// Config.OrphanedRunAge. Each run is claimed first, so of several processes
+// starting at once only one recovers it. Submitted runs that had not
+// recorded a step yet are re-queued for the run workers instead, when this
+// process runs workers. Resumed runs continue in the background and are
+// waited for by Stop.
+func (e *Engine) recoverRuns(ctx context.Context) error {
+	policy := e.config.RecoveryPolicy
+	if policy == cortex.RecoveryNone {
+		return nil
+	}
+	var runs []*run.Run
+	for _, state := range []run.State{run.StateRunning, run.StateQueued} {
+		found, err := e.store.ListRuns(ctx, &run.ListFilter{State: state})
+		if err != nil {
+			return fmt.Errorf("list %s runs: %w", state, err)
+		}
+		runs = append(runs, found...)
+	}
+
+	cutoff := time.Now().Add(-e.config.OrphanedRunAge)
+	for _, r := range runs {
+		if e.config.OrphanedRunAge > 0 && r.UpdatedAt.After(cutoff) {
+			continue
+		}
+		claimed, err := e.store.ClaimRun(ctx, r.ID, r.State, r.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("claim run %s: %w", r.ID, err)
+		}
+		if !claimed {
+			// Another process took the run over first.
+			continue
+		}
+		handled, err := e.requeueRun(ctx, r)
+		if err != nil {
+			return err
+		}
+		if handled {
+			continue
+		}
+		if policy == cortex.RecoveryResume {
+			rr, err := e.rebuildRun(ctx, r)
+			if err == nil {
+				e.logger.Info("resuming orphaned run",
+					log.String("run_id", r.ID.String()),
+					log.String("agent_id", r.AgentID.String()),
+				)
+				e.background.Go(func() {
+					ctx, untrack := e.trackRun(withRunTenant(context.Background(), r), r.ID)
+					defer untrack()
+					release, err := e.admitRun(ctx, r)
+					if err != nil {
+						return
+					}
+					defer release()
+					if _, err := e.reactLoop(ctx, rr); err != nil {
+						e.logger.Warn("resumed run failed", log.String("run_id", r.ID.String()), log.String("error", err.Error()))
+					}
+				})
+				continue
+			}
+			e.logger.Warn("cannot resume orphaned run",
+				log.String("run_id", r.ID.String()),
+				log.String("error", err.Error()),
+			)
+		}
+		e.failOrphan(ctx, r)
+	}
+	return nil
+}
+
+// failOrphan marks a claimed orphaned run failed with cortex.ErrRunOrphaned,
+// unless it left the state it was claimed in meanwhile, e.g. because it was
+// cancelled.
+func (e *Engine) failOrphan(ctx context.Context, r *run.Run) {
+	state := r.State
+	completedAt := time.Now().UTC()
+	r.State = run.StateFailed
+	r.Error = cortex.ErrRunOrphaned.Error()
+	r.CompletedAt = &completedAt
+	clearRunState(r)
+	failed, err := e.store.UpdateRunInState(ctx, r, state)
+	if err != nil {
+		e.logger.Error("update run on failure", log.String("error", err.Error()))
+		return
+	}
+	if failed {
+		e.extensions.EmitRunFailed(ctx, r.AgentID, r.ID, cortex.ErrRunOrphaned)
+	}
+}
+
+// requeueRun moves an orphaned run submitted with SubmitRun that has not
+// recorded a step back to created, so a run worker executes it from the
+// start. Runs started with RunAgent, whose callers got an error already,
+// are not run again, and nothing is re-queued without run workers to
+// execute it. It reports whether the run was handled: re-queued, or taken
+// over by another process first.
+func (e *Engine) requeueRun(ctx context.Context, r *run.Run) (bool, error) {
+	if e.config.RunWorkers <= 0 {
+		return false, nil
+	}
+	if st, ok := loadRunState(r); !ok || !st.Submitted {
+		return false, nil
+	}
+	steps, err := e.store.ListSteps(ctx, r.ID)
+	if err != nil {
+		return false, fmt.Errorf("list steps of run %s: %w", r.ID, err)
+	}
+	if len(steps) > 0 {
+		return false, nil
+	}
+	requeued, err := e.store.TransitionRun(ctx, r.ID, r.State, run.StateCreated)
+	if err != nil {
+		return false, fmt.Errorf("requeue run %s: %w", r.ID, err)
+	}
+	if requeued {
+		e.logger.Info("requeued orphaned run",
+			log.String("run_id", r.ID.String()),
+			log.String("agent_id", r.AgentID.String()),
+		)
+		e.wakeWorker()
+	}
+	return true, nil
+}
+
+// rebuildRun reconstructs an orphaned run from its persisted state. Runs
+// interrupted while running calls approved at a checkpoint cannot be
+// rebuilt: the model would not be asked for them again.
+func (e *Engine) rebuildRun(ctx context.Context, r *run.Run) (*reactRun, error) {
+	if e.llm == nil {
+		return nil, fmt.Errorf("cortex: no LLM client configured")
+	}
+	st, ok := loadRunState(r)
+	if !ok {
+		return nil, fmt.Errorf("no persisted state")
+	}
+	if len(st.Messages) == 0 {
+		return nil, fmt.Errorf("interrupted before the first step")
+	}
+	if len(st.Pending) > 0 {
+		return nil, fmt.Errorf("interrupted during tool execution")
+	}
+	ag, err := e.store.Get(ctx, r.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve agent: %w", err)
+	}
+	rr := e.newReactRun(ctx, ag, st.Overrides)
+	rr.r = r
+	rr.restoreState(st)
+	return rr, nil
+}
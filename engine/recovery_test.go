@@ -0,0 +1,412 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/cognitive"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/store/sqlite"
+)
+
+// crashDBEnv names the database a re-executed test binary runs a crashing
+// agent against.
+const crashDBEnv = "CORTEX_TEST_CRASH_DB"
+
+// crashExitCode is the exit code of the process killed mid-run.
+const crashExitCode = 3
+
+// newRecoveryEngine returns an engine on s with "note" and "crash" tools.
+func newRecoveryEngine(t *testing.T, s *sqlite.Store, client llm.Client, crash ToolHandler, opts ...Option) *Engine {
+	t.Helper()
+	note := func(context.Context, string) (string, error) { return "noted", nil }
+	opts = append([]Option{
+		WithStore(s),
+		WithLLM(client),
+		WithTool(llm.Tool{Name: "note"}, note),
+		WithTool(llm.Tool{Name: "crash"}, crash),
+	}, opts...)
+	e, err := New(opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return e
+}
+
+// runUntilCrash runs the "worker" agent in this process and exits it while
+// the tool call of the second step is executing.
+func runUntilCrash(t *testing.T, path string) {
+	client := &scriptedLLM{responses: []*llm.Response{
+		toolCallResponse("call-1", "note", `{}`),
+		toolCallResponse("call-2", "crash", `{}`),
+	}}
+	crash := func(context.Context, string) (string, error) {
+		os.Exit(crashExitCode)
+		return "", nil
+	}
+	e := newRecoveryEngine(t, openTestStore(t, path), client, crash)
+	_, err := e.RunAgent(context.Background(), "app1", "worker", "do the work", nil)
+	t.Fatalf("RunAgent returned %v; the process should have exited", err)
+}
+
+func TestRecovery_ResumesRunAfterProcessExit(t *testing.T) {
+	if path := os.Getenv(crashDBEnv); path != "" {
+		runUntilCrash(t, path)
+		return
+	}
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cortex.db")
+	s := openTestStore(t, path)
+	if err := s.Create(ctx, &agent.Config{
+		ID: id.NewAgentID(), Name: "worker", AppID: "app1", Tools: []string{"note", "crash"},
+	}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecovery_ResumesRunAfterProcessExit$")
+	cmd.Env = append(os.Environ(), crashDBEnv+"="+path)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != crashExitCode {
+		t.Fatalf("child process: %v, want exit code %d\n%s", err, crashExitCode, out)
+	}
+
+	runs, err := s.ListRuns(ctx, &run.ListFilter{State: run.StateRunning})
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("running runs = %d, %v; want the orphaned run", len(runs), err)
+	}
+	orphan := runs[0]
+	if orphan.StepCount != 1 {
+		t.Errorf("persisted step count = %d, want 1", orphan.StepCount)
+	}
+
+	cfg := cortex.DefaultConfig()
+	cfg.RecoveryPolicy = cortex.RecoveryResume
+	cfg.OrphanedRunAge = 0
+	client := &scriptedLLM{}
+	crash := func(context.Context, string) (string, error) { return "", nil }
+	e := newRecoveryEngine(t, s, client, crash, WithConfig(cfg))
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := e.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	got, err := s.GetRun(ctx, orphan.ID)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	if got.State != run.StateCompleted || got.Output != "done" || got.StepCount != 2 {
+		t.Errorf("run = %s %q after %d steps, want completed \"done\" after 2", got.State, got.Output, got.StepCount)
+	}
+	// The resumed step starts from the messages persisted after step one.
+	msgs := client.lastRequest().Messages
+	if len(msgs) != 3 || msgs[0].Content != "do the work" || msgs[2].Role != "tool" || msgs[2].Content != "noted" {
+		t.Errorf("resumed messages = %+v, want input, note call and its result", msgs)
+	}
+}
+
+func TestRecovery_FailsStaleRunsByDefault(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	orphan := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: ag.ID, State: run.StateRunning}
+	if err := saveRunState(orphan, &runState{Messages: []llm.Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("saveRunState: %v", err)
+	}
+	if err := s.CreateRun(ctx, orphan); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+	if err := s.CreateStep(ctx, &run.Step{Entity: cortex.NewEntity(), ID: id.NewStepID(), RunID: orphan.ID}); err != nil {
+		t.Fatalf("CreateStep: %v", err)
+	}
+
+	// A run updated within OrphanedRunAge may belong to another replica.
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got, _ := s.GetRun(ctx, orphan.ID); got.State != run.StateRunning {
+		t.Fatalf("fresh run state = %s, want running", got.State)
+	}
+
+	cfg := cortex.DefaultConfig()
+	cfg.OrphanedRunAge = 10 * time.Millisecond
+	time.Sleep(2 * cfg.OrphanedRunAge)
+	e, err = New(WithStore(s), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	got, err := s.GetRun(ctx, orphan.ID)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	if got.State != run.StateFailed || got.Error != cortex.ErrRunOrphaned.Error() {
+		t.Errorf("run = %s %q, want failed with ErrRunOrphaned", got.State, got.Error)
+	}
+	if len(got.LoopState) != 0 {
+		t.Errorf("loop state left in the run after failure")
+	}
+}
+
+// listBarrierStore holds every caller listing running runs until all
+// expected callers have listed them, so that several engines starting
+// together see the same orphans.
+type listBarrierStore struct {
+	*sqlite.Store
+	listed *sync.WaitGroup
+}
+
+func (s listBarrierStore) ListRuns(ctx context.Context, filter *run.ListFilter) ([]*run.Run, error) {
+	runs, err := s.Store.ListRuns(ctx, filter)
+	if filter != nil && filter.State == run.StateRunning {
+		s.listed.Done()
+		s.listed.Wait()
+	}
+	return runs, err
+}
+
+func TestRecovery_ReplicasStartingTogetherResumeRunOnce(t *testing.T) {
+	ctx := context.Background()
+	// The replicas write concurrently; wait for the lock instead of failing.
+	s := openTestStore(t, filepath.Join(t.TempDir(), "cortex.db")+"?_pragma=busy_timeout(5000)")
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	orphan := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: ag.ID, State: run.StateRunning}
+	if err := saveRunState(orphan, &runState{Messages: []llm.Message{{Role: "user", Content: "hi"}}, Step: 1}); err != nil {
+		t.Fatalf("saveRunState: %v", err)
+	}
+	if err := s.CreateRun(ctx, orphan); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+	if err := s.CreateStep(ctx, &run.Step{Entity: cortex.NewEntity(), ID: id.NewStepID(), RunID: orphan.ID}); err != nil {
+		t.Fatalf("CreateStep: %v", err)
+	}
+
+	cfg := cortex.DefaultConfig()
+	cfg.RecoveryPolicy = cortex.RecoveryResume
+	cfg.OrphanedRunAge = 10 * time.Millisecond
+	time.Sleep(2 * cfg.OrphanedRunAge)
+
+	listed := new(sync.WaitGroup)
+	listed.Add(2)
+	clients := []*scriptedLLM{{}, {}}
+	engines := make([]*Engine, len(clients))
+	for i, client := range clients {
+		e, err := New(WithStore(listBarrierStore{s, listed}), WithLLM(client), WithConfig(cfg))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		engines[i] = e
+	}
+	var wg sync.WaitGroup
+	for _, e := range engines {
+		wg.Go(func() {
+			if err := e.Start(ctx); err != nil {
+				t.Errorf("Start: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	for _, e := range engines {
+		if err := e.Stop(ctx); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+	}
+
+	if calls := len(clients[0].requests) + len(clients[1].requests); calls != 1 {
+		t.Errorf("model called %d times, want the run resumed by one replica", calls)
+	}
+	if got, err := s.GetRun(ctx, orphan.ID); err != nil || got.State != run.StateCompleted {
+		t.Errorf("run = %+v, %v; want completed", got, err)
+	}
+}
+
+func TestRecovery_RequeuesRunsWithoutSteps(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	orphan := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: ag.ID, State: run.StateRunning, Input: "do the work"}
+	if err := saveRunState(orphan, &runState{Overrides: &RunOverrides{Model: "fast"}, Submitted: true}); err != nil {
+		t.Fatalf("saveRunState: %v", err)
+	}
+	if err := s.CreateRun(ctx, orphan); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+	// A run started with RunAgent already failed for its caller.
+	direct := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: ag.ID, State: run.StateRunning, Input: "answer me"}
+	if err := saveRunState(direct, &runState{}); err != nil {
+		t.Fatalf("saveRunState: %v", err)
+	}
+	if err := s.CreateRun(ctx, direct); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+
+	client := &scriptedLLM{}
+	cfg := cortex.DefaultConfig()
+	cfg.OrphanedRunAge = 0
+	cfg.RunWorkers = 1
+	cfg.RunPollInterval = 10 * time.Millisecond
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop(ctx) //nolint:errcheck // test cleanup
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	got, err := e.WaitRun(waitCtx, orphan.ID)
+	if err != nil {
+		t.Fatalf("WaitRun: %v", err)
+	}
+	if got.State != run.StateCompleted || got.Output != "done" {
+		t.Fatalf("run = %s %q, want re-queued and completed", got.State, got.Output)
+	}
+	if req := client.lastRequest(); req.Model != "fast" || req.Messages[0].Content != "do the work" {
+		t.Errorf("request = model %q, messages %+v; want the run executed from the start", req.Model, req.Messages)
+	}
+	if got, err := s.GetRun(ctx, direct.ID); err != nil || got.State != run.StateFailed || got.Error != cortex.ErrRunOrphaned.Error() {
+		t.Errorf("direct run = %+v, %v; want failed with ErrRunOrphaned instead of run again", got, err)
+	}
+}
+
+func TestRecovery_FailsRunsWithoutStepsWithoutWorkers(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	orphan := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: ag.ID, State: run.StateRunning, Input: "do the work"}
+	if err := saveRunState(orphan, &runState{Submitted: true}); err != nil {
+		t.Fatalf("saveRunState: %v", err)
+	}
+	if err := s.CreateRun(ctx, orphan); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+
+	cfg := cortex.DefaultConfig()
+	time.Sleep(10 * time.Millisecond)
+	cfg.OrphanedRunAge = 5 * time.Millisecond
+	e, err := New(WithStore(s), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop(ctx) //nolint:errcheck // test cleanup
+
+	got, err := s.GetRun(ctx, orphan.ID)
+	if err != nil || got.State != run.StateFailed || got.Error != cortex.ErrRunOrphaned.Error() {
+		t.Errorf("run = %+v, %v; want failed with ErrRunOrphaned instead of left created", got, err)
+	}
+}
+
+func TestRecovery_HeartbeatKeepsLiveRunsFresh(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	r := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: id.NewAgentID(), State: run.StateRunning}
+	if err := s.CreateRun(ctx, r); err != nil {
+		t.Fatalf("CreateRun: %v", err)
+	}
+
+	cfg := cortex.DefaultConfig()
+	cfg.OrphanedRunAge = 40 * time.Millisecond
+	e, err := New(WithStore(s), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, untrack := e.trackRun(ctx, r.ID)
+	defer untrack()
+
+	// A run executing for several times OrphanedRunAge without completing a
+	// step must still look alive to a replica starting up.
+	time.Sleep(4 * cfg.OrphanedRunAge)
+	got, err := s.GetRun(ctx, r.ID)
+	if err != nil {
+		t.Fatalf("GetRun: %v", err)
+	}
+	if age := time.Since(got.UpdatedAt); age > cfg.OrphanedRunAge {
+		t.Errorf("run last updated %s ago, want within %s", age, cfg.OrphanedRunAge)
+	}
+}
+
+func TestRunState_RestoresCognitiveAndBehaviorProgress(t *testing.T) {
+	style := cognitive.Style{Phases: []cognitive.Phase{
+		{Strategy: cognitive.StrategyAnalytical, MaxSteps: 1, Transition: cognitive.TransitionAfterSteps},
+		{Strategy: cognitive.StrategyMethodical, MaxSteps: 3, Transition: cognitive.TransitionAfterSteps},
+		{Strategy: cognitive.StrategyCreative},
+	}}
+	wrapUp := []*behavior.Behavior{{
+		Name:     "wrap-up",
+		Triggers: []behavior.Trigger{{Type: behavior.TriggerOnStepCount, Pattern: "1"}},
+	}}
+	newRun := func() *reactRun {
+		return &reactRun{
+			r:         &run.Run{},
+			cog:       &cognitiveEngine{style: style},
+			behaviors: &behaviorEvaluator{fired: make(map[string]bool), behaviors: wrapUp},
+		}
+	}
+
+	rr := newRun()
+	call := []llm.ToolCall{{Name: "search", Arguments: `{}`}}
+	rr.cog.afterStep(call, false)
+	rr.cog.afterStep(call, false)
+	if fx := rr.behaviors.evaluate(behaviorSignals{Step: 1}); len(fx.Triggered) != 1 {
+		t.Fatalf("triggered = %v, want wrap-up", fx.Triggered)
+	}
+	if err := rr.saveState(); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	st, ok := loadRunState(rr.r)
+	if !ok {
+		t.Fatal("loadRunState: no state")
+	}
+	resumed := newRun()
+	resumed.restoreState(st)
+	if resumed.cog.phase() != cognitive.StrategyMethodical || resumed.cog.stepsInPhase != 1 {
+		t.Errorf("resumed phase = %q after %d steps in it, want methodical after 1", resumed.cog.phase(), resumed.cog.stepsInPhase)
+	}
+	if fx := resumed.behaviors.evaluate(behaviorSignals{Step: 2}); len(fx.Triggered) != 0 {
+		t.Errorf("resumed run fired %v again", fx.Triggered)
+	}
+	// Two more steps finish the methodical phase as they would have without
+	// the interruption.
+	resumed.cog.afterStep(call, false)
+	if ch := resumed.cog.afterStep(call, false); ch == nil || ch.To != "creative" {
+		t.Errorf("afterStep = %+v, want methodical -> creative", ch)
+	}
+}
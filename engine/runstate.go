@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// runState is the part of a ReAct run that must survive a pause or a process
+// exit: everything needed to rebuild the loop from the store.
+type runState struct {
+	// Overrides are the per-run overrides the run was started with.
+	Overrides *RunOverrides `json:"overrides,omitempty"`
+	// Submitted reports whether the run was submitted with SubmitRun for
+	// the run workers, rather than run by a caller waiting for it.
+	Submitted bool `json:"submitted,omitempty"`
+	// Messages is the conversation sent to the model, including tool results.
+	Messages []llm.Message `json:"messages"`
+	// History is the number of leading messages loaded from conversation
+	// memory; they are not saved again when the run completes.
+	History int `json:"history,omitempty"`
+	// Summary is the summary of the conversation older than the loaded
+	// history.
+	Summary string `json:"summary,omitempty"`
+	// Facts are the contents of the long-term facts recalled for the run.
+	Facts []string `json:"facts,omitempty"`
+	// Step is the number of steps taken.
+	Step int `json:"step"`
+	// ExtraSteps is the number of steps granted beyond MaxSteps at
+	// max-steps checkpoints.
+	ExtraSteps int `json:"extra_steps,omitempty"`
+	// TotalTokens is the number of tokens used so far.
+	TotalTokens int `json:"total_tokens"`
+	// ToolFailures counts the consecutive failed calls of each tool.
+	ToolFailures map[string]int `json:"tool_failures,omitempty"`
+	// OutputRepairs is the number of times the model was asked to correct a
+	// final answer that did not match the output schema.
+	OutputRepairs int `json:"output_repairs,omitempty"`
+	// Unsaved lists the indexes in Messages of the messages the engine
+	// added to steer the run, and of the answers they followed up on:
+	// rejected answers and repair prompts, intermediate answers and phase
+	// prompts, and the max-steps summary prompt. They are not saved to
+	// conversation memory.
+	Unsaved []int `json:"unsaved,omitempty"`
+	// Cognitive is the progress through the persona's cognitive phases.
+	Cognitive *cognitiveState `json:"cognitive,omitempty"`
+	// FiredBehaviors lists the on_step_count behaviors that already fired.
+	FiredBehaviors []string `json:"fired_behaviors,omitempty"`
+	// RequiredTools lists the tools required by require_tool actions. A
+	// tool called once during the run meets its requirement.
+	RequiredTools []string `json:"required_tools,omitempty"`
+	// Pending holds the tool calls of the last step that have not run yet.
+	// The first one is awaiting approval.
+	Pending []llm.ToolCall `json:"pending,omitempty"`
+	// PendingStep is the step the pending tool calls belong to.
+	PendingStep id.StepID `json:"pending_step,omitzero"`
+}
+
+// newMessages returns the messages of the run to save to conversation
+// memory: those after the loaded history, without the unsaved ones.
+func (st *runState) newMessages() []llm.Message {
+	var msgs []llm.Message
+	for i := min(st.History, len(st.Messages)); i < len(st.Messages); i++ {
+		if !slices.Contains(st.Unsaved, i) {
+			msgs = append(msgs, st.Messages[i])
+		}
+	}
+	return msgs
+}
+
+// require adds tools to those the model must call before its final answer.
+func (st *runState) require(tools []string) {
+	for _, name := range tools {
+		if !slices.Contains(st.RequiredTools, name) {
+			st.RequiredTools = append(st.RequiredTools, name)
+		}
+	}
+}
+
+// called reports whether the model called the tool during the run.
+func (st *runState) called(name string) bool {
+	for _, m := range st.Messages[min(st.History, len(st.Messages)):] {
+		if slices.ContainsFunc(m.ToolCalls, func(tc llm.ToolCall) bool { return tc.Name == name }) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveRunState stores st as the run's loop state. The loop state is kept
+// apart from the run's metadata, so the messages of the run never reach
+// clients.
+func saveRunState(r *run.Run, st *runState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshal run state: %w", err)
+	}
+	r.LoopState = data
+	return nil
+}
+
+// saveState stores the loop state of rr in the run, along with the progress
+// of its cognitive engine and behavior evaluator.
+func (rr *reactRun) saveState() error {
+	rr.st.Cognitive = rr.cog.state()
+	rr.st.FiredBehaviors = rr.behaviors.firedNames()
+	return saveRunState(rr.r, &rr.st)
+}
+
+// restoreState continues rr from a persisted loop state.
+func (rr *reactRun) restoreState(st *runState) {
+	rr.st = *st
+	rr.cog.restore(st.Cognitive)
+	rr.behaviors.restore(st.FiredBehaviors)
+}
+
+// loadRunState decodes the loop state stored in the run.
+func loadRunState(r *run.Run) (*runState, bool) {
+	if len(r.LoopState) == 0 {
+		return nil, false
+	}
+	var st runState
+	if err := json.Unmarshal(r.LoopState, &st); err != nil {
+		return nil, false
+	}
+	return &st, true
+}
+
+// clearRunState removes the loop state from the run.
+func clearRunState(r *run.Run) {
+	r.LoopState = nil
+}
+
+// runDuration is the time from the run's start to end.
+func runDuration(r *run.Run, end time.Time) time.Duration {
+	if r.StartedAt == nil {
+		return 0
+	}
+	return end.Sub(*r.StartedAt)
+}
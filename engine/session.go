@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
+)
+
+// sessionOf returns the session a run with overrides belongs to; the nil
+// session when it names none.
+func sessionOf(overrides *RunOverrides) id.SessionID {
+	if overrides == nil {
+		return id.Nil
+	}
+	return overrides.SessionID
+}
+
+// checkSession returns cortex.ErrSessionNotFound unless the session named
+// by overrides, if any, is a session of ag under the tenant of ctx.
+func (e *Engine) checkSession(ctx context.Context, ag *agent.Config, overrides *RunOverrides) error {
+	sessionID := sessionOf(overrides)
+	if sessionID.IsNil() {
+		return nil
+	}
+	s, err := e.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("resolve session: %w", err)
+	}
+	if s.AgentID.String() != ag.ID.String() || s.TenantID != cortex.TenantFromContext(ctx) {
+		return fmt.Errorf("resolve session: %w", cortex.ErrSessionNotFound)
+	}
+	return nil
+}
+
+// touchSession records activity in the session of r, if it has one.
+func (e *Engine) touchSession(ctx context.Context, r *run.Run) {
+	if r.SessionID.IsNil() {
+		return
+	}
+	if err := e.store.TouchSession(ctx, r.SessionID, time.Now().UTC()); err != nil {
+		e.logger.Warn("touch session",
+			log.String("session_id", r.SessionID.String()),
+			log.String("error", err.Error()),
+		)
+	}
+}
+
+// ──────────────────────────────────────────────────
+// Session CRUD
+// ──────────────────────────────────────────────────
+
+func (e *Engine) CreateSession(ctx context.Context, s *session.Session) error {
+	if e.store == nil {
+		return cortex.ErrNoStore
+	}
+	return e.store.CreateSession(ctx, s)
+}
+
+// GetSession returns a session readable under ctx; sessions of other
+// tenants are reported as cortex.ErrSessionNotFound.
+func (e *Engine) GetSession(ctx context.Context, sessionID id.SessionID) (*session.Session, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	s, err := e.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.checkRecordTenant(ctx, s.TenantID, cortex.ErrSessionNotFound); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListSessions lists sessions matching filter. A ctx carrying a tenant only
+// lists sessions of that tenant.
+func (e *Engine) ListSessions(ctx context.Context, filter *session.ListFilter) ([]*session.Session, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	var f session.ListFilter
+	if filter != nil {
+		f = *filter
+	}
+	tenant, err := e.listTenant(ctx, f.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	f.TenantID = tenant
+	return e.store.ListSessions(ctx, &f)
+}
+
+// DeleteSession deletes a session of the tenant of ctx together with its
+// conversation memory. Runs started in the session keep their session ID.
+func (e *Engine) DeleteSession(ctx context.Context, sessionID id.SessionID) error {
+	s, err := e.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if err := e.store.ClearConversation(ctx, s.AgentID, s.TenantID, s.ID); err != nil {
+		return fmt.Errorf("clear session conversation: %w", err)
+	}
+	return e.store.DeleteSession(ctx, sessionID)
+}
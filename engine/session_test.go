@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/session"
+)
+
+func TestRunAgent_ScopesConversationToSession(t *testing.T) {
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{})
+	acme := cortex.WithTenant(context.Background(), "acme")
+	ag, err := e.GetAgentByName(acme, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	sess := &session.Session{ID: id.NewSessionID(), AgentID: ag.ID, TenantID: "acme", LastActivityAt: start}
+	if err := e.CreateSession(acme, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := e.RunAgent(acme, "app1", "worker", "outside", nil); err != nil {
+		t.Fatalf("RunAgent outside session: %v", err)
+	}
+
+	inSession := &RunOverrides{SessionID: sess.ID}
+	r, err := e.RunAgent(acme, "app1", "worker", "inside", inSession)
+	if err != nil {
+		t.Fatalf("RunAgent in session: %v", err)
+	}
+	if r.SessionID.String() != sess.ID.String() {
+		t.Errorf("run session = %q, want %q", r.SessionID, sess.ID)
+	}
+	if msgs := client.lastRequest().Messages; len(msgs) != 1 || msgs[0].Content != "inside" {
+		t.Errorf("session run saw messages %+v, want only its input", msgs)
+	}
+	if _, err := e.RunAgent(acme, "app1", "worker", "again", inSession); err != nil {
+		t.Fatalf("second RunAgent in session: %v", err)
+	}
+	if msgs := client.lastRequest().Messages; len(msgs) != 3 || msgs[0].Content != "inside" {
+		t.Errorf("second session run saw messages %+v, want the session's exchange first", msgs)
+	}
+
+	got, err := e.GetSession(acme, sess.ID)
+	if err != nil || !got.LastActivityAt.After(start) {
+		t.Errorf("session last activity = %v, %v; want it moved past %v", got.LastActivityAt, err, start)
+	}
+
+	if err := e.DeleteSession(acme, sess.ID); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if history, err := e.LoadConversation(acme, ag.ID, "acme", sess.ID, 10); err != nil || len(history) != 0 {
+		t.Errorf("deleted session conversation = %+v, %v; want none", history, err)
+	}
+	if history, err := e.LoadConversation(acme, ag.ID, "acme", id.Nil, 10); err != nil || len(history) != 2 {
+		t.Errorf("conversation outside sessions = %+v, %v; want it kept", history, err)
+	}
+}
+
+func TestRunAgent_RejectsForeignSession(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cortex.DefaultConfig(), &agent.Config{})
+	ag, err := e.GetAgentByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+
+	sess := &session.Session{ID: id.NewSessionID(), AgentID: ag.ID, TenantID: "globex"}
+	if err := e.CreateSession(ctx, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	acme := cortex.WithTenant(ctx, "acme")
+	overrides := &RunOverrides{SessionID: sess.ID}
+	if _, err := e.RunAgent(acme, "app1", "worker", "work", overrides); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Fatalf("RunAgent err = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := e.SubmitRun(acme, "app1", "worker", "work", overrides); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Fatalf("SubmitRun err = %v, want ErrSessionNotFound", err)
+	}
+	missing := &RunOverrides{SessionID: id.NewSessionID()}
+	if _, err := e.RunAgent(acme, "app1", "worker", "work", missing); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Fatalf("RunAgent with a missing session err = %v, want ErrSessionNotFound", err)
+	}
+	if len(client.requests) != 0 {
+		t.Errorf("model called %d times for runs in foreign sessions", len(client.requests))
+	}
+}
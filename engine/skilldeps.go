@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/skill"
+)
+
+// maxSkillDependencyDepth bounds how deep a skill's dependency chain may go.
+const maxSkillDependencyDepth = 8
+
+// skillDependency is a skill pulled in transitively by another skill.
+type skillDependency struct {
+	skill      *skill.Skill
+	requiredBy string
+}
+
+// expandSkillDependencies returns the transitive dependencies of roots in
+// depth-first declaration order. Roots and duplicates are left out. It fails
+// with cortex.ErrSkillDependencyCycle when a skill depends on itself directly
+// or indirectly, cortex.ErrSkillDependencyTooDeep when a chain is longer than
+// maxSkillDependencyDepth, and cortex.ErrSkillDependencyNotFound when a
+// dependency does not exist in appID. On error the dependencies resolved so
+// far are returned along with it.
+func (e *Engine) expandSkillDependencies(ctx context.Context, appID string, roots []*skill.Skill) ([]skillDependency, error) {
+	held := make(map[string]*skill.Skill, len(roots))
+	for _, r := range roots {
+		held[r.Name] = r
+	}
+
+	// visiting holds the skills on the current path, done those whose
+	// dependencies were all expanded. A root is only done once visited, so a
+	// cycle through several roots is found too.
+	visiting := make(map[string]bool)
+	done := make(map[string]bool)
+	var deps []skillDependency
+	var visit func(s *skill.Skill, path []string) error
+	visit = func(s *skill.Skill, path []string) error {
+		path = append(path, s.Name)
+		visiting[s.Name] = true
+		defer delete(visiting, s.Name)
+		for _, name := range s.Dependencies {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if visiting[name] {
+				return fmt.Errorf("%w: %s", cortex.ErrSkillDependencyCycle, strings.Join(append(path, name), " -> "))
+			}
+			if done[name] {
+				continue
+			}
+			if len(path) > maxSkillDependencyDepth {
+				return fmt.Errorf("%w: %s -> %s exceeds %d levels",
+					cortex.ErrSkillDependencyTooDeep, strings.Join(path, " -> "), name, maxSkillDependencyDepth)
+			}
+			dep, ok := held[name]
+			if !ok {
+				var err error
+				if dep, err = e.store.GetSkillByName(ctx, appID, name); err != nil {
+					if errors.Is(err, cortex.ErrSkillNotFound) {
+						return fmt.Errorf("%w: %q required by %q", cortex.ErrSkillDependencyNotFound, name, s.Name)
+					}
+					return fmt.Errorf("load skill dependency %q: %w", name, err)
+				}
+				held[name] = dep
+				deps = append(deps, skillDependency{skill: dep, requiredBy: s.Name})
+			}
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		done[s.Name] = true
+		return nil
+	}
+
+	for _, r := range roots {
+		if done[r.Name] {
+			continue
+		}
+		if err := visit(r, nil); err != nil {
+			return deps, err
+		}
+	}
+	return deps, nil
+}
+
+// validateSkillDependencies checks that every dependency of s exists in its
+// app and that the dependency graph through s is acyclic and within the
+// depth limit.
+func (e *Engine) validateSkillDependencies(ctx context.Context, s *skill.Skill) error {
+	if len(s.Dependencies) == 0 {
+		return nil
+	}
+	_, err := e.expandSkillDependencies(ctx, s.AppID, []*skill.Skill{s})
+	return err
+}
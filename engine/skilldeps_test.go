@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/skill"
+)
+
+func newSkill(name string, deps ...string) *skill.Skill {
+	return &skill.Skill{ID: id.NewSkillID(), Name: name, AppID: "app1", SystemPromptFragment: name + " guidance.", Dependencies: deps}
+}
+
+func TestResolvePersona_ExpandsSkillDependencies(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// incident-response -> log-analysis -> grep, metrics; metrics -> grep.
+	for _, sk := range []*skill.Skill{
+		newSkill("grep"),
+		newSkill("metrics", "grep"),
+		newSkill("log-analysis", "grep", "metrics"),
+		newSkill("incident-response", "log-analysis"),
+	} {
+		if err := e.CreateSkill(ctx, sk); err != nil {
+			t.Fatalf("CreateSkill(%s): %v", sk.Name, err)
+		}
+	}
+
+	ag := &agent.Config{Name: "oncall", AppID: "app1", InlineSkills: []string{"incident-response", "metrics"}}
+	rp := e.ResolvePersona(ctx, ag, nil)
+
+	var got []string
+	for _, rs := range rp.Skills {
+		got = append(got, rs.Skill.Name+":"+rs.Source+":"+rs.RequiredBy)
+	}
+	want := "incident-response:inline:,metrics:inline:,log-analysis:dependency:incident-response,grep:dependency:log-analysis"
+	if strings.Join(got, ",") != want {
+		t.Errorf("skills = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	prompt := e.BuildSystemPrompt(ctx, ag, nil)
+	if !strings.Contains(prompt, "## Skill: log-analysis (competent, required by incident-response)\nlog-analysis guidance.") {
+		t.Errorf("prompt missing dependency section:\n%s", prompt)
+	}
+}
+
+func TestCreateSkill_ValidatesDependencies(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := e.CreateSkill(ctx, newSkill("a", "missing")); !errors.Is(err, cortex.ErrSkillDependencyNotFound) {
+		t.Fatalf("missing dependency: err = %v, want ErrSkillDependencyNotFound", err)
+	}
+	other := newSkill("elsewhere")
+	other.AppID = "app2"
+	if err := e.CreateSkill(ctx, other); err != nil {
+		t.Fatalf("CreateSkill: %v", err)
+	}
+	if err := e.CreateSkill(ctx, newSkill("a", "elsewhere")); !errors.Is(err, cortex.ErrSkillDependencyNotFound) {
+		t.Fatalf("dependency in another app: err = %v, want ErrSkillDependencyNotFound", err)
+	}
+	if err := e.CreateSkill(ctx, newSkill("self", "self")); !errors.Is(err, cortex.ErrSkillDependencyCycle) {
+		t.Fatalf("self dependency: err = %v, want ErrSkillDependencyCycle", err)
+	}
+
+	b := newSkill("b")
+	if err := e.CreateSkill(ctx, b); err != nil {
+		t.Fatalf("CreateSkill(b): %v", err)
+	}
+	if err := e.CreateSkill(ctx, newSkill("c", "b")); err != nil {
+		t.Fatalf("CreateSkill(c): %v", err)
+	}
+	b.Dependencies = []string{"c"}
+	err = e.UpdateSkill(ctx, b)
+	if !errors.Is(err, cortex.ErrSkillDependencyCycle) || !strings.Contains(err.Error(), "b -> c -> b") {
+		t.Fatalf("UpdateSkill cycle: err = %v, want ErrSkillDependencyCycle naming b -> c -> b", err)
+	}
+}
+
+func TestCreateSkill_RejectsDeepDependencyChain(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	prev := ""
+	for i := range maxSkillDependencyDepth + 1 {
+		sk := newSkill(fmt.Sprint("level", i))
+		if prev != "" {
+			sk.Dependencies = []string{prev}
+		}
+		if err := e.CreateSkill(ctx, sk); err != nil {
+			t.Fatalf("CreateSkill(%s): %v", sk.Name, err)
+		}
+		prev = sk.Name
+	}
+	if err := e.CreateSkill(ctx, newSkill("top", prev)); !errors.Is(err, cortex.ErrSkillDependencyTooDeep) {
+		t.Fatalf("err = %v, want ErrSkillDependencyTooDeep", err)
+	}
+}
+
+func TestResolvePersona_ReportsCycleThroughHeldSkills(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Written to the store directly, bypassing validation.
+	for _, sk := range []*skill.Skill{newSkill("a", "b"), newSkill("b", "a")} {
+		if err := s.CreateSkill(ctx, sk); err != nil {
+			t.Fatalf("create skill %s: %v", sk.Name, err)
+		}
+	}
+
+	ag := &agent.Config{Name: "looper", AppID: "app1", InlineSkills: []string{"a", "b"}}
+	rp := e.ResolvePersona(ctx, ag, nil)
+	if len(rp.Skills) != 2 {
+		t.Errorf("skills = %d, want the 2 held skills", len(rp.Skills))
+	}
+	if len(rp.Warnings) != 1 || !strings.Contains(rp.Warnings[0], cortex.ErrSkillDependencyCycle.Error()) ||
+		!strings.Contains(rp.Warnings[0], "a -> b -> a") {
+		t.Errorf("warnings = %q, want the a -> b -> a cycle", rp.Warnings)
+	}
+}
@@ -0,0 +1,263 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/run"
+)
+
+// SubmitRun records a run of the named agent in the created state and
+// returns it without executing it. A run worker of this or another process
+// sharing the store picks it up; use WaitRun or GetRun to follow it. The
+// tenant in ctx is recorded on the run and applies when it executes.
+func (e *Engine) SubmitRun(ctx context.Context, appID, agentName, input string, overrides *RunOverrides) (*run.Run, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+	if err := e.checkTenant(ctx); err != nil {
+		return nil, err
+	}
+
+	ag, err := e.store.GetByName(ctx, appID, agentName)
+	if err != nil {
+		return nil, fmt.Errorf("resolve agent: %w", err)
+	}
+	if err := e.checkSession(ctx, ag, overrides); err != nil {
+		return nil, err
+	}
+	if err := checkOutputSchema(ag, overrides); err != nil {
+		return nil, err
+	}
+
+	r := &run.Run{
+		Entity:    cortex.NewEntity(),
+		ID:        id.NewAgentRunID(),
+		AgentID:   ag.ID,
+		TenantID:  cortex.TenantFromContext(ctx),
+		SessionID: sessionOf(overrides),
+		State:     run.StateCreated,
+		Input:     input,
+	}
+	if err := saveRunState(r, &runState{Overrides: overrides, Submitted: true}); err != nil {
+		return nil, err
+	}
+	if err := e.store.CreateRun(ctx, r); err != nil {
+		return nil, fmt.Errorf("create run: %w", err)
+	}
+	e.wakeWorker()
+	return r, nil
+}
+
+// WaitRun blocks until the run completes, fails, is cancelled or pauses for
+// approval, and returns it. If ctx is done first, the run as last read is
+// returned with ctx.Err(). Runs of another tenant than that of ctx are
+// reported as cortex.ErrRunNotFound.
+func (e *Engine) WaitRun(ctx context.Context, runID id.AgentRunID) (*run.Run, error) {
+	if e.store == nil {
+		return nil, cortex.ErrNoStore
+	}
+
+	ticker := time.NewTicker(e.pollInterval())
+	defer ticker.Stop()
+	for {
+		// A run executing in this process is checked as soon as it stops. It
+		// is not settled before then, even while it still reads as paused
+		// because it is resuming from a checkpoint.
+		var done <-chan struct{}
+		e.activeMu.Lock()
+		if ar, ok := e.active[runID]; ok {
+			done = ar.done
+		}
+		e.activeMu.Unlock()
+
+		r, err := e.GetRun(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+		if done == nil && runSettled(r.State) {
+			return r, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return r, ctx.Err()
+		case <-done:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSettled reports whether a run in state s has stopped executing.
+func runSettled(s run.State) bool {
+	switch s {
+	case run.StateCompleted, run.StateFailed, run.StateCancelled, run.StatePaused:
+		return true
+	default:
+		return false
+	}
+}
+
+// pollInterval returns Config.RunPollInterval, or one second when unset.
+func (e *Engine) pollInterval() time.Duration {
+	if e.config.RunPollInterval > 0 {
+		return e.config.RunPollInterval
+	}
+	return time.Second
+}
+
+// wakeWorker signals an idle worker that a run may be waiting.
+func (e *Engine) wakeWorker() {
+	select {
+	case e.submitted <- struct{}{}:
+	default:
+	}
+}
+
+// startWorkers starts Config.RunWorkers workers executing submitted runs.
+func (e *Engine) startWorkers() {
+	if e.config.RunWorkers <= 0 || e.stopWorkers != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.stopWorkers = cancel
+	for range e.config.RunWorkers {
+		e.background.Go(func() { e.runWorker(ctx) })
+	}
+}
+
+// runWorker claims and executes submitted runs one at a time until ctx is
+// done. A run in progress when ctx is done is executed to the end.
+func (e *Engine) runWorker(ctx context.Context) {
+	ticker := time.NewTicker(e.pollInterval())
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		r, err := e.claimRun(ctx)
+		if err != nil && ctx.Err() == nil {
+			e.logger.Warn("claim submitted run", log.String("error", err.Error()))
+		}
+		if r != nil {
+			// Another run may be waiting for an idle worker.
+			e.wakeWorker()
+			e.executeSubmitted(context.WithoutCancel(ctx), r)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-e.submitted:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimBatch is the number of the oldest submitted runs a worker reads
+// when claiming one.
+const claimBatch = 16
+
+// claimRun moves the oldest submitted run to running and returns it, or nil
+// when there is none. Workers of several processes may race for a run; only
+// one wins it.
+func (e *Engine) claimRun(ctx context.Context) (*run.Run, error) {
+	runs, err := e.store.ListRuns(ctx, &run.ListFilter{State: run.StateCreated, OldestFirst: true, Limit: claimBatch})
+	if err != nil {
+		return nil, fmt.Errorf("list created runs: %w", err)
+	}
+	for _, r := range runs {
+		claimed, err := e.store.TransitionRun(ctx, r.ID, run.StateCreated, run.StateRunning)
+		if err != nil {
+			return nil, fmt.Errorf("claim run %s: %w", r.ID, err)
+		}
+		if claimed {
+			r.State = run.StateRunning
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// executeSubmitted executes a claimed run to the end under the tenant it
+// was submitted with.
+func (e *Engine) executeSubmitted(ctx context.Context, r *run.Run) {
+	ctx = withRunTenant(ctx, r)
+	ag, err := e.store.Get(ctx, r.AgentID)
+	if err != nil {
+		e.failRun(ctx, r, r.AgentID, fmt.Errorf("resolve agent: %w", err))
+		return
+	}
+
+	if e.llm == nil {
+		now := time.Now().UTC()
+		r.StartedAt = &now
+		e.completeMock(ctx, ag, r)
+		return
+	}
+
+	var overrides *RunOverrides
+	if st, ok := loadRunState(r); ok {
+		overrides = st.Overrides
+	}
+	rr := e.newReactRun(ctx, ag, overrides)
+	rr.r = r
+	rr.st.Submitted = true
+	ctx, err = e.startSubmittedRun(ctx, rr)
+	if err != nil {
+		if !errors.Is(err, cortex.ErrRunCancelled) {
+			e.logger.Warn("start submitted run", log.String("run_id", r.ID.String()), log.String("error", err.Error()))
+		}
+		return
+	}
+	defer rr.finish()
+	if _, err := e.reactLoop(ctx, rr); err != nil {
+		e.logger.Warn("submitted run failed", log.String("run_id", r.ID.String()), log.String("error", err.Error()))
+	}
+}
+
+// startSubmittedRun is startRun for a claimed run whose record already
+// exists: it seeds the messages, records them with the start time, waits
+// for an execution slot and emits the start hooks. A run cancelled before
+// it starts returns cortex.ErrRunCancelled.
+func (e *Engine) startSubmittedRun(ctx context.Context, rr *reactRun) (context.Context, error) {
+	r := rr.r
+	e.seedMessages(ctx, rr, r.Input)
+	r.PersonaRef = rr.cfg.PersonaRef
+	if err := rr.saveState(); err != nil {
+		e.failRun(ctx, r, r.AgentID, err)
+		return ctx, err
+	}
+
+	ctx, untrack := e.trackRun(ctx, r.ID)
+	if e.cancelledInStore(ctx, r) {
+		untrack()
+		e.recordCancelled(ctx, r, "")
+		return ctx, cortex.ErrRunCancelled
+	}
+	release, admitted := e.admission.tryAcquire(r.TenantID)
+	if admitted {
+		now := time.Now().UTC()
+		r.StartedAt = &now
+		if err := e.store.UpdateRun(ctx, r); err != nil {
+			release()
+			untrack()
+			return ctx, fmt.Errorf("update run: %w", err)
+		}
+	} else {
+		var err error
+		if release, err = e.awaitAdmission(ctx, r); err != nil {
+			untrack()
+			return ctx, err
+		}
+	}
+	rr.finish = func() {
+		untrack()
+		release()
+	}
+
+	e.emitRunStarted(ctx, rr)
+	return ctx, nil
+}
@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/run"
+)
+
+func TestSubmitRun_WorkerExecutesRun(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	client := &scriptedLLM{}
+	cfg := cortex.DefaultConfig()
+	cfg.RunWorkers = 2
+	cfg.RunPollInterval = 10 * time.Millisecond
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	submitted, err := e.SubmitRun(cortex.WithTenant(ctx, "acme"), "app1", "worker", "do the work", &RunOverrides{Model: "fast"})
+	if err != nil {
+		t.Fatalf("SubmitRun: %v", err)
+	}
+	if submitted.State != run.StateCreated || submitted.TenantID != "acme" {
+		t.Fatalf("submitted run = %+v, want created for tenant acme", submitted)
+	}
+
+	if err := e.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer e.Stop(ctx) //nolint:errcheck // test cleanup
+
+	waitCtx, cancel := context.WithTimeout(cortex.WithTenant(ctx, "acme"), 5*time.Second)
+	defer cancel()
+	r, err := e.WaitRun(waitCtx, submitted.ID)
+	if err != nil {
+		t.Fatalf("WaitRun: %v", err)
+	}
+	if r.State != run.StateCompleted || r.Output != "done" || r.StartedAt == nil {
+		t.Fatalf("run = %+v, want completed", r)
+	}
+	if req := client.lastRequest(); req.Model != "fast" || req.Messages[0].Content != "do the work" {
+		t.Errorf("request = model %q, messages %+v; want the submitted input and overrides", req.Model, req.Messages)
+	}
+}
+
+func TestClaimRun_ClaimsOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	e, err := New(WithStore(s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var submitted []*run.Run
+	for _, input := range []string{"first", "second", "third"} {
+		r, err := e.SubmitRun(ctx, "app1", "worker", input, nil)
+		if err != nil {
+			t.Fatalf("SubmitRun(%s): %v", input, err)
+		}
+		submitted = append(submitted, r)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for _, want := range submitted {
+		r, err := e.claimRun(ctx)
+		if err != nil {
+			t.Fatalf("claimRun: %v", err)
+		}
+		if r == nil || r.ID != want.ID || r.State != run.StateRunning {
+			t.Fatalf("claimed %+v, want %q running", r, want.Input)
+		}
+	}
+	if r, err := e.claimRun(ctx); r != nil || err != nil {
+		t.Errorf("claimRun = %+v, %v; want nothing left", r, err)
+	}
+}
+
+func TestCancelRun_CancelsUnclaimedSubmittedRun(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	rec := &cancelRecorder{}
+	e, err := New(WithStore(s), WithLLM(&scriptedLLM{}), WithExtension(rec))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	submitted, err := e.SubmitRun(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("SubmitRun: %v", err)
+	}
+
+	// No worker is running, so the wait gives up with the run still created.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	r, err := e.WaitRun(waitCtx, submitted.ID)
+	if !errors.Is(err, context.DeadlineExceeded) || r == nil || r.State != run.StateCreated {
+		t.Fatalf("WaitRun = %+v, %v; want the created run and DeadlineExceeded", r, err)
+	}
+
+	if err := e.CancelRun(ctx, submitted.ID); err != nil {
+		t.Fatalf("CancelRun: %v", err)
+	}
+	r, err = e.WaitRun(ctx, submitted.ID)
+	if err != nil || r.State != run.StateCancelled || r.CompletedAt == nil {
+		t.Fatalf("WaitRun = %+v, %v; want cancelled", r, err)
+	}
+	if _, ok := rec.output(submitted.ID); !ok {
+		t.Errorf("RunCancelled hook not emitted")
+	}
+	if ok, err := s.TransitionRun(ctx, submitted.ID, run.StateCreated, run.StateRunning); err != nil || ok {
+		t.Errorf("cancelled run claimed: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/memory"
+)
+
+// conversationSummaryPrompt is the system prompt of the call that condenses
+// older conversation messages into a summary.
+const conversationSummaryPrompt = "You maintain the long-term memory of an assistant. " +
+	"Summarize the conversation below so the assistant can continue it without the original messages. " +
+	"Keep facts, decisions, user preferences and open questions; leave out pleasantries. " +
+	"If a previous summary is given, fold it in: your summary replaces it. " +
+	"Answer with the summary only."
+
+// latestSummary returns the most recent summary of the run's conversation;
+// empty when it has none.
+func (e *Engine) latestSummary(ctx context.Context, rr *reactRun) string {
+	summaries, err := e.store.LoadSummaries(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID)
+	if err != nil {
+		e.logger.Warn("load conversation summaries",
+			log.String("agent_id", rr.ag.ID.String()),
+			log.String("error", err.Error()),
+		)
+		return ""
+	}
+	if len(summaries) == 0 {
+		return ""
+	}
+	return summaries[len(summaries)-1]
+}
+
+// summarizeConversation condenses the run's stored conversation once it
+// passes SummarizeAfterMessages messages or SummarizeAfterTokens estimated
+// tokens. All but the most recent SummaryWindow messages are summarized,
+// together with the previous summary, by the summary model; the summary is
+// saved and the messages it covers are removed. Failures are logged and
+// leave the conversation as it is. It runs after the run completed, so its
+// tokens count against budgets but not the run's TokensUsed.
+//
+// Summaries of one conversation run one at a time, each over the
+// conversation as the previous one left it, so a trim never removes
+// messages another summary read but did not cover.
+func (e *Engine) summarizeConversation(ctx context.Context, rr *reactRun) {
+	if e.config.SummarizeAfterMessages <= 0 && e.config.SummarizeAfterTokens <= 0 {
+		return
+	}
+	key := conversationOf(rr)
+	done := e.conversations.summarize(key)
+	defer done()
+
+	history, err := e.loadForSummary(ctx, rr)
+	if err != nil {
+		e.logger.Warn("load conversation for summary", log.String("error", err.Error()))
+		return
+	}
+	if !e.needsSummary(rr.cfg.Model, history) {
+		return
+	}
+	older := olderMessages(history, e.config.SummaryWindow)
+	if len(older) == 0 {
+		return
+	}
+
+	resp, err := e.llm.Complete(ctx, &llm.Request{
+		Model:     coalesceStr(e.config.SummaryModel, rr.cfg.Model),
+		System:    conversationSummaryPrompt,
+		Messages:  []llm.Message{{Role: "user", Content: summaryTranscript(e.latestSummary(ctx, rr), older)}},
+		MaxTokens: rr.cfg.MaxTokens,
+	})
+	if err != nil {
+		e.logger.Warn("summarize conversation", log.String("error", err.Error()))
+		return
+	}
+	e.recordUsage(ctx, rr, resp.Usage.TotalTokens)
+
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return
+	}
+	unlock := e.conversations.lock(key)
+	defer unlock()
+	if err := e.store.SaveSummary(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, summary); err != nil {
+		e.logger.Error("save conversation summary", log.String("error", err.Error()))
+		return
+	}
+	if err := e.store.TrimConversation(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, len(older)); err != nil {
+		e.logger.Error("trim conversation", log.String("error", err.Error()))
+	}
+}
+
+// conversationKey identifies a stored conversation.
+type conversationKey struct {
+	agentID   id.AgentID
+	tenantID  string
+	sessionID id.SessionID
+}
+
+// conversationOf returns the key of the run's conversation.
+func conversationOf(rr *reactRun) conversationKey {
+	return conversationKey{agentID: rr.ag.ID, tenantID: rr.r.TenantID, sessionID: rr.r.SessionID}
+}
+
+// conversationLocks coordinates the summaries of conversations with each
+// other and with runs loading them. Locks are created on first use and
+// dropped once no goroutine holds or waits for them.
+type conversationLocks struct {
+	mu    sync.Mutex
+	locks map[conversationKey]*conversationLock
+}
+
+// conversationLock guards one conversation.
+type conversationLock struct {
+	refs int
+	// summarizing is held for the whole of a summary.
+	summarizing sync.Mutex
+	// replacing is held exclusively while a summary is saved and the
+	// messages it covers are removed, and shared while a run loads the
+	// conversation.
+	replacing sync.RWMutex
+}
+
+// summarize waits until no other summary of the conversation runs and
+// returns the function ending this one.
+func (l *conversationLocks) summarize(key conversationKey) func() {
+	c := l.acquire(key)
+	c.summarizing.Lock()
+	return func() {
+		c.summarizing.Unlock()
+		l.release(key, c)
+	}
+}
+
+// lock locks the conversation against runs loading it and returns the
+// unlock function.
+func (l *conversationLocks) lock(key conversationKey) func() {
+	c := l.acquire(key)
+	c.replacing.Lock()
+	return func() {
+		c.replacing.Unlock()
+		l.release(key, c)
+	}
+}
+
+// rlock waits until no summary is replacing messages of the conversation
+// and returns the function releasing it.
+func (l *conversationLocks) rlock(key conversationKey) func() {
+	c := l.acquire(key)
+	c.replacing.RLock()
+	return func() {
+		c.replacing.RUnlock()
+		l.release(key, c)
+	}
+}
+
+func (l *conversationLocks) acquire(key conversationKey) *conversationLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.locks == nil {
+		l.locks = make(map[conversationKey]*conversationLock)
+	}
+	c := l.locks[key]
+	if c == nil {
+		c = &conversationLock{}
+		l.locks[key] = c
+	}
+	c.refs++
+	return c
+}
+
+func (l *conversationLocks) release(key conversationKey, c *conversationLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c.refs--; c.refs == 0 {
+		delete(l.locks, key)
+	}
+}
+
+// loadForSummary loads the run's conversation for summarizing it. Once
+// summarized, a conversation is trimmed to SummaryWindow messages and only
+// grows by the runs completed since, so it is loaded with a bound; only a
+// conversation reaching that bound, e.g. one that grew before
+// summarization was enabled, is loaded in full. The messages removed after
+// a summary are counted from the oldest, so they must all have been read.
+func (e *Engine) loadForSummary(ctx context.Context, rr *reactRun) ([]memory.Message, error) {
+	limit := max(e.config.SummarizeAfterMessages, e.config.SummaryWindow) + maxHistoryMessages
+	history, err := e.store.LoadConversation(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, limit)
+	if err != nil || len(history) < limit {
+		return history, err
+	}
+	return e.store.LoadConversation(ctx, rr.ag.ID, rr.r.TenantID, rr.r.SessionID, 0)
+}
+
+// needsSummary reports whether history passes a summarization threshold.
+// Tokens are counted with the engine's tokenizer for model.
+func (e *Engine) needsSummary(model string, history []memory.Message) bool {
+	if n := e.config.SummarizeAfterMessages; n > 0 && len(history) > n {
+		return true
+	}
+	if n := e.config.SummarizeAfterTokens; n > 0 {
+		tokens := 0
+		for _, m := range history {
+			tokens += e.tokenizer.Count(model, m.Content)
+		}
+		return tokens > n
+	}
+	return false
+}
+
+// olderMessages returns the messages of history before its most recent
+// window. Tool results at the start of the window go with the older
+// messages, so the kept messages never open with a result whose tool call
+// was summarized.
+func olderMessages(history []memory.Message, window int) []memory.Message {
+	cut := max(len(history)-max(window, 0), 0)
+	for cut < len(history) && history[cut].Role == "tool" {
+		cut++
+	}
+	return history[:cut]
+}
+
+// summaryTranscript renders the previous summary and msgs as the input of a
+// summary call.
+func summaryTranscript(previous string, msgs []memory.Message) string {
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("Previous summary:\n" + previous + "\n\n")
+	}
+	b.WriteString("Conversation:\n")
+	for _, m := range msgs {
+		if m.Content == "" {
+			continue
+		}
+		b.WriteString(m.Role + ": " + m.Content + "\n")
+	}
+	return b.String()
+}
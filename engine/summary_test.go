@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/memory"
+	"github.com/xraph/cortex/run"
+)
+
+func TestRunAgent_SummarizesOlderConversation(t *testing.T) {
+	ctx := context.Background()
+	answer := func(content string) *llm.Response {
+		return &llm.Response{Content: content, Usage: llm.Usage{TotalTokens: 1}}
+	}
+	client := &scriptedLLM{responses: []*llm.Response{
+		answer("a1"), answer("a2"), answer("a3"), answer("the user said first and second"), answer("a4"),
+	}}
+	cfg := cortex.DefaultConfig()
+	cfg.SummarizeAfterMessages = 4
+	cfg.SummaryWindow = 2
+	cfg.SummaryModel = "cheap"
+	e := newBudgetEngine(t, client, cfg, &agent.Config{})
+	ag, err := e.GetAgentByName(ctx, "app1", "worker")
+	if err != nil {
+		t.Fatalf("GetAgentByName: %v", err)
+	}
+
+	var last *run.Run
+	for _, input := range []string{"first", "second", "third"} {
+		if last, err = e.RunAgent(ctx, "app1", "worker", input, nil); err != nil {
+			t.Fatalf("RunAgent(%s): %v", input, err)
+		}
+		e.background.Wait()
+	}
+	if last.TokensUsed != 1 {
+		t.Errorf("TokensUsed = %d, want the summary call left out", last.TokensUsed)
+	}
+	if n := len(client.requests); n != 4 {
+		t.Fatalf("model called %d times, want 3 runs and 1 summary", n)
+	}
+	sumReq := client.lastRequest()
+	if sumReq.Model != "cheap" || !strings.Contains(sumReq.Messages[0].Content, "first") || strings.Contains(sumReq.Messages[0].Content, "third") {
+		t.Errorf("summary request = %+v, want the cheap model over the messages before the window", sumReq)
+	}
+
+	summaries, err := e.LoadSummaries(ctx, ag.ID, "", id.Nil)
+	if err != nil || len(summaries) != 1 || summaries[0] != "the user said first and second" {
+		t.Fatalf("LoadSummaries = %v, %v; want the model's summary", summaries, err)
+	}
+	history, err := e.LoadConversation(ctx, ag.ID, "", id.Nil, 0)
+	if err != nil || len(history) != 2 || history[0].Content != "third" {
+		t.Fatalf("conversation = %+v, %v; want only the window", history, err)
+	}
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "fourth", nil); err != nil {
+		t.Fatalf("RunAgent(fourth): %v", err)
+	}
+	req := client.lastRequest()
+	if !strings.Contains(req.System, "the user said first and second") {
+		t.Errorf("system prompt %q lacks the conversation summary", req.System)
+	}
+	if len(req.Messages) != 3 || req.Messages[0].Content != "third" || req.Messages[2].Content != "fourth" {
+		t.Errorf("run messages = %+v, want the window followed by the input", req.Messages)
+	}
+}
+
+// overlappingSummaryLLM answers runs by echoing their input and summary
+// calls with the transcript they cover. A summary call waits briefly for a
+// second one, so summaries that may overlap do.
+type overlappingSummaryLLM struct {
+	mu          sync.Mutex
+	transcripts []string
+	arrived     chan struct{}
+}
+
+func (c *overlappingSummaryLLM) Complete(_ context.Context, req *llm.Request) (*llm.Response, error) {
+	last := req.Messages[len(req.Messages)-1].Content
+	if req.System != conversationSummaryPrompt {
+		return &llm.Response{Content: "re " + last, Usage: llm.Usage{TotalTokens: 1}}, nil
+	}
+	c.mu.Lock()
+	c.transcripts = append(c.transcripts, last)
+	c.mu.Unlock()
+	select {
+	case c.arrived <- struct{}{}:
+	case <-c.arrived:
+	case <-time.After(200 * time.Millisecond):
+	}
+	return &llm.Response{Content: "summary", Usage: llm.Usage{TotalTokens: 1}}, nil
+}
+
+func (c *overlappingSummaryLLM) CompleteStream(context.Context, *llm.Request) (llm.Stream, error) {
+	return nil, errors.New("overlappingSummaryLLM: streaming not supported")
+}
+
+func TestRunAgent_ConcurrentSummariesKeepUnsummarizedMessages(t *testing.T) {
+	ctx := context.Background()
+	client := &overlappingSummaryLLM{arrived: make(chan struct{})}
+	cfg := cortex.DefaultConfig()
+	cfg.SummarizeAfterMessages = 4
+	cfg.SummaryWindow = 2
+	s := openTestStore(t, filepath.Join(t.TempDir(), "cortex.db")+"?_pragma=busy_timeout(5000)")
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(ctx, ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	earlier := []memory.Message{
+		{Role: "user", Content: "m1"}, {Role: "assistant", Content: "m2"},
+		{Role: "user", Content: "m3"}, {Role: "assistant", Content: "m4"},
+	}
+	if err := s.SaveConversation(ctx, ag.ID, "", id.Nil, earlier); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, input := range []string{"x", "y"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := e.RunAgent(ctx, "app1", "worker", input, nil); err != nil {
+				t.Errorf("RunAgent(%s): %v", input, err)
+			}
+		}()
+	}
+	wg.Wait()
+	e.background.Wait()
+
+	history, err := e.LoadConversation(ctx, ag.ID, "", id.Nil, 0)
+	if err != nil {
+		t.Fatalf("LoadConversation: %v", err)
+	}
+	kept := make(map[string]bool)
+	for _, m := range history {
+		kept[m.Content] = true
+	}
+	summarized := strings.Join(client.transcripts, "\n")
+	for _, content := range []string{"m1", "m2", "m3", "m4", "x", "re x", "y", "re y"} {
+		if !kept[content] && !strings.Contains(summarized, ": "+content+"\n") {
+			t.Errorf("message %q was removed without being summarized; kept %+v", content, history)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/run"
+)
+
+// checkTenant returns cortex.ErrTenantRequired when Config.RequireTenant is
+// set and ctx carries no tenant to start a run under.
+func (e *Engine) checkTenant(ctx context.Context) error {
+	if e.config.RequireTenant && cortex.TenantFromContext(ctx) == "" {
+		return cortex.ErrTenantRequired
+	}
+	return nil
+}
+
+// checkRecordTenant returns notFound unless a record owned by tenantID may
+// be read under ctx, so that records of other tenants cannot be told apart
+// from missing ones. Lookups and listings follow the same rule: a ctx
+// carrying a tenant only reaches records of that tenant, and a ctx without
+// one reaches those of every tenant, unless Config.RequireTenant is set,
+// which refuses it with cortex.ErrTenantRequired.
+func (e *Engine) checkRecordTenant(ctx context.Context, tenantID string, notFound error) error {
+	tenant := cortex.TenantFromContext(ctx)
+	if tenant == "" {
+		return e.checkTenant(ctx)
+	}
+	if tenantID != tenant {
+		return notFound
+	}
+	return nil
+}
+
+// listTenant returns the tenant a listing may cover: the tenant of ctx when
+// it carries one, whatever tenant was requested; otherwise requested, or
+// cortex.ErrTenantRequired under Config.RequireTenant.
+func (e *Engine) listTenant(ctx context.Context, requested string) (string, error) {
+	if tenant := cortex.TenantFromContext(ctx); tenant != "" {
+		return tenant, nil
+	}
+	return requested, e.checkTenant(ctx)
+}
+
+// withRunTenant returns ctx carrying the tenant of r, for continuing a run
+// outside the request that started it.
+func withRunTenant(ctx context.Context, r *run.Run) context.Context {
+	if r.TenantID == "" {
+		return ctx
+	}
+	return cortex.WithTenant(ctx, r.TenantID)
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/checkpoint"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
+)
+
+func TestRunAgent_ScopesConversationToTenant(t *testing.T) {
+	s := newTestStore(t)
+	client := &scriptedLLM{}
+	e, err := New(WithStore(s), WithLLM(client))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(context.Background(), ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	acme := cortex.WithTenant(context.Background(), "acme")
+	r, err := e.RunAgent(acme, "app1", "worker", "secret plans", nil)
+	if err != nil {
+		t.Fatalf("RunAgent(acme): %v", err)
+	}
+	if r.TenantID != "acme" {
+		t.Errorf("run tenant = %q, want acme", r.TenantID)
+	}
+
+	globex := cortex.WithTenant(context.Background(), "globex")
+	if _, err := e.RunAgent(globex, "app1", "worker", "hello", nil); err != nil {
+		t.Fatalf("RunAgent(globex): %v", err)
+	}
+	if msgs := client.lastRequest().Messages; len(msgs) != 1 || msgs[0].Content != "hello" {
+		t.Errorf("globex run saw messages %+v, want only its input", msgs)
+	}
+
+	history, err := e.LoadConversation(acme, ag.ID, "acme", id.Nil, 10)
+	if err != nil || len(history) != 2 || history[0].Content != "secret plans" {
+		t.Fatalf("acme conversation = %+v, %v; want its own exchange", history, err)
+	}
+	if unscoped, err := e.LoadConversation(acme, ag.ID, "", id.Nil, 10); err != nil || len(unscoped) != 0 {
+		t.Errorf("unscoped conversation = %+v, %v; want none", unscoped, err)
+	}
+}
+
+func TestRunAgent_RequireTenant(t *testing.T) {
+	ctx := context.Background()
+	cfg := cortex.DefaultConfig()
+	cfg.RequireTenant = true
+	client := &scriptedLLM{}
+	e := newBudgetEngine(t, client, cfg, &agent.Config{})
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "work", nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Fatalf("RunAgent err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.SubmitRun(ctx, "app1", "worker", "work", nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Fatalf("SubmitRun err = %v, want ErrTenantRequired", err)
+	}
+	events := make(chan StreamEvent, 1)
+	if err := e.StreamAgent(ctx, "app1", "worker", "work", nil, events); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Fatalf("StreamAgent err = %v, want ErrTenantRequired", err)
+	}
+	if len(client.requests) != 0 {
+		t.Errorf("model called %d times for runs without a tenant", len(client.requests))
+	}
+
+	if _, err := e.RunAgent(cortex.WithTenant(ctx, "acme"), "app1", "worker", "work", nil); err != nil {
+		t.Fatalf("RunAgent with a tenant: %v", err)
+	}
+}
+
+func TestRunLookups_HideRunsOfOtherTenants(t *testing.T) {
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, _ := newApprovalEngine(t, client, nil, RequireApproval())
+
+	acme := cortex.WithTenant(context.Background(), "acme")
+	r, err := e.RunAgent(acme, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+
+	globex := cortex.WithTenant(context.Background(), "globex")
+	if _, err := e.GetRun(globex, r.ID); !errors.Is(err, cortex.ErrRunNotFound) {
+		t.Errorf("GetRun err = %v, want ErrRunNotFound", err)
+	}
+	if _, err := e.WaitRun(globex, r.ID); !errors.Is(err, cortex.ErrRunNotFound) {
+		t.Errorf("WaitRun err = %v, want ErrRunNotFound", err)
+	}
+	if _, err := e.ListWorking(globex, r.ID); !errors.Is(err, cortex.ErrRunNotFound) {
+		t.Errorf("ListWorking err = %v, want ErrRunNotFound", err)
+	}
+	if err := e.CancelRun(globex, r.ID); !errors.Is(err, cortex.ErrRunNotFound) {
+		t.Errorf("CancelRun err = %v, want ErrRunNotFound", err)
+	}
+	if err := e.ResolveCheckpoint(globex, cp.ID, checkpoint.Decision{Approved: true}); !errors.Is(err, cortex.ErrCheckpointNotFound) {
+		t.Errorf("ResolveCheckpoint err = %v, want ErrCheckpointNotFound", err)
+	}
+
+	if got, err := e.GetRun(acme, r.ID); err != nil || got.State != run.StatePaused {
+		t.Errorf("GetRun(acme) = %+v, %v; want the run left paused", got, err)
+	}
+}
+
+func TestListingsAndSessions_HideRecordsOfOtherTenants(t *testing.T) {
+	s := newTestStore(t)
+	e, err := New(WithStore(s), WithLLM(&scriptedLLM{}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1"}
+	if err := s.Create(context.Background(), ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	acme := cortex.WithTenant(context.Background(), "acme")
+	sess := &session.Session{ID: id.NewSessionID(), AgentID: ag.ID, TenantID: "acme"}
+	if err := e.CreateSession(acme, sess); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := e.RunAgent(acme, "app1", "worker", "work", nil); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+
+	globex := cortex.WithTenant(context.Background(), "globex")
+	if runs, err := e.ListRuns(globex, &run.ListFilter{TenantID: "acme"}); err != nil || len(runs) != 0 {
+		t.Errorf("ListRuns(globex) = %d runs, %v; want none", len(runs), err)
+	}
+	if n, err := e.CountRuns(globex, nil); err != nil || n != 0 {
+		t.Errorf("CountRuns(globex) = %d, %v; want 0", n, err)
+	}
+	if sessions, err := e.ListSessions(globex, &session.ListFilter{TenantID: "acme"}); err != nil || len(sessions) != 0 {
+		t.Errorf("ListSessions(globex) = %d sessions, %v; want none", len(sessions), err)
+	}
+	if _, err := e.GetSession(globex, sess.ID); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Errorf("GetSession err = %v, want ErrSessionNotFound", err)
+	}
+	if err := e.DeleteSession(globex, sess.ID); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Errorf("DeleteSession err = %v, want ErrSessionNotFound", err)
+	}
+
+	if runs, err := e.ListRuns(acme, nil); err != nil || len(runs) != 1 {
+		t.Errorf("ListRuns(acme) = %d runs, %v; want 1", len(runs), err)
+	}
+	if _, err := e.GetSession(acme, sess.ID); err != nil {
+		t.Errorf("GetSession(acme): %v", err)
+	}
+}
+
+func TestReads_UnscopedContextFollowsOneRule(t *testing.T) {
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "deploy", `{}`)}}
+	e, s, _ := newApprovalEngine(t, client, nil, RequireApproval())
+
+	acme := cortex.WithTenant(context.Background(), "acme")
+	r, err := e.RunAgent(acme, "app1", "ops", "ship it", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	cp := pendingCheckpoint(t, s, r.ID)
+	if _, err := e.GetCheckpoint(cortex.WithTenant(context.Background(), "globex"), cp.ID); !errors.Is(err, cortex.ErrCheckpointNotFound) {
+		t.Errorf("GetCheckpoint(globex) err = %v, want ErrCheckpointNotFound", err)
+	}
+
+	// Without RequireTenant an unscoped context reads every tenant, for
+	// lookups as for listings.
+	ctx := context.Background()
+	if _, err := e.GetRun(ctx, r.ID); err != nil {
+		t.Errorf("GetRun: %v", err)
+	}
+	if runs, err := e.ListRuns(ctx, nil); err != nil || len(runs) != 1 {
+		t.Errorf("ListRuns = %d runs, %v; want 1", len(runs), err)
+	}
+	if _, err := e.GetCheckpoint(ctx, cp.ID); err != nil {
+		t.Errorf("GetCheckpoint: %v", err)
+	}
+	if cps, err := e.ListPendingCheckpoints(ctx, nil); err != nil || len(cps) != 1 {
+		t.Errorf("ListPendingCheckpoints = %d checkpoints, %v; want 1", len(cps), err)
+	}
+
+	e.config.RequireTenant = true
+	if _, err := e.GetRun(ctx, r.ID); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("GetRun err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.ListRuns(ctx, nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("ListRuns err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.CountRuns(ctx, nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("CountRuns err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.GetCheckpoint(ctx, cp.ID); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("GetCheckpoint err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.ListPendingCheckpoints(ctx, nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("ListPendingCheckpoints err = %v, want ErrTenantRequired", err)
+	}
+	if _, err := e.ListSessions(ctx, nil); !errors.Is(err, cortex.ErrTenantRequired) {
+		t.Errorf("ListSessions err = %v, want ErrTenantRequired", err)
+	}
+	if got, err := e.GetRun(acme, r.ID); err != nil || got.ID != r.ID {
+		t.Errorf("GetRun(acme) = %+v, %v; want the run", got, err)
+	}
+}
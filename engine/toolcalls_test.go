@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+)
+
+// concurrencyProbe is a tool handler recording how many of its calls run at
+// once. Each call waits briefly so overlapping calls are observed.
+type concurrencyProbe struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (p *concurrencyProbe) handle(_ context.Context, args string) (string, error) {
+	p.mu.Lock()
+	p.running++
+	p.peak = max(p.peak, p.running)
+	p.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+	return "result " + args, nil
+}
+
+func (p *concurrencyProbe) peakCalls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peak
+}
+
+// fanOutResponse returns a response calling tool n times with arguments 0..n-1.
+func fanOutResponse(tool string, n int) *llm.Response {
+	resp := &llm.Response{Usage: llm.Usage{TotalTokens: 1}}
+	for i := range n {
+		resp.ToolCalls = append(resp.ToolCalls, llm.ToolCall{ID: fmt.Sprintf("call-%d", i), Name: tool, Arguments: fmt.Sprint(i)})
+	}
+	return resp
+}
+
+func newProbeEngine(t *testing.T, client llm.Client, cfg cortex.Config, opts ...ToolOption) (*Engine, *concurrencyProbe) {
+	t.Helper()
+	s := newTestStore(t)
+	probe := &concurrencyProbe{}
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg), WithTool(llm.Tool{Name: "lookup"}, probe.handle, opts...))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(context.Background(), &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1", Tools: []string{"lookup"}}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	return e, probe
+}
+
+func TestRunAgent_RunsToolCallsConcurrentlyInOrder(t *testing.T) {
+	ctx := context.Background()
+	cfg := cortex.DefaultConfig()
+	cfg.ToolConcurrency = 3
+	client := &scriptedLLM{responses: []*llm.Response{fanOutResponse("lookup", 5)}}
+	e, probe := newProbeEngine(t, client, cfg)
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "look things up", nil); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if peak := probe.peakCalls(); peak != 3 {
+		t.Errorf("peak concurrent calls = %d, want 3", peak)
+	}
+
+	// The results follow the assistant message in the order of the calls.
+	msgs := client.lastRequest().Messages
+	results := msgs[len(msgs)-5:]
+	for i, m := range results {
+		if m.Role != "tool" || m.ToolCallID != fmt.Sprintf("call-%d", i) || m.Content != fmt.Sprintf("result %d", i) {
+			t.Errorf("message %d = %+v, want the result of call-%d", i, m, i)
+		}
+	}
+}
+
+func TestRunAgent_RunsSequentialToolAlone(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: []*llm.Response{fanOutResponse("lookup", 3)}}
+	e, probe := newProbeEngine(t, client, cortex.DefaultConfig(), Sequential())
+
+	if _, err := e.RunAgent(ctx, "app1", "worker", "look things up", &RunOverrides{ToolConcurrency: 8}); err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	if peak := probe.peakCalls(); peak != 1 {
+		t.Errorf("peak concurrent calls = %d, want 1", peak)
+	}
+}
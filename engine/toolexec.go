@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/llm"
+)
+
+// ToolTimeout bounds each attempt of a call to the tool. An attempt that
+// runs longer fails with cortex.ErrToolTimeout; the handler's context is
+// cancelled, but a handler ignoring it is not waited for and keeps running
+// in the background until it returns.
+func ToolTimeout(d time.Duration) ToolOption {
+	return func(rt *registeredTool) {
+		rt.timeout = d
+	}
+}
+
+// ToolRetry makes a failed call to the tool be tried up to attempts times in
+// total. The engine waits backoff before the second attempt and doubles the
+// wait before each further one.
+func ToolRetry(attempts int, backoff time.Duration) ToolOption {
+	return func(rt *registeredTool) {
+		rt.attempts = attempts
+		rt.backoff = backoff
+	}
+}
+
+// executeTool executes a tool call and returns the result fed to the model.
+func (e *Engine) executeTool(ctx context.Context, tc llm.ToolCall) string {
+	result, _, _ := e.invokeTool(ctx, tc) //nolint:errcheck // the error is part of the result
+	return result
+}
+
+// invokeTool executes a tool call, retrying a registered tool as configured
+// with ToolRetry. It returns the result fed to the model, the number of times
+// the handler ran and the error of the last attempt. A failed call's result
+// describes the error; builtin tools fail like registered ones.
+func (e *Engine) invokeTool(ctx context.Context, tc llm.ToolCall) (string, int, error) {
+	if result, handled, err := e.executeBuiltinTool(ctx, tc.Name, tc.Arguments); handled {
+		return result, 1, err
+	}
+	rt, ok := e.registeredTool(tc.Name)
+	if !ok {
+		err := fmt.Errorf("unknown tool %q", tc.Name)
+		return jsonResult("error", err.Error()), 0, err
+	}
+
+	backoff := rt.backoff
+	for attempt := 1; ; attempt++ {
+		out, err := rt.call(ctx, tc.Arguments)
+		if err == nil {
+			return out, attempt, nil
+		}
+		if attempt >= rt.attempts || ctx.Err() != nil {
+			return jsonResult("error", err.Error()), attempt, err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return jsonResult("error", err.Error()), attempt, err
+		}
+		backoff *= 2
+	}
+}
+
+// registeredTool returns the first tool registered under name.
+func (e *Engine) registeredTool(name string) (*registeredTool, bool) {
+	for i := range e.tools {
+		if e.tools[i].def.Name == name {
+			return &e.tools[i], true
+		}
+	}
+	return nil, false
+}
+
+// call runs the handler once, within the tool's timeout if it has one. On
+// timeout the handler's goroutine is left to finish on its own; its outcome
+// is dropped into the buffered channel and discarded.
+func (rt *registeredTool) call(ctx context.Context, arguments string) (string, error) {
+	if rt.timeout <= 0 {
+		return rt.handler(ctx, arguments)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, rt.timeout)
+	defer cancel()
+	type outcome struct {
+		out string
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		out, err := rt.handler(callCtx, arguments)
+		done <- outcome{out, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.out, o.err
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("%w: %q after %s", cortex.ErrToolTimeout, rt.def.Name, rt.timeout)
+	}
+}
+
+// toolDisabled reports whether the run stopped offering the named tool
+// after Config.ToolFailureThreshold consecutive failed calls.
+func (e *Engine) toolDisabled(rr *reactRun, name string) bool {
+	threshold := e.config.ToolFailureThreshold
+	return threshold > 0 && rr.st.ToolFailures[name] >= threshold
+}
+
+// offeredTools returns the run's tools except those it has disabled.
+func (e *Engine) offeredTools(rr *reactRun) []llm.Tool {
+	if len(rr.st.ToolFailures) == 0 {
+		return rr.tools
+	}
+	tools := make([]llm.Tool, 0, len(rr.tools))
+	for _, t := range rr.tools {
+		if !e.toolDisabled(rr, t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// countToolOutcome tracks the consecutive failed calls of a tool, disabling
+// it for the rest of the run at Config.ToolFailureThreshold.
+func (e *Engine) countToolOutcome(rr *reactRun, name string, failed bool) {
+	if !failed {
+		delete(rr.st.ToolFailures, name)
+		return
+	}
+	if rr.st.ToolFailures == nil {
+		rr.st.ToolFailures = make(map[string]int)
+	}
+	rr.st.ToolFailures[name]++
+	if rr.st.ToolFailures[name] == e.config.ToolFailureThreshold {
+		e.logger.Warn("tool disabled for run after repeated failures",
+			log.String("run_id", rr.r.ID.String()),
+			log.String("tool", name),
+		)
+	}
+}
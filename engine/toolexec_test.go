@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/store/sqlite"
+)
+
+// toolFailureRecorder is an extension recording ToolFailed hooks.
+type toolFailureRecorder struct {
+	mu     sync.Mutex
+	errors []error
+}
+
+func (f *toolFailureRecorder) Name() string { return "tool-failure-recorder" }
+
+func (f *toolFailureRecorder) OnToolFailed(_ context.Context, _ id.AgentRunID, _ string, err error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors = append(f.errors, err)
+	return nil
+}
+
+// runToolCallRecords returns the tool calls of the run in step order.
+func runToolCallRecords(t *testing.T, s *sqlite.Store, runID id.AgentRunID) []*run.ToolCall {
+	t.Helper()
+	ctx := context.Background()
+	steps, err := s.ListSteps(ctx, runID)
+	if err != nil {
+		t.Fatalf("ListSteps: %v", err)
+	}
+	var calls []*run.ToolCall
+	for _, st := range steps {
+		tcs, err := s.ListToolCalls(ctx, st.ID)
+		if err != nil {
+			t.Fatalf("ListToolCalls: %v", err)
+		}
+		calls = append(calls, tcs...)
+	}
+	return calls
+}
+
+func TestRunAgent_RetriesFailingTool(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	calls := 0
+	flaky := func(context.Context, string) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errors.New("upstream unavailable")
+		}
+		return "ok", nil
+	}
+	client := &scriptedLLM{responses: []*llm.Response{toolCallResponse("call-1", "flaky", `{}`)}}
+	e, err := New(WithStore(s), WithLLM(client), WithTool(llm.Tool{Name: "flaky"}, flaky, ToolRetry(3, time.Millisecond)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1", Tools: []string{"flaky"}}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	tcs := runToolCallRecords(t, s, r.ID)
+	if len(tcs) != 1 || tcs[0].Result != "ok" || tcs[0].Error != "" {
+		t.Fatalf("tool calls = %+v, want one successful call", tcs)
+	}
+	if attempts, _ := tcs[0].Metadata["attempts"].(float64); attempts != 3 {
+		t.Errorf("attempts = %v, want 3", tcs[0].Metadata["attempts"])
+	}
+}
+
+func TestRunAgent_DisablesToolAfterRepeatedTimeouts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	var mu sync.Mutex
+	calls := 0
+	slow := func(ctx context.Context, _ string) (string, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	client := &scriptedLLM{responses: []*llm.Response{
+		toolCallResponse("call-1", "slow", `{}`),
+		toolCallResponse("call-2", "slow", `{}`),
+		toolCallResponse("call-3", "slow", `{}`),
+	}}
+	rec := &toolFailureRecorder{}
+	cfg := cortex.DefaultConfig()
+	cfg.ToolFailureThreshold = 2
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg), WithExtension(rec),
+		WithTool(llm.Tool{Name: "slow"}, slow, ToolTimeout(10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Create(ctx, &agent.Config{ID: id.NewAgentID(), Name: "worker", AppID: "app1", Tools: []string{"slow"}}); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	// Handlers abandoned on timeout may still be running.
+	mu.Lock()
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want 2", calls)
+	}
+	mu.Unlock()
+	tcs := runToolCallRecords(t, s, r.ID)
+	if len(tcs) != 3 {
+		t.Fatalf("tool calls = %d, want 3", len(tcs))
+	}
+	for _, tc := range tcs[:2] {
+		if !strings.Contains(tc.Error, cortex.ErrToolTimeout.Error()) {
+			t.Errorf("tool call error = %q, want a timeout", tc.Error)
+		}
+	}
+	if !strings.Contains(tcs[2].Error, cortex.ErrToolDisabled.Error()) {
+		t.Errorf("tool call error = %q, want the tool disabled", tcs[2].Error)
+	}
+	for _, tool := range client.lastRequest().Tools {
+		if tool.Name == "slow" {
+			t.Errorf("disabled tool still offered: %v", tool)
+		}
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.errors) != 3 || !errors.Is(rec.errors[0], cortex.ErrToolTimeout) {
+		t.Errorf("ToolFailed hooks = %v, want 3 starting with a timeout", rec.errors)
+	}
+}
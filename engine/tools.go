@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 
 	"github.com/xraph/cortex/knowledge"
 	"github.com/xraph/cortex/llm"
@@ -33,23 +34,33 @@ func (e *Engine) builtinTools() []llm.Tool {
 		})
 	}
 
+	if e.store != nil {
+		tools = append(tools, memoryTools()...)
+	}
+
 	return tools
 }
 
-// executeBuiltinTool attempts to execute a built-in tool. Returns (result, true) if handled.
-func (e *Engine) executeBuiltinTool(ctx context.Context, name, arguments string) (string, bool) {
+// executeBuiltinTool attempts to execute a built-in tool. It reports whether
+// name is a built-in tool and, when it is, returns the result fed to the
+// model and the error of a failed call, whose result describes it.
+func (e *Engine) executeBuiltinTool(ctx context.Context, name, arguments string) (string, bool, error) {
 	switch name {
 	case "knowledge_search":
-		return e.executeKnowledgeSearch(ctx, arguments), true
+		result, err := e.executeKnowledgeSearch(ctx, arguments)
+		return result, true, err
+	case "memory_set", "memory_get", "memory_list":
+		result, err := e.executeMemoryTool(ctx, name, arguments)
+		return result, true, err
 	default:
-		return "", false
+		return "", false, nil
 	}
 }
 
 // executeKnowledgeSearch handles the knowledge_search tool call.
-func (e *Engine) executeKnowledgeSearch(ctx context.Context, arguments string) string {
+func (e *Engine) executeKnowledgeSearch(ctx context.Context, arguments string) (string, error) {
 	if e.knowledge == nil {
-		return jsonResult("error", "knowledge provider not configured")
+		return toolFailure("knowledge provider not configured")
 	}
 
 	var args struct {
@@ -57,10 +68,10 @@ func (e *Engine) executeKnowledgeSearch(ctx context.Context, arguments string) s
 		Collection string `json:"collection"`
 	}
 	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-		return jsonResult("error", "invalid arguments: "+err.Error())
+		return toolFailure("invalid arguments: " + err.Error())
 	}
 	if args.Query == "" {
-		return jsonResult("error", "query is required")
+		return toolFailure("query is required")
 	}
 
 	params := &knowledge.RetrieveParams{
@@ -70,11 +81,11 @@ func (e *Engine) executeKnowledgeSearch(ctx context.Context, arguments string) s
 
 	chunks, err := e.knowledge.Retrieve(ctx, args.Query, params)
 	if err != nil {
-		return jsonResult("error", "retrieval failed: "+err.Error())
+		return toolFailure("retrieval failed: " + err.Error())
 	}
 
 	if len(chunks) == 0 {
-		return jsonResult("status", "no relevant results found")
+		return jsonResult("status", "no relevant results found"), nil
 	}
 
 	results := make([]map[string]any, len(chunks))
@@ -92,7 +103,13 @@ func (e *Engine) executeKnowledgeSearch(ctx context.Context, arguments string) s
 		"results": results,
 		"count":   len(results),
 	})
-	return string(b)
+	return string(b), nil
+}
+
+// toolFailure returns the result and error of a builtin tool call that
+// failed with msg.
+func toolFailure(msg string) (string, error) {
+	return jsonResult("error", msg), errors.New(msg)
 }
 
 // jsonResult is a helper for simple JSON tool results.
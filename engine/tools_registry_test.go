@@ -2,9 +2,12 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
 	"github.com/xraph/cortex/llm"
 )
 
@@ -26,7 +29,7 @@ func TestWithTool_AdvertisedInResolveTools(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
-	tools := e.resolveTools(nil)
+	tools := e.resolveTools(toolScope{"echo": true})
 	var found bool
 	for _, tl := range tools {
 		if tl.Name == "echo" {
@@ -60,3 +63,80 @@ func TestExecuteTool_UnknownStillErrors(t *testing.T) {
 		t.Fatalf("executeTool = %q, want it to contain %q", got, "unknown tool")
 	}
 }
+
+func TestResolveTools_OmitsToolsOutsideScope(t *testing.T) {
+	def, h := echoTool()
+	e, err := New(WithTool(def, h))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if tools := e.resolveTools(toolScope{"other": true}); len(tools) != 0 {
+		t.Fatalf("resolveTools advertised %d tools outside scope, want 0", len(tools))
+	}
+	if tools := e.resolveTools(nil); len(tools) != 0 {
+		t.Fatalf("resolveTools(nil) advertised %d tools, want 0", len(tools))
+	}
+}
+
+func TestResolveToolScope_UsesOverrideTools(t *testing.T) {
+	e, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{Name: "support", Tools: []string{"read_ticket"}}
+	overrides := &RunOverrides{Tools: []string{"search_docs"}}
+
+	scope := e.resolveToolScope(e.effectiveConfig(ag, overrides), e.ResolvePersona(context.Background(), ag, overrides))
+	if !scope.allows("search_docs") || scope.allows("read_ticket") {
+		t.Fatalf("scope = %v, want only search_docs", scope)
+	}
+}
+
+func TestResolveToolScope_MemoryToolsAreOptIn(t *testing.T) {
+	e, err := New(WithStore(newTestStore(t)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{Name: "support", Tools: []string{"read_ticket"}}
+
+	scope := e.resolveToolScope(e.effectiveConfig(ag, nil), e.ResolvePersona(context.Background(), ag, nil))
+	if !scope.allows("read_ticket") {
+		t.Errorf("scope = %v, want read_ticket allowed", scope)
+	}
+	for _, name := range []string{"memory_set", "memory_get", "memory_list", "knowledge_search"} {
+		if scope.allows(name) {
+			t.Errorf("scope = %v, want %s left out", scope, name)
+		}
+	}
+
+	overrides := &RunOverrides{Tools: []string{"read_ticket", "memory_set", "memory_get"}}
+	scope = e.resolveToolScope(e.effectiveConfig(ag, overrides), e.ResolvePersona(context.Background(), ag, nil))
+	if !scope.allows("memory_set") || !scope.allows("memory_get") || scope.allows("memory_list") {
+		t.Errorf("scope = %v, want only the named memory tools", scope)
+	}
+	if tools := e.resolveTools(scope); len(tools) != 2 || tools[0].Name != "memory_set" {
+		t.Errorf("advertised tools = %+v, want the named memory tools", tools)
+	}
+}
+
+func TestCallTool_RejectsToolOutsideScope(t *testing.T) {
+	called := false
+	def := llm.Tool{Name: "delete_account"}
+	e, err := New(WithTool(def, func(context.Context, string) (string, error) {
+		called = true
+		return "deleted", nil
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	result, _, err := e.callTool(context.Background(), llm.ToolCall{Name: "delete_account"}, toolScope{"echo": true})
+	if !errors.Is(err, cortex.ErrToolNotAllowed) {
+		t.Fatalf("callTool err = %v, want ErrToolNotAllowed", err)
+	}
+	if called {
+		t.Fatal("handler ran for a tool outside scope")
+	}
+	if !strings.Contains(result, "not available") {
+		t.Fatalf("callTool result = %q, want rejection message", result)
+	}
+}
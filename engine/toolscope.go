@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/llm"
+)
+
+// toolScope is the set of tool names an agent may call during a run.
+type toolScope map[string]bool
+
+// allows reports whether name is in the scope.
+func (s toolScope) allows(name string) bool { return s[name] }
+
+// resolveToolScope builds the effective tool set for a run from the engine's
+// builtin tools, the agent's Tools list (or RunOverrides.Tools when set) and
+// the ToolBinding entries of the resolved persona's skills. The working
+// memory tools are opt-in: they are only in scope when named. Tools outside
+// the scope are neither advertised to the model nor executed.
+func (e *Engine) resolveToolScope(cfg resolvedConfig, rp *ResolvedPersona) toolScope {
+	scope := make(toolScope)
+	for _, t := range e.builtinTools() {
+		if !isMemoryTool(t.Name) {
+			scope[t.Name] = true
+		}
+	}
+	for _, name := range cfg.Tools {
+		if name = strings.TrimSpace(name); name != "" {
+			scope[name] = true
+		}
+	}
+	if rp == nil {
+		return scope
+	}
+	for _, rs := range rp.Skills {
+		for _, tb := range rs.Skill.Tools {
+			if tb.ToolName != "" {
+				scope[tb.ToolName] = true
+			}
+		}
+	}
+	return scope
+}
+
+// callTool executes tc if it is within scope and reports how many attempts
+// it took. Calls to tools outside the scope are rejected without running the
+// handler; the returned error is recorded on the run.ToolCall and the result
+// is fed back to the model.
+func (e *Engine) callTool(ctx context.Context, tc llm.ToolCall, scope toolScope) (string, int, error) {
+	if !scope.allows(tc.Name) {
+		err := fmt.Errorf("%w: %q", cortex.ErrToolNotAllowed, tc.Name)
+		return jsonResult("error", fmt.Sprintf("tool %q is not available to this agent", tc.Name)), 0, err
+	}
+	return e.invokeTool(ctx, tc)
+}
@@ -0,0 +1,264 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/trait"
+)
+
+// traitDenyThreshold is the minimum strength at which a tool_selection
+// influence removes or restricts tools.
+const traitDenyThreshold = 0.5
+
+// traitInfluence is a trait influence that applies to a run, with its
+// effective strength.
+type traitInfluence struct {
+	Trait    string
+	Target   trait.InfluenceTarget
+	Value    any
+	Strength float64
+}
+
+// traitEffects holds the influences of an agent's traits resolved for a run.
+type traitEffects struct {
+	influences []traitInfluence
+}
+
+// resolveTraits returns the influences of the resolved persona's traits
+// that apply to a run.
+//
+// Each influence's strength is its Weight (1 when unset) scaled by the trait's
+// intensity: the mean of its resolved dimension values. Influences whose
+// Condition does not hold are dropped.
+func resolveTraits(rp *ResolvedPersona) *traitEffects {
+	tr := &traitEffects{}
+	if rp == nil {
+		return tr
+	}
+	for _, rt := range rp.Traits {
+		intensity := traitIntensity(rt.DimensionValues)
+		for _, inf := range rt.Trait.Influences {
+			if !traitCondition(inf.Condition, rt.DimensionValues) {
+				continue
+			}
+			weight := inf.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			strength := clamp01(weight * intensity)
+			if strength == 0 {
+				continue
+			}
+			tr.influences = append(tr.influences, traitInfluence{
+				Trait:    rt.Trait.Name,
+				Target:   inf.Target,
+				Value:    inf.Value,
+				Strength: strength,
+			})
+		}
+	}
+	return tr
+}
+
+// applyToConfig blends temperature and max_steps influences into cfg. Each
+// influence moves the value toward its target in proportion to its strength.
+// Explicit per-run overrides are left untouched.
+func (tr *traitEffects) applyToConfig(cfg *resolvedConfig, overrides *RunOverrides) {
+	if tr == nil {
+		return
+	}
+	for _, inf := range tr.influences {
+		target, ok := toFloat(inf.Value)
+		if !ok {
+			continue
+		}
+		switch inf.Target {
+		case trait.TargetTemperature:
+			if overrides != nil && overrides.Temperature != nil {
+				continue
+			}
+			base := 0.0
+			if cfg.Temperature != nil {
+				base = *cfg.Temperature
+			}
+			t := base + (target-base)*inf.Strength
+			cfg.Temperature = &t
+			cfg.FixedTemperature = true
+		case trait.TargetMaxSteps:
+			if overrides != nil && overrides.MaxSteps > 0 {
+				continue
+			}
+			base := float64(cfg.MaxSteps)
+			cfg.MaxSteps = max(1, int(math.Round(base+(target-base)*inf.Strength)))
+		}
+	}
+}
+
+// restrictTools applies tool_selection influences to scope. The value is a
+// map with optional "deny", "allow" and "prefer" tool lists; deny removes
+// tools and allow restricts the scope to the listed tools. Both take effect
+// only at a strength of at least 0.5. Preferences are surfaced in the prompt.
+func (tr *traitEffects) restrictTools(scope toolScope) {
+	if tr == nil {
+		return
+	}
+	for _, inf := range tr.influences {
+		if inf.Target != trait.TargetToolSelection || inf.Strength < traitDenyThreshold {
+			continue
+		}
+		sel, ok := inf.Value.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, name := range toStrings(sel["deny"]) {
+			delete(scope, name)
+		}
+		if allow := toStrings(sel["allow"]); len(allow) > 0 {
+			keep := make(map[string]bool, len(allow))
+			for _, name := range allow {
+				keep[name] = true
+			}
+			for name := range scope {
+				if !keep[name] {
+					delete(scope, name)
+				}
+			}
+		}
+	}
+}
+
+// prompt returns the system prompt sections contributed by prompt_injection,
+// response_style and tool_selection preferences.
+func (tr *traitEffects) prompt() []string {
+	if tr == nil {
+		return nil
+	}
+	var parts []string
+	for _, inf := range tr.influences {
+		switch inf.Target {
+		case trait.TargetPromptInjection:
+			if v, ok := inf.Value.(string); ok && v != "" {
+				parts = append(parts, "\n## Trait: "+inf.Trait+"\n"+v)
+			}
+		case trait.TargetResponseStyle:
+			if v, ok := inf.Value.(string); ok && v != "" {
+				parts = append(parts, "\n## Response style ("+inf.Trait+")\n"+v)
+			}
+		case trait.TargetToolSelection:
+			sel, ok := inf.Value.(map[string]any)
+			if !ok {
+				continue
+			}
+			if prefer := toStrings(sel["prefer"]); len(prefer) > 0 {
+				parts = append(parts, "\n## Trait: "+inf.Trait+"\nPrefer these tools when they fit the task: "+strings.Join(prefer, ", ")+".")
+			}
+		}
+	}
+	return parts
+}
+
+// emitTraits fires the TraitApplied hook once per applied influence.
+func (e *Engine) emitTraits(ctx context.Context, runID id.AgentRunID, tr *traitEffects) {
+	if tr == nil {
+		return
+	}
+	for _, inf := range tr.influences {
+		e.extensions.EmitTraitApplied(ctx, runID, inf.Trait, string(inf.Target))
+	}
+}
+
+// traitDimensions returns the trait's dimension values keyed by name, with
+// persona overrides applied.
+func traitDimensions(t *trait.Trait, overrides map[string]float64) map[string]float64 {
+	dims := make(map[string]float64, len(t.Dimensions))
+	for _, d := range t.Dimensions {
+		dims[d.Name] = d.Value
+		if v, ok := overrides[d.Name]; ok {
+			dims[d.Name] = v
+		}
+	}
+	return dims
+}
+
+// traitIntensity is the mean dimension value, or 1 for traits without dimensions.
+func traitIntensity(dims map[string]float64) float64 {
+	if len(dims) == 0 {
+		return 1
+	}
+	var sum float64
+	for _, v := range dims {
+		sum += v
+	}
+	return clamp01(sum / float64(len(dims)))
+}
+
+var traitConditionRe = regexp.MustCompile(`^\s*([\w.-]+)\s*(>=|<=|==|!=|>|<)\s*(-?[0-9]*\.?[0-9]+)\s*$`)
+
+// traitCondition evaluates an influence condition of the form
+// "<dimension> <op> <number>" against the trait's dimension values. An empty
+// condition always holds; a malformed one or an unknown dimension never does.
+func traitCondition(cond string, dims map[string]float64) bool {
+	if strings.TrimSpace(cond) == "" {
+		return true
+	}
+	m := traitConditionRe.FindStringSubmatch(cond)
+	if m == nil {
+		return false
+	}
+	v, ok := dims[m[1]]
+	if !ok {
+		return false
+	}
+	n, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return false
+	}
+	switch m[2] {
+	case ">":
+		return v > n
+	case ">=":
+		return v >= n
+	case "<":
+		return v < n
+	case "<=":
+		return v <= n
+	case "==":
+		return v == n
+	case "!=":
+		return v != n
+	}
+	return false
+}
+
+// toStrings converts a JSON-decoded string or list of strings to []string.
+func toStrings(v any) []string {
+	switch s := v.(type) {
+	case string:
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	case []string:
+		return s
+	case []any:
+		out := make([]string, 0, len(s))
+		for _, item := range s {
+			if str, ok := item.(string); ok && str != "" {
+				out = append(out, str)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// clamp01 limits v to the range [0, 1].
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex/trait"
+)
+
+func TestTraitEffects_ApplyToConfigBlendsByStrength(t *testing.T) {
+	base := 0.7
+	cfg := resolvedConfig{Temperature: &base, MaxSteps: 10}
+	tr := &traitEffects{influences: []traitInfluence{
+		{Trait: "cautious", Target: trait.TargetTemperature, Value: 0.1, Strength: 0.5},
+		{Trait: "cautious", Target: trait.TargetMaxSteps, Value: 20.0, Strength: 0.5},
+	}}
+	tr.applyToConfig(&cfg, nil)
+
+	if got := *cfg.Temperature; got < 0.399 || got > 0.401 {
+		t.Errorf("Temperature = %v, want 0.4", got)
+	}
+	if !cfg.FixedTemperature {
+		t.Errorf("FixedTemperature not set after trait temperature influence")
+	}
+	if cfg.MaxSteps != 15 {
+		t.Errorf("MaxSteps = %d, want 15", cfg.MaxSteps)
+	}
+}
+
+func TestTraitEffects_RunOverridesWin(t *testing.T) {
+	base := 0.7
+	cfg := resolvedConfig{Temperature: &base, MaxSteps: 10}
+	tr := &traitEffects{influences: []traitInfluence{
+		{Trait: "cautious", Target: trait.TargetTemperature, Value: 0.1, Strength: 1},
+		{Trait: "cautious", Target: trait.TargetMaxSteps, Value: 2.0, Strength: 1},
+	}}
+	tr.applyToConfig(&cfg, &RunOverrides{Temperature: &base, MaxSteps: 10})
+	if *cfg.Temperature != 0.7 || cfg.MaxSteps != 10 {
+		t.Fatalf("cfg = (%v, %d), want overrides kept (0.7, 10)", *cfg.Temperature, cfg.MaxSteps)
+	}
+}
+
+func TestTraitEffects_RestrictTools(t *testing.T) {
+	tr := &traitEffects{influences: []traitInfluence{
+		{Trait: "cautious", Target: trait.TargetToolSelection, Strength: 0.8, Value: map[string]any{
+			"deny": []any{"delete_file"},
+		}},
+		{Trait: "timid", Target: trait.TargetToolSelection, Strength: 0.3, Value: map[string]any{
+			"deny": []any{"search"},
+		}},
+	}}
+	scope := toolScope{"delete_file": true, "search": true, "read_file": true}
+	tr.restrictTools(scope)
+	if scope.allows("delete_file") {
+		t.Errorf("denied tool still in scope")
+	}
+	if !scope.allows("search") {
+		t.Errorf("weak influence (strength 0.3) removed a tool")
+	}
+
+	allow := &traitEffects{influences: []traitInfluence{
+		{Trait: "focused", Target: trait.TargetToolSelection, Strength: 1, Value: map[string]any{
+			"allow": []any{"read_file"},
+		}},
+	}}
+	allow.restrictTools(scope)
+	if len(scope) != 1 || !scope.allows("read_file") {
+		t.Errorf("scope = %v, want only read_file", scope)
+	}
+}
+
+func TestTraitEffects_Prompt(t *testing.T) {
+	tr := &traitEffects{influences: []traitInfluence{
+		{Trait: "cautious", Target: trait.TargetPromptInjection, Value: "Double-check before acting.", Strength: 1},
+		{Trait: "terse", Target: trait.TargetResponseStyle, Value: "Answer in at most three sentences.", Strength: 1},
+		{Trait: "cautious", Target: trait.TargetToolSelection, Strength: 1, Value: map[string]any{
+			"prefer": []any{"read_file", "search"},
+		}},
+	}}
+	got := strings.Join(tr.prompt(), "\n")
+	for _, want := range []string{
+		"## Trait: cautious\nDouble-check before acting.",
+		"## Response style (terse)\nAnswer in at most three sentences.",
+		"Prefer these tools when they fit the task: read_file, search.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestTraitDimensionsAndCondition(t *testing.T) {
+	tt := &trait.Trait{Dimensions: []trait.Dimension{
+		{Name: "caution", Value: 0.2},
+		{Name: "speed", Value: 0.6},
+	}}
+	dims := traitDimensions(tt, map[string]float64{"caution": 0.8})
+	if dims["caution"] != 0.8 {
+		t.Fatalf("persona dimension override not applied: %v", dims)
+	}
+	if got := traitIntensity(dims); got < 0.699 || got > 0.701 {
+		t.Errorf("traitIntensity = %v, want 0.7", got)
+	}
+	if traitIntensity(nil) != 1 {
+		t.Errorf("traitIntensity(nil) = %v, want 1", traitIntensity(nil))
+	}
+
+	tests := []struct {
+		cond string
+		want bool
+	}{
+		{"", true},
+		{"caution > 0.5", true},
+		{"caution<=0.5", false},
+		{"speed == 0.6", true},
+		{"unknown > 0", false},
+		{"not a condition", false},
+	}
+	for _, c := range tests {
+		if got := traitCondition(c.cond, dims); got != c.want {
+			t.Errorf("traitCondition(%q) = %v, want %v", c.cond, got, c.want)
+		}
+	}
+}
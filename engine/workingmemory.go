@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	log "github.com/xraph/go-utils/log"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/run"
+)
+
+// runIDKey is the context key of the run a tool call belongs to.
+type runIDKey struct{}
+
+// withRunID returns ctx carrying the ID of the run whose tool calls execute
+// under it.
+func withRunID(ctx context.Context, runID id.AgentRunID) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// runIDFrom returns the run ID carried by ctx.
+func runIDFrom(ctx context.Context) (id.AgentRunID, bool) {
+	runID, ok := ctx.Value(runIDKey{}).(id.AgentRunID)
+	return runID, ok && !runID.IsNil()
+}
+
+// isMemoryTool reports whether name is one of the scratchpad tools.
+func isMemoryTool(name string) bool {
+	return name == "memory_set" || name == "memory_get" || name == "memory_list"
+}
+
+// memoryTools returns the definitions of the scratchpad tools backed by the
+// working memory of the current run. Unlike knowledge_search they are only
+// in the scope of agents naming them in their tools.
+func memoryTools() []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        "memory_set",
+			Description: "Save a note to your scratchpad for this task under a key, replacing any note already under it. Use it to keep intermediate findings you will need in later steps.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"key": map[string]any{
+						"type":        "string",
+						"description": "Name of the note",
+					},
+					"value": map[string]any{
+						"description": "Content of the note: text or any JSON value",
+					},
+				},
+				"required": []string{"key", "value"},
+			},
+		},
+		{
+			Name:        "memory_get",
+			Description: "Read the note saved in your scratchpad under a key.",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"key": map[string]any{
+						"type":        "string",
+						"description": "Name of the note",
+					},
+				},
+				"required": []string{"key"},
+			},
+		},
+		{
+			Name:        "memory_list",
+			Description: "List the keys of the notes saved in your scratchpad for this task.",
+			Parameters: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			},
+		},
+	}
+}
+
+// executeMemoryTool handles the memory_set, memory_get and memory_list tool
+// calls against the working memory of the run carried by ctx.
+func (e *Engine) executeMemoryTool(ctx context.Context, name, arguments string) (string, error) {
+	runID, ok := runIDFrom(ctx)
+	if !ok || e.store == nil {
+		return toolFailure("working memory is only available during a run")
+	}
+
+	var args struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	if arguments != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return toolFailure("invalid arguments: " + err.Error())
+		}
+	}
+	if name != "memory_list" && args.Key == "" {
+		return toolFailure("key is required")
+	}
+
+	switch name {
+	case "memory_set":
+		var value any
+		if len(args.Value) > 0 {
+			if err := json.Unmarshal(args.Value, &value); err != nil {
+				return toolFailure("invalid value: " + err.Error())
+			}
+		}
+		if err := e.store.SaveWorking(ctx, runID, args.Key, value); err != nil {
+			return toolFailure("save failed: " + err.Error())
+		}
+		return jsonResult("status", "saved"), nil
+
+	case "memory_get":
+		value, err := e.store.LoadWorking(ctx, runID, args.Key)
+		if errors.Is(err, cortex.ErrWorkingMemoryNotFound) {
+			return toolFailure("no note under key " + args.Key)
+		}
+		if err != nil {
+			return toolFailure("load failed: " + err.Error())
+		}
+		b, _ := json.Marshal(map[string]any{"key": args.Key, "value": value}) //nolint:errcheck // best-effort JSON encoding
+		return string(b), nil
+
+	default:
+		values, err := e.store.ListWorking(ctx, runID)
+		if err != nil {
+			return toolFailure("list failed: " + err.Error())
+		}
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		b, _ := json.Marshal(map[string]any{"keys": keys, "count": len(keys)}) //nolint:errcheck // best-effort JSON encoding
+		return string(b), nil
+	}
+}
+
+// clearWorkingMemory removes the working memory of a completed run unless
+// Config.KeepWorkingMemory is set.
+func (e *Engine) clearWorkingMemory(ctx context.Context, r *run.Run) {
+	if e.config.KeepWorkingMemory {
+		return
+	}
+	if err := e.store.ClearWorking(ctx, r.ID); err != nil {
+		e.logger.Warn("clear working memory",
+			log.String("run_id", r.ID.String()),
+			log.String("error", err.Error()),
+		)
+	}
+}
+
+// ListWorking returns the working memory of a run of the tenant of ctx by
+// key.
+func (e *Engine) ListWorking(ctx context.Context, runID id.AgentRunID) (map[string]any, error) {
+	if _, err := e.GetRun(ctx, runID); err != nil {
+		return nil, err
+	}
+	return e.store.ListWorking(ctx, runID)
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/llm"
+	"github.com/xraph/cortex/store/sqlite"
+)
+
+// newMemoryToolsEngine returns an engine and an agent allowed to use the
+// working memory tools.
+func newMemoryToolsEngine(t *testing.T, client llm.Client, cfg cortex.Config) *Engine {
+	t.Helper()
+	s := newTestStore(t)
+	e, err := New(WithStore(s), WithLLM(client), WithConfig(cfg))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ag := &agent.Config{
+		ID: id.NewAgentID(), Name: "worker", AppID: "app1",
+		Tools: []string{"memory_set", "memory_get", "memory_list"},
+	}
+	if err := s.Create(context.Background(), ag); err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+	return e
+}
+
+// memoryToolsScript saves a note, then lists and reads it back.
+func memoryToolsScript() []*llm.Response {
+	return []*llm.Response{
+		toolCallResponse("c1", "memory_set", `{"key":"plan","value":{"step":2}}`),
+		{
+			ToolCalls: []llm.ToolCall{
+				{ID: "c2", Name: "memory_list", Arguments: `{}`},
+				{ID: "c3", Name: "memory_get", Arguments: `{"key":"plan"}`},
+				{ID: "c4", Name: "memory_get", Arguments: `{"key":"missing"}`},
+			},
+			Usage: llm.Usage{TotalTokens: 1},
+		},
+	}
+}
+
+func TestMemoryTools_SaveAndReadNotesDuringRun(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedLLM{responses: memoryToolsScript()}
+	e := newMemoryToolsEngine(t, client, cortex.DefaultConfig())
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, tool := range client.requests[0].Tools {
+		names[tool.Name] = true
+	}
+	if !names["memory_set"] || !names["memory_get"] || !names["memory_list"] {
+		t.Errorf("tools offered = %v, want the memory tools", names)
+	}
+
+	results := make(map[string]string)
+	for _, m := range client.lastRequest().Messages {
+		if m.Role == "tool" {
+			results[m.ToolCallID] = m.Content
+		}
+	}
+	want := map[string]string{
+		"c1": `{"status":"saved"}`,
+		"c2": `{"count":1,"keys":["plan"]}`,
+		"c3": `{"key":"plan","value":{"step":2}}`,
+	}
+	for callID, content := range want {
+		if results[callID] != content {
+			t.Errorf("result of %s = %q, want %q", callID, results[callID], content)
+		}
+	}
+	if !strings.Contains(results["c4"], "no note under key missing") {
+		t.Errorf("result of missing key = %q, want not-found error", results["c4"])
+	}
+	for _, tc := range runToolCallRecords(t, e.store.(*sqlite.Store), r.ID) {
+		if failed := tc.Error != ""; failed != (tc.ToolName == "memory_get" && strings.Contains(tc.Arguments, "missing")) {
+			t.Errorf("tool call %s(%s) error = %q, want only the missing key failed", tc.ToolName, tc.Arguments, tc.Error)
+		}
+	}
+
+	working, err := e.ListWorking(ctx, r.ID)
+	if err != nil || len(working) != 0 {
+		t.Errorf("working memory after completion = %v, %v; want cleared", working, err)
+	}
+}
+
+func TestMemoryTools_KeepWorkingMemory(t *testing.T) {
+	ctx := context.Background()
+	cfg := cortex.DefaultConfig()
+	cfg.KeepWorkingMemory = true
+	e := newMemoryToolsEngine(t, &scriptedLLM{responses: memoryToolsScript()}, cfg)
+
+	r, err := e.RunAgent(ctx, "app1", "worker", "work", nil)
+	if err != nil {
+		t.Fatalf("RunAgent: %v", err)
+	}
+	working, err := e.ListWorking(ctx, r.ID)
+	if err != nil {
+		t.Fatalf("ListWorking: %v", err)
+	}
+	plan, ok := working["plan"].(map[string]any)
+	if !ok || plan["step"] != float64(2) {
+		t.Errorf("working memory = %v, want plan kept", working)
+	}
+}
+
+func TestMemoryTools_RequireRun(t *testing.T) {
+	e := newMemoryToolsEngine(t, &scriptedLLM{}, cortex.DefaultConfig())
+	result, ok, err := e.executeBuiltinTool(context.Background(), "memory_list", `{}`)
+	if !ok || err == nil || !strings.Contains(result, "only available during a run") {
+		t.Errorf("memory_list without a run = %q, %v, %v; want error", result, ok, err)
+	}
+}
@@ -20,6 +20,9 @@ var (
 	ErrCheckpointNotFound       = errors.New("cortex: checkpoint not found")
 	ErrOrchestrationNotFound    = errors.New("cortex: orchestration not found")
 	ErrOrchestrationRunNotFound = errors.New("cortex: orchestration run not found")
+	ErrSessionNotFound          = errors.New("cortex: session not found")
+	ErrWorkingMemoryNotFound    = errors.New("cortex: working memory key not found")
+	ErrFactNotFound             = errors.New("cortex: fact not found")
 
 	// Conflict errors.
 	ErrAlreadyExists = errors.New("cortex: resource already exists")
@@ -28,7 +31,29 @@ var (
 	ErrInvalidState     = errors.New("cortex: invalid state transition")
 	ErrRunCancelled     = errors.New("cortex: run cancelled")
 	ErrRunAlreadyDone   = errors.New("cortex: run already completed")
+	ErrRunOrphaned      = errors.New("cortex: run interrupted by process exit")
+	ErrRunQueueTimeout  = errors.New("cortex: timed out waiting for a run slot")
 	ErrBudgetExhausted  = errors.New("cortex: budget exhausted")
 	ErrMaxStepsReached  = errors.New("cortex: maximum steps reached")
 	ErrMaxTokensReached = errors.New("cortex: maximum tokens reached")
+	ErrOutputInvalid    = errors.New("cortex: output does not match the output schema")
+
+	// Output schema errors.
+	ErrOutputSchemaInvalid = errors.New("cortex: unsupported output schema")
+
+	// Behavior errors.
+	ErrBehaviorInvalid = errors.New("cortex: invalid behavior")
+
+	// Tenant errors.
+	ErrTenantRequired = errors.New("cortex: tenant required")
+
+	// Tool errors.
+	ErrToolNotAllowed = errors.New("cortex: tool not allowed for agent")
+	ErrToolTimeout    = errors.New("cortex: tool call timed out")
+	ErrToolDisabled   = errors.New("cortex: tool disabled after repeated failures")
+
+	// Skill dependency errors.
+	ErrSkillDependencyNotFound = errors.New("cortex: skill dependency not found")
+	ErrSkillDependencyCycle    = errors.New("cortex: skill dependency cycle")
+	ErrSkillDependencyTooDeep  = errors.New("cortex: skill dependency chain too deep")
 )
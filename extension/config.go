@@ -1,6 +1,10 @@
 package extension
 
-import "time"
+import (
+	"time"
+
+	"github.com/xraph/cortex"
+)
 
 // Config holds the Cortex extension configuration.
 // Fields can be set programmatically via ExtOption functions or loaded from
@@ -21,6 +25,63 @@ type Config struct {
 	// DefaultMaxSteps is the maximum reasoning steps per run.
 	DefaultMaxSteps int `json:"default_max_steps" mapstructure:"default_max_steps" yaml:"default_max_steps"`
 
+	// MaxStepsPolicy decides what happens to a run that reaches its step
+	// limit without a final answer: "fail" (default), "summarize" or
+	// "checkpoint".
+	MaxStepsPolicy string `json:"max_steps_policy" mapstructure:"max_steps_policy" yaml:"max_steps_policy"`
+
+	// ToolConcurrency is the maximum number of tool calls from one model
+	// response a run executes concurrently (default: 4).
+	ToolConcurrency int `json:"tool_concurrency" mapstructure:"tool_concurrency" yaml:"tool_concurrency"`
+
+	// ToolFailureThreshold is the number of consecutive failed calls of a
+	// tool after which a run stops offering it to the model (default: 3).
+	ToolFailureThreshold int `json:"tool_failure_threshold" mapstructure:"tool_failure_threshold" yaml:"tool_failure_threshold"`
+
+	// OutputRepairAttempts is the number of times a run with an output
+	// schema asks the model to correct an invalid final answer (default: 2).
+	OutputRepairAttempts int `json:"output_repair_attempts" mapstructure:"output_repair_attempts" yaml:"output_repair_attempts"`
+
+	// SummarizeAfterMessages is the number of stored conversation messages
+	// after which older messages are summarized (0 = never).
+	SummarizeAfterMessages int `json:"summarize_after_messages" mapstructure:"summarize_after_messages" yaml:"summarize_after_messages"`
+
+	// SummarizeAfterTokens is the estimated conversation size in tokens
+	// after which older messages are summarized (0 = never).
+	SummarizeAfterTokens int `json:"summarize_after_tokens" mapstructure:"summarize_after_tokens" yaml:"summarize_after_tokens"`
+
+	// SummaryWindow is the number of recent messages kept verbatim when a
+	// conversation is summarized (default: 20).
+	SummaryWindow int `json:"summary_window" mapstructure:"summary_window" yaml:"summary_window"`
+
+	// SummaryModel is the LLM model that writes conversation summaries
+	// (default: the agent's model).
+	SummaryModel string `json:"summary_model" mapstructure:"summary_model" yaml:"summary_model"`
+
+	// KeepWorkingMemory keeps the working memory of completed runs instead
+	// of clearing it.
+	KeepWorkingMemory bool `json:"keep_working_memory" mapstructure:"keep_working_memory" yaml:"keep_working_memory"`
+
+	// FactMemory extracts durable facts from completed runs and adds the
+	// relevant ones to later prompts.
+	FactMemory bool `json:"fact_memory" mapstructure:"fact_memory" yaml:"fact_memory"`
+
+	// FactModel is the LLM model that extracts facts (default: the agent's
+	// model).
+	FactModel string `json:"fact_model" mapstructure:"fact_model" yaml:"fact_model"`
+
+	// FactRecallLimit is the maximum number of facts added to a run's system
+	// prompt (default: 10).
+	FactRecallLimit int `json:"fact_recall_limit" mapstructure:"fact_recall_limit" yaml:"fact_recall_limit"`
+
+	// ContextLimits sets the context window in tokens of models, on top of
+	// the built-in table. Keys also match model names they are a prefix of.
+	ContextLimits map[string]int `json:"context_limits" mapstructure:"context_limits" yaml:"context_limits"`
+
+	// DefaultContextLimit is the context window assumed for unknown models
+	// (default: 128000).
+	DefaultContextLimit int `json:"default_context_limit" mapstructure:"default_context_limit" yaml:"default_context_limit"`
+
 	// DefaultMaxTokens is the maximum tokens per LLM call.
 	DefaultMaxTokens int `json:"default_max_tokens" mapstructure:"default_max_tokens" yaml:"default_max_tokens"`
 
@@ -34,8 +95,45 @@ type Config struct {
 	ShutdownTimeout time.Duration `json:"shutdown_timeout" mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"`
 
 	// RunConcurrency controls how many agent runs can execute in parallel.
+	// Runs over the limit are queued (default: 4, negative = no limit).
 	RunConcurrency int `json:"run_concurrency" mapstructure:"run_concurrency" yaml:"run_concurrency"`
 
+	// TenantRunConcurrency limits concurrent runs per tenant (0 = no limit).
+	TenantRunConcurrency int `json:"tenant_run_concurrency" mapstructure:"tenant_run_concurrency" yaml:"tenant_run_concurrency"`
+
+	// RunQueueTimeout is how long a queued run waits for a slot before
+	// failing (0 = until the run's context is done).
+	RunQueueTimeout time.Duration `json:"run_queue_timeout" mapstructure:"run_queue_timeout" yaml:"run_queue_timeout"`
+
+	// TenantMonthlyTokenBudget limits the tokens each tenant's runs may use
+	// per calendar month (0 = no limit).
+	TenantMonthlyTokenBudget int `json:"tenant_monthly_token_budget" mapstructure:"tenant_monthly_token_budget" yaml:"tenant_monthly_token_budget"`
+
+	// TenantTokenBudgets overrides the monthly token budget of individual
+	// tenants. A zero entry exempts the tenant.
+	TenantTokenBudgets map[string]int `json:"tenant_token_budgets" mapstructure:"tenant_token_budgets" yaml:"tenant_token_budgets"`
+
+	// RequireTenant rejects runs and reads whose request carries no tenant.
+	RequireTenant bool `json:"require_tenant" mapstructure:"require_tenant" yaml:"require_tenant"`
+
+	// RunWorkers is the number of background workers executing runs
+	// submitted asynchronously (0 = no workers; set it to execute submitted
+	// runs in this process).
+	RunWorkers int `json:"run_workers" mapstructure:"run_workers" yaml:"run_workers"`
+
+	// RunPollInterval is how often idle workers check for submitted runs
+	// (default: 1s).
+	RunPollInterval time.Duration `json:"run_poll_interval" mapstructure:"run_poll_interval" yaml:"run_poll_interval"`
+
+	// RecoveryPolicy decides what happens at start to runs left running by a
+	// process that exited mid-run: "fail" (default), "resume" or "none".
+	RecoveryPolicy string `json:"recovery_policy" mapstructure:"recovery_policy" yaml:"recovery_policy"`
+
+	// OrphanedRunAge is how long a running or queued run must have gone
+	// without an update before it is treated as orphaned at start
+	// (default: 1m). Live runs are touched every quarter of it.
+	OrphanedRunAge time.Duration `json:"orphaned_run_age" mapstructure:"orphaned_run_age" yaml:"orphaned_run_age"`
+
 	// GroveDatabase is the name of a grove.DB registered in the DI container.
 	// When set, the extension resolves this named database and auto-constructs
 	// the appropriate store based on the driver type (pg/sqlite/mongo).
@@ -52,10 +150,56 @@ func DefaultConfig() Config {
 	return Config{
 		DefaultModel:         "smart",
 		DefaultMaxSteps:      25,
+		MaxStepsPolicy:       string(cortex.MaxStepsFail),
+		ToolConcurrency:      4,
+		ToolFailureThreshold: 3,
+		OutputRepairAttempts: 2,
+		SummaryWindow:        20,
+		FactRecallLimit:      10,
+		DefaultContextLimit:  128000,
 		DefaultMaxTokens:     4096,
 		DefaultTemperature:   0.7,
 		DefaultReasoningLoop: "react",
 		ShutdownTimeout:      30 * time.Second,
 		RunConcurrency:       4,
+		RunPollInterval:      time.Second,
+		RecoveryPolicy:       string(cortex.RecoveryFail),
+		OrphanedRunAge:       time.Minute,
+	}
+}
+
+// engineConfig returns the engine configuration described by c.
+func (c Config) engineConfig() cortex.Config {
+	return cortex.Config{
+		DefaultModel:             c.DefaultModel,
+		DefaultMaxSteps:          c.DefaultMaxSteps,
+		MaxStepsPolicy:           cortex.MaxStepsPolicy(c.MaxStepsPolicy),
+		ToolConcurrency:          c.ToolConcurrency,
+		ToolFailureThreshold:     c.ToolFailureThreshold,
+		OutputRepairAttempts:     c.OutputRepairAttempts,
+		SummarizeAfterMessages:   c.SummarizeAfterMessages,
+		SummarizeAfterTokens:     c.SummarizeAfterTokens,
+		SummaryWindow:            c.SummaryWindow,
+		SummaryModel:             c.SummaryModel,
+		KeepWorkingMemory:        c.KeepWorkingMemory,
+		FactMemory:               c.FactMemory,
+		FactModel:                c.FactModel,
+		FactRecallLimit:          c.FactRecallLimit,
+		ContextLimits:            c.ContextLimits,
+		DefaultContextLimit:      c.DefaultContextLimit,
+		DefaultMaxTokens:         c.DefaultMaxTokens,
+		DefaultTemperature:       c.DefaultTemperature,
+		DefaultReasoningLoop:     c.DefaultReasoningLoop,
+		ShutdownTimeout:          c.ShutdownTimeout,
+		RunConcurrency:           c.RunConcurrency,
+		TenantRunConcurrency:     c.TenantRunConcurrency,
+		RunQueueTimeout:          c.RunQueueTimeout,
+		TenantMonthlyTokenBudget: c.TenantMonthlyTokenBudget,
+		TenantTokenBudgets:       c.TenantTokenBudgets,
+		RequireTenant:            c.RequireTenant,
+		RunWorkers:               c.RunWorkers,
+		RunPollInterval:          c.RunPollInterval,
+		RecoveryPolicy:           cortex.RecoveryPolicy(c.RecoveryPolicy),
+		OrphanedRunAge:           c.OrphanedRunAge,
 	}
 }
@@ -160,7 +160,9 @@ func (e *Extension) init(fapp forge.App) error {
 		e.Logger().Info("cortex: discovered weave engine, knowledge page enabled")
 	}
 
-	eng, err := engine.New(e.engineOpts...)
+	// The extension configuration comes first so explicit engine options win.
+	opts := append([]engine.Option{engine.WithConfig(e.config.engineConfig())}, e.engineOpts...)
+	eng, err := engine.New(opts...)
 	if err != nil {
 		return fmt.Errorf("cortex: create engine: %w", err)
 	}
@@ -278,6 +280,7 @@ func (e *Extension) loadConfiguration() error {
 		forge.F("grove_database", e.config.GroveDatabase),
 		forge.F("default_model", e.config.DefaultModel),
 		forge.F("run_concurrency", e.config.RunConcurrency),
+		forge.F("recovery_policy", e.config.RecoveryPolicy),
 	)
 
 	return nil
@@ -326,6 +329,18 @@ func (e *Extension) mergeWithDefaults(cfg Config) Config {
 	if cfg.DefaultMaxSteps == 0 {
 		cfg.DefaultMaxSteps = defaults.DefaultMaxSteps
 	}
+	if cfg.MaxStepsPolicy == "" {
+		cfg.MaxStepsPolicy = defaults.MaxStepsPolicy
+	}
+	if cfg.ToolConcurrency == 0 {
+		cfg.ToolConcurrency = defaults.ToolConcurrency
+	}
+	if cfg.ToolFailureThreshold == 0 {
+		cfg.ToolFailureThreshold = defaults.ToolFailureThreshold
+	}
+	if cfg.OutputRepairAttempts == 0 {
+		cfg.OutputRepairAttempts = defaults.OutputRepairAttempts
+	}
 	if cfg.DefaultMaxTokens == 0 {
 		cfg.DefaultMaxTokens = defaults.DefaultMaxTokens
 	}
@@ -341,6 +356,24 @@ func (e *Extension) mergeWithDefaults(cfg Config) Config {
 	if cfg.RunConcurrency == 0 {
 		cfg.RunConcurrency = defaults.RunConcurrency
 	}
+	if cfg.RunPollInterval == 0 {
+		cfg.RunPollInterval = defaults.RunPollInterval
+	}
+	if cfg.SummaryWindow == 0 {
+		cfg.SummaryWindow = defaults.SummaryWindow
+	}
+	if cfg.FactRecallLimit == 0 {
+		cfg.FactRecallLimit = defaults.FactRecallLimit
+	}
+	if cfg.DefaultContextLimit == 0 {
+		cfg.DefaultContextLimit = defaults.DefaultContextLimit
+	}
+	if cfg.RecoveryPolicy == "" {
+		cfg.RecoveryPolicy = defaults.RecoveryPolicy
+	}
+	if cfg.OrphanedRunAge == 0 {
+		cfg.OrphanedRunAge = defaults.OrphanedRunAge
+	}
 	return cfg
 }
 
@@ -354,6 +387,15 @@ func (e *Extension) mergeConfigurations(yamlConfig, programmaticConfig Config) C
 	if programmaticConfig.DisableMigrate {
 		yamlConfig.DisableMigrate = true
 	}
+	if programmaticConfig.RequireTenant {
+		yamlConfig.RequireTenant = true
+	}
+	if programmaticConfig.KeepWorkingMemory {
+		yamlConfig.KeepWorkingMemory = true
+	}
+	if programmaticConfig.FactMemory {
+		yamlConfig.FactMemory = true
+	}
 
 	// String fields: YAML takes precedence.
 	if yamlConfig.BasePath == "" && programmaticConfig.BasePath != "" {
@@ -368,11 +410,50 @@ func (e *Extension) mergeConfigurations(yamlConfig, programmaticConfig Config) C
 	if yamlConfig.DefaultReasoningLoop == "" && programmaticConfig.DefaultReasoningLoop != "" {
 		yamlConfig.DefaultReasoningLoop = programmaticConfig.DefaultReasoningLoop
 	}
+	if yamlConfig.MaxStepsPolicy == "" && programmaticConfig.MaxStepsPolicy != "" {
+		yamlConfig.MaxStepsPolicy = programmaticConfig.MaxStepsPolicy
+	}
+	if yamlConfig.RecoveryPolicy == "" && programmaticConfig.RecoveryPolicy != "" {
+		yamlConfig.RecoveryPolicy = programmaticConfig.RecoveryPolicy
+	}
+	if yamlConfig.SummaryModel == "" && programmaticConfig.SummaryModel != "" {
+		yamlConfig.SummaryModel = programmaticConfig.SummaryModel
+	}
+	if yamlConfig.FactModel == "" && programmaticConfig.FactModel != "" {
+		yamlConfig.FactModel = programmaticConfig.FactModel
+	}
 
 	// Numeric fields: YAML takes precedence, programmatic fills gaps.
 	if yamlConfig.DefaultMaxSteps == 0 && programmaticConfig.DefaultMaxSteps != 0 {
 		yamlConfig.DefaultMaxSteps = programmaticConfig.DefaultMaxSteps
 	}
+	if yamlConfig.ToolConcurrency == 0 && programmaticConfig.ToolConcurrency != 0 {
+		yamlConfig.ToolConcurrency = programmaticConfig.ToolConcurrency
+	}
+	if yamlConfig.ToolFailureThreshold == 0 && programmaticConfig.ToolFailureThreshold != 0 {
+		yamlConfig.ToolFailureThreshold = programmaticConfig.ToolFailureThreshold
+	}
+	if yamlConfig.OutputRepairAttempts == 0 && programmaticConfig.OutputRepairAttempts != 0 {
+		yamlConfig.OutputRepairAttempts = programmaticConfig.OutputRepairAttempts
+	}
+	if yamlConfig.SummarizeAfterMessages == 0 && programmaticConfig.SummarizeAfterMessages != 0 {
+		yamlConfig.SummarizeAfterMessages = programmaticConfig.SummarizeAfterMessages
+	}
+	if yamlConfig.SummarizeAfterTokens == 0 && programmaticConfig.SummarizeAfterTokens != 0 {
+		yamlConfig.SummarizeAfterTokens = programmaticConfig.SummarizeAfterTokens
+	}
+	if yamlConfig.SummaryWindow == 0 && programmaticConfig.SummaryWindow != 0 {
+		yamlConfig.SummaryWindow = programmaticConfig.SummaryWindow
+	}
+	if yamlConfig.FactRecallLimit == 0 && programmaticConfig.FactRecallLimit != 0 {
+		yamlConfig.FactRecallLimit = programmaticConfig.FactRecallLimit
+	}
+	if yamlConfig.ContextLimits == nil && programmaticConfig.ContextLimits != nil {
+		yamlConfig.ContextLimits = programmaticConfig.ContextLimits
+	}
+	if yamlConfig.DefaultContextLimit == 0 && programmaticConfig.DefaultContextLimit != 0 {
+		yamlConfig.DefaultContextLimit = programmaticConfig.DefaultContextLimit
+	}
 	if yamlConfig.DefaultMaxTokens == 0 && programmaticConfig.DefaultMaxTokens != 0 {
 		yamlConfig.DefaultMaxTokens = programmaticConfig.DefaultMaxTokens
 	}
@@ -385,6 +466,27 @@ func (e *Extension) mergeConfigurations(yamlConfig, programmaticConfig Config) C
 	if yamlConfig.RunConcurrency == 0 && programmaticConfig.RunConcurrency != 0 {
 		yamlConfig.RunConcurrency = programmaticConfig.RunConcurrency
 	}
+	if yamlConfig.TenantRunConcurrency == 0 && programmaticConfig.TenantRunConcurrency != 0 {
+		yamlConfig.TenantRunConcurrency = programmaticConfig.TenantRunConcurrency
+	}
+	if yamlConfig.RunQueueTimeout == 0 && programmaticConfig.RunQueueTimeout != 0 {
+		yamlConfig.RunQueueTimeout = programmaticConfig.RunQueueTimeout
+	}
+	if yamlConfig.TenantMonthlyTokenBudget == 0 && programmaticConfig.TenantMonthlyTokenBudget != 0 {
+		yamlConfig.TenantMonthlyTokenBudget = programmaticConfig.TenantMonthlyTokenBudget
+	}
+	if yamlConfig.TenantTokenBudgets == nil && programmaticConfig.TenantTokenBudgets != nil {
+		yamlConfig.TenantTokenBudgets = programmaticConfig.TenantTokenBudgets
+	}
+	if yamlConfig.RunWorkers == 0 && programmaticConfig.RunWorkers != 0 {
+		yamlConfig.RunWorkers = programmaticConfig.RunWorkers
+	}
+	if yamlConfig.RunPollInterval == 0 && programmaticConfig.RunPollInterval != 0 {
+		yamlConfig.RunPollInterval = programmaticConfig.RunPollInterval
+	}
+	if yamlConfig.OrphanedRunAge == 0 && programmaticConfig.OrphanedRunAge != 0 {
+		yamlConfig.OrphanedRunAge = programmaticConfig.OrphanedRunAge
+	}
 
 	// Fill remaining zeros with defaults.
 	return e.mergeWithDefaults(yamlConfig)
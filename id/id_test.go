@@ -25,6 +25,7 @@ func TestConstructors(t *testing.T) {
 		{"TraitID", id.NewTraitID, "trt_"},
 		{"BehaviorID", id.NewBehaviorID, "bhv_"},
 		{"PersonaID", id.NewPersonaID, "prs_"},
+		{"SessionID", id.NewSessionID, "sess_"},
 	}
 
 	for _, tt := range tests {
@@ -65,6 +66,7 @@ func TestParseRoundTrip(t *testing.T) {
 		{"TraitID", id.NewTraitID, id.ParseTraitID},
 		{"BehaviorID", id.NewBehaviorID, id.ParseBehaviorID},
 		{"PersonaID", id.NewPersonaID, id.ParsePersonaID},
+		{"SessionID", id.NewSessionID, id.ParseSessionID},
 	}
 
 	for _, tt := range tests {
@@ -99,6 +101,7 @@ func TestCrossTypeRejection(t *testing.T) {
 		{"ParseTraitID rejects bhv_", id.NewBehaviorID().String(), id.ParseTraitID},
 		{"ParseBehaviorID rejects prs_", id.NewPersonaID().String(), id.ParseBehaviorID},
 		{"ParsePersonaID rejects agt_", id.NewAgentID().String(), id.ParsePersonaID},
+		{"ParseSessionID rejects arun_", id.NewAgentRunID().String(), id.ParseSessionID},
 	}
 
 	for _, tt := range tests {
@@ -125,6 +128,7 @@ func TestParseAny(t *testing.T) {
 		id.NewTraitID(),
 		id.NewBehaviorID(),
 		id.NewPersonaID(),
+		id.NewSessionID(),
 	}
 
 	for _, i := range ids {
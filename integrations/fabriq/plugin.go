@@ -24,7 +24,8 @@ const opCreate = "create"
 
 // Plugin is a cortex extension that writes agent run activity back into the
 // fabric so fabriq's embed + distillation workers turn it into future recall
-// material. It implements plugin.RunStarted and plugin.RunCompleted.
+// material. It implements plugin.RunStarted, plugin.RunCompleted,
+// plugin.RunFailed and plugin.RunCancelled.
 type Plugin struct {
 	rem      rememberer
 	cfg      config
@@ -87,6 +88,13 @@ func (p *Plugin) OnRunCompleted(ctx context.Context, agentID id.AgentID, runID i
 	return nil
 }
 
+// OnRunCancelled removes the run's stashed input. Cancelled runs are not
+// recorded: a partial answer is poor recall material.
+func (p *Plugin) OnRunCancelled(_ context.Context, _ id.AgentID, runID id.AgentRunID, _ string) error {
+	p.inflight.Delete(runID.String())
+	return nil
+}
+
 // OnRunFailed removes the run's stashed input (preventing an inflight-map leak)
 // and records the failure as memory so the brain can learn from failed runs.
 // Write/marshal errors are logged and swallowed.
@@ -29,6 +29,7 @@ func TestPlugin_ImplementsExtensionAndHooks(_ *testing.T) {
 	var _ plugin.RunStarted = (*Plugin)(nil)
 	var _ plugin.RunCompleted = (*Plugin)(nil)
 	var _ plugin.RunFailed = (*Plugin)(nil)
+	var _ plugin.RunCancelled = (*Plugin)(nil)
 }
 
 func TestPlugin_WritesMemoryOnRunCompleted(t *testing.T) {
@@ -93,6 +94,25 @@ func TestPlugin_SkipsEmptyContent(t *testing.T) {
 	}
 }
 
+func TestPlugin_OnRunCancelledCleansUpWithoutRecording(t *testing.T) {
+	rem := &fakeRememberer{}
+	p := NewPlugin(rem)
+	runID := id.AgentRunID{}
+	ctx := context.Background()
+	if err := p.OnRunStarted(ctx, id.AgentID{}, runID, "do a thing"); err != nil {
+		t.Fatalf("OnRunStarted: %v", err)
+	}
+	if err := p.OnRunCancelled(ctx, id.AgentID{}, runID, "half an answer"); err != nil {
+		t.Fatalf("OnRunCancelled: %v", err)
+	}
+	if _, ok := p.inflight.Load(runID.String()); ok {
+		t.Fatalf("inflight entry should be deleted after OnRunCancelled")
+	}
+	if len(rem.reqs) != 0 {
+		t.Fatalf("got %d Remember calls, want 0", len(rem.reqs))
+	}
+}
+
 func TestPlugin_OnRunFailedCleansUpAndRecords(t *testing.T) {
 	rem := &fakeRememberer{}
 	p := NewPlugin(rem)
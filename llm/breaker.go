@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerConfig configures a BreakerClient.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive retryable failures of a
+	// model that open its circuit. Default: 5.
+	FailureThreshold int
+
+	// OpenTimeout is how long an open circuit rejects calls before a single
+	// probe call is let through. Default: 30s.
+	OpenTimeout time.Duration
+}
+
+// BreakerClient keeps a circuit per model. After FailureThreshold
+// consecutive retryable failures a model's calls fail fast with
+// ErrCircuitOpen for OpenTimeout; the next call is then let through as a
+// probe, closing the circuit if it succeeds and reopening it otherwise.
+type BreakerClient struct {
+	next Client
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit is the state of one model.
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewBreakerClient wraps next with a circuit breaker per model.
+func NewBreakerClient(next Client, cfg BreakerConfig) *BreakerClient {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	return &BreakerClient{next: next, cfg: cfg, now: time.Now, circuits: make(map[string]*circuit)}
+}
+
+// Complete sends the request unless the circuit of its model is open.
+func (b *BreakerClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	if err := b.allow(req.Model); err != nil {
+		return nil, err
+	}
+	resp, err := b.next.Complete(ctx, req)
+	b.record(ctx, req.Model, err)
+	return resp, err
+}
+
+// CompleteStream opens a stream unless the circuit of the request's model is
+// open. Only failures to open the stream count against the circuit.
+func (b *BreakerClient) CompleteStream(ctx context.Context, req *Request) (Stream, error) {
+	if err := b.allow(req.Model); err != nil {
+		return nil, err
+	}
+	s, err := b.next.CompleteStream(ctx, req)
+	b.record(ctx, req.Model, err)
+	return s, err
+}
+
+// Open reports whether the circuit of model currently rejects calls.
+func (b *BreakerClient) Open(model string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[model]
+	return c != nil && c.failures >= b.cfg.FailureThreshold && (c.probing || b.now().Before(c.openUntil))
+}
+
+// allow returns ErrCircuitOpen when the circuit of model rejects the call,
+// and marks the call as the probe of a circuit whose timeout has passed.
+func (b *BreakerClient) allow(model string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuits[model]
+	if c == nil || c.failures < b.cfg.FailureThreshold {
+		return nil
+	}
+	if c.probing || b.now().Before(c.openUntil) {
+		return fmt.Errorf("%w: model %q", ErrCircuitOpen, model)
+	}
+	c.probing = true
+	return nil
+}
+
+// record updates the circuit of model with the outcome of a call. Errors
+// that are not retryable, and calls whose context is done, say nothing
+// about the model and leave the failure count as it is.
+func (b *BreakerClient) record(ctx context.Context, model string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.circuits, model)
+		return
+	}
+	c := b.circuits[model]
+	if c == nil {
+		c = &circuit{}
+		b.circuits[model] = c
+	}
+	c.probing = false
+	if ctx.Err() != nil || !Retryable(err) {
+		return
+	}
+	c.failures++
+	if c.failures >= b.cfg.FailureThreshold {
+		c.openUntil = b.now().Add(b.cfg.OpenTimeout)
+	}
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrCircuitOpen is returned by a BreakerClient for a model whose circuit is
+// open after repeated failures.
+var ErrCircuitOpen = errors.New("llm: circuit open")
+
+// StatusError is a failed provider call with the HTTP status the provider or
+// gateway answered with. Adapters wrap provider errors in it so Retryable can
+// tell transient failures from permanent ones.
+type StatusError struct {
+	// StatusCode is the HTTP status of the failed call.
+	StatusCode int
+
+	// Err is the underlying provider error.
+	Err error
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("llm: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("llm: status %d: %v", e.StatusCode, e.Err)
+}
+
+// Unwrap returns the underlying provider error.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether err is a transient failure worth retrying or
+// falling back on: a StatusError for a timeout, rate limit, overload or
+// server error, a network timeout, a deadline exceeded inside the provider,
+// ErrCircuitOpen, or an error with a Retryable() bool method returning true.
+// Cancellation is never retryable.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return retryableStatus(se.StatusCode)
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// retryableStatus reports whether a call failing with the HTTP status code
+// may succeed when repeated.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // overloaded
+		return true
+	default:
+		return false
+	}
+}
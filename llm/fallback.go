@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"slices"
+)
+
+// FallbackClient tries an ordered list of fallback models after the
+// requested model fails with a retryable error. The Model of the response is
+// the model that answered.
+type FallbackClient struct {
+	next   Client
+	models []string
+}
+
+// NewFallbackClient wraps next so a request whose model fails is sent to
+// each of models in turn.
+func NewFallbackClient(next Client, models ...string) *FallbackClient {
+	return &FallbackClient{next: next, models: models}
+}
+
+// Complete sends the request to its model, then to each fallback model
+// until one answers.
+func (c *FallbackClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	return fallback(ctx, c.candidates(req), func(model string) (*Response, error) {
+		resp, err := c.next.Complete(ctx, withModel(req, model))
+		if err == nil && resp.Model == "" {
+			resp.Model = model
+		}
+		return resp, err
+	})
+}
+
+// CompleteStream opens a stream on the request's model, then on each
+// fallback model until one opens. StreamModel reports the model streaming.
+func (c *FallbackClient) CompleteStream(ctx context.Context, req *Request) (Stream, error) {
+	return fallback(ctx, c.candidates(req), func(model string) (Stream, error) {
+		s, err := c.next.CompleteStream(ctx, withModel(req, model))
+		if err != nil {
+			return nil, err
+		}
+		return &modelStream{Stream: s, model: model}, nil
+	})
+}
+
+// candidates returns the models to try for req: its own model followed by
+// the fallback models it is not already.
+func (c *FallbackClient) candidates(req *Request) []string {
+	models := []string{req.Model}
+	for _, m := range c.models {
+		if !slices.Contains(models, m) {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// fallback calls fn with each model until it succeeds or fails with an
+// error that is not retryable. It returns the last error when all fail.
+func fallback[T any](ctx context.Context, models []string, fn func(model string) (T, error)) (T, error) {
+	var (
+		out T
+		err error
+	)
+	for _, model := range models {
+		out, err = fn(model)
+		if err == nil || ctx.Err() != nil || !Retryable(err) {
+			return out, err
+		}
+	}
+	return out, err
+}
+
+// withModel returns a copy of req for model.
+func withModel(req *Request, model string) *Request {
+	if req.Model == model {
+		return req
+	}
+	cp := *req
+	cp.Model = model
+	return &cp
+}
+
+// modelStream is a stream that knows the model answering it.
+type modelStream struct {
+	Stream
+	model string
+}
+
+func (s *modelStream) Model() string {
+	return s.model
+}
+
+// StreamModel returns the model answering a stream opened by a
+// FallbackClient, or "" for a stream that does not report it.
+func StreamModel(s Stream) string {
+	if m, ok := s.(interface{ Model() string }); ok {
+		return m.Model()
+	}
+	return ""
+}
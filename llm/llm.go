@@ -36,6 +36,11 @@ type Request struct {
 
 	// Temperature controls sampling randomness. Nil means provider default.
 	Temperature *float64
+
+	// OutputSchema is a JSON Schema the response content must be a JSON
+	// value of. Providers supporting structured output constrain generation
+	// to it; others ignore it. Nil means free text.
+	OutputSchema map[string]any
 }
 
 // Message is a single message in a conversation.
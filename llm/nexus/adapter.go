@@ -3,6 +3,8 @@ package nexus
 
 import (
 	"context"
+	"errors"
+	"net/http"
 
 	"github.com/xraph/nexus"
 	"github.com/xraph/nexus/provider"
@@ -38,7 +40,7 @@ func (a *Adapter) Complete(ctx context.Context, req *llm.Request) (*llm.Response
 	nReq := toNexusRequest(req)
 	resp, err := a.engine.Complete(ctx, nReq)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 	return fromNexusResponse(resp), nil
 }
@@ -49,11 +51,70 @@ func (a *Adapter) CompleteStream(ctx context.Context, req *llm.Request) (llm.Str
 	nReq.Stream = true
 	stream, err := a.engine.CompleteStream(ctx, nReq)
 	if err != nil {
-		return nil, err
+		return nil, statusError(err)
 	}
 	return &streamAdapter{stream: stream}, nil
 }
 
+// ──────────────────────────────────────────────────
+// Error conversion: gateway errors → llm.StatusError
+// ──────────────────────────────────────────────────
+
+// statusError wraps a gateway error in an llm.StatusError carrying the HTTP
+// status it stands for, so llm.Retryable can tell transient failures from
+// permanent ones. Errors without a known status are returned unchanged.
+func statusError(err error) error {
+	if code := statusCode(err); code != 0 {
+		return &llm.StatusError{StatusCode: code, Err: err}
+	}
+	return err
+}
+
+// statusCode returns the HTTP status of a failed gateway call: the status
+// reported by the provider error, or the one matching a Nexus error.
+func statusCode(err error) int {
+	// Already classified by the provider.
+	var se *llm.StatusError
+	if errors.As(err, &se) {
+		return 0
+	}
+	var sc interface{ StatusCode() int }
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	switch {
+	case errors.Is(err, nexus.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, nexus.ErrProviderUnavailable),
+		errors.Is(err, nexus.ErrAllProvidersFailed),
+		errors.Is(err, nexus.ErrCircuitOpen),
+		errors.Is(err, nexus.ErrNoTargetsAvailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, nexus.ErrUnauthorized),
+		errors.Is(err, nexus.ErrAPIKeyInvalid),
+		errors.Is(err, nexus.ErrAPIKeyRevoked),
+		errors.Is(err, nexus.ErrCredentialExpired),
+		errors.Is(err, nexus.ErrCredentialNotFound):
+		return http.StatusUnauthorized
+	case errors.Is(err, nexus.ErrTenantDisabled),
+		errors.Is(err, nexus.ErrQuotaExceeded),
+		errors.Is(err, nexus.ErrBudgetExceeded):
+		return http.StatusForbidden
+	case errors.Is(err, nexus.ErrProviderNotFound),
+		errors.Is(err, nexus.ErrTenantNotFound),
+		errors.Is(err, nexus.ErrAliasNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, nexus.ErrModelNotSupported),
+		errors.Is(err, nexus.ErrThinkingNotSupported),
+		errors.Is(err, nexus.ErrContextOverflow),
+		errors.Is(err, nexus.ErrContentBlocked),
+		errors.Is(err, nexus.ErrPIIDetected),
+		errors.Is(err, nexus.ErrInjectionDetected):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
 // ──────────────────────────────────────────────────
 // Request conversion: llm.Request → provider.CompletionRequest
 // ──────────────────────────────────────────────────
@@ -67,6 +128,15 @@ func toNexusRequest(req *llm.Request) *provider.CompletionRequest {
 		Messages:    toNexusMessages(req.Messages),
 		Tools:       toNexusTools(req.Tools),
 	}
+	if req.OutputSchema != nil {
+		nReq.ResponseFormat = &provider.ResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &provider.JSONSchemaDef{
+				Name:   "output",
+				Schema: req.OutputSchema,
+			},
+		}
+	}
 	return nReq
 }
 
@@ -0,0 +1,121 @@
+package nexus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xraph/nexus"
+	"github.com/xraph/nexus/provider"
+
+	"github.com/xraph/cortex/llm"
+)
+
+// httpError is a provider error reporting the HTTP status of the failed call.
+type httpError struct{ code int }
+
+func (e *httpError) Error() string   { return fmt.Sprintf("upstream answered %d", e.code) }
+func (e *httpError) StatusCode() int { return e.code }
+
+// flakyProvider fails with the queued errors before answering "ok".
+type flakyProvider struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (p *flakyProvider) Name() string { return "flaky" }
+
+func (p *flakyProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{Chat: true, Streaming: true, Tools: true}
+}
+
+func (p *flakyProvider) Models(context.Context) ([]provider.Model, error) {
+	return []provider.Model{{ID: "smart", Provider: "flaky", Capabilities: p.Capabilities()}}, nil
+}
+
+func (p *flakyProvider) Complete(_ context.Context, req *provider.CompletionRequest) (*provider.CompletionResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &provider.CompletionResponse{
+		Model:   req.Model,
+		Choices: []provider.Choice{{Message: provider.Message{Role: "assistant", Content: "ok"}}},
+	}, nil
+}
+
+func (p *flakyProvider) CompleteStream(context.Context, *provider.CompletionRequest) (provider.Stream, error) {
+	return nil, provider.ErrNotSupported
+}
+
+func (p *flakyProvider) Embed(context.Context, *provider.EmbeddingRequest) (*provider.EmbeddingResponse, error) {
+	return nil, provider.ErrNotSupported
+}
+
+func (p *flakyProvider) Healthy(context.Context) bool { return true }
+
+// newTestAdapter returns an adapter on a gateway serving p, without the
+// gateway's own retries.
+func newTestAdapter(t *testing.T, p provider.Provider) *Adapter {
+	t.Helper()
+	gw := nexus.New(nexus.WithProvider(p), nexus.WithMaxRetries(0))
+	if err := gw.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	return New(gw)
+}
+
+func TestAdapter_ProviderErrorsAreRetried(t *testing.T) {
+	p := &flakyProvider{errs: []error{&httpError{code: http.StatusServiceUnavailable}}}
+	client := llm.NewRetryClient(newTestAdapter(t, p), llm.RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	resp, err := client.Complete(context.Background(), &llm.Request{Model: "smart"})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if resp.Content != "ok" || p.calls != 2 {
+		t.Errorf("content = %q after %d calls, want ok after a retry", resp.Content, p.calls)
+	}
+}
+
+func TestAdapter_PermanentErrorsAreNotRetried(t *testing.T) {
+	p := &flakyProvider{errs: []error{&httpError{code: http.StatusBadRequest}}}
+	client := llm.NewRetryClient(newTestAdapter(t, p), llm.RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond})
+
+	_, err := client.Complete(context.Background(), &llm.Request{Model: "smart"})
+	var se *llm.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Complete err = %v, want a 400 StatusError", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("provider called %d times, want 1", p.calls)
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("nexus: provider flaky: %w", &httpError{code: 529}), 529},
+		{fmt.Errorf("nexus: routing: %w", nexus.ErrRateLimited), http.StatusTooManyRequests},
+		{nexus.ErrAllProvidersFailed, http.StatusServiceUnavailable},
+		{nexus.ErrContextOverflow, http.StatusBadRequest},
+		{nexus.ErrBudgetExceeded, http.StatusForbidden},
+		{errors.New("boom"), 0},
+	}
+	for _, tt := range tests {
+		if got := statusCode(tt.err); got != tt.want {
+			t.Errorf("statusCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
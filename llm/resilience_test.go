@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeClient fails calls to a model with the errors scripted for it, in
+// order, and answers once they run out.
+type fakeClient struct {
+	errs  map[string][]error
+	calls []string
+}
+
+func (f *fakeClient) next(req *Request) error {
+	f.calls = append(f.calls, req.Model)
+	errs := f.errs[req.Model]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.errs[req.Model] = errs[1:]
+	return errs[0]
+}
+
+func (f *fakeClient) Complete(_ context.Context, req *Request) (*Response, error) {
+	if err := f.next(req); err != nil {
+		return nil, err
+	}
+	return &Response{Content: "ok from " + req.Model}, nil
+}
+
+func (f *fakeClient) CompleteStream(_ context.Context, req *Request) (Stream, error) {
+	if err := f.next(req); err != nil {
+		return nil, err
+	}
+	return &mockStream{content: "ok"}, nil
+}
+
+var overloaded = &StatusError{StatusCode: 529, Err: errors.New("overloaded")}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{overloaded, true},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{&StatusError{StatusCode: http.StatusBadRequest}, false},
+		{ErrCircuitOpen, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("boom"), false},
+	} {
+		if got := Retryable(tc.err); got != tc.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryClient_RetriesTransientFailures(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeClient{errs: map[string][]error{"smart": {overloaded, overloaded}}}
+	c := NewRetryClient(fake, RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	resp, err := c.Complete(ctx, &Request{Model: "smart"})
+	if err != nil || resp.Content != "ok from smart" {
+		t.Fatalf("Complete = %+v, %v; want an answer", resp, err)
+	}
+	if len(fake.calls) != 3 {
+		t.Errorf("calls = %d, want 3", len(fake.calls))
+	}
+
+	fake.errs["smart"] = []error{&StatusError{StatusCode: http.StatusBadRequest}, nil}
+	fake.calls = nil
+	if _, err := c.Complete(ctx, &Request{Model: "smart"}); err == nil || len(fake.calls) != 1 {
+		t.Errorf("Complete after a bad request = %v with %d calls; want the error after 1 call", err, len(fake.calls))
+	}
+}
+
+func TestRetryClient_Backoff(t *testing.T) {
+	cfg := RetryConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for attempt, limit := range map[int]time.Duration{1: 100, 2: 200, 3: 300, 6: 300} {
+		limit *= time.Millisecond
+		if d := backoff(cfg, attempt); d < limit/2 || d > limit {
+			t.Errorf("backoff(%d) = %s, want between %s and %s", attempt, d, limit/2, limit)
+		}
+	}
+}
+
+func TestFallbackClient_FallsBackInOrder(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeClient{errs: map[string][]error{"primary": {overloaded}, "backup": {overloaded}}}
+	c := NewFallbackClient(fake, "backup", "primary", "last")
+
+	resp, err := c.Complete(ctx, &Request{Model: "primary"})
+	if err != nil || resp.Model != "last" {
+		t.Fatalf("Complete = %+v, %v; want an answer from last", resp, err)
+	}
+	if want := []string{"primary", "backup", "last"}; !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+
+	fake.errs["primary"] = []error{overloaded}
+	s, err := c.CompleteStream(ctx, &Request{Model: "primary"})
+	if err != nil || StreamModel(s) != "backup" {
+		t.Fatalf("CompleteStream model = %q, %v; want backup", StreamModel(s), err)
+	}
+
+	fake.errs["primary"] = []error{errors.New("invalid request")}
+	fake.calls = nil
+	if _, err := c.Complete(ctx, &Request{Model: "primary"}); err == nil || len(fake.calls) != 1 {
+		t.Errorf("Complete on a permanent error = %v with calls %v; want no fallback", err, fake.calls)
+	}
+}
+
+func TestBreakerClient_OpensPerModel(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeClient{errs: map[string][]error{"primary": {overloaded, overloaded, overloaded}}}
+	b := NewBreakerClient(fake, BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	for range 2 {
+		if _, err := b.Complete(ctx, &Request{Model: "primary"}); !errors.Is(err, overloaded) {
+			t.Fatalf("Complete err = %v, want the provider error", err)
+		}
+	}
+	if _, err := b.Complete(ctx, &Request{Model: "primary"}); !errors.Is(err, ErrCircuitOpen) || !b.Open("primary") {
+		t.Fatalf("Complete on an open circuit err = %v, want ErrCircuitOpen", err)
+	}
+	if len(fake.calls) != 2 {
+		t.Errorf("calls = %d, want 2", len(fake.calls))
+	}
+	if _, err := b.Complete(ctx, &Request{Model: "backup"}); err != nil {
+		t.Errorf("Complete on another model: %v", err)
+	}
+
+	// After the timeout a failed probe reopens the circuit, a successful
+	// one closes it.
+	now = now.Add(time.Minute)
+	if _, err := b.Complete(ctx, &Request{Model: "primary"}); !errors.Is(err, overloaded) || !b.Open("primary") {
+		t.Fatalf("failed probe err = %v, open = %v; want the circuit reopened", err, b.Open("primary"))
+	}
+	now = now.Add(time.Minute)
+	if _, err := b.Complete(ctx, &Request{Model: "primary"}); err != nil || b.Open("primary") {
+		t.Fatalf("probe err = %v, open = %v; want the circuit closed", err, b.Open("primary"))
+	}
+}
+
+func TestFallbackClient_SkipsOpenCircuits(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeClient{errs: map[string][]error{"primary": {overloaded}}}
+	b := NewBreakerClient(fake, BreakerConfig{FailureThreshold: 1})
+	c := NewFallbackClient(b, "backup")
+
+	for range 2 {
+		resp, err := c.Complete(ctx, &Request{Model: "primary"})
+		if err != nil || resp.Model != "backup" {
+			t.Fatalf("Complete = %+v, %v; want an answer from backup", resp, err)
+		}
+	}
+	if want := []string{"primary", "backup", "backup"}; !slices.Equal(fake.calls, want) {
+		t.Errorf("calls = %v, want %v", fake.calls, want)
+	}
+}
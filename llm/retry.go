@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryConfig configures a RetryClient.
+type RetryConfig struct {
+	// MaxAttempts is the number of times a call is tried in total. Default: 3.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the second attempt; it doubles before each
+	// further one. Default: 500ms.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the wait between attempts. Default: 10s.
+	MaxDelay time.Duration
+
+	// Retryable decides which errors are retried. Default: Retryable.
+	Retryable func(error) bool
+}
+
+// RetryClient retries failed calls to the client it wraps with jittered
+// exponential backoff. Only the opening of a stream is retried; an error
+// while reading one is returned to the caller.
+type RetryClient struct {
+	next Client
+	cfg  RetryConfig
+}
+
+// NewRetryClient wraps next so retryable failures are tried again.
+func NewRetryClient(next Client, cfg RetryConfig) *RetryClient {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 500 * time.Millisecond
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 10 * time.Second
+	}
+	if cfg.Retryable == nil {
+		cfg.Retryable = Retryable
+	}
+	return &RetryClient{next: next, cfg: cfg}
+}
+
+// Complete sends the request, retrying retryable failures.
+func (c *RetryClient) Complete(ctx context.Context, req *Request) (*Response, error) {
+	return retry(ctx, c.cfg, func() (*Response, error) {
+		return c.next.Complete(ctx, req)
+	})
+}
+
+// CompleteStream opens a stream, retrying retryable failures to open it.
+func (c *RetryClient) CompleteStream(ctx context.Context, req *Request) (Stream, error) {
+	return retry(ctx, c.cfg, func() (Stream, error) {
+		return c.next.CompleteStream(ctx, req)
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error cfg does not retry,
+// runs out of attempts or ctx is done.
+func retry[T any](ctx context.Context, cfg RetryConfig, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		out, err := fn()
+		if err == nil || attempt >= cfg.MaxAttempts || ctx.Err() != nil || !cfg.Retryable(err) {
+			return out, err
+		}
+		select {
+		case <-time.After(backoff(cfg, attempt)):
+		case <-ctx.Done():
+			return out, err
+		}
+	}
+}
+
+// backoff returns the wait after the given failed attempt: a random duration
+// between half and all of BaseDelay doubled per previous attempt, capped at
+// MaxDelay.
+func backoff(cfg RetryConfig, attempt int) time.Duration {
+	d := cfg.BaseDelay
+	for i := 1; i < attempt && d < cfg.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, cfg.MaxDelay)
+	return d/2 + rand.N(d/2+1) //nolint:gosec // jitter needs no cryptographic randomness
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Tokenizer counts the tokens a model reads for a piece of text.
+type Tokenizer interface {
+	// Count returns the number of tokens text takes for model.
+	Count(model, text string) int
+}
+
+// HeuristicTokenizer estimates token counts at four bytes per token, which
+// is close for English text with most tokenizers. It is the default when no
+// model-specific tokenizer is configured.
+type HeuristicTokenizer struct{}
+
+// Count returns the estimated number of tokens of text.
+func (HeuristicTokenizer) Count(_, text string) int {
+	return (len(text) + 3) / 4
+}
+
+// messageOverhead is the number of tokens counted for the framing of each
+// message (role and separators).
+const messageOverhead = 4
+
+// CountMessage returns the number of tokens msg takes for model: its
+// content, tool calls and framing.
+func CountMessage(t Tokenizer, model string, msg Message) int {
+	n := messageOverhead + t.Count(model, msg.Content)
+	for _, tc := range msg.ToolCalls {
+		n += t.Count(model, tc.Name) + t.Count(model, tc.Arguments)
+	}
+	return n
+}
+
+// CountTool returns the number of tokens the definition of tool takes for
+// model.
+func CountTool(t Tokenizer, model string, tool Tool) int {
+	n := t.Count(model, tool.Name) + t.Count(model, tool.Description)
+	if tool.Parameters != nil {
+		if data, err := json.Marshal(tool.Parameters); err == nil {
+			n += t.Count(model, string(data))
+		}
+	}
+	return n
+}
+
+// CountRequest returns the number of prompt tokens req takes for its model:
+// the system prompt, messages and tool definitions.
+func CountRequest(t Tokenizer, req *Request) int {
+	n := t.Count(req.Model, req.System)
+	for _, msg := range req.Messages {
+		n += CountMessage(t, req.Model, msg)
+	}
+	for _, tool := range req.Tools {
+		n += CountTool(t, req.Model, tool)
+	}
+	return n
+}
+
+// DefaultContextLimits maps model names to their context windows in tokens.
+// A key also matches model names it is a prefix of, so dated releases
+// resolve to their family; the longest matching key wins.
+var DefaultContextLimits = map[string]int{
+	"gpt-3.5-turbo":    16385,
+	"gpt-4":            8192,
+	"gpt-4-turbo":      128000,
+	"gpt-4o":           128000,
+	"gpt-4.1":          1047576,
+	"gpt-5":            400000,
+	"o1":               200000,
+	"o3":               200000,
+	"o4-mini":          200000,
+	"claude-":          200000,
+	"gemini-1.5-flash": 1048576,
+	"gemini-1.5-pro":   2097152,
+	"gemini-2":         1048576,
+	"llama-3.1":        128000,
+	"llama-3.3":        128000,
+	"mistral-large":    128000,
+}
+
+// ContextLimit returns the context window of model from limits: the entry
+// for model itself, or else the entry with the longest key model starts
+// with. It reports false when no entry matches.
+func ContextLimit(limits map[string]int, model string) (int, bool) {
+	if n, ok := limits[model]; ok {
+		return n, true
+	}
+	best, limit := -1, 0
+	for prefix, n := range limits {
+		if len(prefix) > best && strings.HasPrefix(model, prefix) {
+			best, limit = len(prefix), n
+		}
+	}
+	return limit, best >= 0
+}
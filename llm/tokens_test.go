@@ -0,0 +1,32 @@
+package llm
+
+import "testing"
+
+func TestContextLimit_MatchesLongestPrefix(t *testing.T) {
+	limits := map[string]int{"gpt-4": 8192, "gpt-4o": 128000, "exact": 10}
+	for model, want := range map[string]int{
+		"exact":             10,
+		"gpt-4o-2024-08-06": 128000,
+		"gpt-4-0613":        8192,
+	} {
+		if got, ok := ContextLimit(limits, model); !ok || got != want {
+			t.Errorf("ContextLimit(%q) = %d, %v; want %d", model, got, ok, want)
+		}
+	}
+	if _, ok := ContextLimit(limits, "smart"); ok {
+		t.Error("ContextLimit matched a model with no entry")
+	}
+}
+
+func TestCountRequest_CountsSystemMessagesAndTools(t *testing.T) {
+	tok := HeuristicTokenizer{}
+	req := &Request{
+		System:   "12345678",
+		Messages: []Message{{Role: "user", Content: "1234"}, {Role: "assistant", ToolCalls: []ToolCall{{Name: "note", Arguments: "{}"}}}},
+		Tools:    []Tool{{Name: "note", Description: "1234"}},
+	}
+	// system 2; messages 4+1 and 4+1+1; tool 1+1.
+	if got := CountRequest(tok, req); got != 15 {
+		t.Errorf("CountRequest = %d, want 15", got)
+	}
+}
@@ -1,7 +1,11 @@
 // Package memory defines the Message entity and memory store interface.
 package memory
 
-import "time"
+import (
+	"time"
+
+	"github.com/xraph/cortex/id"
+)
 
 // Message represents a single message in a conversation.
 type Message struct {
@@ -11,3 +15,24 @@ type Message struct {
 	Metadata  map[string]any `json:"metadata,omitempty"`
 	Timestamp time.Time      `json:"timestamp"`
 }
+
+// FactCategory classifies a fact.
+type FactCategory string
+
+const (
+	FactPreference FactCategory = "preference"
+	FactDecision   FactCategory = "decision"
+	FactEntity     FactCategory = "entity"
+	FactOther      FactCategory = "other"
+)
+
+// Fact is a durable piece of knowledge extracted from conversations, such
+// as a user preference, a decision or an entity. Facts are kept per agent
+// and tenant under a key; saving a fact under an existing key replaces it.
+type Fact struct {
+	Key       string        `json:"key"`
+	Category  FactCategory  `json:"category"`
+	Content   string        `json:"content"`
+	RunID     id.AgentRunID `json:"run_id,omitzero"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
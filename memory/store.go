@@ -6,16 +6,41 @@ import (
 	"github.com/xraph/cortex/id"
 )
 
-// Store defines persistence for agent memory (conversation, working, summaries).
+// Store defines persistence for agent memory (conversation, working,
+// summaries, facts).
+//
+// A conversation is kept per agent, tenant and session. The nil session is
+// the conversation of runs started outside any session. Summaries are kept
+// per conversation too, oldest first. Facts are kept per agent and tenant,
+// across sessions.
 type Store interface {
-	SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, messages []Message) error
-	LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, limit int) ([]Message, error)
-	ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string) error
+	SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, messages []Message) error
+	// LoadConversation returns the limit most recent messages of the
+	// conversation, oldest first; all of them when limit is 0.
+	LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, limit int) ([]Message, error)
+	// ClearConversation removes the conversation's messages and summaries.
+	ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) error
+	// TrimConversation removes the n oldest messages of the conversation.
+	TrimConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, n int) error
 
 	SaveWorking(ctx context.Context, runID id.AgentRunID, key string, value any) error
+	// LoadWorking returns cortex.ErrWorkingMemoryNotFound when the run has
+	// no value under key.
 	LoadWorking(ctx context.Context, runID id.AgentRunID, key string) (any, error)
+	// ListWorking returns all working memory of the run by key.
+	ListWorking(ctx context.Context, runID id.AgentRunID) (map[string]any, error)
 	ClearWorking(ctx context.Context, runID id.AgentRunID) error
 
-	SaveSummary(ctx context.Context, agentID id.AgentID, tenantID string, summary string) error
-	LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string) ([]string, error)
+	SaveSummary(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, summary string) error
+	LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) ([]string, error)
+
+	// SaveFact stores fact under its key, replacing the fact already under
+	// it, and sets its UpdatedAt.
+	SaveFact(ctx context.Context, agentID id.AgentID, tenantID string, fact *Fact) error
+	// ListFacts returns the facts of the agent and tenant, most recently
+	// updated first.
+	ListFacts(ctx context.Context, agentID id.AgentID, tenantID string) ([]Fact, error)
+	// DeleteFact returns cortex.ErrFactNotFound when no fact is under key.
+	DeleteFact(ctx context.Context, agentID id.AgentID, tenantID, key string) error
+	ClearFacts(ctx context.Context, agentID id.AgentID, tenantID string) error
 }
@@ -18,11 +18,15 @@ var (
 	_ plugin.RunStarted            = (*MetricsExtension)(nil)
 	_ plugin.RunCompleted          = (*MetricsExtension)(nil)
 	_ plugin.RunFailed             = (*MetricsExtension)(nil)
+	_ plugin.RunCancelled          = (*MetricsExtension)(nil)
+	_ plugin.RunQueued             = (*MetricsExtension)(nil)
+	_ plugin.RunAdmitted           = (*MetricsExtension)(nil)
 	_ plugin.ToolCalled            = (*MetricsExtension)(nil)
 	_ plugin.ToolCompleted         = (*MetricsExtension)(nil)
 	_ plugin.ToolFailed            = (*MetricsExtension)(nil)
 	_ plugin.PersonaResolved       = (*MetricsExtension)(nil)
 	_ plugin.BehaviorTriggered     = (*MetricsExtension)(nil)
+	_ plugin.TraitApplied          = (*MetricsExtension)(nil)
 	_ plugin.CognitivePhaseChanged = (*MetricsExtension)(nil)
 	_ plugin.CheckpointCreated     = (*MetricsExtension)(nil)
 	_ plugin.CheckpointResolved    = (*MetricsExtension)(nil)
@@ -33,11 +37,16 @@ type MetricsExtension struct {
 	RunStartedCount            gu.Counter
 	RunCompletedCount          gu.Counter
 	RunFailedCount             gu.Counter
+	RunCancelledCount          gu.Counter
+	RunQueuedCount             gu.Counter
+	RunQueueDepth              gu.Gauge
+	RunQueueWait               gu.Histogram
 	ToolCalledCount            gu.Counter
 	ToolCompletedCount         gu.Counter
 	ToolFailedCount            gu.Counter
 	PersonaResolvedCount       gu.Counter
 	BehaviorTriggeredCount     gu.Counter
+	TraitAppliedCount          gu.Counter
 	CognitivePhaseChangedCount gu.Counter
 	CheckpointCreatedCount     gu.Counter
 	CheckpointResolvedCount    gu.Counter
@@ -54,11 +63,16 @@ func NewMetricsExtensionWithFactory(factory gu.MetricFactory) *MetricsExtension
 		RunStartedCount:            factory.Counter("cortex.agent.run.started"),
 		RunCompletedCount:          factory.Counter("cortex.agent.run.completed"),
 		RunFailedCount:             factory.Counter("cortex.agent.run.failed"),
+		RunCancelledCount:          factory.Counter("cortex.agent.run.cancelled"),
+		RunQueuedCount:             factory.Counter("cortex.agent.run.queued"),
+		RunQueueDepth:              factory.Gauge("cortex.agent.run.queue_depth"),
+		RunQueueWait:               factory.Histogram("cortex.agent.run.queue_wait_seconds"),
 		ToolCalledCount:            factory.Counter("cortex.tool.called"),
 		ToolCompletedCount:         factory.Counter("cortex.tool.completed"),
 		ToolFailedCount:            factory.Counter("cortex.tool.failed"),
 		PersonaResolvedCount:       factory.Counter("cortex.persona.resolved"),
 		BehaviorTriggeredCount:     factory.Counter("cortex.behavior.triggered"),
+		TraitAppliedCount:          factory.Counter("cortex.trait.applied"),
 		CognitivePhaseChangedCount: factory.Counter("cortex.cognitive.phase_changed"),
 		CheckpointCreatedCount:     factory.Counter("cortex.checkpoint.created"),
 		CheckpointResolvedCount:    factory.Counter("cortex.checkpoint.resolved"),
@@ -83,6 +97,23 @@ func (m *MetricsExtension) OnRunFailed(_ context.Context, _ id.AgentID, _ id.Age
 	return nil
 }
 
+func (m *MetricsExtension) OnRunQueued(_ context.Context, _ id.AgentID, _ id.AgentRunID, queueDepth int) error {
+	m.RunQueuedCount.Inc()
+	m.RunQueueDepth.Set(float64(queueDepth))
+	return nil
+}
+
+func (m *MetricsExtension) OnRunAdmitted(_ context.Context, _ id.AgentID, _ id.AgentRunID, waited time.Duration, queueDepth int) error {
+	m.RunQueueWait.Observe(waited.Seconds())
+	m.RunQueueDepth.Set(float64(queueDepth))
+	return nil
+}
+
+func (m *MetricsExtension) OnRunCancelled(_ context.Context, _ id.AgentID, _ id.AgentRunID, _ string) error {
+	m.RunCancelledCount.Inc()
+	return nil
+}
+
 func (m *MetricsExtension) OnToolCalled(_ context.Context, _ id.AgentRunID, _ string, _ any) error {
 	m.ToolCalledCount.Inc()
 	return nil
@@ -108,6 +139,11 @@ func (m *MetricsExtension) OnBehaviorTriggered(_ context.Context, _ id.AgentRunI
 	return nil
 }
 
+func (m *MetricsExtension) OnTraitApplied(_ context.Context, _ id.AgentRunID, _, _ string) error {
+	m.TraitAppliedCount.Inc()
+	return nil
+}
+
 func (m *MetricsExtension) OnCognitivePhaseChanged(_ context.Context, _ id.AgentRunID, _, _ string) error {
 	m.CognitivePhaseChangedCount.Inc()
 	return nil
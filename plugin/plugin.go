@@ -43,6 +43,25 @@ type RunFailed interface {
 	OnRunFailed(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, err error) error
 }
 
+// RunQueued is called when an agent run waits for an execution slot because
+// the engine is at its concurrency limits. queueDepth includes the run.
+type RunQueued interface {
+	OnRunQueued(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, queueDepth int) error
+}
+
+// RunAdmitted is called when a queued agent run gets an execution slot.
+// waited is the time spent queued; queueDepth is the number of runs still
+// waiting.
+type RunAdmitted interface {
+	OnRunAdmitted(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, waited time.Duration, queueDepth int) error
+}
+
+// RunCancelled is called when an agent run is cancelled. partialOutput is
+// the output produced before the run stopped, which may be empty.
+type RunCancelled interface {
+	OnRunCancelled(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, partialOutput string) error
+}
+
 // ──────────────────────────────────────────────────
 // Reasoning lifecycle hooks
 // ──────────────────────────────────────────────────
@@ -90,6 +109,11 @@ type BehaviorTriggered interface {
 	OnBehaviorTriggered(ctx context.Context, runID id.AgentRunID, behaviorName string) error
 }
 
+// TraitApplied is called when a trait influence is applied to a run.
+type TraitApplied interface {
+	OnTraitApplied(ctx context.Context, runID id.AgentRunID, traitName, target string) error
+}
+
 // CognitivePhaseChanged is called when the cognitive engine switches phases.
 type CognitivePhaseChanged interface {
 	OnCognitivePhaseChanged(ctx context.Context, runID id.AgentRunID, fromPhase, toPhase string) error
@@ -26,6 +26,21 @@ type runFailedEntry struct {
 	hook RunFailed
 }
 
+type runQueuedEntry struct {
+	name string
+	hook RunQueued
+}
+
+type runAdmittedEntry struct {
+	name string
+	hook RunAdmitted
+}
+
+type runCancelledEntry struct {
+	name string
+	hook RunCancelled
+}
+
 type stepStartedEntry struct {
 	name string
 	hook StepStarted
@@ -61,6 +76,11 @@ type behaviorTriggeredEntry struct {
 	hook BehaviorTriggered
 }
 
+type traitAppliedEntry struct {
+	name string
+	hook TraitApplied
+}
+
 type cognitivePhaseChangedEntry struct {
 	name string
 	hook CognitivePhaseChanged
@@ -106,6 +126,9 @@ type Registry struct {
 	runStarted             []runStartedEntry
 	runCompleted           []runCompletedEntry
 	runFailed              []runFailedEntry
+	runCancelled           []runCancelledEntry
+	runQueued              []runQueuedEntry
+	runAdmitted            []runAdmittedEntry
 	stepStarted            []stepStartedEntry
 	stepCompleted          []stepCompletedEntry
 	toolCalled             []toolCalledEntry
@@ -113,6 +136,7 @@ type Registry struct {
 	toolFailed             []toolFailedEntry
 	personaResolved        []personaResolvedEntry
 	behaviorTriggered      []behaviorTriggeredEntry
+	traitApplied           []traitAppliedEntry
 	cognitivePhaseChanged  []cognitivePhaseChangedEntry
 	checkpointCreated      []checkpointCreatedEntry
 	checkpointResolved     []checkpointResolvedEntry
@@ -142,6 +166,15 @@ func (r *Registry) Register(e Extension) {
 	if h, ok := e.(RunFailed); ok {
 		r.runFailed = append(r.runFailed, runFailedEntry{name, h})
 	}
+	if h, ok := e.(RunCancelled); ok {
+		r.runCancelled = append(r.runCancelled, runCancelledEntry{name, h})
+	}
+	if h, ok := e.(RunQueued); ok {
+		r.runQueued = append(r.runQueued, runQueuedEntry{name, h})
+	}
+	if h, ok := e.(RunAdmitted); ok {
+		r.runAdmitted = append(r.runAdmitted, runAdmittedEntry{name, h})
+	}
 	if h, ok := e.(StepStarted); ok {
 		r.stepStarted = append(r.stepStarted, stepStartedEntry{name, h})
 	}
@@ -163,6 +196,9 @@ func (r *Registry) Register(e Extension) {
 	if h, ok := e.(BehaviorTriggered); ok {
 		r.behaviorTriggered = append(r.behaviorTriggered, behaviorTriggeredEntry{name, h})
 	}
+	if h, ok := e.(TraitApplied); ok {
+		r.traitApplied = append(r.traitApplied, traitAppliedEntry{name, h})
+	}
 	if h, ok := e.(CognitivePhaseChanged); ok {
 		r.cognitivePhaseChanged = append(r.cognitivePhaseChanged, cognitivePhaseChangedEntry{name, h})
 	}
@@ -217,6 +253,30 @@ func (r *Registry) EmitRunFailed(ctx context.Context, agentID id.AgentID, runID
 	}
 }
 
+func (r *Registry) EmitRunCancelled(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, partialOutput string) {
+	for _, e := range r.runCancelled {
+		if err := e.hook.OnRunCancelled(ctx, agentID, runID, partialOutput); err != nil {
+			r.logHookError("OnRunCancelled", e.name, err)
+		}
+	}
+}
+
+func (r *Registry) EmitRunQueued(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, queueDepth int) {
+	for _, e := range r.runQueued {
+		if err := e.hook.OnRunQueued(ctx, agentID, runID, queueDepth); err != nil {
+			r.logHookError("OnRunQueued", e.name, err)
+		}
+	}
+}
+
+func (r *Registry) EmitRunAdmitted(ctx context.Context, agentID id.AgentID, runID id.AgentRunID, waited time.Duration, queueDepth int) {
+	for _, e := range r.runAdmitted {
+		if err := e.hook.OnRunAdmitted(ctx, agentID, runID, waited, queueDepth); err != nil {
+			r.logHookError("OnRunAdmitted", e.name, err)
+		}
+	}
+}
+
 // ──────────────────────────────────────────────────
 // Step event emitters
 // ──────────────────────────────────────────────────
@@ -285,6 +345,14 @@ func (r *Registry) EmitBehaviorTriggered(ctx context.Context, runID id.AgentRunI
 	}
 }
 
+func (r *Registry) EmitTraitApplied(ctx context.Context, runID id.AgentRunID, traitName, target string) {
+	for _, e := range r.traitApplied {
+		if err := e.hook.OnTraitApplied(ctx, runID, traitName, target); err != nil {
+			r.logHookError("OnTraitApplied", e.name, err)
+		}
+	}
+}
+
 func (r *Registry) EmitCognitivePhaseChanged(ctx context.Context, runID id.AgentRunID, fromPhase, toPhase string) {
 	for _, e := range r.cognitivePhaseChanged {
 		if err := e.hook.OnCognitivePhaseChanged(ctx, runID, fromPhase, toPhase); err != nil {
@@ -13,6 +13,7 @@ type State string
 
 const (
 	StateCreated   State = "created"
+	StateQueued    State = "queued"
 	StateRunning   State = "running"
 	StateCompleted State = "completed"
 	StateFailed    State = "failed"
@@ -26,6 +27,7 @@ type Run struct {
 	ID          id.AgentRunID  `json:"id"`
 	AgentID     id.AgentID     `json:"agent_id"`
 	TenantID    string         `json:"tenant_id,omitempty"`
+	SessionID   id.SessionID   `json:"session_id,omitempty"`
 	State       State          `json:"state"`
 	Input       string         `json:"input"`
 	Output      string         `json:"output,omitempty"`
@@ -36,4 +38,8 @@ type Run struct {
 	CompletedAt *time.Time     `json:"completed_at,omitempty"`
 	PersonaRef  string         `json:"persona_ref,omitempty"`
 	Metadata    map[string]any `json:"metadata,omitempty"`
+	// LoopState is the engine's persisted loop state of an unfinished run:
+	// the messages sent to the model and the progress needed to continue
+	// it. It is internal to the engine and never serialized to clients.
+	LoopState []byte `json:"-"`
 }
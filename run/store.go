@@ -2,6 +2,7 @@ package run
 
 import (
 	"context"
+	"time"
 
 	"github.com/xraph/cortex/id"
 )
@@ -11,6 +12,19 @@ type Store interface {
 	CreateRun(ctx context.Context, run *Run) error
 	GetRun(ctx context.Context, runID id.AgentRunID) (*Run, error)
 	UpdateRun(ctx context.Context, run *Run) error
+	// UpdateRunInState stores run like UpdateRun, but only while the stored
+	// run is in state. It reports false otherwise, so that a write based on
+	// a stale read cannot undo a concurrent transition such as a cancel.
+	UpdateRunInState(ctx context.Context, run *Run, state State) (bool, error)
+	// TransitionRun atomically moves a run from one state to another. It
+	// reports false when the run is not in the from state, so that only one
+	// of several concurrent callers wins.
+	TransitionRun(ctx context.Context, runID id.AgentRunID, from, to State) (bool, error)
+	// ClaimRun atomically refreshes the update time of a run that is in
+	// state and was last updated at updatedAt. It reports false when the
+	// run changed since, so that of several processes taking over the same
+	// stale run only one claims it.
+	ClaimRun(ctx context.Context, runID id.AgentRunID, state State, updatedAt time.Time) (bool, error)
 	ListRuns(ctx context.Context, filter *ListFilter) ([]*Run, error)
 	CountRuns(ctx context.Context, filter *ListFilter) (int64, error)
 
@@ -21,11 +35,13 @@ type Store interface {
 	ListToolCalls(ctx context.Context, stepID id.StepID) ([]*ToolCall, error)
 }
 
-// ListFilter controls pagination for run listing.
+// ListFilter controls pagination for run listing. Runs are listed newest
+// first, or oldest first with OldestFirst.
 type ListFilter struct {
-	AgentID  string
-	TenantID string
-	State    State
-	Limit    int
-	Offset   int
+	AgentID     string
+	TenantID    string
+	State       State
+	OldestFirst bool
+	Limit       int
+	Offset      int
 }
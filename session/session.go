@@ -0,0 +1,22 @@
+// Package session defines conversation sessions: separate threads of
+// conversation between a tenant and an agent.
+package session
+
+import (
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+)
+
+// Session is a conversation thread with an agent. Runs started in a session
+// see and extend only the session's conversation memory.
+type Session struct {
+	cortex.Entity
+	ID             id.SessionID   `json:"id"`
+	AgentID        id.AgentID     `json:"agent_id"`
+	TenantID       string         `json:"tenant_id,omitempty"`
+	Title          string         `json:"title,omitempty"`
+	LastActivityAt time.Time      `json:"last_activity_at"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+}
@@ -0,0 +1,27 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/xraph/cortex/id"
+)
+
+// Store defines persistence for sessions.
+type Store interface {
+	CreateSession(ctx context.Context, s *Session) error
+	GetSession(ctx context.Context, sessionID id.SessionID) (*Session, error)
+	// TouchSession sets the last activity time of the session.
+	TouchSession(ctx context.Context, sessionID id.SessionID, at time.Time) error
+	ListSessions(ctx context.Context, filter *ListFilter) ([]*Session, error)
+	DeleteSession(ctx context.Context, sessionID id.SessionID) error
+}
+
+// ListFilter controls pagination for session listing. Sessions are listed
+// most recently active first.
+type ListFilter struct {
+	AgentID  string
+	TenantID string
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/xraph/cortex/budget"
+)
+
+// AddUsage adds tokens to the usage of key in scope for the period starting
+// at period, creating the usage document if needed.
+func (s *Store) AddUsage(ctx context.Context, scope budget.Scope, key string, period time.Time, tokens int64) error {
+	_, err := s.mdb.NewUpdate((*budgetUsageModel)(nil)).
+		Filter(bson.M{"_id": budgetUsageID(scope, key, period)}).
+		SetUpdate(bson.M{
+			"$inc": bson.M{"tokens": tokens},
+			"$set": bson.M{"updated_at": now()},
+			"$setOnInsert": bson.M{
+				"scope":  string(scope),
+				"key":    key,
+				"period": period.Unix(),
+			},
+		}).
+		Upsert().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: add budget usage: %w", err)
+	}
+
+	return nil
+}
+
+// SumUsage returns the tokens used by key in scope over the periods starting
+// at or after since.
+func (s *Store) SumUsage(ctx context.Context, scope budget.Scope, key string, since time.Time) (int64, error) {
+	var models []budgetUsageModel
+
+	err := s.mdb.NewFind(&models).
+		Filter(bson.M{
+			"scope":  string(scope),
+			"key":    key,
+			"period": bson.M{"$gte": since.Unix()},
+		}).
+		Scan(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cortex/mongo: sum budget usage: %w", err)
+	}
+
+	var total int64
+	for i := range models {
+		total += models[i].Tokens
+	}
+
+	return total, nil
+}
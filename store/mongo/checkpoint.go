@@ -44,24 +44,35 @@ func (s *Store) GetCheckpoint(ctx context.Context, cpID id.CheckpointID) (*check
 	return checkpointFromModel(&m)
 }
 
-// Resolve resolves a pending checkpoint with a decision.
-func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) error {
+// Resolve records a decision on a pending checkpoint, reporting false when
+// no pending checkpoint has the ID.
+func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) (bool, error) {
 	t := now()
 
 	res, err := s.mdb.NewUpdate((*checkpointModel)(nil)).
-		Filter(bson.M{"_id": cpID.String()}).
-		Set("state", "resolved").
+		Filter(bson.M{"_id": cpID.String(), "state": checkpoint.StatePending}).
+		Set("state", checkpoint.StateResolved).
 		Set("decision", decision).
 		Set("updated_at", t).
 		Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("cortex/mongo: resolve checkpoint: %w", err)
+		return false, fmt.Errorf("cortex/mongo: resolve checkpoint: %w", err)
 	}
 
-	if res.MatchedCount() == 0 {
-		return cortex.ErrCheckpointNotFound
-	}
+	return res.MatchedCount() > 0, nil
+}
 
+// ExpirePending moves the pending checkpoints of a run to expired.
+func (s *Store) ExpirePending(ctx context.Context, runID id.AgentRunID) error {
+	_, err := s.mdb.NewUpdate((*checkpointModel)(nil)).
+		Filter(bson.M{"run_id": runID.String(), "state": checkpoint.StatePending}).
+		Set("state", checkpoint.StateExpired).
+		Set("updated_at", now()).
+		Many().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: expire checkpoints: %w", err)
+	}
 	return nil
 }
 
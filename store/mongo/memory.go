@@ -4,22 +4,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 
+	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/id"
 	"github.com/xraph/cortex/memory"
 )
 
 // SaveConversation appends messages to conversation memory.
-func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, messages []memory.Message) error {
+func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, messages []memory.Message) error {
 	if len(messages) == 0 {
 		return nil
 	}
 
 	models := make([]memoryModel, len(messages))
 	for i, msg := range messages {
-		models[i] = *messageToModel(agentID.String(), tenantID, msg)
+		models[i] = *messageToModel(agentID.String(), tenantID, sessionID.String(), msg)
 		models[i].CreatedAt = now()
 	}
 
@@ -31,20 +34,22 @@ func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenant
 	return nil
 }
 
-// LoadConversation returns conversation messages for an agent and tenant.
-func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, limit int) ([]memory.Message, error) {
+// LoadConversation returns conversation messages for an agent, tenant and session.
+func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, limit int) ([]memory.Message, error) {
 	var models []memoryModel
 
 	q := s.mdb.NewFind(&models).
 		Filter(bson.M{
-			"agent_id":  agentID.String(),
-			"tenant_id": tenantID,
-			"kind":      "conversation",
-		}).
-		Sort(bson.D{{Key: "created_at", Value: 1}})
+			"agent_id":   agentID.String(),
+			"tenant_id":  tenantID,
+			"session_id": sessionFilter(sessionID),
+			"kind":       "conversation",
+		})
 
 	if limit > 0 {
-		q = q.Limit(int64(limit))
+		q = q.Sort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).Limit(int64(limit))
+	} else {
+		q = q.Sort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
 	}
 
 	if err := q.Scan(ctx); err != nil {
@@ -59,17 +64,23 @@ func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenant
 		}
 	}
 
+	if limit > 0 {
+		slices.Reverse(messages)
+	}
+
 	return messages, nil
 }
 
-// ClearConversation removes all conversation messages for an agent and tenant.
-func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string) error {
+// ClearConversation removes all conversation messages and summaries for an
+// agent, tenant and session.
+func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) error {
 	_, err := s.mdb.NewDelete((*memoryModel)(nil)).
 		Many().
 		Filter(bson.M{
-			"agent_id":  agentID.String(),
-			"tenant_id": tenantID,
-			"kind":      "conversation",
+			"agent_id":   agentID.String(),
+			"tenant_id":  tenantID,
+			"session_id": sessionFilter(sessionID),
+			"kind":       bson.M{"$in": bson.A{"conversation", "summary"}},
 		}).
 		Exec(ctx)
 	if err != nil {
@@ -79,6 +90,67 @@ func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenan
 	return nil
 }
 
+// TrimConversation removes the n oldest conversation messages for an agent,
+// tenant and session.
+func (s *Store) TrimConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	// Document IDs are driver-generated ObjectIDs, so they are read raw
+	// rather than through memoryModel's string ID.
+	cur, err := s.mdb.Collection(colMemories).Find(ctx,
+		bson.M{
+			"agent_id":   agentID.String(),
+			"tenant_id":  tenantID,
+			"session_id": sessionFilter(sessionID),
+			"kind":       "conversation",
+		},
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+			SetProjection(bson.M{"_id": 1}).
+			SetLimit(int64(n)),
+	)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: trim conversation: %w", err)
+	}
+
+	var docs []struct {
+		ID any `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return fmt.Errorf("cortex/mongo: trim conversation: %w", err)
+	}
+
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make(bson.A, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+
+	_, err = s.mdb.NewDelete((*memoryModel)(nil)).
+		Many().
+		Filter(bson.M{"_id": bson.M{"$in": ids}}).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: trim conversation: %w", err)
+	}
+
+	return nil
+}
+
+// sessionFilter matches the conversation of sessionID. Messages saved before
+// sessions existed have no session_id and belong to the nil session.
+func sessionFilter(sessionID id.SessionID) any {
+	if sessionID.IsNil() {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+	return sessionID.String()
+}
+
 // SaveWorking stores a working memory key-value pair, upserting if the key already exists.
 func (s *Store) SaveWorking(ctx context.Context, runID id.AgentRunID, key string, value any) error {
 	t := now()
@@ -117,6 +189,10 @@ func (s *Store) LoadWorking(ctx context.Context, runID id.AgentRunID, key string
 		}).
 		Scan(ctx)
 	if err != nil {
+		if isNoDocuments(err) {
+			return nil, cortex.ErrWorkingMemoryNotFound
+		}
+
 		return nil, fmt.Errorf("cortex/mongo: load working memory: %w", err)
 	}
 
@@ -128,6 +204,32 @@ func (s *Store) LoadWorking(ctx context.Context, runID id.AgentRunID, key string
 	return v, nil
 }
 
+// ListWorking returns all working memory for a run by key.
+func (s *Store) ListWorking(ctx context.Context, runID id.AgentRunID) (map[string]any, error) {
+	var models []memoryModel
+
+	err := s.mdb.NewFind(&models).
+		Filter(bson.M{
+			"agent_id": runID.String(),
+			"kind":     "working",
+		}).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cortex/mongo: list working memory: %w", err)
+	}
+
+	values := make(map[string]any, len(models))
+	for _, m := range models {
+		var v any
+		if err := json.Unmarshal([]byte(m.Content), &v); err != nil {
+			return nil, fmt.Errorf("cortex/mongo: unmarshal working memory: %w", err)
+		}
+		values[m.Key] = v
+	}
+
+	return values, nil
+}
+
 // ClearWorking removes all working memory for a run.
 func (s *Store) ClearWorking(ctx context.Context, runID id.AgentRunID) error {
 	_, err := s.mdb.NewDelete((*memoryModel)(nil)).
@@ -144,11 +246,13 @@ func (s *Store) ClearWorking(ctx context.Context, runID id.AgentRunID) error {
 	return nil
 }
 
-// SaveSummary appends a summary to the agent's memory.
-func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID, summary string) error {
+// SaveSummary appends a summary to the conversation memory of an agent,
+// tenant and session.
+func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, summary string) error {
 	m := &memoryModel{
 		AgentID:   agentID.String(),
 		TenantID:  tenantID,
+		SessionID: sessionID.String(),
 		Kind:      "summary",
 		Content:   summary,
 		CreatedAt: now(),
@@ -162,17 +266,19 @@ func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID, s
 	return nil
 }
 
-// LoadSummaries returns all summaries for an agent and tenant.
-func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string) ([]string, error) {
+// LoadSummaries returns all summaries for an agent, tenant and session,
+// oldest first.
+func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) ([]string, error) {
 	var models []memoryModel
 
 	err := s.mdb.NewFind(&models).
 		Filter(bson.M{
-			"agent_id":  agentID.String(),
-			"tenant_id": tenantID,
-			"kind":      "summary",
+			"agent_id":   agentID.String(),
+			"tenant_id":  tenantID,
+			"session_id": sessionFilter(sessionID),
+			"kind":       "summary",
 		}).
-		Sort(bson.D{{Key: "created_at", Value: 1}}).
+		Sort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
 		Scan(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("cortex/mongo: load summaries: %w", err)
@@ -185,3 +291,98 @@ func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID
 
 	return summaries, nil
 }
+
+// SaveFact stores a fact under its key, replacing the fact already under it.
+func (s *Store) SaveFact(ctx context.Context, agentID id.AgentID, tenantID string, fact *memory.Fact) error {
+	fact.UpdatedAt = now()
+
+	_, err := s.mdb.NewUpdate((*memoryModel)(nil)).
+		Filter(bson.M{
+			"agent_id":  agentID.String(),
+			"tenant_id": tenantID,
+			"kind":      "fact",
+			"key":       fact.Key,
+		}).
+		SetUpdate(bson.M{"$set": bson.M{
+			"agent_id":   agentID.String(),
+			"tenant_id":  tenantID,
+			"kind":       "fact",
+			"key":        fact.Key,
+			"content":    mustJSON(fact),
+			"run_id":     fact.RunID.String(),
+			"category":   string(fact.Category),
+			"created_at": fact.UpdatedAt,
+		}}).
+		Upsert().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: save fact: %w", err)
+	}
+
+	return nil
+}
+
+// ListFacts returns the facts of an agent and tenant, most recently updated first.
+func (s *Store) ListFacts(ctx context.Context, agentID id.AgentID, tenantID string) ([]memory.Fact, error) {
+	var models []memoryModel
+
+	err := s.mdb.NewFind(&models).
+		Filter(bson.M{
+			"agent_id":  agentID.String(),
+			"tenant_id": tenantID,
+			"kind":      "fact",
+		}).
+		Sort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cortex/mongo: list facts: %w", err)
+	}
+
+	facts := make([]memory.Fact, 0, len(models))
+	for _, m := range models {
+		var f memory.Fact
+		if err := json.Unmarshal([]byte(m.Content), &f); err == nil {
+			facts = append(facts, f)
+		}
+	}
+
+	return facts, nil
+}
+
+// DeleteFact removes the fact of an agent and tenant under key.
+func (s *Store) DeleteFact(ctx context.Context, agentID id.AgentID, tenantID, key string) error {
+	res, err := s.mdb.NewDelete((*memoryModel)(nil)).
+		Filter(bson.M{
+			"agent_id":  agentID.String(),
+			"tenant_id": tenantID,
+			"kind":      "fact",
+			"key":       key,
+		}).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: delete fact: %w", err)
+	}
+
+	if res.DeletedCount() == 0 {
+		return cortex.ErrFactNotFound
+	}
+
+	return nil
+}
+
+// ClearFacts removes all facts of an agent and tenant.
+func (s *Store) ClearFacts(ctx context.Context, agentID id.AgentID, tenantID string) error {
+	_, err := s.mdb.NewDelete((*memoryModel)(nil)).
+		Many().
+		Filter(bson.M{
+			"agent_id":  agentID.String(),
+			"tenant_id": tenantID,
+			"kind":      "fact",
+		}).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: clear facts: %w", err)
+	}
+
+	return nil
+}
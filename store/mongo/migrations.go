@@ -352,6 +352,86 @@ func init() {
 				return mexec.DropCollection(ctx, (*orchestrationConfigModel)(nil))
 			},
 		},
+		&migrate.Migration{
+			Name:    "create_cortex_budget_usage",
+			Version: "20240101000012",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+
+				if err := mexec.CreateCollection(ctx, (*budgetUsageModel)(nil)); err != nil {
+					return err
+				}
+
+				return mexec.CreateIndexes(ctx, colBudgetUsage, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "key", Value: 1}, {Key: "period", Value: 1}}},
+				})
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+				return mexec.DropCollection(ctx, (*budgetUsageModel)(nil))
+			},
+		},
+		&migrate.Migration{
+			Name:    "create_cortex_sessions",
+			Version: "20240101000013",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+
+				if err := mexec.CreateCollection(ctx, (*sessionModel)(nil)); err != nil {
+					return err
+				}
+
+				if err := mexec.CreateIndexes(ctx, colSessions, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "last_activity_at", Value: -1}}},
+				}); err != nil {
+					return err
+				}
+
+				return mexec.CreateIndexes(ctx, colMemories, []mongo.IndexModel{
+					{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "session_id", Value: 1}, {Key: "kind", Value: 1}}},
+				})
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+				return mexec.DropCollection(ctx, (*sessionModel)(nil))
+			},
+		},
+		&migrate.Migration{
+			Name:    "create_cortex_fact_index",
+			Version: "20240101000014",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+
+				return mexec.CreateIndexes(ctx, colMemories, []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "key", Value: 1}},
+						Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"kind": "fact"}),
+					},
+				})
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				mexec, ok := exec.(*mongomigrate.Executor)
+				if !ok {
+					return fmt.Errorf("expected mongomigrate executor, got %T", exec)
+				}
+				return mexec.DB().Collection(colMemories).Indexes().DropOne(ctx, "agent_id_1_tenant_id_1_key_1")
+			},
+		},
 	)
 }
 
@@ -384,10 +464,15 @@ func migrationIndexes() map[string][]mongo.IndexModel {
 		colMemories: {
 			{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "kind", Value: 1}}},
 			{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "kind", Value: 1}}},
+			{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "session_id", Value: 1}, {Key: "kind", Value: 1}}},
 			{
 				Keys:    bson.D{{Key: "agent_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "key", Value: 1}},
 				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"kind": "working"}),
 			},
+			{
+				Keys:    bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"kind": "fact"}),
+			},
 			{Keys: bson.D{{Key: "created_at", Value: 1}}},
 		},
 		colCheckpoints: {
@@ -441,5 +526,11 @@ func migrationIndexes() map[string][]mongo.IndexModel {
 			{Keys: bson.D{{Key: "config_id", Value: 1}}},
 			{Keys: bson.D{{Key: "created_at", Value: -1}}},
 		},
+		colBudgetUsage: {
+			{Keys: bson.D{{Key: "scope", Value: 1}, {Key: "key", Value: 1}, {Key: "period", Value: 1}}},
+		},
+		colSessions: {
+			{Keys: bson.D{{Key: "agent_id", Value: 1}, {Key: "tenant_id", Value: 1}, {Key: "last_activity_at", Value: -1}}},
+		},
 	}
 }
@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/xraph/grove"
@@ -9,6 +10,7 @@ import (
 	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/agent"
 	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/budget"
 	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/cognitive"
 	"github.com/xraph/cortex/communication"
@@ -18,6 +20,7 @@ import (
 	"github.com/xraph/cortex/perception"
 	"github.com/xraph/cortex/persona"
 	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
 	"github.com/xraph/cortex/skill"
 	"github.com/xraph/cortex/trait"
 )
@@ -46,6 +49,9 @@ type agentModel struct {
 	InlineSkills    []string       `grove:"inline_skills"      bson:"inline_skills,omitempty"`
 	InlineTraits    []string       `grove:"inline_traits"      bson:"inline_traits,omitempty"`
 	InlineBehaviors []string       `grove:"inline_behaviors"   bson:"inline_behaviors,omitempty"`
+	MaxTotalTokens  int            `grove:"max_total_tokens"   bson:"max_total_tokens"`
+	DailyBudget     int            `grove:"daily_token_budget" bson:"daily_token_budget"`
+	OutputSchema    string         `grove:"output_schema"      bson:"output_schema,omitempty"` // JSON; schemas use $-prefixed keys
 	CreatedAt       time.Time      `grove:"created_at"         bson:"created_at"`
 	UpdatedAt       time.Time      `grove:"updated_at"         bson:"updated_at"`
 }
@@ -70,6 +76,9 @@ func agentToModel(c *agent.Config) *agentModel {
 		InlineSkills:    c.InlineSkills,
 		InlineTraits:    c.InlineTraits,
 		InlineBehaviors: c.InlineBehaviors,
+		MaxTotalTokens:  c.MaxTotalTokens,
+		DailyBudget:     c.DailyTokenBudget,
+		OutputSchema:    outputSchemaJSON(c.OutputSchema),
 		CreatedAt:       c.CreatedAt,
 		UpdatedAt:       c.UpdatedAt,
 	}
@@ -80,27 +89,43 @@ func agentFromModel(m *agentModel) (*agent.Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &agent.Config{
-		Entity:          cortex.Entity{CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt},
-		ID:              agentID,
-		Name:            m.Name,
-		Description:     m.Description,
-		AppID:           m.AppID,
-		SystemPrompt:    m.SystemPrompt,
-		Model:           m.Model,
-		Tools:           m.Tools,
-		MaxSteps:        m.MaxSteps,
-		MaxTokens:       m.MaxTokens,
-		Temperature:     m.Temperature,
-		ReasoningLoop:   m.ReasoningLoop,
-		Guardrails:      m.Guardrails,
-		Metadata:        m.Metadata,
-		Enabled:         m.Enabled,
-		PersonaRef:      m.PersonaRef,
-		InlineSkills:    m.InlineSkills,
-		InlineTraits:    m.InlineTraits,
-		InlineBehaviors: m.InlineBehaviors,
-	}, nil
+	c := &agent.Config{
+		Entity:           cortex.Entity{CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt},
+		ID:               agentID,
+		Name:             m.Name,
+		Description:      m.Description,
+		AppID:            m.AppID,
+		SystemPrompt:     m.SystemPrompt,
+		Model:            m.Model,
+		Tools:            m.Tools,
+		MaxSteps:         m.MaxSteps,
+		MaxTokens:        m.MaxTokens,
+		Temperature:      m.Temperature,
+		ReasoningLoop:    m.ReasoningLoop,
+		Guardrails:       m.Guardrails,
+		Metadata:         m.Metadata,
+		Enabled:          m.Enabled,
+		PersonaRef:       m.PersonaRef,
+		InlineSkills:     m.InlineSkills,
+		InlineTraits:     m.InlineTraits,
+		InlineBehaviors:  m.InlineBehaviors,
+		MaxTotalTokens:   m.MaxTotalTokens,
+		DailyTokenBudget: m.DailyBudget,
+	}
+	if m.OutputSchema != "" {
+		if err := json.Unmarshal([]byte(m.OutputSchema), &c.OutputSchema); err != nil {
+			return nil, fmt.Errorf("unmarshal output_schema: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// outputSchemaJSON encodes an agent's output schema; empty when it has none.
+func outputSchemaJSON(schema map[string]any) string {
+	if schema == nil {
+		return ""
+	}
+	return mustJSON(schema)
 }
 
 // ──────────────────────────────────────────────────
@@ -112,6 +137,7 @@ type runModel struct {
 	ID              string         `grove:"id,pk"          bson:"_id"`
 	AgentID         string         `grove:"agent_id"       bson:"agent_id"`
 	TenantID        string         `grove:"tenant_id"      bson:"tenant_id"`
+	SessionID       string         `grove:"session_id"     bson:"session_id"`
 	State           string         `grove:"state"          bson:"state"`
 	Input           string         `grove:"input"          bson:"input"`
 	Output          string         `grove:"output"         bson:"output"`
@@ -121,6 +147,7 @@ type runModel struct {
 	StartedAt       *time.Time     `grove:"started_at"     bson:"started_at,omitempty"`
 	CompletedAt     *time.Time     `grove:"completed_at"   bson:"completed_at,omitempty"`
 	PersonaRef      string         `grove:"persona_ref"    bson:"persona_ref"`
+	LoopState       []byte         `grove:"loop_state"     bson:"loop_state,omitempty"`
 	Metadata        map[string]any `grove:"metadata"       bson:"metadata,omitempty"`
 	CreatedAt       time.Time      `grove:"created_at"     bson:"created_at"`
 	UpdatedAt       time.Time      `grove:"updated_at"     bson:"updated_at"`
@@ -131,6 +158,7 @@ func runToModel(r *run.Run) *runModel {
 		ID:          r.ID.String(),
 		AgentID:     r.AgentID.String(),
 		TenantID:    r.TenantID,
+		SessionID:   r.SessionID.String(),
 		State:       string(r.State),
 		Input:       r.Input,
 		Output:      r.Output,
@@ -140,6 +168,7 @@ func runToModel(r *run.Run) *runModel {
 		StartedAt:   r.StartedAt,
 		CompletedAt: r.CompletedAt,
 		PersonaRef:  r.PersonaRef,
+		LoopState:   r.LoopState,
 		Metadata:    r.Metadata,
 		CreatedAt:   r.CreatedAt,
 		UpdatedAt:   r.UpdatedAt,
@@ -155,7 +184,7 @@ func runFromModel(m *runModel) (*run.Run, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &run.Run{
+	r := &run.Run{
 		Entity:      cortex.Entity{CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt},
 		ID:          runID,
 		AgentID:     agentID,
@@ -169,8 +198,17 @@ func runFromModel(m *runModel) (*run.Run, error) {
 		StartedAt:   m.StartedAt,
 		CompletedAt: m.CompletedAt,
 		PersonaRef:  m.PersonaRef,
+		LoopState:   m.LoopState,
 		Metadata:    m.Metadata,
-	}, nil
+	}
+	if m.SessionID != "" {
+		sessionID, serr := id.ParseSessionID(m.SessionID)
+		if serr != nil {
+			return nil, serr
+		}
+		r.SessionID = sessionID
+	}
+	return r, nil
 }
 
 // ──────────────────────────────────────────────────
@@ -308,20 +346,24 @@ type memoryModel struct {
 	ID              string         `grove:"id,pk"          bson:"_id,omitempty"`
 	AgentID         string         `grove:"agent_id"       bson:"agent_id"`
 	TenantID        string         `grove:"tenant_id"      bson:"tenant_id"`
+	SessionID       string         `grove:"session_id"     bson:"session_id"`
 	Kind            string         `grove:"kind"           bson:"kind"`
 	Key             string         `grove:"key"            bson:"key"`
 	Content         string         `grove:"content"        bson:"content"`
 	Metadata        map[string]any `grove:"metadata"       bson:"metadata,omitempty"`
+	RunID           string         `grove:"run_id"         bson:"run_id,omitempty"`
+	Category        string         `grove:"category"       bson:"category,omitempty"`
 	CreatedAt       time.Time      `grove:"created_at"     bson:"created_at"`
 }
 
-func messageToModel(agentID, tenantID string, msg memory.Message) *memoryModel {
+func messageToModel(agentID, tenantID, sessionID string, msg memory.Message) *memoryModel {
 	return &memoryModel{
-		AgentID:  agentID,
-		TenantID: tenantID,
-		Kind:     "conversation",
-		Content:  mustJSON(msg),
-		Metadata: msg.Metadata,
+		AgentID:   agentID,
+		TenantID:  tenantID,
+		SessionID: sessionID,
+		Kind:      "conversation",
+		Content:   mustJSON(msg),
+		Metadata:  msg.Metadata,
 	}
 }
 
@@ -746,6 +788,74 @@ func orchestrationRunFromModel(m *orchestrationRunModel) (*orchestration.Run, er
 	return r, nil
 }
 
+// ──────────────────────────────────────────────────
+// Session model
+// ──────────────────────────────────────────────────
+
+type sessionModel struct {
+	grove.BaseModel `grove:"table:cortex_sessions"`
+	ID              string         `grove:"id,pk"            bson:"_id"`
+	AgentID         string         `grove:"agent_id"         bson:"agent_id"`
+	TenantID        string         `grove:"tenant_id"        bson:"tenant_id"`
+	Title           string         `grove:"title"            bson:"title"`
+	LastActivityAt  time.Time      `grove:"last_activity_at" bson:"last_activity_at"`
+	Metadata        map[string]any `grove:"metadata"         bson:"metadata,omitempty"`
+	CreatedAt       time.Time      `grove:"created_at"       bson:"created_at"`
+	UpdatedAt       time.Time      `grove:"updated_at"       bson:"updated_at"`
+}
+
+func sessionToModel(s *session.Session) *sessionModel {
+	return &sessionModel{
+		ID:             s.ID.String(),
+		AgentID:        s.AgentID.String(),
+		TenantID:       s.TenantID,
+		Title:          s.Title,
+		LastActivityAt: s.LastActivityAt.UTC(),
+		Metadata:       s.Metadata,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+func sessionFromModel(m *sessionModel) (*session.Session, error) {
+	sessID, err := id.ParseSessionID(m.ID)
+	if err != nil {
+		return nil, err
+	}
+	agentID, err := id.ParseAgentID(m.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	return &session.Session{
+		Entity:         cortex.Entity{CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt},
+		ID:             sessID,
+		AgentID:        agentID,
+		TenantID:       m.TenantID,
+		Title:          m.Title,
+		LastActivityAt: m.LastActivityAt,
+		Metadata:       m.Metadata,
+	}, nil
+}
+
+// ──────────────────────────────────────────────────
+// Budget usage model
+// ──────────────────────────────────────────────────
+
+type budgetUsageModel struct {
+	grove.BaseModel `grove:"table:cortex_budget_usage"`
+	ID              string    `grove:"id,pk"      bson:"_id"`
+	Scope           string    `grove:"scope"      bson:"scope"`
+	Key             string    `grove:"key"        bson:"key"`
+	Period          int64     `grove:"period"     bson:"period"`
+	Tokens          int64     `grove:"tokens"     bson:"tokens"`
+	UpdatedAt       time.Time `grove:"updated_at" bson:"updated_at"`
+}
+
+// budgetUsageID is the ID of the usage document of key in scope for period.
+func budgetUsageID(scope budget.Scope, key string, period time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", scope, key, period.Unix())
+}
+
 // ──────────────────────────────────────────────────
 // JSON helper
 // ──────────────────────────────────────────────────
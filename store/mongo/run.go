@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 
@@ -63,6 +64,51 @@ func (s *Store) UpdateRun(ctx context.Context, r *run.Run) error {
 	return nil
 }
 
+// UpdateRunInState updates a run while the stored run is in state and
+// reports whether it was.
+func (s *Store) UpdateRunInState(ctx context.Context, r *run.Run, state run.State) (bool, error) {
+	r.UpdatedAt = now()
+	m := runToModel(r)
+
+	res, err := s.mdb.NewUpdate(m).
+		Filter(bson.M{"_id": m.ID, "state": string(state)}).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/mongo: update run: %w", err)
+	}
+
+	return res.MatchedCount() > 0, nil
+}
+
+// TransitionRun atomically moves a run from one state to another and
+// reports whether the run was in the from state.
+func (s *Store) TransitionRun(ctx context.Context, runID id.AgentRunID, from, to run.State) (bool, error) {
+	res, err := s.mdb.NewUpdate((*runModel)(nil)).
+		Filter(bson.M{"_id": runID.String(), "state": string(from)}).
+		Set("state", string(to)).
+		Set("updated_at", now()).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/mongo: transition run: %w", err)
+	}
+
+	return res.MatchedCount() > 0, nil
+}
+
+// ClaimRun refreshes the update time of a run still in state and last
+// updated at updatedAt.
+func (s *Store) ClaimRun(ctx context.Context, runID id.AgentRunID, state run.State, updatedAt time.Time) (bool, error) {
+	res, err := s.mdb.NewUpdate((*runModel)(nil)).
+		Filter(bson.M{"_id": runID.String(), "state": string(state), "updated_at": updatedAt}).
+		Set("updated_at", now()).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/mongo: claim run: %w", err)
+	}
+
+	return res.MatchedCount() > 0, nil
+}
+
 // ListRuns returns runs, optionally filtered.
 func (s *Store) ListRuns(ctx context.Context, filter *run.ListFilter) ([]*run.Run, error) {
 	var models []runModel
@@ -82,9 +128,14 @@ func (s *Store) ListRuns(ctx context.Context, filter *run.ListFilter) ([]*run.Ru
 		}
 	}
 
+	order := -1
+	if filter != nil && filter.OldestFirst {
+		order = 1
+	}
+
 	q := s.mdb.NewFind(&models).
 		Filter(f).
-		Sort(bson.D{{Key: "created_at", Value: -1}})
+		Sort(bson.D{{Key: "created_at", Value: order}})
 
 	if filter != nil {
 		if filter.Limit > 0 {
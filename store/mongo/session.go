@@ -0,0 +1,128 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/session"
+)
+
+// CreateSession persists a new session.
+func (s *Store) CreateSession(ctx context.Context, sess *session.Session) error {
+	t := now()
+	sess.CreatedAt = t
+	sess.UpdatedAt = t
+	if sess.LastActivityAt.IsZero() {
+		sess.LastActivityAt = t
+	}
+	m := sessionToModel(sess)
+
+	_, err := s.mdb.NewInsert(m).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: create session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession returns a session by ID.
+func (s *Store) GetSession(ctx context.Context, sessionID id.SessionID) (*session.Session, error) {
+	var m sessionModel
+
+	err := s.mdb.NewFind(&m).
+		Filter(bson.M{"_id": sessionID.String()}).
+		Scan(ctx)
+	if err != nil {
+		if isNoDocuments(err) {
+			return nil, cortex.ErrSessionNotFound
+		}
+
+		return nil, fmt.Errorf("cortex/mongo: get session: %w", err)
+	}
+
+	return sessionFromModel(&m)
+}
+
+// TouchSession sets the last activity time of a session.
+func (s *Store) TouchSession(ctx context.Context, sessionID id.SessionID, at time.Time) error {
+	res, err := s.mdb.NewUpdate((*sessionModel)(nil)).
+		Filter(bson.M{"_id": sessionID.String()}).
+		Set("last_activity_at", at.UTC()).
+		Set("updated_at", now()).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: touch session: %w", err)
+	}
+
+	if res.MatchedCount() == 0 {
+		return cortex.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// ListSessions returns sessions, most recently active first.
+func (s *Store) ListSessions(ctx context.Context, filter *session.ListFilter) ([]*session.Session, error) {
+	var models []sessionModel
+
+	f := bson.M{}
+	if filter != nil {
+		if filter.AgentID != "" {
+			f["agent_id"] = filter.AgentID
+		}
+
+		if filter.TenantID != "" {
+			f["tenant_id"] = filter.TenantID
+		}
+	}
+
+	q := s.mdb.NewFind(&models).
+		Filter(f).
+		Sort(bson.D{{Key: "last_activity_at", Value: -1}})
+
+	if filter != nil {
+		if filter.Limit > 0 {
+			q = q.Limit(int64(filter.Limit))
+		}
+
+		if filter.Offset > 0 {
+			q = q.Skip(int64(filter.Offset))
+		}
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("cortex/mongo: list sessions: %w", err)
+	}
+
+	result := make([]*session.Session, len(models))
+	for i := range models {
+		sess, convErr := sessionFromModel(&models[i])
+		if convErr != nil {
+			return nil, convErr
+		}
+		result[i] = sess
+	}
+
+	return result, nil
+}
+
+// DeleteSession removes a session.
+func (s *Store) DeleteSession(ctx context.Context, sessionID id.SessionID) error {
+	res, err := s.mdb.NewDelete((*sessionModel)(nil)).
+		Filter(bson.M{"_id": sessionID.String()}).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/mongo: delete session: %w", err)
+	}
+
+	if res.DeletedCount() == 0 {
+		return cortex.ErrSessionNotFound
+	}
+
+	return nil
+}
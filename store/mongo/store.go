@@ -28,6 +28,8 @@ const (
 	colPersonas             = "cortex_personas"
 	colOrchestrationConfigs = "cortex_orchestration_configs"
 	colOrchestrationRuns    = "cortex_orchestration_runs"
+	colBudgetUsage          = "cortex_budget_usage"
+	colSessions             = "cortex_sessions"
 )
 
 // Compile-time interface check.
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xraph/cortex/budget"
+)
+
+func (s *Store) AddUsage(ctx context.Context, scope budget.Scope, key string, period time.Time, tokens int64) error {
+	m := &budgetUsageModel{
+		ID:        budgetUsageID(scope, key, period),
+		Scope:     string(scope),
+		Key:       key,
+		Period:    period.Unix(),
+		Tokens:    tokens,
+		UpdatedAt: time.Now().UTC(),
+	}
+	_, err := s.pgdb.NewInsert(m).
+		OnConflict("(id) DO UPDATE").
+		Set("tokens = cortex_budget_usage.tokens + EXCLUDED.tokens").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex: add budget usage: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) SumUsage(ctx context.Context, scope budget.Scope, key string, since time.Time) (int64, error) {
+	var models []budgetUsageModel
+	err := s.pgdb.NewSelect(&models).
+		Where("scope = ?", string(scope)).
+		Where("\"key\" = ?", key).
+		Where("period >= ?", since.Unix()).
+		Scan(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cortex: sum budget usage: %w", err)
+	}
+	var total int64
+	for i := range models {
+		total += models[i].Tokens
+	}
+	return total, nil
+}
@@ -37,26 +37,37 @@ func (s *Store) GetCheckpoint(ctx context.Context, cpID id.CheckpointID) (*check
 	return checkpointFromModel(m)
 }
 
-func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) error {
+func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) (bool, error) {
 	decisionJSON, err := json.Marshal(decision)
 	if err != nil {
-		return fmt.Errorf("cortex: marshal decision: %w", err)
+		return false, fmt.Errorf("cortex: marshal decision: %w", err)
 	}
 	res, err := s.pgdb.NewUpdate((*checkpointModel)(nil)).
-		Set("state = ?", "resolved").
+		Set("state = ?", checkpoint.StateResolved).
 		Set("decision = ?", string(decisionJSON)).
 		Set("updated_at = ?", time.Now().UTC()).
 		Where("id = ?", cpID.String()).
+		Where("state = ?", checkpoint.StatePending).
 		Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("cortex: resolve checkpoint: %w", err)
+		return false, fmt.Errorf("cortex: resolve checkpoint: %w", err)
 	}
 	n, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("cortex: resolve checkpoint rows affected: %w", err)
+		return false, fmt.Errorf("cortex: resolve checkpoint rows affected: %w", err)
 	}
-	if n == 0 {
-		return cortex.ErrCheckpointNotFound
+	return n > 0, nil
+}
+
+func (s *Store) ExpirePending(ctx context.Context, runID id.AgentRunID) error {
+	_, err := s.pgdb.NewUpdate((*checkpointModel)(nil)).
+		Set("state = ?", checkpoint.StateExpired).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("run_id = ?", runID.String()).
+		Where("state = ?", checkpoint.StatePending).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex: expire checkpoints: %w", err)
 	}
 	return nil
 }
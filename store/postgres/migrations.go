@@ -351,6 +351,133 @@ DROP TABLE IF EXISTS cortex_orchestration_configs;
 				return err
 			},
 		},
+		&migrate.Migration{
+			Name:    "create_budgets",
+			Version: "20240101000010",
+			Comment: "Add agent token budgets and create cortex_budget_usage table",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+ALTER TABLE cortex_agents ADD COLUMN IF NOT EXISTS max_total_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cortex_agents ADD COLUMN IF NOT EXISTS daily_token_budget INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS cortex_budget_usage (
+    id          TEXT PRIMARY KEY,
+    scope       TEXT NOT NULL,
+    "key"       TEXT NOT NULL,
+    period      BIGINT NOT NULL,
+    tokens      BIGINT NOT NULL DEFAULT 0,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cortex_budget_usage_scope_key ON cortex_budget_usage (scope, "key", period);
+`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+DROP TABLE IF EXISTS cortex_budget_usage;
+ALTER TABLE cortex_agents DROP COLUMN IF EXISTS daily_token_budget;
+ALTER TABLE cortex_agents DROP COLUMN IF EXISTS max_total_tokens;
+`)
+				return err
+			},
+		},
+		&migrate.Migration{
+			Name:    "create_sessions",
+			Version: "20240101000011",
+			Comment: "Create cortex_sessions table and add session_id to runs and memories",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS cortex_sessions (
+    id                TEXT PRIMARY KEY,
+    agent_id          TEXT NOT NULL REFERENCES cortex_agents(id) ON DELETE CASCADE,
+    tenant_id         TEXT NOT NULL DEFAULT '',
+    title             TEXT NOT NULL DEFAULT '',
+    last_activity_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    metadata          JSONB DEFAULT '{}',
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cortex_sessions_agent_tenant ON cortex_sessions (agent_id, tenant_id, last_activity_at);
+
+ALTER TABLE cortex_runs ADD COLUMN IF NOT EXISTS session_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE cortex_memories ADD COLUMN IF NOT EXISTS session_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_cortex_memories_session ON cortex_memories (agent_id, tenant_id, session_id);
+`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+DROP INDEX IF EXISTS idx_cortex_memories_session;
+ALTER TABLE cortex_memories DROP COLUMN IF EXISTS session_id;
+ALTER TABLE cortex_runs DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS cortex_sessions CASCADE;
+`)
+				return err
+			},
+		},
+		&migrate.Migration{
+			Name:    "create_fact_memory_index",
+			Version: "20240101000012",
+			Comment: "Add a unique index on fact keys to cortex_memories",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cortex_memories_fact ON cortex_memories (agent_id, tenant_id, key) WHERE kind = 'fact';
+`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `DROP INDEX IF EXISTS idx_cortex_memories_fact`)
+				return err
+			},
+		},
+		&migrate.Migration{
+			Name:    "add_agent_output_schema",
+			Version: "20240101000013",
+			Comment: "Add output_schema to cortex_agents",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `ALTER TABLE cortex_agents ADD COLUMN IF NOT EXISTS output_schema JSONB NOT NULL DEFAULT 'null'`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `ALTER TABLE cortex_agents DROP COLUMN IF EXISTS output_schema`)
+				return err
+			},
+		},
+		&migrate.Migration{
+			Name:    "add_fact_run_category",
+			Version: "20240101000014",
+			Comment: "Add run_id and category to cortex_memories",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+ALTER TABLE cortex_memories ADD COLUMN IF NOT EXISTS run_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE cortex_memories ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `
+ALTER TABLE cortex_memories DROP COLUMN IF EXISTS category;
+ALTER TABLE cortex_memories DROP COLUMN IF EXISTS run_id;
+`)
+				return err
+			},
+		},
+		&migrate.Migration{
+			Name:    "add_run_loop_state",
+			Version: "20240101000015",
+			Comment: "Add loop_state to cortex_runs",
+			Up: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `ALTER TABLE cortex_runs ADD COLUMN IF NOT EXISTS loop_state TEXT NOT NULL DEFAULT ''`)
+				return err
+			},
+			Down: func(ctx context.Context, exec migrate.Executor) error {
+				_, err := exec.Exec(ctx, `ALTER TABLE cortex_runs DROP COLUMN IF EXISTS loop_state`)
+				return err
+			},
+		},
 	)
 	return g
 }()
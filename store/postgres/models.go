@@ -10,12 +10,14 @@ import (
 	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/agent"
 	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/budget"
 	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/id"
 	"github.com/xraph/cortex/memory"
 	"github.com/xraph/cortex/orchestration"
 	"github.com/xraph/cortex/persona"
 	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
 	"github.com/xraph/cortex/skill"
 	"github.com/xraph/cortex/trait"
 )
@@ -55,6 +57,9 @@ type agentModel struct {
 	InlineSkills    string    `grove:"inline_skills,type:jsonb"`
 	InlineTraits    string    `grove:"inline_traits,type:jsonb"`
 	InlineBehaviors string    `grove:"inline_behaviors,type:jsonb"`
+	MaxTotalTokens  int       `grove:"max_total_tokens"`
+	DailyBudget     int       `grove:"daily_token_budget"`
+	OutputSchema    string    `grove:"output_schema,type:jsonb"`
 	CreatedAt       time.Time `grove:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt       time.Time `grove:"updated_at,notnull,default:current_timestamp"`
 }
@@ -79,6 +84,9 @@ func agentToModel(c *agent.Config) *agentModel {
 		InlineSkills:    mustJSON(c.InlineSkills),
 		InlineTraits:    mustJSON(c.InlineTraits),
 		InlineBehaviors: mustJSON(c.InlineBehaviors),
+		MaxTotalTokens:  c.MaxTotalTokens,
+		DailyBudget:     c.DailyTokenBudget,
+		OutputSchema:    mustJSON(c.OutputSchema),
 		CreatedAt:       c.CreatedAt,
 		UpdatedAt:       c.UpdatedAt,
 	}
@@ -103,6 +111,9 @@ func agentFromModel(m *agentModel) (*agent.Config, error) {
 		ReasoningLoop: m.ReasoningLoop,
 		Enabled:       m.Enabled,
 		PersonaRef:    m.PersonaRef,
+
+		MaxTotalTokens:   m.MaxTotalTokens,
+		DailyTokenBudget: m.DailyBudget,
 	}
 	for _, f := range []struct {
 		name string
@@ -115,6 +126,7 @@ func agentFromModel(m *agentModel) (*agent.Config, error) {
 		{"inline_skills", m.InlineSkills, &c.InlineSkills},
 		{"inline_traits", m.InlineTraits, &c.InlineTraits},
 		{"inline_behaviors", m.InlineBehaviors, &c.InlineBehaviors},
+		{"output_schema", m.OutputSchema, &c.OutputSchema},
 	} {
 		if err := unmarshalField(f.name, f.data, f.dest); err != nil {
 			return nil, err
@@ -404,6 +416,7 @@ type runModel struct {
 	ID              string     `grove:"id,pk"`
 	AgentID         string     `grove:"agent_id,notnull"`
 	TenantID        string     `grove:"tenant_id"`
+	SessionID       string     `grove:"session_id"`
 	State           string     `grove:"state,notnull"`
 	Input           string     `grove:"input"`
 	Output          string     `grove:"output"`
@@ -413,6 +426,7 @@ type runModel struct {
 	StartedAt       *time.Time `grove:"started_at"`
 	CompletedAt     *time.Time `grove:"completed_at"`
 	PersonaRef      string     `grove:"persona_ref"`
+	LoopState       string     `grove:"loop_state"`
 	Metadata        string     `grove:"metadata,type:jsonb"`
 	CreatedAt       time.Time  `grove:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt       time.Time  `grove:"updated_at,notnull,default:current_timestamp"`
@@ -423,6 +437,7 @@ func runToModel(r *run.Run) *runModel {
 		ID:          r.ID.String(),
 		AgentID:     r.AgentID.String(),
 		TenantID:    r.TenantID,
+		SessionID:   r.SessionID.String(),
 		State:       string(r.State),
 		Input:       r.Input,
 		Output:      r.Output,
@@ -432,6 +447,7 @@ func runToModel(r *run.Run) *runModel {
 		StartedAt:   r.StartedAt,
 		CompletedAt: r.CompletedAt,
 		PersonaRef:  r.PersonaRef,
+		LoopState:   string(r.LoopState),
 		Metadata:    mustJSON(r.Metadata),
 		CreatedAt:   r.CreatedAt,
 		UpdatedAt:   r.UpdatedAt,
@@ -462,6 +478,16 @@ func runFromModel(m *runModel) (*run.Run, error) {
 		CompletedAt: m.CompletedAt,
 		PersonaRef:  m.PersonaRef,
 	}
+	if m.LoopState != "" {
+		r.LoopState = []byte(m.LoopState)
+	}
+	if m.SessionID != "" {
+		sessionID, serr := id.ParseSessionID(m.SessionID)
+		if serr != nil {
+			return nil, serr
+		}
+		r.SessionID = sessionID
+	}
 	if err := unmarshalField("metadata", m.Metadata, &r.Metadata); err != nil {
 		return nil, err
 	}
@@ -609,20 +635,38 @@ type memoryModel struct {
 	ID              int64     `grove:"id,pk,autoincrement"`
 	AgentID         string    `grove:"agent_id,notnull"`
 	TenantID        string    `grove:"tenant_id"`
+	SessionID       string    `grove:"session_id"`
 	Kind            string    `grove:"kind,notnull"`
 	Key             string    `grove:"key"`
 	Content         string    `grove:"content,notnull"`
 	Metadata        string    `grove:"metadata,type:jsonb"`
+	RunID           string    `grove:"run_id"`
+	Category        string    `grove:"category"`
 	CreatedAt       time.Time `grove:"created_at,notnull,default:current_timestamp"`
 }
 
-func messageToModel(agentID, tenantID string, msg memory.Message) *memoryModel {
+func messageToModel(agentID, tenantID, sessionID string, msg memory.Message) *memoryModel {
+	return &memoryModel{
+		AgentID:   agentID,
+		TenantID:  tenantID,
+		SessionID: sessionID,
+		Kind:      "conversation",
+		Content:   mustJSON(msg),
+		Metadata:  mustJSON(msg.Metadata),
+	}
+}
+
+func factToModel(agentID, tenantID string, fact *memory.Fact) *memoryModel {
 	return &memoryModel{
-		AgentID:  agentID,
-		TenantID: tenantID,
-		Kind:     "conversation",
-		Content:  mustJSON(msg),
-		Metadata: mustJSON(msg.Metadata),
+		AgentID:   agentID,
+		TenantID:  tenantID,
+		Kind:      "fact",
+		Key:       fact.Key,
+		Content:   mustJSON(fact),
+		Metadata:  "{}",
+		RunID:     fact.RunID.String(),
+		Category:  string(fact.Category),
+		CreatedAt: fact.UpdatedAt,
 	}
 }
 
@@ -693,6 +737,77 @@ func checkpointFromModel(m *checkpointModel) (*checkpoint.Checkpoint, error) {
 	return cp, nil
 }
 
+// ──────────────────────────────────────────────────
+// Session model
+// ──────────────────────────────────────────────────
+
+type sessionModel struct {
+	grove.BaseModel `grove:"table:cortex_sessions"`
+	ID              string    `grove:"id,pk"`
+	AgentID         string    `grove:"agent_id,notnull"`
+	TenantID        string    `grove:"tenant_id"`
+	Title           string    `grove:"title"`
+	LastActivityAt  time.Time `grove:"last_activity_at,notnull,default:current_timestamp"`
+	Metadata        string    `grove:"metadata,type:jsonb"`
+	CreatedAt       time.Time `grove:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt       time.Time `grove:"updated_at,notnull,default:current_timestamp"`
+}
+
+func sessionToModel(s *session.Session) *sessionModel {
+	return &sessionModel{
+		ID:             s.ID.String(),
+		AgentID:        s.AgentID.String(),
+		TenantID:       s.TenantID,
+		Title:          s.Title,
+		LastActivityAt: s.LastActivityAt.UTC(),
+		Metadata:       mustJSON(s.Metadata),
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+func sessionFromModel(m *sessionModel) (*session.Session, error) {
+	sessID, err := id.ParseSessionID(m.ID)
+	if err != nil {
+		return nil, err
+	}
+	agentID, err := id.ParseAgentID(m.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	s := &session.Session{
+		Entity:         cortex.Entity{CreatedAt: m.CreatedAt, UpdatedAt: m.UpdatedAt},
+		ID:             sessID,
+		AgentID:        agentID,
+		TenantID:       m.TenantID,
+		Title:          m.Title,
+		LastActivityAt: m.LastActivityAt,
+	}
+	if err := unmarshalField("metadata", m.Metadata, &s.Metadata); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ──────────────────────────────────────────────────
+// Budget usage model
+// ──────────────────────────────────────────────────
+
+type budgetUsageModel struct {
+	grove.BaseModel `grove:"table:cortex_budget_usage"`
+	ID              string    `grove:"id,pk"`
+	Scope           string    `grove:"scope,notnull"`
+	Key             string    `grove:"key,notnull"`
+	Period          int64     `grove:"period,notnull"`
+	Tokens          int64     `grove:"tokens"`
+	UpdatedAt       time.Time `grove:"updated_at"`
+}
+
+// budgetUsageID is the ID of the usage row of key in scope for period.
+func budgetUsageID(scope budget.Scope, key string, period time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", scope, key, period.Unix())
+}
+
 // ──────────────────────────────────────────────────
 // JSON helper
 // ──────────────────────────────────────────────────
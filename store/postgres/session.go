@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xraph/cortex"
+	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/session"
+)
+
+func (s *Store) CreateSession(ctx context.Context, sess *session.Session) error {
+	now := time.Now().UTC()
+	sess.CreatedAt = now
+	sess.UpdatedAt = now
+	if sess.LastActivityAt.IsZero() {
+		sess.LastActivityAt = now
+	}
+	m := sessionToModel(sess)
+	_, err := s.pgdb.NewInsert(m).Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex: create session: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetSession(ctx context.Context, sessionID id.SessionID) (*session.Session, error) {
+	m := new(sessionModel)
+	err := s.pgdb.NewSelect(m).Where("id = ?", sessionID.String()).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, cortex.ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("cortex: get session: %w", err)
+	}
+	return sessionFromModel(m)
+}
+
+func (s *Store) TouchSession(ctx context.Context, sessionID id.SessionID, at time.Time) error {
+	res, err := s.pgdb.NewUpdate((*sessionModel)(nil)).
+		Set("last_activity_at = ?", at.UTC()).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ?", sessionID.String()).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex: touch session: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("cortex: touch session rows affected: %w", rowsErr)
+	}
+	if n == 0 {
+		return cortex.ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *Store) ListSessions(ctx context.Context, filter *session.ListFilter) ([]*session.Session, error) {
+	var models []sessionModel
+	q := s.pgdb.NewSelect(&models).OrderExpr("last_activity_at DESC")
+	if filter != nil {
+		if filter.AgentID != "" {
+			q = q.Where("agent_id = ?", filter.AgentID)
+		}
+		if filter.TenantID != "" {
+			q = q.Where("tenant_id = ?", filter.TenantID)
+		}
+		if filter.Limit > 0 {
+			q = q.Limit(filter.Limit)
+		}
+		if filter.Offset > 0 {
+			q = q.Offset(filter.Offset)
+		}
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("cortex: list sessions: %w", err)
+	}
+	result := make([]*session.Session, len(models))
+	for i := range models {
+		sess, err := sessionFromModel(&models[i])
+		if err != nil {
+			return nil, err
+		}
+		result[i] = sess
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteSession(ctx context.Context, sessionID id.SessionID) error {
+	res, err := s.pgdb.NewDelete((*sessionModel)(nil)).
+		Where("id = ?", sessionID.String()).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex: delete session: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("cortex: delete session rows affected: %w", rowsErr)
+	}
+	if n == 0 {
+		return cortex.ErrSessionNotFound
+	}
+	return nil
+}
@@ -35,26 +35,37 @@ func (s *Store) GetCheckpoint(ctx context.Context, cpID id.CheckpointID) (*check
 	return checkpointFromModel(m)
 }
 
-func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) error {
+func (s *Store) Resolve(ctx context.Context, cpID id.CheckpointID, decision checkpoint.Decision) (bool, error) {
 	decisionJSON, marshalErr := json.Marshal(decision)
 	if marshalErr != nil {
-		return fmt.Errorf("cortex/sqlite: marshal decision: %w", marshalErr)
+		return false, fmt.Errorf("cortex/sqlite: marshal decision: %w", marshalErr)
 	}
 	res, err := s.sdb.NewUpdate((*checkpointModel)(nil)).
-		Set("state = ?", "resolved").
+		Set("state = ?", checkpoint.StateResolved).
 		Set("decision = ?", string(decisionJSON)).
 		Set("updated_at = ?", time.Now().UTC()).
 		Where("id = ?", cpID.String()).
+		Where("state = ?", checkpoint.StatePending).
 		Exec(ctx)
 	if err != nil {
-		return fmt.Errorf("cortex/sqlite: resolve checkpoint: %w", err)
+		return false, fmt.Errorf("cortex/sqlite: resolve checkpoint: %w", err)
 	}
 	n, rowsErr := res.RowsAffected()
 	if rowsErr != nil {
-		return fmt.Errorf("cortex/sqlite: resolve checkpoint rows affected: %w", rowsErr)
+		return false, fmt.Errorf("cortex/sqlite: resolve checkpoint rows affected: %w", rowsErr)
 	}
-	if n == 0 {
-		return cortex.ErrCheckpointNotFound
+	return n > 0, nil
+}
+
+func (s *Store) ExpirePending(ctx context.Context, runID id.AgentRunID) error {
+	_, err := s.sdb.NewUpdate((*checkpointModel)(nil)).
+		Set("state = ?", checkpoint.StateExpired).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("run_id = ?", runID.String()).
+		Where("state = ?", checkpoint.StatePending).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/sqlite: expire checkpoints: %w", err)
 	}
 	return nil
 }
@@ -4,18 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"time"
 
+	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/id"
 	"github.com/xraph/cortex/memory"
 )
 
-func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, messages []memory.Message) error {
+func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, messages []memory.Message) error {
 	if len(messages) == 0 {
 		return nil
 	}
 	models := make([]memoryModel, len(messages))
 	for i, msg := range messages {
-		models[i] = *messageToModel(agentID.String(), tenantID, msg)
+		models[i] = *messageToModel(agentID.String(), tenantID, sessionID.String(), msg)
 	}
 	_, err := s.sdb.NewInsert(&models).Exec(ctx)
 	if err != nil {
@@ -24,15 +27,17 @@ func (s *Store) SaveConversation(ctx context.Context, agentID id.AgentID, tenant
 	return nil
 }
 
-func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, limit int) ([]memory.Message, error) {
+func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, limit int) ([]memory.Message, error) {
 	var models []memoryModel
 	q := s.sdb.NewSelect(&models).
 		Where("agent_id = ?", agentID.String()).
 		Where("tenant_id = ?", tenantID).
-		Where("kind = ?", "conversation").
-		OrderExpr("created_at ASC")
+		Where("session_id = ?", sessionID.String()).
+		Where("kind = ?", "conversation")
 	if limit > 0 {
-		q = q.Limit(limit)
+		q = q.OrderExpr("id DESC").Limit(limit)
+	} else {
+		q = q.OrderExpr("id ASC")
 	}
 	if err := q.Scan(ctx); err != nil {
 		return nil, fmt.Errorf("cortex/sqlite: load conversation: %w", err)
@@ -44,14 +49,18 @@ func (s *Store) LoadConversation(ctx context.Context, agentID id.AgentID, tenant
 			messages = append(messages, msg)
 		}
 	}
+	if limit > 0 {
+		slices.Reverse(messages)
+	}
 	return messages, nil
 }
 
-func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string) error {
+func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) error {
 	_, err := s.sdb.NewDelete((*memoryModel)(nil)).
 		Where("agent_id = ?", agentID.String()).
 		Where("tenant_id = ?", tenantID).
-		Where("kind = ?", "conversation").
+		Where("session_id = ?", sessionID.String()).
+		Where("kind IN (?, ?)", "conversation", "summary").
 		Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("cortex/sqlite: clear conversation: %w", err)
@@ -59,6 +68,22 @@ func (s *Store) ClearConversation(ctx context.Context, agentID id.AgentID, tenan
 	return nil
 }
 
+func (s *Store) TrimConversation(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	_, err := s.sdb.NewDelete((*memoryModel)(nil)).
+		Where(`id IN (SELECT id FROM cortex_memories
+			WHERE agent_id = ? AND tenant_id = ? AND session_id = ? AND kind = ?
+			ORDER BY id ASC LIMIT ?)`,
+			agentID.String(), tenantID, sessionID.String(), "conversation", n).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/sqlite: trim conversation: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) SaveWorking(ctx context.Context, runID id.AgentRunID, key string, value any) error {
 	m := &memoryModel{
 		AgentID: runID.String(),
@@ -67,7 +92,7 @@ func (s *Store) SaveWorking(ctx context.Context, runID id.AgentRunID, key string
 		Content: mustJSON(value),
 	}
 	_, err := s.sdb.NewInsert(m).
-		OnConflict("(agent_id, kind, key) DO UPDATE").
+		OnConflict("(agent_id, kind, key) WHERE kind = 'working' DO UPDATE").
 		Set("content = EXCLUDED.content").
 		Exec(ctx)
 	if err != nil {
@@ -84,6 +109,9 @@ func (s *Store) LoadWorking(ctx context.Context, runID id.AgentRunID, key string
 		Where("\"key\" = ?", key).
 		Scan(ctx)
 	if err != nil {
+		if isNoRows(err) {
+			return nil, cortex.ErrWorkingMemoryNotFound
+		}
 		return nil, fmt.Errorf("cortex/sqlite: load working memory: %w", err)
 	}
 	var v any
@@ -93,6 +121,26 @@ func (s *Store) LoadWorking(ctx context.Context, runID id.AgentRunID, key string
 	return v, nil
 }
 
+func (s *Store) ListWorking(ctx context.Context, runID id.AgentRunID) (map[string]any, error) {
+	var models []memoryModel
+	err := s.sdb.NewSelect(&models).
+		Where("agent_id = ?", runID.String()).
+		Where("kind = ?", "working").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cortex/sqlite: list working memory: %w", err)
+	}
+	values := make(map[string]any, len(models))
+	for _, m := range models {
+		var v any
+		if err := json.Unmarshal([]byte(m.Content), &v); err != nil {
+			return nil, fmt.Errorf("cortex/sqlite: unmarshal working memory: %w", err)
+		}
+		values[m.Key] = v
+	}
+	return values, nil
+}
+
 func (s *Store) ClearWorking(ctx context.Context, runID id.AgentRunID) error {
 	_, err := s.sdb.NewDelete((*memoryModel)(nil)).
 		Where("agent_id = ?", runID.String()).
@@ -104,12 +152,13 @@ func (s *Store) ClearWorking(ctx context.Context, runID id.AgentRunID) error {
 	return nil
 }
 
-func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID, summary string) error {
+func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID, summary string) error {
 	m := &memoryModel{
-		AgentID:  agentID.String(),
-		TenantID: tenantID,
-		Kind:     "summary",
-		Content:  summary,
+		AgentID:   agentID.String(),
+		TenantID:  tenantID,
+		SessionID: sessionID.String(),
+		Kind:      "summary",
+		Content:   summary,
 	}
 	_, err := s.sdb.NewInsert(m).Exec(ctx)
 	if err != nil {
@@ -118,13 +167,14 @@ func (s *Store) SaveSummary(ctx context.Context, agentID id.AgentID, tenantID, s
 	return nil
 }
 
-func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string) ([]string, error) {
+func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID string, sessionID id.SessionID) ([]string, error) {
 	var models []memoryModel
 	err := s.sdb.NewSelect(&models).
 		Where("agent_id = ?", agentID.String()).
 		Where("tenant_id = ?", tenantID).
+		Where("session_id = ?", sessionID.String()).
 		Where("kind = ?", "summary").
-		OrderExpr("created_at ASC").
+		OrderExpr("id ASC").
 		Scan(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("cortex/sqlite: load summaries: %w", err)
@@ -135,3 +185,71 @@ func (s *Store) LoadSummaries(ctx context.Context, agentID id.AgentID, tenantID
 	}
 	return summaries, nil
 }
+
+func (s *Store) SaveFact(ctx context.Context, agentID id.AgentID, tenantID string, fact *memory.Fact) error {
+	fact.UpdatedAt = time.Now().UTC()
+	_, err := s.sdb.NewInsert(factToModel(agentID.String(), tenantID, fact)).
+		OnConflict("(agent_id, tenant_id, key) WHERE kind = 'fact' DO UPDATE").
+		Set("content = EXCLUDED.content").
+		Set("run_id = EXCLUDED.run_id").
+		Set("category = EXCLUDED.category").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/sqlite: save fact: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) ListFacts(ctx context.Context, agentID id.AgentID, tenantID string) ([]memory.Fact, error) {
+	var models []memoryModel
+	err := s.sdb.NewSelect(&models).
+		Where("agent_id = ?", agentID.String()).
+		Where("tenant_id = ?", tenantID).
+		Where("kind = ?", "fact").
+		OrderExpr("created_at DESC, id DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cortex/sqlite: list facts: %w", err)
+	}
+	facts := make([]memory.Fact, 0, len(models))
+	for _, m := range models {
+		var f memory.Fact
+		if err := json.Unmarshal([]byte(m.Content), &f); err == nil {
+			facts = append(facts, f)
+		}
+	}
+	return facts, nil
+}
+
+func (s *Store) DeleteFact(ctx context.Context, agentID id.AgentID, tenantID, key string) error {
+	res, err := s.sdb.NewDelete((*memoryModel)(nil)).
+		Where("agent_id = ?", agentID.String()).
+		Where("tenant_id = ?", tenantID).
+		Where("kind = ?", "fact").
+		Where("\"key\" = ?", key).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/sqlite: delete fact: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("cortex/sqlite: delete fact rows affected: %w", rowsErr)
+	}
+	if n == 0 {
+		return cortex.ErrFactNotFound
+	}
+	return nil
+}
+
+func (s *Store) ClearFacts(ctx context.Context, agentID id.AgentID, tenantID string) error {
+	_, err := s.sdb.NewDelete((*memoryModel)(nil)).
+		Where("agent_id = ?", agentID.String()).
+		Where("tenant_id = ?", tenantID).
+		Where("kind = ?", "fact").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("cortex/sqlite: clear facts: %w", err)
+	}
+	return nil
+}
@@ -51,9 +51,62 @@ func (s *Store) UpdateRun(ctx context.Context, r *run.Run) error {
 	return nil
 }
 
+func (s *Store) UpdateRunInState(ctx context.Context, r *run.Run, state run.State) (bool, error) {
+	r.UpdatedAt = time.Now().UTC()
+	m := runToModel(r)
+	res, err := s.sdb.NewUpdate(m).WherePK().Where("state = ?", string(state)).Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/sqlite: update run: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return false, fmt.Errorf("cortex/sqlite: update run rows affected: %w", rowsErr)
+	}
+	return n > 0, nil
+}
+
+func (s *Store) TransitionRun(ctx context.Context, runID id.AgentRunID, from, to run.State) (bool, error) {
+	res, err := s.sdb.NewUpdate((*runModel)(nil)).
+		Set("state = ?", string(to)).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ?", runID.String()).
+		Where("state = ?", string(from)).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/sqlite: transition run: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return false, fmt.Errorf("cortex/sqlite: transition run rows affected: %w", rowsErr)
+	}
+	return n > 0, nil
+}
+
+func (s *Store) ClaimRun(ctx context.Context, runID id.AgentRunID, state run.State, updatedAt time.Time) (bool, error) {
+	res, err := s.sdb.NewUpdate((*runModel)(nil)).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ?", runID.String()).
+		Where("state = ?", string(state)).
+		Where("updated_at = ?", updatedAt).
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cortex/sqlite: claim run: %w", err)
+	}
+	n, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return false, fmt.Errorf("cortex/sqlite: claim run rows affected: %w", rowsErr)
+	}
+	return n > 0, nil
+}
+
 func (s *Store) ListRuns(ctx context.Context, filter *run.ListFilter) ([]*run.Run, error) {
 	var models []runModel
-	q := s.sdb.NewSelect(&models).OrderExpr("created_at DESC")
+	q := s.sdb.NewSelect(&models)
+	if filter != nil && filter.OldestFirst {
+		q = q.OrderExpr("created_at ASC")
+	} else {
+		q = q.OrderExpr("created_at DESC")
+	}
 	if filter != nil {
 		if filter.AgentID != "" {
 			q = q.Where("agent_id = ?", filter.AgentID)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/xraph/grove"
 	"github.com/xraph/grove/drivers/sqlitedriver"
@@ -12,8 +13,13 @@ import (
 
 	"github.com/xraph/cortex"
 	"github.com/xraph/cortex/agent"
+	"github.com/xraph/cortex/budget"
+	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/id"
+	"github.com/xraph/cortex/memory"
 	"github.com/xraph/cortex/persona"
+	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
 )
 
 // newTestStore opens a migrated SQLite store backed by a temporary file.
@@ -69,3 +75,326 @@ func TestCreatePersonaDuplicateReturnsAlreadyExists(t *testing.T) {
 		t.Fatalf("duplicate create err = %v, want ErrAlreadyExists", err)
 	}
 }
+
+func TestTransitionRunOnlyFromExpectedState(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	r := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: id.NewAgentID(), State: run.StateCreated}
+	if err := s.CreateRun(ctx, r); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+
+	ok, err := s.TransitionRun(ctx, r.ID, run.StateCreated, run.StateRunning)
+	if err != nil || !ok {
+		t.Fatalf("first transition = %v, %v; want true", ok, err)
+	}
+	// A second claim of the same created run must lose.
+	ok, err = s.TransitionRun(ctx, r.ID, run.StateCreated, run.StateRunning)
+	if err != nil || ok {
+		t.Fatalf("second transition = %v, %v; want false", ok, err)
+	}
+	got, err := s.GetRun(ctx, r.ID)
+	if err != nil || got.State != run.StateRunning {
+		t.Fatalf("stored run = %+v, %v; want running", got, err)
+	}
+}
+
+func TestUpdateRunInStateKeepsConcurrentCancel(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	r := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: id.NewAgentID(), State: run.StateRunning}
+	if err := s.CreateRun(ctx, r); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+
+	r.StepCount = 1
+	ok, err := s.UpdateRunInState(ctx, r, run.StateRunning)
+	if err != nil || !ok {
+		t.Fatalf("update while running = %v, %v; want true", ok, err)
+	}
+	if _, err := s.TransitionRun(ctx, r.ID, run.StateRunning, run.StateCancelled); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	r.StepCount = 2
+	ok, err = s.UpdateRunInState(ctx, r, run.StateRunning)
+	if err != nil || ok {
+		t.Fatalf("update after cancel = %v, %v; want false", ok, err)
+	}
+	got, err := s.GetRun(ctx, r.ID)
+	if err != nil || got.State != run.StateCancelled || got.StepCount != 1 {
+		t.Fatalf("stored run = %+v, %v; want cancelled after 1 step", got, err)
+	}
+}
+
+func TestClaimRunOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	r := &run.Run{Entity: cortex.NewEntity(), ID: id.NewAgentRunID(), AgentID: id.NewAgentID(), State: run.StateRunning}
+	if err := s.CreateRun(ctx, r); err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+	listed, err := s.GetRun(ctx, r.ID)
+	if err != nil {
+		t.Fatalf("get run: %v", err)
+	}
+
+	ok, err := s.ClaimRun(ctx, r.ID, run.StateRunning, listed.UpdatedAt)
+	if err != nil || !ok {
+		t.Fatalf("first claim = %v, %v; want true", ok, err)
+	}
+	// A second process that listed the run at the same time must lose.
+	ok, err = s.ClaimRun(ctx, r.ID, run.StateRunning, listed.UpdatedAt)
+	if err != nil || ok {
+		t.Fatalf("second claim = %v, %v; want false", ok, err)
+	}
+}
+
+func TestResolveClaimsPendingCheckpointOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	cp := &checkpoint.Checkpoint{
+		Entity: cortex.NewEntity(), ID: id.NewCheckpointID(), RunID: id.NewAgentRunID(),
+		AgentID: id.NewAgentID(), State: checkpoint.StatePending,
+	}
+	if err := s.CreateCheckpoint(ctx, cp); err != nil {
+		t.Fatalf("create checkpoint: %v", err)
+	}
+
+	ok, err := s.Resolve(ctx, cp.ID, checkpoint.Decision{Approved: true, DecidedBy: "alice"})
+	if err != nil || !ok {
+		t.Fatalf("first resolve = %v, %v; want true", ok, err)
+	}
+	// A second decision must not overwrite the first.
+	ok, err = s.Resolve(ctx, cp.ID, checkpoint.Decision{DecidedBy: "bob"})
+	if err != nil || ok {
+		t.Fatalf("second resolve = %v, %v; want false", ok, err)
+	}
+	got, err := s.GetCheckpoint(ctx, cp.ID)
+	if err != nil || got.State != checkpoint.StateResolved || got.Decision.DecidedBy != "alice" {
+		t.Fatalf("stored checkpoint = %+v, %v; want resolved by alice", got, err)
+	}
+}
+
+func TestBudgetUsageAccumulatesPerPeriod(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	hour := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+	for _, u := range []struct {
+		key    string
+		period time.Time
+		tokens int64
+	}{
+		{"agt_a", hour, 100},
+		{"agt_a", hour, 50},
+		{"agt_a", hour.Add(-time.Hour), 25},
+		{"agt_a", hour.Add(-48 * time.Hour), 1000},
+		{"agt_b", hour, 7},
+	} {
+		if err := s.AddUsage(ctx, budget.ScopeAgent, u.key, u.period, u.tokens); err != nil {
+			t.Fatalf("AddUsage: %v", err)
+		}
+	}
+
+	got, err := s.SumUsage(ctx, budget.ScopeAgent, "agt_a", hour.Add(-2*time.Hour))
+	if err != nil || got != 175 {
+		t.Fatalf("SumUsage = %d, %v; want 175", got, err)
+	}
+	if got, _ := s.SumUsage(ctx, budget.ScopeTenant, "agt_a", time.Time{}); got != 0 {
+		t.Errorf("tenant usage = %d, want 0", got)
+	}
+}
+
+func TestSessionsListMostRecentlyActiveFirst(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	agentID := id.NewAgentID()
+	older := &session.Session{ID: id.NewSessionID(), AgentID: agentID, TenantID: "acme", Title: "older"}
+	newer := &session.Session{ID: id.NewSessionID(), AgentID: agentID, TenantID: "acme", Title: "newer"}
+	other := &session.Session{ID: id.NewSessionID(), AgentID: agentID, TenantID: "globex"}
+	for _, sess := range []*session.Session{older, newer, other} {
+		if err := s.CreateSession(ctx, sess); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+	}
+	if err := s.TouchSession(ctx, older.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("TouchSession: %v", err)
+	}
+
+	got, err := s.ListSessions(ctx, &session.ListFilter{AgentID: agentID.String(), TenantID: "acme"})
+	if err != nil || len(got) != 2 || got[0].Title != "older" || got[1].Title != "newer" {
+		t.Fatalf("ListSessions = %+v, %v; want older then newer", got, err)
+	}
+
+	if err := s.DeleteSession(ctx, older.ID); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, err := s.GetSession(ctx, older.ID); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Errorf("GetSession after delete err = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.TouchSession(ctx, older.ID, time.Now()); !errors.Is(err, cortex.ErrSessionNotFound) {
+		t.Errorf("TouchSession after delete err = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func TestConversationScopedToSession(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	agentID := id.NewAgentID()
+	sessionID := id.NewSessionID()
+	if err := s.SaveConversation(ctx, agentID, "acme", id.Nil, []memory.Message{{Role: "user", Content: "outside"}}); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if err := s.SaveConversation(ctx, agentID, "acme", sessionID, []memory.Message{{Role: "user", Content: "inside"}}); err != nil {
+		t.Fatalf("SaveConversation(session): %v", err)
+	}
+
+	got, err := s.LoadConversation(ctx, agentID, "acme", sessionID, 10)
+	if err != nil || len(got) != 1 || got[0].Content != "inside" {
+		t.Fatalf("session conversation = %+v, %v; want only its message", got, err)
+	}
+	if err := s.ClearConversation(ctx, agentID, "acme", sessionID); err != nil {
+		t.Fatalf("ClearConversation: %v", err)
+	}
+	got, err = s.LoadConversation(ctx, agentID, "acme", id.Nil, 10)
+	if err != nil || len(got) != 1 || got[0].Content != "outside" {
+		t.Errorf("conversation outside sessions = %+v, %v; want it kept", got, err)
+	}
+}
+
+func TestTrimConversationRemovesOldestAndKeepsSummaries(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	agentID := id.NewAgentID()
+	sessionID := id.NewSessionID()
+	msgs := []memory.Message{{Role: "user", Content: "one"}, {Role: "assistant", Content: "two"}, {Role: "user", Content: "three"}}
+	if err := s.SaveConversation(ctx, agentID, "acme", sessionID, msgs); err != nil {
+		t.Fatalf("SaveConversation: %v", err)
+	}
+	if err := s.SaveSummary(ctx, agentID, "acme", sessionID, "about one and two"); err != nil {
+		t.Fatalf("SaveSummary: %v", err)
+	}
+	if err := s.TrimConversation(ctx, agentID, "acme", sessionID, 2); err != nil {
+		t.Fatalf("TrimConversation: %v", err)
+	}
+
+	got, err := s.LoadConversation(ctx, agentID, "acme", sessionID, 0)
+	if err != nil || len(got) != 1 || got[0].Content != "three" {
+		t.Fatalf("trimmed conversation = %+v, %v; want only the newest message", got, err)
+	}
+	summaries, err := s.LoadSummaries(ctx, agentID, "acme", sessionID)
+	if err != nil || len(summaries) != 1 || summaries[0] != "about one and two" {
+		t.Fatalf("LoadSummaries = %v, %v; want the saved summary", summaries, err)
+	}
+	if other, err := s.LoadSummaries(ctx, agentID, "acme", id.Nil); err != nil || len(other) != 0 {
+		t.Errorf("summaries outside the session = %v, %v; want none", other, err)
+	}
+
+	if err := s.ClearConversation(ctx, agentID, "acme", sessionID); err != nil {
+		t.Fatalf("ClearConversation: %v", err)
+	}
+	if summaries, err := s.LoadSummaries(ctx, agentID, "acme", sessionID); err != nil || len(summaries) != 0 {
+		t.Errorf("summaries after clear = %v, %v; want none", summaries, err)
+	}
+}
+
+func TestWorkingMemoryUpsertListAndClear(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	runID := id.NewAgentRunID()
+	for _, v := range []string{"draft", "final"} {
+		if err := s.SaveWorking(ctx, runID, "plan", v); err != nil {
+			t.Fatalf("SaveWorking(%q): %v", v, err)
+		}
+	}
+	if err := s.SaveWorking(ctx, runID, "count", 3); err != nil {
+		t.Fatalf("SaveWorking: %v", err)
+	}
+
+	if v, err := s.LoadWorking(ctx, runID, "plan"); err != nil || v != "final" {
+		t.Errorf("LoadWorking = %v, %v; want the latest value", v, err)
+	}
+	if _, err := s.LoadWorking(ctx, runID, "missing"); !errors.Is(err, cortex.ErrWorkingMemoryNotFound) {
+		t.Errorf("LoadWorking missing key err = %v, want ErrWorkingMemoryNotFound", err)
+	}
+	all, err := s.ListWorking(ctx, runID)
+	if err != nil || len(all) != 2 || all["plan"] != "final" || all["count"] != float64(3) {
+		t.Fatalf("ListWorking = %v, %v; want both keys", all, err)
+	}
+
+	if err := s.ClearWorking(ctx, runID); err != nil {
+		t.Fatalf("ClearWorking: %v", err)
+	}
+	if all, err := s.ListWorking(ctx, runID); err != nil || len(all) != 0 {
+		t.Errorf("ListWorking after clear = %v, %v; want empty", all, err)
+	}
+}
+
+func TestFactsUpsertByKeyPerTenant(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	agentID := id.NewAgentID()
+	save := func(tenantID, key, content string) {
+		t.Helper()
+		if err := s.SaveFact(ctx, agentID, tenantID, &memory.Fact{Key: key, Category: memory.FactPreference, Content: content}); err != nil {
+			t.Fatalf("SaveFact(%q): %v", key, err)
+		}
+	}
+	save("acme", "language", "Prefers English")
+	save("acme", "timezone", "Works in CET")
+	runID := id.NewAgentRunID()
+	if err := s.SaveFact(ctx, agentID, "acme", &memory.Fact{Key: "language", Category: memory.FactDecision, Content: "Prefers French", RunID: runID}); err != nil {
+		t.Fatalf("SaveFact(update): %v", err)
+	}
+	save("globex", "language", "Prefers German")
+
+	var row memoryModel
+	err := s.sdb.NewSelect(&row).
+		Where("agent_id = ?", agentID.String()).
+		Where("tenant_id = ?", "acme").
+		Where("kind = ?", "fact").
+		Where("key = ?", "language").
+		Scan(ctx)
+	if err != nil {
+		t.Fatalf("select fact row: %v", err)
+	}
+	if row.RunID != runID.String() || row.Category != string(memory.FactDecision) {
+		t.Errorf("fact row run_id = %q, category = %q; want the updating run and category", row.RunID, row.Category)
+	}
+
+	facts, err := s.ListFacts(ctx, agentID, "acme")
+	if err != nil {
+		t.Fatalf("ListFacts: %v", err)
+	}
+	if len(facts) != 2 || facts[0].Key != "language" || facts[0].Content != "Prefers French" || facts[1].Key != "timezone" {
+		t.Fatalf("facts = %+v; want the updated language fact first, then timezone", facts)
+	}
+	if facts[0].UpdatedAt.IsZero() {
+		t.Error("UpdatedAt not set")
+	}
+
+	if err := s.DeleteFact(ctx, agentID, "acme", "language"); err != nil {
+		t.Fatalf("DeleteFact: %v", err)
+	}
+	if err := s.DeleteFact(ctx, agentID, "acme", "language"); !errors.Is(err, cortex.ErrFactNotFound) {
+		t.Errorf("DeleteFact twice err = %v, want ErrFactNotFound", err)
+	}
+	if err := s.ClearFacts(ctx, agentID, "acme"); err != nil {
+		t.Fatalf("ClearFacts: %v", err)
+	}
+	if facts, err := s.ListFacts(ctx, agentID, "acme"); err != nil || len(facts) != 0 {
+		t.Errorf("facts after clear = %+v, %v; want none", facts, err)
+	}
+	if facts, err := s.ListFacts(ctx, agentID, "globex"); err != nil || len(facts) != 1 || facts[0].Content != "Prefers German" {
+		t.Errorf("other tenant's facts = %+v, %v; want untouched", facts, err)
+	}
+}
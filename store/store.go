@@ -6,11 +6,13 @@ import (
 
 	"github.com/xraph/cortex/agent"
 	"github.com/xraph/cortex/behavior"
+	"github.com/xraph/cortex/budget"
 	"github.com/xraph/cortex/checkpoint"
 	"github.com/xraph/cortex/memory"
 	"github.com/xraph/cortex/orchestration"
 	"github.com/xraph/cortex/persona"
 	"github.com/xraph/cortex/run"
+	"github.com/xraph/cortex/session"
 	"github.com/xraph/cortex/skill"
 	"github.com/xraph/cortex/trait"
 )
@@ -27,6 +29,8 @@ type Store interface {
 	persona.Store
 	orchestration.ConfigStore
 	orchestration.RunStore
+	budget.Store
+	session.Store
 
 	Migrate(ctx context.Context) error
 	Ping(ctx context.Context) error